@@ -1,37 +1,189 @@
 package main
 
 import (
+	"fmt"
+	"runtime"
 	"strings"
 
+	log "github.com/sirupsen/logrus"
+
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/backend"
 )
 
 // Config the application's configuration structure
 type Config struct {
 	RedisListenPort         int
+	ListenSocket            string
 	RedisConnectionTimeout  int
 	StaticDiscovery         string
 	LocalConnection         string
 	DefaultWriteConsistency string
 	DefaultReadConsistency  string
+	DefaultLockConsistency  string
+	OperationConsistency    string
 	Policy                  string
 	Backend                 string
+	NodeBackends            string
 	Profiling               bool
+	ScanBatchSize           int64
+	ScanBatchIntervalMs     int
+	SentinelAddresses       string
+	RedisCluster            bool
+	RedisPoolSize           int
+	RedisMinIdleConns       int
+	RedisPoolTimeoutMs      int
+	RedisIdleTimeoutMs      int
+	RedisDialTimeoutMs      int
+	RedisReadTimeoutMs      int
+	RedisWriteTimeoutMs     int
+	RedisMaxRetries         int
+	RedisMinRetryBackoffMs  int
+	RedisMaxRetryBackoffMs  int
+	RedisKeepAliveMs        int
+	RedisDatabase           int
+	NodeDatabases           string
+	RedisNoDelay            bool
+	ShutdownTimeoutMs       int
+	NodeWeights             string
+	NodeIDs                 string
+	ReadOnlyNodes           string
+	ReadAllRepair           bool
+	ConflictResolution      string
+	NoMajorityFallback      string
+	ValueVersioning         bool
+	ValueMetadata           bool
+	WriteRollback           bool
+	TTLToleranceMs          int
+	MaxRepairsPerRead       int
+	MaxRepairsPerSecond     int
+	RetryAttempts           int
+	RetryDelayMs            int
+	HintedHandoffIntervalMs int
+	HintedHandoffMaxHints   int
+	IdempotencyTTLMs        int
+	IdempotencyLeaseMs      int
+	HealthCheckIntervalMs   int
+	WriteToUpNodesOnly      bool
+	LogLevel                string
+	LogFormat               string
+	MetricsListenPort       int
+	RepairLogging           bool
+	EngineMaxConcurrency    int
+	MaxKeyBytes             int
+	MaxValueBytes           int
+	Compression             bool
+	CompressionMinBytes     int
+	EncryptionKeys          string
+	EncryptionKeyID         int
+	KeyspaceNotifications   bool
+	LockPollIntervalMs      int
+	DefaultWriteTTLMs       int
+	TTLJitterPercent        float64
+	MaxWriteTTLMs           int
+	MaxWriteTTLPolicy       string
+	MaxOpsPerSecond         int
+	OperationRateLimits     string
+	SessionConsistencyTTLMs int
+	SlowLogThresholdMs      int
+	WriteCoalescingWindowMs int
+	WriteCoalescingPrefixes string
+	SlowLogHashKeys         bool
+	MaxConnections          int
+	PreferLocalReads        bool
+	EvenQuorum              string
+	AdminListenPort         int
+	TTLSamplingIntervalMs   int
+	TTLSamplingKeys         int
 }
 
 // LoadConfig loads the config from a file if specified, otherwise from the environment
 func LoadConfig(cmd *cobra.Command, envPrefix string) (*Config, error) {
 	// Setting defaults for this application
 	viper.SetDefault("redisListenPort", 6380)
+	viper.SetDefault("listenSocket", "")
 	viper.SetDefault("redisConnectionTimeout", 30000)
 	viper.SetDefault("backend", "redis")
+	viper.SetDefault("nodeBackends", "")
 	viper.SetDefault("staticDiscovery", "")
 	viper.SetDefault("localConnection", "")
 	viper.SetDefault("defaultWriteConsistency", "majority")
 	viper.SetDefault("defaultReadConsistency", "majority")
+	viper.SetDefault("defaultLockConsistency", "majority")
+	viper.SetDefault("operationConsistency", "")
 	viper.SetDefault("policy", "")
 	viper.SetDefault("profiling", false)
+	viper.SetDefault("scanBatchSize", 100)
+	viper.SetDefault("scanBatchIntervalMs", 10)
+	viper.SetDefault("sentinelAddresses", "")
+	viper.SetDefault("redisCluster", false)
+	viper.SetDefault("redisPoolSize", 20*runtime.GOMAXPROCS(0))
+	viper.SetDefault("redisMinIdleConns", runtime.GOMAXPROCS(0))
+	viper.SetDefault("redisPoolTimeoutMs", 0)
+	viper.SetDefault("redisIdleTimeoutMs", 0)
+	viper.SetDefault("redisDialTimeoutMs", 1000)
+	viper.SetDefault("redisReadTimeoutMs", 3000)
+	viper.SetDefault("redisWriteTimeoutMs", 3000)
+	viper.SetDefault("redisMaxRetries", 0)
+	viper.SetDefault("redisMinRetryBackoffMs", 8)
+	viper.SetDefault("redisMaxRetryBackoffMs", 512)
+	viper.SetDefault("redisKeepAliveMs", 30000)
+	viper.SetDefault("redisDatabase", 0)
+	viper.SetDefault("nodeDatabases", "")
+	viper.SetDefault("redisNoDelay", true)
+	viper.SetDefault("shutdownTimeoutMs", 10000)
+	viper.SetDefault("nodeWeights", "")
+	viper.SetDefault("nodeIDs", "")
+	viper.SetDefault("readOnlyNodes", "")
+	viper.SetDefault("readAllRepair", false)
+	viper.SetDefault("conflictResolution", "")
+	viper.SetDefault("noMajorityFallback", "fail")
+	viper.SetDefault("valueVersioning", false)
+	viper.SetDefault("valueMetadata", false)
+	viper.SetDefault("writeRollback", true)
+	viper.SetDefault("ttlToleranceMs", 2000)
+	viper.SetDefault("maxRepairsPerRead", 0)
+	viper.SetDefault("maxRepairsPerSecond", 0)
+	viper.SetDefault("retryAttempts", 1)
+	viper.SetDefault("retryDelayMs", 20)
+	viper.SetDefault("hintedHandoffIntervalMs", 0)
+	viper.SetDefault("hintedHandoffMaxHints", 10000)
+	viper.SetDefault("idempotencyTTLMs", 300000)
+	viper.SetDefault("idempotencyLeaseMs", 30000)
+	viper.SetDefault("healthCheckIntervalMs", 1000)
+	viper.SetDefault("writeToUpNodesOnly", false)
+	viper.SetDefault("logLevel", "info")
+	viper.SetDefault("logFormat", "text")
+	viper.SetDefault("metricsListenPort", 0)
+	viper.SetDefault("repairLogging", false)
+	viper.SetDefault("engineMaxConcurrency", 0)
+	viper.SetDefault("maxKeyBytes", 0)
+	viper.SetDefault("maxValueBytes", 0)
+	viper.SetDefault("compression", false)
+	viper.SetDefault("compressionMinBytes", 1024)
+	viper.SetDefault("encryptionKeys", "")
+	viper.SetDefault("encryptionKeyID", 0)
+	viper.SetDefault("keyspaceNotifications", false)
+	viper.SetDefault("lockPollIntervalMs", 50)
+	viper.SetDefault("defaultWriteTTLMs", 0)
+	viper.SetDefault("ttlJitterPercent", 0)
+	viper.SetDefault("maxWriteTTLMs", 0)
+	viper.SetDefault("maxWriteTTLPolicy", "clamp")
+	viper.SetDefault("maxOpsPerSecond", 0)
+	viper.SetDefault("operationRateLimits", "")
+	viper.SetDefault("sessionConsistencyTTLMs", 0)
+	viper.SetDefault("slowLogThresholdMs", 0)
+	viper.SetDefault("writeCoalescingWindowMs", 0)
+	viper.SetDefault("writeCoalescingPrefixes", "")
+	viper.SetDefault("slowLogHashKeys", false)
+	viper.SetDefault("maxConnections", 0)
+	viper.SetDefault("preferLocalReads", false)
+	viper.SetDefault("evenQuorum", "majority")
+	viper.SetDefault("adminListenPort", 0)
+	viper.SetDefault("ttlSamplingIntervalMs", 0)
+	viper.SetDefault("ttlSamplingKeys", 10)
 
 	// Read Config from ENV
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -62,3 +214,167 @@ func LoadConfig(cmd *cobra.Command, envPrefix string) (*Config, error) {
 
 	return &config, nil
 }
+
+// Validate checks the configuration before anything starts, reporting every
+// problem at once rather than only the first one.
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	if c.StaticDiscovery == "" && c.LocalConnection == "" {
+		problems = append(problems, "no nodes given, expected staticDiscovery or localConnection")
+	}
+
+	if !backend.Registered(c.Backend) {
+		problems = append(problems, fmt.Sprintf("unknown backend: %v", c.Backend))
+	}
+
+	nodeBackends, err := parseNodeBackends(c.NodeBackends)
+	check(fieldError("nodeBackends", err))
+	for host, nodeBackend := range nodeBackends {
+		if !backend.Registered(nodeBackend) {
+			problems = append(problems, fmt.Sprintf("nodeBackends: unknown backend of %v: %v", host, nodeBackend))
+		}
+	}
+
+	nodeIDs, err := parseNodeIDs(c.NodeIDs)
+	check(fieldError("nodeIDs", err))
+	if err == nil {
+		check(fieldError("nodeIDs", validateNodeIDs(nodeIDs, c.hosts())))
+	}
+
+	if c.ListenSocket == "" {
+		check(validatePort("redisListenPort", c.RedisListenPort, false))
+	} else if !strings.HasPrefix(c.ListenSocket, "unix://") {
+		problems = append(problems, fmt.Sprintf("unsupported listen socket %v, expected unix://<path>", c.ListenSocket))
+	}
+
+	check(validatePort("metricsListenPort", c.MetricsListenPort, true))
+	check(validatePort("adminListenPort", c.AdminListenPort, true))
+
+	if c.RedisDialTimeoutMs <= 0 {
+		problems = append(problems, fmt.Sprintf("redisDialTimeoutMs: expected a positive timeout, got %d",
+			c.RedisDialTimeoutMs))
+	}
+
+	if c.TTLToleranceMs <= 0 {
+		problems = append(problems, fmt.Sprintf("ttlToleranceMs: expected a positive tolerance, got %d",
+			c.TTLToleranceMs))
+	}
+
+	if c.MaxRepairsPerRead < 0 {
+		problems = append(problems, fmt.Sprintf("maxRepairsPerRead: expected a non-negative limit, got %d",
+			c.MaxRepairsPerRead))
+	}
+
+	if c.MaxRepairsPerSecond < 0 {
+		problems = append(problems, fmt.Sprintf("maxRepairsPerSecond: expected a non-negative limit, got %d",
+			c.MaxRepairsPerSecond))
+	}
+
+	if c.RetryAttempts < 1 {
+		problems = append(problems, fmt.Sprintf("retryAttempts: expected at least one attempt, got %d",
+			c.RetryAttempts))
+	}
+
+	if c.RetryDelayMs < 0 {
+		problems = append(problems, fmt.Sprintf("retryDelayMs: expected a non-negative delay, got %d",
+			c.RetryDelayMs))
+	}
+
+	if c.HintedHandoffIntervalMs < 0 {
+		problems = append(problems, fmt.Sprintf("hintedHandoffIntervalMs: expected a non-negative interval, got %d",
+			c.HintedHandoffIntervalMs))
+	}
+
+	if c.HintedHandoffMaxHints < 0 {
+		problems = append(problems, fmt.Sprintf("hintedHandoffMaxHints: expected a non-negative limit, got %d",
+			c.HintedHandoffMaxHints))
+	}
+
+	consistencies := []struct{ name, value string }{
+		{"defaultReadConsistency", c.DefaultReadConsistency},
+		{"defaultWriteConsistency", c.DefaultWriteConsistency},
+		{"defaultLockConsistency", c.DefaultLockConsistency},
+	}
+	for _, consistency := range consistencies {
+		if consistency.value != "" {
+			_, err := parseConsistency(consistency.value)
+			check(fieldError(consistency.name, err))
+		}
+	}
+
+	_, err = parseOperationConsistency(c.OperationConsistency)
+	check(fieldError("operationConsistency", err))
+
+	if c.Policy != "" {
+		_, err = parsePolicyList(c.Policy)
+		check(fieldError("policy", err))
+	}
+
+	_, err = parseConflictResolver(c.ConflictResolution)
+	check(fieldError("conflictResolution", err))
+
+	_, err = parseNoMajorityFallback(c)
+	check(fieldError("noMajorityFallback", err))
+
+	_, err = parseQuorumMode(c.EvenQuorum)
+	check(fieldError("evenQuorum", err))
+
+	_, err = parseMaxWriteTTLPolicy(c.MaxWriteTTLPolicy)
+	check(fieldError("maxWriteTTLPolicy", err))
+
+	_, err = log.ParseLevel(c.LogLevel)
+	check(fieldError("logLevel", err))
+
+	if format := strings.ToLower(c.LogFormat); format != "" && format != "text" && format != "json" {
+		problems = append(problems, fmt.Sprintf("unrecognized log format: %v", c.LogFormat))
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid configuration: %v", strings.Join(problems, "; "))
+}
+
+// hosts returns the nodes given by staticDiscovery and localConnection.
+func (c *Config) hosts() []string {
+	var result []string
+	if c.StaticDiscovery != "" {
+		for _, host := range strings.Split(c.StaticDiscovery, ",") {
+			result = append(result, strings.TrimSpace(host))
+		}
+	}
+
+	if c.LocalConnection != "" {
+		result = append(result, c.LocalConnection)
+	}
+
+	return result
+}
+
+func validatePort(name string, port int, optional bool) error {
+	if optional && port == 0 {
+		return nil
+	}
+
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("%v: invalid port %d", name, port)
+	}
+
+	return nil
+}
+
+// fieldError prefixes err with the name of the field it is about.
+func fieldError(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%v: %v", name, err)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+}
+
+func TestConfigTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
+
+func (s *ConfigTestSuite) SetupSuite() {
+	config, err := LoadConfig(&cobra.Command{}, "KVS_CONFIG_TEST")
+	s.Require().Nil(err)
+
+	registerBackends(config)
+}
+
+func (s *ConfigTestSuite) TestValidate() {
+	cases := []struct {
+		name     string
+		modify   func(config *Config)
+		problems []string
+	}{
+		{
+			name:   "defaults with static discovery",
+			modify: func(config *Config) {},
+		},
+		{
+			name: "local connection only",
+			modify: func(config *Config) {
+				config.StaticDiscovery = ""
+				config.LocalConnection = "localhost:6379"
+			},
+		},
+		{
+			name:     "no nodes",
+			modify:   func(config *Config) { config.StaticDiscovery = "" },
+			problems: []string{"no nodes given"},
+		},
+		{
+			name:     "unknown backend",
+			modify:   func(config *Config) { config.Backend = "unknown" },
+			problems: []string{"unknown backend: unknown"},
+		},
+		{
+			name:     "unknown node backend",
+			modify:   func(config *Config) { config.NodeBackends = "10.0.0.1:6379=unknown" },
+			problems: []string{"nodeBackends: unknown backend of 10.0.0.1:6379: unknown"},
+		},
+		{
+			name:     "invalid listen port",
+			modify:   func(config *Config) { config.RedisListenPort = 70000 },
+			problems: []string{"redisListenPort: invalid port 70000"},
+		},
+		{
+			name: "listen socket instead of port",
+			modify: func(config *Config) {
+				config.RedisListenPort = 0
+				config.ListenSocket = "unix:///tmp/kvs.sock"
+			},
+		},
+		{
+			name:     "unsupported listen socket",
+			modify:   func(config *Config) { config.ListenSocket = "tcp://localhost:6380" },
+			problems: []string{"unsupported listen socket"},
+		},
+		{
+			name:     "invalid metrics port",
+			modify:   func(config *Config) { config.MetricsListenPort = -1 },
+			problems: []string{"metricsListenPort: invalid port -1"},
+		},
+		{
+			name:     "non-positive dial timeout",
+			modify:   func(config *Config) { config.RedisDialTimeoutMs = 0 },
+			problems: []string{"redisDialTimeoutMs"},
+		},
+		{
+			name:     "non-positive ttl tolerance",
+			modify:   func(config *Config) { config.TTLToleranceMs = 0 },
+			problems: []string{"ttlToleranceMs: expected a positive tolerance, got 0"},
+		},
+		{
+			name:     "no retry attempts",
+			modify:   func(config *Config) { config.RetryAttempts = 0 },
+			problems: []string{"retryAttempts"},
+		},
+		{
+			name:     "invalid consistency",
+			modify:   func(config *Config) { config.DefaultWriteConsistency = "most" },
+			problems: []string{"defaultWriteConsistency"},
+		},
+		{
+			name:     "invalid operation consistency",
+			modify:   func(config *Config) { config.OperationConsistency = "get" },
+			problems: []string{"operationConsistency"},
+		},
+		{
+			name:     "invalid log level",
+			modify:   func(config *Config) { config.LogLevel = "loud" },
+			problems: []string{"logLevel"},
+		},
+		{
+			name:     "invalid log format",
+			modify:   func(config *Config) { config.LogFormat = "xml" },
+			problems: []string{"unrecognized log format: xml"},
+		},
+		{
+			name: "ids of every node",
+			modify: func(config *Config) {
+				config.NodeIDs = "10.0.0.1:6379=node-a,10.0.0.2:6379=node-b"
+			},
+		},
+		{
+			name:     "ids of some nodes",
+			modify:   func(config *Config) { config.NodeIDs = "10.0.0.1:6379=node-a" },
+			problems: []string{"nodeIDs: either all nodes or none should have an id, missing: 10.0.0.2:6379"},
+		},
+		{
+			name: "ids of unknown nodes",
+			modify: func(config *Config) {
+				config.NodeIDs = "10.0.0.1:6379=node-a,10.0.0.2:6379=node-b,10.0.0.3:6379=node-c"
+			},
+			problems: []string{"nodeIDs: ids of unknown nodes: 10.0.0.3:6379"},
+		},
+		{
+			name: "duplicate node ids",
+			modify: func(config *Config) {
+				config.NodeIDs = "10.0.0.1:6379=node-a,10.0.0.2:6379=node-a"
+			},
+			problems: []string{"nodeIDs: duplicate node id: node-a"},
+		},
+		{
+			name: "every problem at once",
+			modify: func(config *Config) {
+				config.Backend = "unknown"
+				config.RetryAttempts = 0
+				config.LogFormat = "xml"
+			},
+			problems: []string{"unknown backend", "retryAttempts", "unrecognized log format"},
+		},
+	}
+
+	for _, c := range cases {
+		s.Run(c.name, func() {
+			config := s.validConfig()
+			c.modify(config)
+
+			err := config.Validate()
+			if len(c.problems) == 0 {
+				s.Nil(err)
+				return
+			}
+
+			s.Require().NotNil(err)
+			for _, problem := range c.problems {
+				s.Contains(err.Error(), problem)
+			}
+		})
+	}
+}
+
+func (s *ConfigTestSuite) validConfig() *Config {
+	config, err := LoadConfig(&cobra.Command{}, "KVS_CONFIG_TEST")
+	s.Require().Nil(err)
+
+	config.StaticDiscovery = "10.0.0.1:6379, 10.0.0.2:6379"
+	return config
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+var dumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "write every key of the configured nodes to stdout",
+	Run:   dump,
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "write every key of a dump read from stdin to the configured nodes",
+	Run:   restore,
+}
+
+var (
+	dumpPattern   *string
+	dumpCursor    *string
+	dumpRateLimit *int
+
+	restoreConsistency  *string
+	restoreConcurrency  *int
+	restoreSkipExisting *bool
+)
+
+func init() {
+	rootCmd.AddCommand(dumpCmd)
+	rootCmd.AddCommand(restoreCmd)
+	dumpPattern = dumpCmd.Flags().String("pattern", "", "glob-style pattern of keys to dump, all keys by default")
+	dumpCursor = dumpCmd.Flags().String("cursor", "", "cursor of an interrupted dump to resume")
+	dumpRateLimit = dumpCmd.Flags().Int("rate", 0, "maximum number of keys dumped per second, unlimited by default")
+	restoreConsistency = restoreCmd.Flags().String("consistency", "",
+		"consistency of restored keys, the default write consistency by default")
+	restoreConcurrency = restoreCmd.Flags().Int("concurrency", 0, "number of keys restored at once")
+	restoreSkipExisting = restoreCmd.Flags().Bool("skip-existing", false, "keep the value of keys which already exist")
+}
+
+func dump(cmd *cobra.Command, args []string) {
+	config := loadConfigOrPanic(cmd)
+	configureLoggingOrPanic(config)
+
+	svc := getService(configureClusterOrPanic(config), configureEngineOrPanic(config), config)
+	defer svc.Close()
+
+	response, err := svc.Export(interruptibleContext(), &keyvaluestore.ExportRequest{
+		Writer:    os.Stdout,
+		Pattern:   *dumpPattern,
+		Cursor:    *dumpCursor,
+		RateLimit: *dumpRateLimit,
+	})
+	if err != nil {
+		entry := log.WithError(err)
+		if response != nil {
+			entry = entry.WithField("exported", response.Exported).WithField("cursor", response.Cursor)
+		}
+		entry.Fatal("dump stopped, resume it using the cursor")
+	}
+
+	log.WithField("exported", response.Exported).Info("dump completed")
+}
+
+func restore(cmd *cobra.Command, args []string) {
+	config := loadConfigOrPanic(cmd)
+	configureLoggingOrPanic(config)
+
+	svc := getService(configureClusterOrPanic(config), configureEngineOrPanic(config), config)
+	defer svc.Close()
+
+	consistency := keyvaluestore.ConsistencyLevel_DEFAULT
+	if *restoreConsistency != "" {
+		consistency = convertConsistencyOrPanic(*restoreConsistency)
+	}
+
+	response, err := svc.Import(interruptibleContext(), &keyvaluestore.ImportRequest{
+		Source:       os.Stdin,
+		Concurrency:  *restoreConcurrency,
+		SkipExisting: *restoreSkipExisting,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: consistency,
+		},
+	})
+	for _, importErr := range response.Errors {
+		log.WithError(importErr.Err).WithField("key", importErr.Key).Error("failed to restore key")
+	}
+
+	entry := log.WithField("imported", response.Imported).WithField("skipped", response.Skipped).
+		WithField("failed", response.Failed)
+	if err != nil {
+		entry.WithError(err).Fatal("restore stopped")
+	}
+
+	entry.Info("restore completed")
+}
+
+// interruptibleContext is canceled upon SIGINT or SIGTERM.
+func interruptibleContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	return ctx
+}
@@ -1,8 +1,15 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -10,14 +17,25 @@ import (
 	"github.com/cafebazaar/keyvalue-store/internal/core"
 	"github.com/pkg/profile"
 
+	"github.com/cafebazaar/keyvalue-store/internal/admin"
+	"github.com/cafebazaar/keyvalue-store/internal/coalesce"
+	"github.com/cafebazaar/keyvalue-store/internal/encryption"
 	"github.com/cafebazaar/keyvalue-store/internal/engine"
+	"github.com/cafebazaar/keyvalue-store/internal/handoff"
+	"github.com/cafebazaar/keyvalue-store/internal/ratelimit"
+	"github.com/cafebazaar/keyvalue-store/internal/resolver"
+	"github.com/cafebazaar/keyvalue-store/internal/sampler"
+	"github.com/cafebazaar/keyvalue-store/internal/slowlog"
 	"github.com/cafebazaar/keyvalue-store/internal/voting"
 
 	"github.com/go-redis/redis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	redisBackend "github.com/cafebazaar/keyvalue-store/internal/backend/redis"
 	staticCluster "github.com/cafebazaar/keyvalue-store/internal/cluster/static"
 	redisTransport "github.com/cafebazaar/keyvalue-store/internal/transport/redis"
+	"github.com/cafebazaar/keyvalue-store/pkg/backend"
 	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
 
 	log "github.com/sirupsen/logrus"
@@ -39,6 +57,7 @@ func init() {
 
 func serve(cmd *cobra.Command, args []string) {
 	config := loadConfigOrPanic(cmd)
+	configureLoggingOrPanic(config)
 
 	if config.Profiling {
 		// Read following blog on Go profiling:
@@ -61,12 +80,18 @@ func serve(cmd *cobra.Command, args []string) {
 
 	server := makeRedisServerOrPanic(svc, config)
 	startServerOrPanic(server)
+	metricsServer := startMetricsServer(config, svc)
+	adminServer := startAdminServer(config, cluster, svc)
+	ttlSampler := startTTLSampler(config, cluster)
 
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 	<-sigs
 
-	shutdownServerOrPanic(server)
+	stopTTLSampler(ttlSampler)
+	shutdownServerOrPanic(server, svc, config)
+	shutdownMetricsServer(metricsServer)
+	shutdownAdminServer(adminServer)
 }
 
 func loadConfigOrPanic(cmd *cobra.Command) *Config {
@@ -74,11 +99,47 @@ func loadConfigOrPanic(cmd *cobra.Command) *Config {
 	if err != nil {
 		log.WithError(err).Panic("Failed to load configurations")
 	}
+
+	registerBackends(config)
+	if err := config.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
 	return config
 }
 
+const keyspaceNotificationEvents = "Kg$x"
+
+func configureLoggingOrPanic(config *Config) {
+	level, err := log.ParseLevel(config.LogLevel)
+	if err != nil {
+		panicWithError(err, "invalid log level")
+	}
+	log.SetLevel(level)
+
+	switch strings.ToLower(config.LogFormat) {
+	case "", "text":
+		log.SetFormatter(&log.TextFormatter{})
+
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+
+	default:
+		log.Panicf("unrecognized log format: %v", config.LogFormat)
+	}
+}
+
 func configureEngineOrPanic(config *Config) keyvaluestore.Engine {
-	return engine.New(voting.New)
+	var options []engine.Option
+	if config.EngineMaxConcurrency > 0 {
+		options = append(options, engine.WithMaxConcurrency(config.EngineMaxConcurrency))
+	}
+
+	if fallback := convertNoMajorityFallbackOrPanic(config); fallback != nil {
+		options = append(options, engine.WithNoMajorityFallback(fallback))
+	}
+
+	return engine.New(voting.New, options...)
 }
 
 func configureClusterOrPanic(config *Config) keyvaluestore.Cluster {
@@ -92,19 +153,49 @@ func configureClusterOrPanic(config *Config) keyvaluestore.Cluster {
 
 func configureStaticDiscoveryClusterOrPanic(config *Config) keyvaluestore.Cluster {
 	hosts := strings.Split(config.StaticDiscovery, ",")
+	weights := convertNodeWeightsOrPanic(config.NodeWeights)
+	readOnly := convertReadOnlyNodes(config.ReadOnlyNodes)
 	var nodes []keyvaluestore.Backend
+	var options []staticCluster.Option
 
 	for _, host := range hosts {
-		nodes = append(nodes, connectToHostOrPanic(config, strings.TrimSpace(host)))
-	}
+		node := connectToHostOrPanic(config, strings.TrimSpace(host))
+		nodes = append(nodes, node)
 
-	var options []staticCluster.Option
+		if weight, ok := weights[strings.TrimSpace(host)]; ok {
+			options = append(options, staticCluster.WithWeight(node, weight))
+		}
+
+		if readOnly[strings.TrimSpace(host)] {
+			options = append(options, staticCluster.WithReadOnly(node))
+		}
+	}
 
 	if config.LocalConnection != "" {
 		options = append(options,
 			staticCluster.WithLocal(connectToHostOrPanic(config, config.LocalConnection)))
 	}
 
+	if config.HealthCheckIntervalMs > 0 {
+		options = append(options, staticCluster.WithHealthCheckInterval(
+			time.Duration(config.HealthCheckIntervalMs)*time.Millisecond))
+	}
+
+	if config.WriteToUpNodesOnly {
+		options = append(options, staticCluster.WithWritesToUpNodesOnly(true))
+	}
+
+	if config.ReadAllRepair {
+		options = append(options, staticCluster.WithReadAllRepair(true))
+	}
+
+	if config.PreferLocalReads {
+		options = append(options, staticCluster.WithPreferLocalReads(true))
+	}
+
+	options = append(options, staticCluster.WithQuorumMode(convertQuorumModeOrPanic(config.EvenQuorum)))
+	options = append(options, staticCluster.WithMinWritableNodes(requiredReplicasOrPanic(config)))
+
 	if config.Policy != "" {
 		for _, policy := range convertPolicyListOrPanic(config.Policy) {
 			options = append(options, staticCluster.WithPolicy(policy))
@@ -114,20 +205,222 @@ func configureStaticDiscoveryClusterOrPanic(config *Config) keyvaluestore.Cluste
 	return staticCluster.New(nodes, options...)
 }
 
+// requiredReplicasOrPanic returns the most nodes any of the configured fixed
+// consistencies, such as three, writes to or reads from.
+func requiredReplicasOrPanic(config *Config) int {
+	consistencies := []keyvaluestore.ConsistencyLevel{}
+	for _, consistency := range []string{
+		config.DefaultReadConsistency, config.DefaultWriteConsistency, config.DefaultLockConsistency} {
+		if consistency != "" {
+			consistencies = append(consistencies, convertConsistencyOrPanic(consistency))
+		}
+	}
+	for _, consistency := range convertOperationConsistencyOrPanic(config.OperationConsistency) {
+		consistencies = append(consistencies, consistency)
+	}
+
+	result := 0
+	for _, consistency := range consistencies {
+		switch {
+		case consistency == keyvaluestore.ConsistencyLevel_TWO && result < 2:
+			result = 2
+
+		case consistency == keyvaluestore.ConsistencyLevel_THREE && result < 3:
+			result = 3
+		}
+	}
+
+	return result
+}
+
+// registerBackends registers the built-in backend types, which depend on the
+// configuration. Others are registered by their packages.
+func registerBackends(config *Config) {
+	backend.Register("redis", func(host string) (keyvaluestore.Backend, error) {
+		return connectToRedis(config, host)
+	})
+}
+
 func connectToHostOrPanic(config *Config, host string) keyvaluestore.Backend {
-	switch config.Backend {
-	case "redis":
-		return connectToRedisOrPanic(host)
+	result, err := connectToHost(config, host)
+	if err != nil {
+		panicWithError(err, "failed to connect to %v", host)
+	}
 
-	default:
-		log.Panicf("unknown backend: %v", config.Backend)
-		return nil
+	return result
+}
+
+func connectToHost(config *Config, host string) (keyvaluestore.Backend, error) {
+	nodeBackend, err := backendOf(config, host)
+	if err != nil {
+		return nil, err
 	}
+
+	return backend.Open(nodeBackend, host)
 }
 
-func connectToRedisOrPanic(host string) keyvaluestore.Backend {
-	client := redis.NewClient(&redis.Options{Addr: host})
-	return redisBackend.New(client, host)
+// backendOf returns the backend type of host given by nodeBackends, falling
+// back to backend.
+func backendOf(config *Config, host string) (string, error) {
+	backends, err := parseNodeBackends(config.NodeBackends)
+	if err != nil {
+		return "", err
+	}
+
+	if nodeBackend, ok := backends[host]; ok {
+		return nodeBackend, nil
+	}
+
+	return config.Backend, nil
+}
+
+func parseNodeBackends(nodeBackends string) (map[string]string, error) {
+	result := make(map[string]string)
+	if nodeBackends == "" {
+		return result, nil
+	}
+
+	for _, item := range strings.Split(nodeBackends, ",") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid node backend, expected host=backend: %v", item)
+		}
+
+		result[strings.TrimSpace(parts[0])] = strings.ToLower(strings.TrimSpace(parts[1]))
+	}
+
+	return result, nil
+}
+
+func connectToRedis(config *Config, host string) (keyvaluestore.Backend, error) {
+	if config.RedisCluster {
+		return connectToRedisCluster(config, host)
+	}
+
+	if config.SentinelAddresses != "" {
+		return connectToRedisSentinel(config, host)
+	}
+
+	database, err := redisDatabase(config, host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialTimeout := time.Duration(config.RedisDialTimeoutMs) * time.Millisecond
+	dialer := redisBackend.NewDialer(host, dialTimeout,
+		time.Duration(config.RedisKeepAliveMs)*time.Millisecond, config.RedisNoDelay)
+
+	client := redis.NewClient(&redis.Options{
+		Addr:            host,
+		DB:              database,
+		DialTimeout:     dialTimeout,
+		ReadTimeout:     time.Duration(config.RedisReadTimeoutMs) * time.Millisecond,
+		WriteTimeout:    time.Duration(config.RedisWriteTimeoutMs) * time.Millisecond,
+		Dialer:          dialer,
+		PoolSize:        config.RedisPoolSize,
+		MinIdleConns:    config.RedisMinIdleConns,
+		PoolTimeout:     time.Duration(config.RedisPoolTimeoutMs) * time.Millisecond,
+		IdleTimeout:     time.Duration(config.RedisIdleTimeoutMs) * time.Millisecond,
+		MaxRetries:      config.RedisMaxRetries,
+		MinRetryBackoff: time.Duration(config.RedisMinRetryBackoffMs) * time.Millisecond,
+		MaxRetryBackoff: time.Duration(config.RedisMaxRetryBackoffMs) * time.Millisecond,
+	})
+	enableKeyspaceNotifications(config, client, host)
+
+	return redisBackend.New(client, host), nil
+}
+
+// connectToRedisSentinel treats host as the name of a sentinel-managed
+// master, the resulting client follows the master through failovers.
+func connectToRedisSentinel(config *Config, masterName string) (keyvaluestore.Backend, error) {
+	database, err := redisDatabase(config, masterName)
+	if err != nil {
+		return nil, err
+	}
+
+	var sentinels []string
+	for _, sentinel := range strings.Split(config.SentinelAddresses, ",") {
+		sentinels = append(sentinels, strings.TrimSpace(sentinel))
+	}
+
+	client := redis.NewFailoverClient(&redis.FailoverOptions{
+		MasterName:      masterName,
+		SentinelAddrs:   sentinels,
+		DB:              database,
+		PoolSize:        config.RedisPoolSize,
+		MinIdleConns:    config.RedisMinIdleConns,
+		DialTimeout:     time.Duration(config.RedisDialTimeoutMs) * time.Millisecond,
+		ReadTimeout:     time.Duration(config.RedisReadTimeoutMs) * time.Millisecond,
+		WriteTimeout:    time.Duration(config.RedisWriteTimeoutMs) * time.Millisecond,
+		PoolTimeout:     time.Duration(config.RedisPoolTimeoutMs) * time.Millisecond,
+		IdleTimeout:     time.Duration(config.RedisIdleTimeoutMs) * time.Millisecond,
+		MaxRetries:      config.RedisMaxRetries,
+		MinRetryBackoff: time.Duration(config.RedisMinRetryBackoffMs) * time.Millisecond,
+		MaxRetryBackoff: time.Duration(config.RedisMaxRetryBackoffMs) * time.Millisecond,
+	})
+	enableKeyspaceNotifications(config, client, masterName)
+
+	return redisBackend.New(client, masterName), nil
+}
+
+// connectToRedisCluster treats host as a `|` separated list of seed addresses
+// of a single redis cluster, which forms one node of our cluster.
+func connectToRedisCluster(config *Config, host string) (keyvaluestore.Backend, error) {
+	var seeds []string
+	for _, seed := range strings.Split(host, "|") {
+		seeds = append(seeds, strings.TrimSpace(seed))
+	}
+
+	database, err := redisDatabase(config, host)
+	if err != nil {
+		return nil, err
+	}
+	if database != 0 {
+		return nil, fmt.Errorf("redis cluster only supports database 0: %v", host)
+	}
+
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:           seeds,
+		PoolSize:        config.RedisPoolSize,
+		MinIdleConns:    config.RedisMinIdleConns,
+		DialTimeout:     time.Duration(config.RedisDialTimeoutMs) * time.Millisecond,
+		ReadTimeout:     time.Duration(config.RedisReadTimeoutMs) * time.Millisecond,
+		WriteTimeout:    time.Duration(config.RedisWriteTimeoutMs) * time.Millisecond,
+		PoolTimeout:     time.Duration(config.RedisPoolTimeoutMs) * time.Millisecond,
+		IdleTimeout:     time.Duration(config.RedisIdleTimeoutMs) * time.Millisecond,
+		MaxRetries:      config.RedisMaxRetries,
+		MinRetryBackoff: time.Duration(config.RedisMinRetryBackoffMs) * time.Millisecond,
+		MaxRetryBackoff: time.Duration(config.RedisMaxRetryBackoffMs) * time.Millisecond,
+	})
+	enableKeyspaceNotifications(config, client, seeds[0])
+
+	return redisBackend.New(client, seeds[0]), nil
+}
+
+// enableKeyspaceNotifications turns on notifications of generic commands,
+// string commands and expirations, which WATCH relies on. Failures are only
+// logged, since nodes might be down at startup.
+func enableKeyspaceNotifications(config *Config, client redis.UniversalClient, host string) {
+	if !config.KeyspaceNotifications {
+		return
+	}
+
+	configure := func(client *redis.Client) error {
+		return client.ConfigSet("notify-keyspace-events", keyspaceNotificationEvents).Err()
+	}
+
+	var err error
+	switch client := client.(type) {
+	case *redis.ClusterClient:
+		err = client.ForEachMaster(configure)
+
+	case *redis.Client:
+		err = configure(client)
+	}
+
+	if err != nil {
+		log.WithError(err).WithField("host", host).Warn("failed to enable keyspace notifications")
+	}
 }
 
 func getService(cluster keyvaluestore.Cluster,
@@ -143,54 +436,564 @@ func getService(cluster keyvaluestore.Cluster,
 		options = append(options,
 			core.WithDefaultWriteConsistency(convertConsistencyOrPanic(config.DefaultWriteConsistency)))
 	}
+	if config.DefaultLockConsistency != "" {
+		options = append(options,
+			core.WithDefaultLockConsistency(convertConsistencyOrPanic(config.DefaultLockConsistency)))
+	}
+	for operation, consistency := range convertOperationConsistencyOrPanic(config.OperationConsistency) {
+		options = append(options, core.WithOperationConsistency(operation, consistency))
+	}
+	for address, id := range convertNodeIDsOrPanic(config.NodeIDs) {
+		options = append(options, core.WithNodeID(address, id))
+	}
+
+	if config.ScanBatchSize > 0 {
+		options = append(options, core.WithScanBatchSize(config.ScanBatchSize))
+	}
+	if config.ScanBatchIntervalMs > 0 {
+		options = append(options,
+			core.WithScanBatchInterval(time.Duration(config.ScanBatchIntervalMs)*time.Millisecond))
+	}
+
+	if config.IdempotencyTTLMs > 0 {
+		options = append(options,
+			core.WithIdempotencyTTL(time.Duration(config.IdempotencyTTLMs)*time.Millisecond))
+	}
+
+	if config.IdempotencyLeaseMs > 0 {
+		options = append(options,
+			core.WithIdempotencyLease(time.Duration(config.IdempotencyLeaseMs)*time.Millisecond))
+	}
+
+	if config.DefaultWriteTTLMs > 0 {
+		options = append(options,
+			core.WithDefaultWriteTTL(time.Duration(config.DefaultWriteTTLMs)*time.Millisecond))
+	}
+
+	if config.TTLJitterPercent > 0 {
+		options = append(options, core.WithTTLJitter(config.TTLJitterPercent))
+	}
+
+	if config.MaxWriteTTLMs > 0 {
+		options = append(options, core.WithMaxWriteTTL(time.Duration(config.MaxWriteTTLMs)*time.Millisecond,
+			convertMaxWriteTTLPolicyOrPanic(config.MaxWriteTTLPolicy)))
+	}
+
+	options = append(options, core.WithQuorumMode(convertQuorumModeOrPanic(config.EvenQuorum)))
+
+	if config.SessionConsistencyTTLMs > 0 {
+		options = append(options,
+			core.WithSessionConsistency(time.Duration(config.SessionConsistencyTTLMs)*time.Millisecond))
+	}
+
+	if config.LockPollIntervalMs > 0 {
+		options = append(options,
+			core.WithLockPollInterval(time.Duration(config.LockPollIntervalMs)*time.Millisecond))
+	}
+
+	if config.MaxKeyBytes > 0 {
+		options = append(options, core.WithMaxKeyBytes(config.MaxKeyBytes))
+	}
+	if config.MaxValueBytes > 0 {
+		options = append(options, core.WithMaxValueBytes(config.MaxValueBytes))
+	}
+
+	if config.Compression {
+		options = append(options, core.WithCompression(config.CompressionMinBytes))
+	}
+
+	if config.EncryptionKeys != "" {
+		options = append(options,
+			core.WithEncryption(convertEncryptionKeysOrPanic(config.EncryptionKeys, config.EncryptionKeyID)))
+	}
+
+	if config.RepairLogging {
+		options = append(options, core.WithRepairLogging(true))
+	}
+
+	if config.ValueVersioning {
+		options = append(options, core.WithValueVersioning(true))
+	}
+
+	if config.ValueMetadata {
+		options = append(options, core.WithValueMetadata(true))
+	}
+
+	if config.RetryAttempts > 1 {
+		options = append(options, core.WithConsistencyRetry(config.RetryAttempts,
+			time.Duration(config.RetryDelayMs)*time.Millisecond))
+	}
+
+	if config.MaxRepairsPerRead > 0 || config.MaxRepairsPerSecond > 0 {
+		options = append(options, core.WithRepairLimits(config.MaxRepairsPerRead, config.MaxRepairsPerSecond))
+	}
+
+	options = append(options, core.WithTTLTolerance(time.Duration(config.TTLToleranceMs)*time.Millisecond))
+
+	if !config.WriteRollback {
+		options = append(options, core.WithWriteRollback(false))
+	}
+
+	if conflictResolver := convertConflictResolverOrPanic(config.ConflictResolution); conflictResolver != nil {
+		options = append(options, core.WithConflictResolver(conflictResolver))
+	}
+
+	if config.HintedHandoffIntervalMs > 0 {
+		hints := handoff.New(time.Duration(config.HintedHandoffIntervalMs)*time.Millisecond,
+			handoff.WithMaxHints(config.HintedHandoffMaxHints))
+		hints.Start()
+		options = append(options, core.WithHintedHandoff(hints))
+	}
 
 	svc := core.New(cluster, engine, options...)
 
-	return svc
+	return limitServiceOrPanic(slowLogService(coalesceService(svc, config), config), config)
+}
+
+func coalesceService(svc keyvaluestore.Service, config *Config) keyvaluestore.Service {
+	if config.WriteCoalescingWindowMs <= 0 {
+		return svc
+	}
+
+	var options []coalesce.Option
+	if config.WriteCoalescingPrefixes != "" {
+		for _, prefix := range strings.Split(config.WriteCoalescingPrefixes, ",") {
+			options = append(options, coalesce.WithKeyPrefix(strings.TrimSpace(prefix)))
+		}
+	}
+
+	return coalesce.New(svc, time.Duration(config.WriteCoalescingWindowMs)*time.Millisecond, options...)
+}
+
+func slowLogService(svc keyvaluestore.Service, config *Config) keyvaluestore.Service {
+	if config.SlowLogThresholdMs <= 0 {
+		return svc
+	}
+
+	return slowlog.New(svc, time.Duration(config.SlowLogThresholdMs)*time.Millisecond,
+		slowlog.WithHashedKeys(config.SlowLogHashKeys))
+}
+
+func limitServiceOrPanic(svc keyvaluestore.Service, config *Config) keyvaluestore.Service {
+	var options []ratelimit.Option
+	if config.MaxOpsPerSecond > 0 {
+		options = append(options, ratelimit.WithRate(config.MaxOpsPerSecond))
+	}
+
+	for operation, rate := range convertOperationRateLimitsOrPanic(config.OperationRateLimits) {
+		options = append(options, ratelimit.WithOperationRate(operation, rate))
+	}
+
+	if len(options) == 0 {
+		return svc
+	}
+
+	return ratelimit.New(svc, options...)
+}
+
+func convertOperationRateLimitsOrPanic(operationRateLimits string) map[string]int {
+	result := make(map[string]int)
+	if operationRateLimits == "" {
+		return result
+	}
+
+	for _, item := range strings.Split(operationRateLimits, ",") {
+		parts := strings.Split(item, "=")
+		if len(parts) != 2 {
+			log.Panicf("invalid operation rate limit, expected operation=rate: %v", item)
+		}
+
+		rate, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || rate <= 0 {
+			log.Panicf("invalid operation rate limit: %v", item)
+		}
+
+		result[strings.ToLower(strings.TrimSpace(parts[0]))] = rate
+	}
+
+	return result
+}
+
+func convertOperationConsistencyOrPanic(operationConsistency string) map[string]keyvaluestore.ConsistencyLevel {
+	result, err := parseOperationConsistency(operationConsistency)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return result
+}
+
+func parseOperationConsistency(operationConsistency string) (map[string]keyvaluestore.ConsistencyLevel, error) {
+	result := make(map[string]keyvaluestore.ConsistencyLevel)
+	if operationConsistency == "" {
+		return result, nil
+	}
+
+	for _, item := range strings.Split(operationConsistency, ",") {
+		parts := strings.Split(item, "=")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid operation consistency, expected operation=consistency: %v", item)
+		}
+
+		consistency, err := parseConsistency(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+
+		result[strings.ToLower(strings.TrimSpace(parts[0]))] = consistency
+	}
+
+	return result, nil
+}
+
+func convertConflictResolverOrPanic(conflictResolution string) keyvaluestore.ConflictResolver {
+	result, err := parseConflictResolver(conflictResolution)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return result
+}
+
+func parseConflictResolver(conflictResolution string) (keyvaluestore.ConflictResolver, error) {
+	switch strings.ToLower(conflictResolution) {
+	case "", "majority":
+		return nil, nil
+
+	case "longest-ttl":
+		return resolver.LongestTTL, nil
+
+	case "last-write-wins":
+		return resolver.LastWriteWins, nil
+
+	default:
+		return nil, fmt.Errorf("unrecognized conflict resolution: %v", conflictResolution)
+	}
+}
+
+func convertNoMajorityFallbackOrPanic(config *Config) keyvaluestore.FallbackOperator {
+	result, err := parseNoMajorityFallback(config)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return result
+}
+
+func parseNoMajorityFallback(config *Config) (keyvaluestore.FallbackOperator, error) {
+	switch strings.ToLower(config.NoMajorityFallback) {
+	case "", "fail":
+		return nil, nil
+
+	case "newest":
+		if !config.ValueVersioning {
+			return nil, fmt.Errorf("newest no-majority fallback requires value versioning")
+		}
+
+		return resolver.NewestValue, nil
+
+	case "any":
+		return resolver.AnyValue, nil
+
+	default:
+		return nil, fmt.Errorf("unrecognized no-majority fallback: %v", config.NoMajorityFallback)
+	}
+}
+
+func convertQuorumModeOrPanic(evenQuorum string) keyvaluestore.QuorumMode {
+	result, err := parseQuorumMode(evenQuorum)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return result
+}
+
+func parseQuorumMode(evenQuorum string) (keyvaluestore.QuorumMode, error) {
+	switch strings.ToLower(evenQuorum) {
+	case "", "majority":
+		return keyvaluestore.QuorumModeStrictMajority, nil
+
+	case "half":
+		return keyvaluestore.QuorumModeHalf, nil
+
+	default:
+		return keyvaluestore.QuorumModeStrictMajority, fmt.Errorf("unrecognized even quorum: %v", evenQuorum)
+	}
+}
+
+// convertMaxWriteTTLPolicyOrPanic tells whether TTLs above the maximum should
+// be rejected rather than clamped.
+func convertMaxWriteTTLPolicyOrPanic(policy string) bool {
+	result, err := parseMaxWriteTTLPolicy(policy)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return result
+}
+
+func parseMaxWriteTTLPolicy(policy string) (bool, error) {
+	switch strings.ToLower(policy) {
+	case "", "clamp":
+		return false, nil
+
+	case "reject":
+		return true, nil
+
+	default:
+		return false, fmt.Errorf("unrecognized max write TTL policy: %v", policy)
+	}
 }
 
 func convertConsistencyOrPanic(consistency string) keyvaluestore.ConsistencyLevel {
+	result, err := parseConsistency(consistency)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return result
+}
+
+func parseConsistency(consistency string) (keyvaluestore.ConsistencyLevel, error) {
 	switch strings.ToLower(consistency) {
 	case "1":
-		return keyvaluestore.ConsistencyLevel_ONE
+		return keyvaluestore.ConsistencyLevel_ONE, nil
 
 	case "one":
-		return keyvaluestore.ConsistencyLevel_ONE
+		return keyvaluestore.ConsistencyLevel_ONE, nil
+
+	case "2":
+		return keyvaluestore.ConsistencyLevel_TWO, nil
+
+	case "two":
+		return keyvaluestore.ConsistencyLevel_TWO, nil
+
+	case "3":
+		return keyvaluestore.ConsistencyLevel_THREE, nil
+
+	case "three":
+		return keyvaluestore.ConsistencyLevel_THREE, nil
 
 	case "all":
-		return keyvaluestore.ConsistencyLevel_ALL
+		return keyvaluestore.ConsistencyLevel_ALL, nil
 
 	case "majority":
-		return keyvaluestore.ConsistencyLevel_MAJORITY
+		return keyvaluestore.ConsistencyLevel_MAJORITY, nil
 
 	default:
-		log.Panicf("unrecognized consistency level: %v", consistency)
-		return keyvaluestore.ConsistencyLevel_ALL
+		return keyvaluestore.ConsistencyLevel_ALL, fmt.Errorf("unrecognized consistency level: %v", consistency)
+	}
+}
+
+// convertEncryptionKeysOrPanic parses a list of id:base64key pairs. Messages
+// must never include the keys themselves.
+func convertEncryptionKeysOrPanic(encryptionKeys string, currentID int) *encryption.Keyring {
+	keys := make(map[byte][]byte)
+	for _, item := range strings.Split(encryptionKeys, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+		if len(parts) != 2 {
+			log.Panic("invalid encryption key, expected id:base64key")
+		}
+
+		id, err := strconv.ParseUint(parts[0], 10, 8)
+		if err != nil {
+			log.Panic("invalid encryption key id, expected a number between 0 and 255")
+		}
+
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			log.Panicf("invalid encryption key %d, expected base64", id)
+		}
+
+		keys[byte(id)] = key
+	}
+
+	if currentID < 0 || currentID > 255 {
+		log.Panic("invalid encryptionKeyID, expected a number between 0 and 255")
+	}
+
+	keyring, err := encryption.NewKeyring(keys, byte(currentID))
+	if err != nil {
+		log.Panic(err)
 	}
+
+	return keyring
+}
+
+func convertReadOnlyNodes(readOnlyNodes string) map[string]bool {
+	result := make(map[string]bool)
+	if readOnlyNodes == "" {
+		return result
+	}
+
+	for _, host := range strings.Split(readOnlyNodes, ",") {
+		result[strings.TrimSpace(host)] = true
+	}
+
+	return result
+}
+
+func convertNodeWeightsOrPanic(nodeWeights string) map[string]int {
+	result := make(map[string]int)
+	if nodeWeights == "" {
+		return result
+	}
+
+	for _, item := range strings.Split(nodeWeights, ",") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			log.Panicf("invalid node weight, expected host=weight: %v", item)
+		}
+
+		weight, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || weight < 0 {
+			log.Panicf("invalid node weight: %v", item)
+		}
+
+		result[strings.TrimSpace(parts[0])] = weight
+	}
+
+	return result
+}
+
+func convertNodeIDsOrPanic(nodeIDs string) map[string]string {
+	result, err := parseNodeIDs(nodeIDs)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return result
+}
+
+func parseNodeIDs(nodeIDs string) (map[string]string, error) {
+	result := make(map[string]string)
+	if nodeIDs == "" {
+		return result, nil
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(nodeIDs, ",") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid node id, expected host=id: %v", item)
+		}
+
+		id := strings.TrimSpace(parts[1])
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate node id: %v", id)
+		}
+
+		seen[id] = true
+		result[strings.TrimSpace(parts[0])] = id
+	}
+
+	return result, nil
+}
+
+// validateNodeIDs checks that either every one of hosts or none has an ID,
+// and that IDs are only given to hosts.
+func validateNodeIDs(nodeIDs map[string]string, hosts []string) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	var missing []string
+	for _, host := range hosts {
+		known[host] = true
+		if _, ok := nodeIDs[host]; !ok {
+			missing = append(missing, host)
+		}
+	}
+
+	var unknown []string
+	for host := range nodeIDs {
+		if !known[host] {
+			unknown = append(unknown, host)
+		}
+	}
+	sort.Strings(unknown)
+
+	if len(unknown) > 0 {
+		return fmt.Errorf("ids of unknown nodes: %v", strings.Join(unknown, ", "))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("either all nodes or none should have an id, missing: %v", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// redisDatabase returns the database of host given by nodeDatabases, falling
+// back to redisDatabase.
+func redisDatabase(config *Config, host string) (int, error) {
+	if config.NodeDatabases == "" {
+		return config.RedisDatabase, nil
+	}
+
+	for _, item := range strings.Split(config.NodeDatabases, ",") {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return 0, fmt.Errorf("invalid node database, expected host=database: %v", item)
+		}
+
+		if strings.TrimSpace(parts[0]) != host {
+			continue
+		}
+
+		database, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || database < 0 {
+			return 0, fmt.Errorf("invalid node database: %v", item)
+		}
+
+		return database, nil
+	}
+
+	return config.RedisDatabase, nil
 }
 
 func convertPolicyListOrPanic(policyList string) []keyvaluestore.Policy {
+	result, err := parsePolicyList(policyList)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return result
+}
+
+func parsePolicyList(policyList string) ([]keyvaluestore.Policy, error) {
 	items := strings.Split(policyList, ",")
 	var result []keyvaluestore.Policy
 
 	for _, item := range items {
-		result = append(result, convertPolicyOrPanic(item))
+		policy, err := parsePolicy(item)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, policy)
 	}
 
-	return result
+	return result, nil
 }
 
-func convertPolicyOrPanic(policy string) keyvaluestore.Policy {
+func parsePolicy(policy string) (keyvaluestore.Policy, error) {
 	switch strings.ToLower(policy) {
 	case "readone-localorrandomnode":
-		return keyvaluestore.PolicyReadOneLocalOrRandomNode
+		return keyvaluestore.PolicyReadOneLocalOrRandomNode, nil
 
 	case "readone-firstavailable":
-		return keyvaluestore.PolicyReadOneFirstAvailable
+		return keyvaluestore.PolicyReadOneFirstAvailable, nil
+
+	case "readone-roundrobin":
+		return keyvaluestore.PolicyReadOneRoundRobin, nil
+
+	case "read-all":
+		return keyvaluestore.PolicyReadAll, nil
 
 	default:
-		log.Panicf("unrecognized policy: %v", policy)
-		return 0
+		return 0, fmt.Errorf("unrecognized policy: %v", policy)
 	}
 }
 
@@ -206,9 +1009,21 @@ func makeRedisServerOrPanic(svc keyvaluestore.Service, config *Config) keyvalues
 		writeConsistency = convertConsistencyOrPanic(config.DefaultWriteConsistency)
 	}
 
+	// Leave the choice to the service, which knows the operation of each
+	// command, unless a connection overrides the consistency.
+	if config.OperationConsistency != "" {
+		readConsistency = keyvaluestore.ConsistencyLevel_DEFAULT
+		writeConsistency = keyvaluestore.ConsistencyLevel_DEFAULT
+	}
+
+	options := []redisTransport.Option{redisTransport.WithMaxConnections(config.MaxConnections)}
+	if config.ListenSocket != "" {
+		options = append(options, redisTransport.WithListenSocket(config.ListenSocket))
+	}
+
 	return redisTransport.New(svc, config.RedisListenPort,
 		time.Duration(config.RedisConnectionTimeout)*time.Millisecond,
-		readConsistency, writeConsistency)
+		readConsistency, writeConsistency, options...)
 }
 
 func startServerOrPanic(server keyvaluestore.Server) {
@@ -218,9 +1033,118 @@ func startServerOrPanic(server keyvaluestore.Server) {
 	}
 }
 
-func shutdownServerOrPanic(server keyvaluestore.Server) {
+func shutdownServerOrPanic(server keyvaluestore.Server, svc keyvaluestore.Service, config *Config) {
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Duration(config.ShutdownTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.WithError(err).Error("failed to drain connections gracefully")
+	}
+
+	if err := svc.Close(); err != nil {
+		panicWithError(err, "failed to close service")
+	}
+}
+
+func startMetricsServer(config *Config, svc keyvaluestore.Service) *http.Server {
+	if config.MetricsListenPort <= 0 {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	// OpenMetrics exposes exemplars, e.g. correlation IDs of slow operations.
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := svc.Stats(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(stats); err != nil {
+			log.WithError(err).Error("failed to write stats")
+		}
+	})
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", config.MetricsListenPort),
+		Handler: mux,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Error("metrics server stopped unexpectedly")
+		}
+	}()
+
+	return server
+}
+
+func shutdownMetricsServer(server *http.Server) {
+	if server == nil {
+		return
+	}
+
 	if err := server.Close(); err != nil {
-		panicWithError(err, "failed to close server")
+		log.WithError(err).Error("failed to close metrics server")
+	}
+}
+
+func startAdminServer(config *Config, cluster keyvaluestore.Cluster,
+	svc keyvaluestore.Service) *http.Server {
+
+	if config.AdminListenPort <= 0 {
+		return nil
+	}
+
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%d", config.AdminListenPort),
+		Handler: admin.NewHandler(cluster, svc, func(address string) (keyvaluestore.Backend, error) {
+			return connectToHost(config, address)
+		}),
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Error("admin server stopped unexpectedly")
+		}
+	}()
+
+	return server
+}
+
+func shutdownAdminServer(server *http.Server) {
+	if server == nil {
+		return
+	}
+
+	if err := server.Close(); err != nil {
+		log.WithError(err).Error("failed to close admin server")
+	}
+}
+
+func startTTLSampler(config *Config, cluster keyvaluestore.Cluster) *sampler.Sampler {
+	if config.TTLSamplingIntervalMs <= 0 {
+		return nil
+	}
+
+	result := sampler.New(cluster.Nodes, time.Duration(config.TTLSamplingIntervalMs)*time.Millisecond,
+		sampler.WithKeysPerRound(config.TTLSamplingKeys))
+	result.Start()
+
+	return result
+}
+
+func stopTTLSampler(ttlSampler *sampler.Sampler) {
+	if ttlSampler == nil {
+		return
+	}
+
+	if err := ttlSampler.Close(); err != nil {
+		log.WithError(err).Error("failed to stop TTL sampler")
 	}
 }
 
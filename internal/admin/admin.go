@@ -0,0 +1,222 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// Connector connects to the node of address, without checking whether it is
+// reachable. It fails if the node cannot be set up, e.g. for an invalid
+// address.
+type Connector func(address string) (keyvaluestore.Backend, error)
+
+type NodeInfo struct {
+	Address string `json:"address"`
+}
+
+type MembershipResponse struct {
+	Nodes []NodeInfo `json:"nodes"`
+}
+
+type OwnersResponse struct {
+	Key   string     `json:"key"`
+	Nodes []NodeInfo `json:"nodes"`
+}
+
+type handler struct {
+	cluster keyvaluestore.Cluster
+	service keyvaluestore.Service
+	connect Connector
+}
+
+// NewHandler serves membership of cluster on /nodes. GET lists the members,
+// POST adds the node given by the address query parameter and DELETE removes
+// it. Every call responds with the resulting membership.
+//
+// GET /keys responds with the raw values of the key query parameter on every
+// node, as reported by Inspect of service.
+//
+// GET /owners responds with the nodes which writes of the key query parameter
+// are sent to.
+//
+// GET /capabilities responds with what service supports as configured, as
+// reported by Capabilities of service.
+func NewHandler(cluster keyvaluestore.Cluster, service keyvaluestore.Service, connect Connector) http.Handler {
+	h := &handler{
+		cluster: cluster,
+		service: service,
+		connect: connect,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/nodes", h.serveNodes)
+	mux.HandleFunc("/keys", h.serveKeys)
+	mux.HandleFunc("/owners", h.serveOwners)
+	mux.HandleFunc("/capabilities", h.serveCapabilities)
+
+	return mux
+}
+
+func (h *handler) serveNodes(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+
+	case http.MethodPost:
+		address := r.URL.Query().Get("address")
+		if address == "" {
+			http.Error(w, "address is required", http.StatusBadRequest)
+			return
+		}
+
+		node, err := h.connect(address)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if err := node.Ping(); err != nil {
+			h.closeNode(node)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if err := h.cluster.AddNode(node); err != nil {
+			h.closeNode(node)
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+
+	case http.MethodDelete:
+		address := r.URL.Query().Get("address")
+		if address == "" {
+			http.Error(w, "address is required", http.StatusBadRequest)
+			return
+		}
+
+		if err := h.cluster.RemoveNode(address); err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writeMembership(w)
+}
+
+func (h *handler) serveKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.service.Inspect(r.Context(), &keyvaluestore.InspectRequest{Key: key})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("failed to write key values")
+	}
+}
+
+func (h *handler) serveOwners(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		http.Error(w, "key is required", http.StatusBadRequest)
+		return
+	}
+
+	view, err := h.cluster.Write(key, keyvaluestore.ConsistencyLevel_ALL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := OwnersResponse{Key: key, Nodes: []NodeInfo{}}
+	for _, node := range view.Backends {
+		response.Nodes = append(response.Nodes, NodeInfo{Address: node.Address()})
+	}
+
+	sort.Slice(response.Nodes, func(i, j int) bool {
+		return response.Nodes[i].Address < response.Nodes[j].Address
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("failed to write owners")
+	}
+}
+
+func (h *handler) serveCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response, err := h.service.Capabilities(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("failed to write capabilities")
+	}
+}
+
+func (h *handler) writeMembership(w http.ResponseWriter) {
+	response := MembershipResponse{Nodes: []NodeInfo{}}
+	for _, node := range h.cluster.Nodes() {
+		response.Nodes = append(response.Nodes, NodeInfo{Address: node.Address()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		logrus.WithError(err).Error("failed to write membership")
+	}
+}
+
+func (h *handler) closeNode(node keyvaluestore.Backend) {
+	if err := node.Close(); err != nil {
+		logrus.WithError(err).WithField("node", node.Address()).Error("unexpected error while closing node")
+	}
+}
+
+func statusOf(err error) int {
+	switch err {
+	case keyvaluestore.ErrUnknownNode:
+		return http.StatusNotFound
+
+	case keyvaluestore.ErrNodeExists, keyvaluestore.ErrQuorumLoss, keyvaluestore.ErrTooFewReplicas:
+		return http.StatusConflict
+
+	default:
+		return http.StatusInternalServerError
+	}
+}
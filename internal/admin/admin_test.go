@@ -0,0 +1,223 @@
+package admin_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cafebazaar/keyvalue-store/internal/admin"
+	"github.com/cafebazaar/keyvalue-store/internal/cluster/static"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type AdminTestSuite struct {
+	suite.Suite
+
+	nodes     map[string]*keyvaluestore.Mock_Backend
+	connected []string
+	cluster   keyvaluestore.Cluster
+	service   *keyvaluestore.Mock_Service
+	handler   http.Handler
+}
+
+func TestAdminTestSuite(t *testing.T) {
+	suite.Run(t, new(AdminTestSuite))
+}
+
+func (s *AdminTestSuite) SetupTest() {
+	s.nodes = make(map[string]*keyvaluestore.Mock_Backend)
+	s.connected = nil
+
+	var backends []keyvaluestore.Backend
+	for _, address := range []string{"node1", "node2", "node3"} {
+		backends = append(backends, s.makeNode(address, nil))
+	}
+
+	s.cluster = static.New(backends)
+	s.service = &keyvaluestore.Mock_Service{}
+	s.handler = admin.NewHandler(s.cluster, s.service, func(address string) (keyvaluestore.Backend, error) {
+		s.connected = append(s.connected, address)
+		if node, ok := s.nodes[address]; ok {
+			return node, nil
+		}
+
+		return nil, errors.New("invalid node database")
+	})
+}
+
+func (s *AdminTestSuite) TestGetShouldListMembers() {
+	code, nodes := s.call(http.MethodGet, "/nodes")
+	s.Equal(http.StatusOK, code)
+	s.Equal([]string{"node1", "node2", "node3"}, nodes)
+}
+
+func (s *AdminTestSuite) TestPostShouldAddNode() {
+	s.makeNode("node4", nil)
+
+	code, nodes := s.call(http.MethodPost, "/nodes?address=node4")
+	s.Equal(http.StatusOK, code)
+	s.Equal([]string{"node1", "node2", "node3", "node4"}, nodes)
+
+	view, err := s.cluster.Write("", keyvaluestore.ConsistencyLevel_ALL)
+	s.Nil(err)
+	s.Equal(4, view.AcknowledgeRequired)
+}
+
+func (s *AdminTestSuite) TestPostShouldRejectUnreachableNode() {
+	node := s.makeNode("node4", errors.New("connection refused"))
+
+	code, _ := s.call(http.MethodPost, "/nodes?address=node4")
+	s.Equal(http.StatusBadGateway, code)
+	node.AssertCalled(s.T(), "Close")
+	s.Equal(3, len(s.cluster.Nodes()))
+}
+
+func (s *AdminTestSuite) TestPostShouldRejectNodeFailingToConnect() {
+	code, _ := s.call(http.MethodPost, "/nodes?address=node4")
+	s.Equal(http.StatusBadGateway, code)
+	s.Equal([]string{"node4"}, s.connected)
+	s.Equal(3, len(s.cluster.Nodes()))
+}
+
+func (s *AdminTestSuite) TestPostShouldRejectExistingNode() {
+	s.makeNode("node1", nil)
+
+	code, _ := s.call(http.MethodPost, "/nodes?address=node1")
+	s.Equal(http.StatusConflict, code)
+	s.Equal(3, len(s.cluster.Nodes()))
+}
+
+func (s *AdminTestSuite) TestDeleteShouldRemoveAndCloseNode() {
+	code, nodes := s.call(http.MethodDelete, "/nodes?address=node2")
+	s.Equal(http.StatusOK, code)
+	s.Equal([]string{"node1", "node3"}, nodes)
+	s.nodes["node2"].AssertCalled(s.T(), "Close")
+
+	view, err := s.cluster.Read("", keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.Nil(err)
+	s.Equal(2, view.VoteRequired)
+	s.ElementsMatch([]keyvaluestore.Backend{s.nodes["node1"], s.nodes["node3"]}, view.Backends)
+}
+
+func (s *AdminTestSuite) TestDeleteShouldRejectRemovalBelowQuorum() {
+	code, _ := s.call(http.MethodDelete, "/nodes?address=node2")
+	s.Equal(http.StatusOK, code)
+
+	code, _ = s.call(http.MethodDelete, "/nodes?address=node3")
+	s.Equal(http.StatusConflict, code)
+	s.Equal(2, len(s.cluster.Nodes()))
+}
+
+func (s *AdminTestSuite) TestDeleteShouldRejectUnknownNode() {
+	code, _ := s.call(http.MethodDelete, "/nodes?address=node5")
+	s.Equal(http.StatusNotFound, code)
+}
+
+func (s *AdminTestSuite) TestShouldRequireAddress() {
+	code, _ := s.call(http.MethodPost, "/nodes")
+	s.Equal(http.StatusBadRequest, code)
+	s.Empty(s.connected)
+
+	code, _ = s.call(http.MethodDelete, "/nodes")
+	s.Equal(http.StatusBadRequest, code)
+}
+
+func (s *AdminTestSuite) TestGetKeysShouldInspectKey() {
+	ttl := int64(1000)
+	s.service.On("Inspect", mock.Anything, &keyvaluestore.InspectRequest{Key: "key"}).Once().Return(
+		&keyvaluestore.InspectResponse{Nodes: []keyvaluestore.NodeValue{
+			{Address: "node1", Exists: true, Data: []byte("value"), TTLMs: &ttl},
+			{Address: "node2", Error: "connection refused"},
+		}}, nil)
+
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/keys?key=key", nil))
+	s.Equal(http.StatusOK, recorder.Code)
+	s.JSONEq(`{"nodes": [
+		{"address": "node1", "exists": true, "data": "dmFsdWU=", "ttlMs": 1000},
+		{"address": "node2", "exists": false, "error": "connection refused"}
+	]}`, recorder.Body.String())
+}
+
+func (s *AdminTestSuite) TestGetKeysShouldRequireKey() {
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	s.Equal(http.StatusBadRequest, recorder.Code)
+	s.service.AssertNotCalled(s.T(), "Inspect", mock.Anything, mock.Anything)
+}
+
+func (s *AdminTestSuite) TestGetOwnersShouldListWritableNodes() {
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/owners?key=key", nil))
+	s.Equal(http.StatusOK, recorder.Code)
+	s.JSONEq(`{"key": "key", "nodes": [
+		{"address": "node1"}, {"address": "node2"}, {"address": "node3"}
+	]}`, recorder.Body.String())
+}
+
+func (s *AdminTestSuite) TestGetOwnersShouldRequireKey() {
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/owners", nil))
+	s.Equal(http.StatusBadRequest, recorder.Code)
+}
+
+func (s *AdminTestSuite) TestGetCapabilitiesShouldDescribeService() {
+	s.service.On("Capabilities", mock.Anything).Once().Return(&keyvaluestore.CapabilitiesResponse{
+		Operations:              []string{"get", "set"},
+		ConsistencyLevels:       []string{"one", "all"},
+		DefaultReadConsistency:  "majority",
+		DefaultWriteConsistency: "all",
+		Nodes:                   3,
+		ValueVersioning:         true,
+	}, nil)
+
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/capabilities", nil))
+	s.Equal(http.StatusOK, recorder.Code)
+	s.JSONEq(`{
+		"operations": ["get", "set"],
+		"consistencyLevels": ["one", "all"],
+		"defaultReadConsistency": "majority",
+		"defaultWriteConsistency": "all",
+		"nodes": 3,
+		"sharding": false,
+		"valueVersioning": true,
+		"valueMetadata": false,
+		"compression": false,
+		"encryption": false,
+		"sessionConsistency": false
+	}`, recorder.Body.String())
+}
+
+func (s *AdminTestSuite) makeNode(address string, pingErr error) *keyvaluestore.Mock_Backend {
+	node := &keyvaluestore.Mock_Backend{}
+	node.On("Address").Return(address)
+	node.On("Ping").Return(pingErr)
+	node.On("Close").Return(nil)
+	s.nodes[address] = node
+
+	return node
+}
+
+func (s *AdminTestSuite) call(method string, target string) (int, []string) {
+	recorder := httptest.NewRecorder()
+	s.handler.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+
+	if recorder.Code != http.StatusOK {
+		return recorder.Code, nil
+	}
+
+	var response admin.MembershipResponse
+	s.Require().Nil(json.NewDecoder(recorder.Body).Decode(&response))
+
+	var addresses []string
+	for _, node := range response.Nodes {
+		addresses = append(addresses, node.Address)
+	}
+
+	return recorder.Code, addresses
+}
@@ -0,0 +1,35 @@
+package redis
+
+import (
+	"net"
+	"time"
+)
+
+// NewDialer returns a dialer for redis.Options which connects to address
+// over TCP, sending keepalive probes every keepAlive so that idle connections
+// are not dropped silently by NATs and load balancers in between. A negative
+// keepAlive disables keepalive, and noDelay controls Nagle's algorithm.
+func NewDialer(address string, timeout time.Duration, keepAlive time.Duration,
+	noDelay bool) func() (net.Conn, error) {
+
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: keepAlive,
+	}
+
+	return func() (net.Conn, error) {
+		conn, err := dialer.Dial("tcp", address)
+		if err != nil {
+			return nil, err
+		}
+
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			if err := tcpConn.SetNoDelay(noDelay); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+
+		return conn, nil
+	}
+}
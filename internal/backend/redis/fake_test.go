@@ -0,0 +1,104 @@
+package redis_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/cafebazaar/go-redisproto"
+)
+
+// fakeRedis is a redis server for what miniredis lacks. It serves PSUBSCRIBE,
+// publishing messages passed to publish to its subscribers, and replies every
+// other command with errorReply.
+type fakeRedis struct {
+	listener   net.Listener
+	errorReply string
+
+	lock        sync.Mutex
+	subscribers []*fakeSubscriber
+}
+
+type fakeSubscriber struct {
+	lock    sync.Mutex
+	pattern string
+	writer  *redisproto.Writer
+}
+
+func newFakeRedis(errorReply string) (*fakeRedis, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	result := &fakeRedis{listener: listener, errorReply: errorReply}
+	go result.serve()
+
+	return result, nil
+}
+
+func (f *fakeRedis) Addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRedis) Close() {
+	f.listener.Close()
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go f.serveConn(conn)
+	}
+}
+
+func (f *fakeRedis) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	parser := redisproto.NewParser(conn)
+	buffer := bufio.NewWriter(conn)
+	writer := redisproto.NewWriter(buffer)
+
+	for {
+		command, err := parser.ReadCommand()
+		if err != nil {
+			return
+		}
+
+		if !strings.EqualFold(string(command.Get(0)), "PSUBSCRIBE") {
+			writer.WriteError(f.errorReply)
+			buffer.Flush()
+			continue
+		}
+
+		// Subscribers are known before they are told, so that messages
+		// published once they have subscribed reach them
+		subscriber := &fakeSubscriber{pattern: string(command.Get(1)), writer: writer}
+		subscriber.lock.Lock()
+
+		f.lock.Lock()
+		f.subscribers = append(f.subscribers, subscriber)
+		f.lock.Unlock()
+
+		writer.WriteObjects("psubscribe", subscriber.pattern, 1)
+		buffer.Flush()
+		subscriber.lock.Unlock()
+	}
+}
+
+func (f *fakeRedis) publish(channel string, payload string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, subscriber := range f.subscribers {
+		subscriber.lock.Lock()
+		subscriber.writer.WriteObjects("pmessage", subscriber.pattern, channel, payload)
+		subscriber.writer.Flush()
+		subscriber.lock.Unlock()
+	}
+}
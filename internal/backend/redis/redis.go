@@ -1,23 +1,241 @@
 package redis
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
 	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
 
 	"github.com/go-redis/redis"
 )
 
+const keyspaceChannelPrefix = "__keyspace@%v__:"
+
+const pipelineCommand = "pipeline"
+
+var renewLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// setKeepTTLScript falls back to ARGV[2] milliseconds, if positive, when the
+// key does not exist. Unlike KEEPTTL of SET, it does not require redis 6. The
+// TTL is formatted explicitly, as Lua might use exponent notation otherwise.
+var setKeepTTLScript = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl == -2 then
+	ttl = tonumber(ARGV[2])
+end
+if ttl > 0 then
+	return redis.call('SET', KEYS[1], ARGV[1], 'PX', string.format('%d', ttl))
+end
+return redis.call('SET', KEYS[1], ARGV[1])
+`)
+
+var getDelScript = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if value then
+	redis.call('DEL', KEYS[1])
+end
+return value
+`)
+
+// acquireScript keeps holders of a semaphore in a sorted set scored by their
+// expiration time in milliseconds, dropping expired ones before counting. It
+// returns 0 if the semaphore is full, 1 if a slot is taken and 2 if the slot
+// of the holder is renewed.
+var acquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local expiration = tonumber(ARGV[2])
+local maxHolders = tonumber(ARGV[3])
+local holder = ARGV[4]
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local held = redis.call('ZSCORE', KEYS[1], holder)
+if not held and redis.call('ZCARD', KEYS[1]) >= maxHolders then
+	return 0
+end
+
+redis.call('ZADD', KEYS[1], now + expiration, holder)
+local latest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+redis.call('PEXPIREAT', KEYS[1], latest[2])
+if held then
+	return 2
+end
+return 1
+`)
+
+// Streams are sorted sets whose members are the encoded ID of an entry
+// followed by its data. All scores are zero, so members sort by their ID.
+const streamIDBytes = 16
+
+// Members found by bounds are checked against the ID in ARGV[3] as well,
+// since miniredis ignores min bounds which no member reaches.
+var streamAddScript = redis.NewScript(`
+local found = redis.call('ZRANGEBYLEX', KEYS[1], ARGV[1], ARGV[2], 'LIMIT', 0, 1)
+if found[1] and string.sub(found[1], 1, string.len(ARGV[3])) == ARGV[3] then
+	return 0
+end
+return redis.call('ZADD', KEYS[1], 0, ARGV[3] .. ARGV[4])
+`)
+
+var streamRemoveScript = redis.NewScript(`
+local removed = 0
+for _, member in ipairs(redis.call('ZRANGEBYLEX', KEYS[1], ARGV[1], ARGV[2])) do
+	if string.sub(member, 1, string.len(ARGV[3])) == ARGV[3] then
+		removed = removed + redis.call('ZREM', KEYS[1], member)
+	end
+end
+return removed
+`)
+
+// incrWindowScript also sets the expiration of counters which have lost it,
+// so that a counter never outlives its window indefinitely.
+var incrWindowScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 or redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
 type redisBackend struct {
-	client  *redis.Client
+	client  redis.UniversalClient
 	address string
+	ctx     context.Context
 }
 
-func New(client *redis.Client, address string) keyvaluestore.Backend {
-	return &redisBackend{
+// New creates a backend on top of either a single redis (or a sentinel
+// failover client) or a redis cluster client. In case of a redis cluster,
+// address is merely a representative endpoint of the cluster.
+func New(client redis.UniversalClient, address string) keyvaluestore.Backend {
+	result := &redisBackend{
 		client:  client,
 		address: address,
 	}
+
+	if client != nil {
+		client.WrapProcess(result.wrapProcess)
+		client.WrapProcessPipeline(result.wrapProcessPipeline)
+	}
+
+	return result
+}
+
+func (r *redisBackend) wrapProcess(process func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+	return func(cmd redis.Cmder) error {
+		start := time.Now()
+		err := process(cmd)
+		r.recordDuration(cmd.Name(), start)
+		r.recordError(err)
+		return err
+	}
+}
+
+func (r *redisBackend) wrapProcessPipeline(process func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+	return func(cmds []redis.Cmder) error {
+		start := time.Now()
+		err := process(cmds)
+		r.recordDuration(pipelineCommand, start)
+		for _, cmd := range cmds {
+			r.recordError(cmd.Err())
+		}
+		return err
+	}
+}
+
+// recordDuration records the duration of a command, or of a whole pipeline
+// as pipelineCommand, since commands of a pipeline are not timed one by one.
+func (r *redisBackend) recordDuration(command string, start time.Time) {
+	metrics.NodeCommandDuration.WithLabelValues(r.address, command).Observe(time.Since(start).Seconds())
+}
+
+func (r *redisBackend) recordError(err error) {
+	if err == nil || err == redis.Nil || strings.HasPrefix(err.Error(), "NOSCRIPT ") {
+		return
+	}
+
+	metrics.NodeErrorsTotal.WithLabelValues(r.address, classifyError(err)).Inc()
+}
+
+// classifyError tells network failures apart from errors replied by redis,
+// which are either authentication failures or logical errors such as
+// WRONGTYPE.
+func classifyError(err error) string {
+	var netErr net.Error
+	isNetErr := errors.As(err, &netErr)
+
+	switch {
+	case isNetErr && netErr.Timeout(), err.Error() == "redis: connection pool timeout":
+		return metrics.ErrorClassTimeout
+
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return metrics.ErrorClassConnectionRefused
+
+	case isNetErr, errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		strings.HasPrefix(err.Error(), "redis: "):
+
+		return metrics.ErrorClassConnection
+
+	case isAuthError(err.Error()):
+		return metrics.ErrorClassAuth
+
+	default:
+		return metrics.ErrorClassLogical
+	}
+}
+
+func isAuthError(message string) bool {
+	return strings.HasPrefix(message, "NOAUTH ") || strings.HasPrefix(message, "WRONGPASS ") ||
+		strings.HasPrefix(message, "NOPERM ") || strings.HasPrefix(message, "ERR invalid password") ||
+		strings.HasPrefix(message, "ERR AUTH ") || strings.HasPrefix(message, "ERR Client sent AUTH")
+}
+
+// WithContext returns the backend with commands bound to ctx. Commands are
+// not sent anymore once ctx is done, although go-redis does not interrupt a
+// command which has already been sent, leaving it to the read timeout.
+func (r *redisBackend) WithContext(ctx context.Context) keyvaluestore.Backend {
+	client := r.client
+	switch c := client.(type) {
+	case *redis.Client:
+		client = c.WithContext(ctx)
+
+	case *redis.ClusterClient:
+		client = c.WithContext(ctx)
+	}
+
+	return &redisBackend{
+		client:  client,
+		address: r.address,
+		ctx:     ctx,
+	}
+}
+
+// available tells why commands cannot be sent, if the backend is either
+// closed or bound to a context which is done.
+func (r *redisBackend) available() error {
+	if r.client == nil {
+		return keyvaluestore.ErrClosed
+	}
+
+	if r.ctx != nil {
+		return r.ctx.Err()
+	}
+
+	return nil
 }
 
 func (r *redisBackend) Address() string {
@@ -25,16 +243,24 @@ func (r *redisBackend) Address() string {
 }
 
 func (r *redisBackend) Set(key string, value []byte, expiration time.Duration) error {
-	if r.client == nil {
-		return keyvaluestore.ErrClosed
+	if err := r.available(); err != nil {
+		return err
 	}
 
 	return r.client.Set(key, value, expiration).Err()
 }
 
+func (r *redisBackend) SetKeepTTL(key string, value []byte, expiration time.Duration) error {
+	if err := r.available(); err != nil {
+		return err
+	}
+
+	return setKeepTTLScript.Run(r.client, []string{key}, value, expiration.Milliseconds()).Err()
+}
+
 func (r *redisBackend) Expire(key string, expiration time.Duration) error {
-	if r.client == nil {
-		return keyvaluestore.ErrClosed
+	if err := r.available(); err != nil {
+		return err
 	}
 
 	ok, err := r.client.Expire(key, expiration).Result()
@@ -47,9 +273,11 @@ func (r *redisBackend) Expire(key string, expiration time.Duration) error {
 	return nil
 }
 
+// Lock takes the lock using a single atomic SET key value NX PX (or EX for
+// whole seconds) command, which SetNX issues whenever expiration is set.
 func (r *redisBackend) Lock(key string, value []byte, expiration time.Duration) error {
-	if r.client == nil {
-		return keyvaluestore.ErrClosed
+	if err := r.available(); err != nil {
+		return err
 	}
 
 	ok, err := r.client.SetNX(key, value, expiration).Result()
@@ -63,16 +291,64 @@ func (r *redisBackend) Lock(key string, value []byte, expiration time.Duration)
 }
 
 func (r *redisBackend) Unlock(key string) error {
-	if r.client == nil {
-		return keyvaluestore.ErrClosed
+	if err := r.available(); err != nil {
+		return err
 	}
 
 	return r.client.Del(key).Err()
 }
 
+func (r *redisBackend) Acquire(key string, holder string, maxHolders int, expiration time.Duration) (bool, error) {
+	if err := r.available(); err != nil {
+		return false, err
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	acquired, err := acquireScript.Run(r.client, []string{key},
+		now, expiration.Milliseconds(), maxHolders, holder).Int()
+	if err != nil {
+		return false, err
+	}
+	if acquired == 0 {
+		return false, keyvaluestore.ErrNotAcquired
+	}
+	return acquired == 2, nil
+}
+
+func (r *redisBackend) Release(key string, holder string) error {
+	if err := r.available(); err != nil {
+		return err
+	}
+
+	return r.client.ZRem(key, holder).Err()
+}
+
+func (r *redisBackend) IncrWindow(key string, window time.Duration) (int64, error) {
+	if err := r.available(); err != nil {
+		return 0, err
+	}
+
+	return incrWindowScript.Run(r.client, []string{key}, window.Milliseconds()).Int64()
+}
+
+func (r *redisBackend) RenewLock(key string, value []byte, expiration time.Duration) error {
+	if err := r.available(); err != nil {
+		return err
+	}
+
+	renewed, err := renewLockScript.Run(r.client, []string{key}, value, expiration.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if renewed == 0 {
+		return keyvaluestore.ErrLockLost
+	}
+	return nil
+}
+
 func (r *redisBackend) TTL(key string) (*time.Duration, error) {
-	if r.client == nil {
-		return nil, keyvaluestore.ErrClosed
+	if err := r.available(); err != nil {
+		return nil, err
 	}
 
 	result, err := r.client.PTTL(key).Result()
@@ -96,8 +372,8 @@ func (r *redisBackend) TTL(key string) (*time.Duration, error) {
 }
 
 func (r *redisBackend) Exists(key string) (bool, error) {
-	if r.client == nil {
-		return false, keyvaluestore.ErrClosed
+	if err := r.available(); err != nil {
+		return false, err
 	}
 
 	result, err := r.client.Exists(key).Result()
@@ -109,8 +385,8 @@ func (r *redisBackend) Exists(key string) (bool, error) {
 }
 
 func (r *redisBackend) Get(key string) ([]byte, error) {
-	if r.client == nil {
-		return nil, keyvaluestore.ErrClosed
+	if err := r.available(); err != nil {
+		return nil, err
 	}
 
 	result, err := r.client.Get(key).Bytes()
@@ -121,22 +397,650 @@ func (r *redisBackend) Get(key string) ([]byte, error) {
 	return result, err
 }
 
+func (r *redisBackend) GetDel(key string) ([]byte, error) {
+	if err := r.available(); err != nil {
+		return nil, err
+	}
+
+	result, err := getDelScript.Run(r.client, []string{key}).String()
+	if err == redis.Nil {
+		return nil, keyvaluestore.ErrNotFound
+	}
+
+	return []byte(result), err
+}
+
+func (r *redisBackend) GetWithTTL(key string) (*keyvaluestore.ValueWithTTL, error) {
+	if err := r.available(); err != nil {
+		return nil, err
+	}
+
+	var getCommand *redis.StringCmd
+	var ttlCommand *redis.DurationCmd
+
+	_, err := r.client.Pipelined(func(pipe redis.Pipeliner) error {
+		getCommand = pipe.Get(key)
+		ttlCommand = pipe.PTTL(key)
+
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	data, err := getCommand.Bytes()
+	if err == redis.Nil {
+		return nil, keyvaluestore.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &keyvaluestore.ValueWithTTL{Data: data}
+
+	ttl, err := ttlCommand.Result()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ttl == -2*time.Millisecond:
+		return nil, keyvaluestore.ErrNotFound
+
+	case ttl >= 0:
+		result.TTL = &ttl
+	}
+
+	return result, nil
+}
+
 func (r *redisBackend) Delete(key string) error {
-	if r.client == nil {
-		return keyvaluestore.ErrClosed
+	if err := r.available(); err != nil {
+		return err
 	}
 
 	return r.client.Del(key).Err()
 }
 
+func (r *redisBackend) GetMany(keys []string) (map[string][]byte, error) {
+	if err := r.available(); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return result, nil
+	}
+
+	// Like DeleteMany, keys of a redis cluster are read one by one in a
+	// pipeline which is split by slot.
+	if _, ok := r.client.(*redis.ClusterClient); ok {
+		return r.getManyOnCluster(keys, result)
+	}
+
+	values, err := r.client.MGet(keys...).Result()
+	if err != nil {
+		return nil, convertCrossSlotError(err)
+	}
+
+	for i, value := range values {
+		if data, ok := value.(string); ok {
+			result[keys[i]] = []byte(data)
+		}
+	}
+
+	return result, nil
+}
+
+func (r *redisBackend) getManyOnCluster(keys []string, result map[string][]byte) (map[string][]byte, error) {
+	commands := make([]*redis.StringCmd, len(keys))
+
+	_, err := r.client.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			commands[i] = pipe.Get(key)
+		}
+
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for i, command := range commands {
+		data, err := command.Bytes()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		result[keys[i]] = data
+	}
+
+	return result, nil
+}
+
+func (r *redisBackend) DeleteMany(keys []string) (int64, error) {
+	if err := r.available(); err != nil {
+		return 0, err
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	// Keys might belong to different slots of a redis cluster, which rejects
+	// multi-key commands across slots. Delete them one by one in a pipeline
+	// which is split by slot.
+	if _, ok := r.client.(*redis.ClusterClient); ok {
+		return r.deleteManyOnCluster(keys)
+	}
+
+	deleted, err := r.client.Del(keys...).Result()
+	return deleted, convertCrossSlotError(err)
+}
+
+func (r *redisBackend) deleteManyOnCluster(keys []string) (int64, error) {
+	var commands []*redis.IntCmd
+
+	_, err := r.client.Pipelined(func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			commands = append(commands, pipe.Del(key))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, command := range commands {
+		deleted += command.Val()
+	}
+
+	return deleted, nil
+}
+
+func (r *redisBackend) Transaction(ops []keyvaluestore.Op) error {
+	if err := r.available(); err != nil {
+		return err
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	if err := r.checkSameSlot(keyvaluestore.OpKeys(ops)); err != nil {
+		return err
+	}
+
+	_, err := r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		return pipelineOps(pipe, ops)
+	})
+
+	return convertCrossSlotError(err)
+}
+
+func (r *redisBackend) OptimisticTransaction(keys []string,
+	apply keyvaluestore.OptimisticFunc) ([]keyvaluestore.Op, error) {
+
+	if err := r.available(); err != nil {
+		return nil, err
+	}
+
+	if err := r.checkSameSlot(keys); err != nil {
+		return nil, err
+	}
+
+	var applied []keyvaluestore.Op
+	err := r.client.Watch(func(tx *redis.Tx) error {
+		values := make(map[string][]byte, len(keys))
+		for _, key := range keys {
+			value, err := tx.Get(key).Bytes()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			values[key] = value
+		}
+
+		ops, err := apply(values)
+		if err != nil {
+			return err
+		}
+
+		if err := r.checkSameSlot(append(keyvaluestore.OpKeys(ops), keys...)); err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+			// EXEC fails if keys have changed even without any op, so the
+			// values read by a transaction without ops were still a snapshot
+			if len(ops) == 0 && len(keys) > 0 {
+				pipe.Exists(keys...)
+			}
+
+			return pipelineOps(pipe, ops)
+		})
+		if err != nil {
+			return err
+		}
+
+		applied = ops
+		return nil
+	}, keys...)
+
+	if err == redis.TxFailedErr {
+		return nil, keyvaluestore.ErrOptimisticConflict
+	}
+
+	return applied, convertCrossSlotError(err)
+}
+
+// convertCrossSlotError maps the CROSSSLOT reply of a cluster node, e.g. when
+// a plain client is pointed at a single node of a redis cluster, to
+// ErrCrossShard.
+func convertCrossSlotError(err error) error {
+	if err != nil && strings.HasPrefix(err.Error(), "CROSSSLOT ") {
+		return keyvaluestore.ErrCrossShard
+	}
+
+	return err
+}
+
+// checkSameSlot fails with ErrCrossShard if keys span multiple slots of a
+// redis cluster, which can not run MULTI/EXEC across slots. go-redis would
+// split such a transaction into one per slot, losing atomicity.
+func (r *redisBackend) checkSameSlot(keys []string) error {
+	if _, ok := r.client.(*redis.ClusterClient); !ok || len(keys) == 0 {
+		return nil
+	}
+
+	slot := keySlot(keys[0])
+	for _, key := range keys[1:] {
+		if keySlot(key) != slot {
+			return keyvaluestore.ErrCrossShard
+		}
+	}
+
+	return nil
+}
+
+func pipelineOps(pipe redis.Pipeliner, ops []keyvaluestore.Op) error {
+	for _, op := range ops {
+		switch op.Type {
+		case keyvaluestore.OpSet:
+			pipe.Set(op.Key, op.Data, op.Expiration)
+
+		case keyvaluestore.OpDelete:
+			pipe.Del(op.Key)
+
+		default:
+			return keyvaluestore.ErrInvalidOp
+		}
+	}
+
+	return nil
+}
+
+func (r *redisBackend) StreamAdd(key string, entry keyvaluestore.StreamEntry) error {
+	if err := r.available(); err != nil {
+		return err
+	}
+
+	min, max := streamBounds(entry.ID, entry.ID)
+	return streamAddScript.Run(r.client, []string{key}, min, max, encodeStreamID(entry.ID), entry.Data).Err()
+}
+
+func (r *redisBackend) StreamRemove(key string, id keyvaluestore.StreamID) error {
+	if err := r.available(); err != nil {
+		return err
+	}
+
+	min, max := streamBounds(id, id)
+	return streamRemoveScript.Run(r.client, []string{key}, min, max, encodeStreamID(id)).Err()
+}
+
+func (r *redisBackend) StreamRange(key string, start keyvaluestore.StreamID, end keyvaluestore.StreamID,
+	count int64) ([]keyvaluestore.StreamEntry, error) {
+
+	if err := r.available(); err != nil {
+		return nil, err
+	}
+
+	min, max := streamBounds(start, end)
+	members, err := r.client.ZRangeByLex(key, redis.ZRangeBy{Min: min, Max: max, Count: count}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]keyvaluestore.StreamEntry, 0, len(members))
+	for _, member := range members {
+		if len(member) < streamIDBytes {
+			return nil, fmt.Errorf("malformed entry of stream %v", key)
+		}
+
+		id := keyvaluestore.StreamID{
+			Time:     binary.BigEndian.Uint64([]byte(member[:8])),
+			Sequence: binary.BigEndian.Uint64([]byte(member[8:streamIDBytes])),
+		}
+		if id.Less(start) || end.Less(id) {
+			continue
+		}
+
+		result = append(result, keyvaluestore.StreamEntry{ID: id, Data: []byte(member[streamIDBytes:])})
+	}
+
+	return result, nil
+}
+
+// encodeStreamID encodes id so that members of a stream sort by their ID.
+func encodeStreamID(id keyvaluestore.StreamID) []byte {
+	result := make([]byte, streamIDBytes)
+	binary.BigEndian.PutUint64(result[:8], id.Time)
+	binary.BigEndian.PutUint64(result[8:], id.Sequence)
+	return result
+}
+
+// streamBounds returns the ZRANGEBYLEX bounds of members whose IDs are
+// between start and end inclusive.
+func streamBounds(start keyvaluestore.StreamID, end keyvaluestore.StreamID) (string, string) {
+	min := "[" + string(encodeStreamID(start))
+	if end == keyvaluestore.MaxStreamID {
+		return min, "+"
+	}
+
+	next := keyvaluestore.StreamID{Time: end.Time, Sequence: end.Sequence + 1}
+	if next.Sequence == 0 {
+		next.Time++
+	}
+
+	return min, "(" + string(encodeStreamID(next))
+}
+
+func (r *redisBackend) Scan(cursor uint64, pattern string, count int64) ([]string, uint64, error) {
+	if err := r.available(); err != nil {
+		return nil, 0, err
+	}
+
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return r.scanOnCluster(cluster, pattern, count)
+	}
+
+	return r.client.Scan(cursor, pattern, count).Result()
+}
+
+// scanOnCluster performs a complete scan on every master of the cluster, since
+// a single cursor is meaningless across masters. It always returns a zero
+// cursor.
+func (r *redisBackend) scanOnCluster(cluster *redis.ClusterClient,
+	pattern string, count int64) ([]string, uint64, error) {
+
+	var lock sync.Mutex
+	var result []string
+
+	err := cluster.ForEachMaster(func(master *redis.Client) error {
+		iterator := master.Scan(0, pattern, count).Iterator()
+		var keys []string
+
+		for iterator.Next() {
+			keys = append(keys, iterator.Val())
+		}
+		if err := iterator.Err(); err != nil {
+			return err
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		result = append(result, keys...)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result, 0, nil
+}
+
+func (r *redisBackend) RandomKey() (string, error) {
+	if err := r.available(); err != nil {
+		return "", err
+	}
+
+	key, err := r.client.RandomKey().Result()
+	if err == redis.Nil {
+		return "", keyvaluestore.ErrNotFound
+	}
+
+	return key, err
+}
+
 func (r *redisBackend) FlushDB() error {
-	if r.client == nil {
-		return keyvaluestore.ErrClosed
+	if err := r.available(); err != nil {
+		return err
+	}
+
+	if cluster, ok := r.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(func(master *redis.Client) error {
+			return master.FlushDB().Err()
+		})
 	}
 
 	return r.client.FlushDB().Err()
 }
 
+func (r *redisBackend) Type(key string) (string, error) {
+	if err := r.available(); err != nil {
+		return "", err
+	}
+
+	result, err := r.client.Type(key).Result()
+	if err != nil {
+		return "", err
+	}
+	if result == "none" {
+		return "", keyvaluestore.ErrNotFound
+	}
+
+	return result, nil
+}
+
+func (r *redisBackend) DBSize() (int64, error) {
+	return r.sumOverMasters(func(client *redis.Client) (int64, error) {
+		return client.DBSize().Result()
+	})
+}
+
+func (r *redisBackend) UsedMemory() (int64, error) {
+	return r.sumOverMasters(func(client *redis.Client) (int64, error) {
+		info, err := client.Info("memory").Result()
+		if err != nil {
+			return 0, err
+		}
+
+		return parseUsedMemory(info)
+	})
+}
+
+// sumOverMasters sums up the results of every master in case of a redis
+// cluster, since keys are sharded between them.
+func (r *redisBackend) sumOverMasters(query func(client *redis.Client) (int64, error)) (int64, error) {
+	if err := r.available(); err != nil {
+		return 0, err
+	}
+
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		client, ok := r.client.(*redis.Client)
+		if !ok {
+			return 0, fmt.Errorf("unsupported redis client: %T", r.client)
+		}
+
+		return query(client)
+	}
+
+	var total int64
+	err := cluster.ForEachMaster(func(master *redis.Client) error {
+		result, err := query(master)
+		if err != nil {
+			return err
+		}
+
+		atomic.AddInt64(&total, result)
+		return nil
+	})
+
+	return total, err
+}
+
+func parseUsedMemory(info string) (int64, error) {
+	for _, line := range strings.Split(info, "\n") {
+		if value := strings.TrimPrefix(line, "used_memory:"); value != line {
+			return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		}
+	}
+
+	return 0, fmt.Errorf("used_memory is missing from redis info")
+}
+
+func (r *redisBackend) Ping() error {
+	if err := r.available(); err != nil {
+		return err
+	}
+
+	return r.client.Ping().Err()
+}
+
+// Watch relies on keyspace notifications, which have to be enabled on redis
+// using notify-keyspace-events. In case of a redis cluster, only
+// notifications of a single master are received.
+func (r *redisBackend) Watch(ctx context.Context, pattern string) (<-chan keyvaluestore.Event, error) {
+	if err := r.available(); err != nil {
+		return nil, err
+	}
+
+	pubsubs, err := r.subscribe(r.keyspaceChannelPrefix() + pattern)
+	if err != nil {
+		for _, pubsub := range pubsubs {
+			_ = pubsub.Close()
+		}
+
+		return nil, err
+	}
+
+	result := make(chan keyvaluestore.Event)
+
+	var wg sync.WaitGroup
+	for _, pubsub := range pubsubs {
+		wg.Add(1)
+		go func(pubsub *redis.PubSub) {
+			defer wg.Done()
+			defer pubsub.Close()
+
+			forwardEvents(ctx, pubsub.Channel(), result)
+		}(pubsub)
+	}
+
+	go func() {
+		wg.Wait()
+		close(result)
+	}()
+
+	return result, nil
+}
+
+// subscribe subscribes to channel on every master of a redis cluster, since
+// keyspace notifications are only published by the master owning the key.
+// Masters which join the cluster later are not subscribed.
+func (r *redisBackend) subscribe(channel string) ([]*redis.PubSub, error) {
+	cluster, ok := r.client.(*redis.ClusterClient)
+	if !ok {
+		pubsub := r.client.PSubscribe(channel)
+		_, err := pubsub.Receive()
+		return []*redis.PubSub{pubsub}, err
+	}
+
+	var lock sync.Mutex
+	var result []*redis.PubSub
+
+	err := cluster.ForEachMaster(func(master *redis.Client) error {
+		pubsub := master.PSubscribe(channel)
+
+		lock.Lock()
+		result = append(result, pubsub)
+		lock.Unlock()
+
+		_, err := pubsub.Receive()
+		return err
+	})
+
+	return result, err
+}
+
+func forwardEvents(ctx context.Context, messages <-chan *redis.Message, result chan<- keyvaluestore.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+
+			event, ok := parseKeyspaceEvent(message)
+			if !ok {
+				continue
+			}
+
+			select {
+			case result <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// keyspaceChannelPrefix only matches notifications of the selected database,
+// which redis cluster does not support choosing.
+func (r *redisBackend) keyspaceChannelPrefix() string {
+	if client, ok := r.client.(*redis.Client); ok {
+		return fmt.Sprintf(keyspaceChannelPrefix, client.Options().DB)
+	}
+
+	return fmt.Sprintf(keyspaceChannelPrefix, "*")
+}
+
+func parseKeyspaceEvent(message *redis.Message) (keyvaluestore.Event, bool) {
+	index := strings.Index(message.Channel, "__:")
+	if index < 0 {
+		return keyvaluestore.Event{}, false
+	}
+
+	event := keyvaluestore.Event{Key: message.Channel[index+3:]}
+
+	switch message.Payload {
+	case "set":
+		event.Type = keyvaluestore.EventSet
+
+	case "del":
+		event.Type = keyvaluestore.EventDelete
+
+	case "expired":
+		event.Type = keyvaluestore.EventExpire
+
+	default:
+		return keyvaluestore.Event{}, false
+	}
+
+	return event, true
+}
+
 func (r *redisBackend) Close() error {
 	if r.client != nil {
 		err := r.client.Close()
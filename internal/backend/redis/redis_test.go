@@ -1,11 +1,19 @@
 package redis_test
 
 import (
+	"context"
+	"errors"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
 	redisBackend "github.com/cafebazaar/keyvalue-store/internal/backend/redis"
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
 	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
 	"github.com/go-redis/redis"
 	"github.com/stretchr/testify/suite"
@@ -68,6 +76,56 @@ func (s *RedisBackendTestSuite) TestSetOnClosedBackendShouldReturnErrClosed() {
 	s.Equal(keyvaluestore.ErrClosed, s.backend.Set(KEY, []byte(VALUE), 0))
 }
 
+func (s *RedisBackendTestSuite) TestBoundBackendShouldNotSendCommandsOnceContextIsDone() {
+	ctx, cancel := context.WithCancel(context.Background())
+	bound := keyvaluestore.BindContext(ctx, s.backend)
+
+	s.Nil(bound.Set(KEY, []byte(VALUE), 0))
+	s.db.CheckGet(s.T(), KEY, VALUE)
+
+	cancel()
+	s.Equal(context.Canceled, bound.Set(KEY2, []byte(VALUE2), 0))
+	s.False(s.db.Exists(KEY2))
+	s.Nil(s.backend.Set(KEY2, []byte(VALUE2), 0))
+}
+
+func (s *RedisBackendTestSuite) TestBoundBackendShouldKeepNodeOpenOnClose() {
+	bound := keyvaluestore.BindContext(context.Background(), s.backend)
+	s.Equal(s.backend, keyvaluestore.Origin(bound))
+	s.Nil(bound.Close())
+	s.Nil(s.backend.Set(KEY, []byte(VALUE), 0))
+}
+
+func (s *RedisBackendTestSuite) TestSetKeepTTLShouldKeepExpirationOfExistingKey() {
+	s.Nil(s.db.Set(KEY, "_"))
+	s.db.SetTTL(KEY, 1*time.Hour)
+	s.Nil(s.backend.SetKeepTTL(KEY, []byte(VALUE), 1*time.Minute))
+	s.db.CheckGet(s.T(), KEY, VALUE)
+	ttl := s.db.TTL(KEY)
+	s.True(ttl > 59*time.Minute)
+	s.True(ttl <= 1*time.Hour)
+}
+
+func (s *RedisBackendTestSuite) TestSetKeepTTLShouldKeepExistingKeyPersistent() {
+	s.Nil(s.db.Set(KEY, "_"))
+	s.Nil(s.backend.SetKeepTTL(KEY, []byte(VALUE), 1*time.Minute))
+	s.db.CheckGet(s.T(), KEY, VALUE)
+	s.Zero(s.db.TTL(KEY))
+}
+
+func (s *RedisBackendTestSuite) TestSetKeepTTLShouldEmployTTLIfKeyDoesNotExist() {
+	s.Nil(s.backend.SetKeepTTL(KEY, []byte(VALUE), 1*time.Minute))
+	s.db.CheckGet(s.T(), KEY, VALUE)
+	ttl := s.db.TTL(KEY)
+	s.True(ttl > 59*time.Second)
+	s.True(ttl <= 1*time.Minute)
+}
+
+func (s *RedisBackendTestSuite) TestSetKeepTTLOnClosedBackendShouldReturnErrClosed() {
+	s.Nil(s.backend.Close())
+	s.Equal(keyvaluestore.ErrClosed, s.backend.SetKeepTTL(KEY, []byte(VALUE), 0))
+}
+
 func (s *RedisBackendTestSuite) TestGetShouldReturnNotFoundIfKeyDoesNotExist() {
 	_, err := s.backend.Get(KEY)
 	s.Equal(keyvaluestore.ErrNotFound, err)
@@ -80,6 +138,25 @@ func (s *RedisBackendTestSuite) TestGetShouldReturnValueIfKeyExists() {
 	s.Equal(VALUE, string(result))
 }
 
+func (s *RedisBackendTestSuite) TestGetDelShouldReturnValueAndDeleteKey() {
+	s.Nil(s.db.Set(KEY, VALUE))
+	result, err := s.backend.GetDel(KEY)
+	s.Nil(err)
+	s.Equal(VALUE, string(result))
+	s.False(s.db.Exists(KEY))
+}
+
+func (s *RedisBackendTestSuite) TestGetDelShouldReturnNotFoundIfKeyDoesNotExist() {
+	_, err := s.backend.GetDel(KEY)
+	s.Equal(keyvaluestore.ErrNotFound, err)
+}
+
+func (s *RedisBackendTestSuite) TestGetDelShouldReturnErrClosedIfBackendIsClosed() {
+	s.Nil(s.backend.Close())
+	_, err := s.backend.GetDel(KEY)
+	s.Equal(keyvaluestore.ErrClosed, err)
+}
+
 func (s *RedisBackendTestSuite) TestGetShouldReturnErrClosedIfBackendIsClosed() {
 	s.Nil(s.backend.Close())
 	_, err := s.backend.Get(KEY)
@@ -137,6 +214,18 @@ func (s *RedisBackendTestSuite) TestLockShouldSucceedOnCleanDatabase() {
 	s.True(s.db.Exists(KEY))
 }
 
+func (s *RedisBackendTestSuite) TestLockShouldEmployExpiration() {
+	s.Nil(s.backend.Lock(KEY, []byte("-"), 1500*time.Millisecond))
+	s.Equal(1500*time.Millisecond, s.db.TTL(KEY))
+}
+
+func (s *RedisBackendTestSuite) TestFailedLockShouldPreserveHolderAndExpiration() {
+	s.Nil(s.backend.Lock(KEY, []byte("holder"), 1*time.Second))
+	s.Equal(keyvaluestore.ErrNotAcquired, s.backend.Lock(KEY, []byte("other"), 1*time.Hour))
+	s.db.CheckGet(s.T(), KEY, "holder")
+	s.Equal(1*time.Second, s.db.TTL(KEY))
+}
+
 func (s *RedisBackendTestSuite) TestUnlockShouldReleasePreviouslyLockedKey() {
 	s.Nil(s.backend.Lock(KEY, []byte("-"), 1*time.Second))
 	s.Nil(s.backend.Unlock(KEY))
@@ -154,6 +243,123 @@ func (s *RedisBackendTestSuite) TestAfterUnlockShouldBeLockable() {
 	s.Nil(s.backend.Lock(KEY, []byte("-"), 1*time.Second))
 }
 
+func (s *RedisBackendTestSuite) TestRenewLockShouldExtendExpirationOfHeldLock() {
+	s.Nil(s.backend.Lock(KEY, []byte("token"), 1*time.Second))
+	s.Nil(s.backend.RenewLock(KEY, []byte("token"), 1*time.Hour))
+	s.True(s.db.TTL(KEY) > 59*time.Minute)
+}
+
+func (s *RedisBackendTestSuite) TestRenewLockShouldFailIfLockIsHeldByOthers() {
+	s.Nil(s.backend.Lock(KEY, []byte("other"), 1*time.Second))
+	s.Equal(keyvaluestore.ErrLockLost, s.backend.RenewLock(KEY, []byte("token"), 1*time.Hour))
+	s.True(s.db.TTL(KEY) <= 1*time.Second)
+}
+
+func (s *RedisBackendTestSuite) TestRenewLockShouldFailIfLockHasExpired() {
+	s.Equal(keyvaluestore.ErrLockLost, s.backend.RenewLock(KEY, []byte("token"), 1*time.Hour))
+	s.False(s.db.Exists(KEY))
+}
+
+func (s *RedisBackendTestSuite) TestAcquireShouldAllowUpToMaxHolders() {
+	s.acquire("a", 2, 1*time.Minute)
+	s.acquire("b", 2, 1*time.Minute)
+	_, err := s.backend.Acquire(KEY, "c", 2, 1*time.Minute)
+	s.Equal(keyvaluestore.ErrNotAcquired, err)
+	s.True(s.db.TTL(KEY) > 59*time.Second)
+}
+
+func (s *RedisBackendTestSuite) TestAcquireShouldRenewExistingHolder() {
+	renewed, err := s.backend.Acquire(KEY, "a", 1, 1*time.Minute)
+	s.Nil(err)
+	s.False(renewed)
+
+	renewed, err = s.backend.Acquire(KEY, "a", 1, 1*time.Minute)
+	s.Nil(err)
+	s.True(renewed)
+}
+
+func (s *RedisBackendTestSuite) TestAcquireShouldSucceedAfterRelease() {
+	s.acquire("a", 1, 1*time.Minute)
+	s.Nil(s.backend.Release(KEY, "a"))
+	s.acquire("b", 1, 1*time.Minute)
+}
+
+func (s *RedisBackendTestSuite) TestAcquireShouldIgnoreExpiredHolders() {
+	s.acquire("a", 1, 1*time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	s.acquire("b", 1, 1*time.Minute)
+}
+
+func (s *RedisBackendTestSuite) TestIncrWindowShouldSetExpirationOnlyOnFirstIncrement() {
+	count, err := s.backend.IncrWindow(KEY, 1*time.Minute)
+	s.Nil(err)
+	s.Equal(int64(1), count)
+	s.db.SetTTL(KEY, 10*time.Second)
+
+	count, err = s.backend.IncrWindow(KEY, 1*time.Minute)
+	s.Nil(err)
+	s.Equal(int64(2), count)
+	s.Equal(10*time.Second, s.db.TTL(KEY))
+}
+
+func (s *RedisBackendTestSuite) TestIncrWindowShouldExpireCounterWithoutExpiration() {
+	s.Nil(s.db.Set(KEY, "5"))
+
+	count, err := s.backend.IncrWindow(KEY, 1*time.Minute)
+	s.Nil(err)
+	s.Equal(int64(6), count)
+	s.Equal(1*time.Minute, s.db.TTL(KEY))
+}
+
+func (s *RedisBackendTestSuite) TestStreamRangeShouldReturnEntriesOrderedByID() {
+	second := keyvaluestore.StreamEntry{ID: keyvaluestore.StreamID{Time: 1, Sequence: 256}, Data: []byte(VALUE2)}
+	first := keyvaluestore.StreamEntry{ID: keyvaluestore.StreamID{Time: 1, Sequence: 2}, Data: []byte(VALUE)}
+	third := keyvaluestore.StreamEntry{ID: keyvaluestore.StreamID{Time: 2}, Data: []byte{}}
+	s.Nil(s.backend.StreamAdd(KEY, second))
+	s.Nil(s.backend.StreamAdd(KEY, first))
+	s.Nil(s.backend.StreamAdd(KEY, third))
+
+	entries, err := s.backend.StreamRange(KEY, keyvaluestore.StreamID{}, keyvaluestore.MaxStreamID, 0)
+	s.Nil(err)
+	s.Equal([]keyvaluestore.StreamEntry{first, second, third}, entries)
+
+	entries, err = s.backend.StreamRange(KEY, second.ID, keyvaluestore.MaxStreamID, 1)
+	s.Nil(err)
+	s.Equal([]keyvaluestore.StreamEntry{second}, entries)
+
+	entries, err = s.backend.StreamRange(KEY, keyvaluestore.StreamID{}, second.ID, 0)
+	s.Nil(err)
+	s.Equal([]keyvaluestore.StreamEntry{first, second}, entries)
+}
+
+func (s *RedisBackendTestSuite) TestStreamAddShouldKeepExistingEntryOfSameID() {
+	id := keyvaluestore.StreamID{Time: 1}
+	s.Nil(s.backend.StreamAdd(KEY, keyvaluestore.StreamEntry{ID: id, Data: []byte(VALUE)}))
+	s.Nil(s.backend.StreamAdd(KEY, keyvaluestore.StreamEntry{ID: id, Data: []byte("other")}))
+
+	entries, err := s.backend.StreamRange(KEY, keyvaluestore.StreamID{}, keyvaluestore.MaxStreamID, 0)
+	s.Nil(err)
+	s.Equal([]keyvaluestore.StreamEntry{{ID: id, Data: []byte(VALUE)}}, entries)
+}
+
+func (s *RedisBackendTestSuite) TestStreamRemoveShouldOnlyRemoveEntryOfID() {
+	first := keyvaluestore.StreamEntry{ID: keyvaluestore.StreamID{Time: 1}, Data: []byte(VALUE)}
+	second := keyvaluestore.StreamEntry{ID: keyvaluestore.StreamID{Time: 1, Sequence: 1}, Data: []byte(VALUE2)}
+	s.Nil(s.backend.StreamAdd(KEY, first))
+	s.Nil(s.backend.StreamAdd(KEY, second))
+	s.Nil(s.backend.StreamRemove(KEY, first.ID))
+
+	entries, err := s.backend.StreamRange(KEY, keyvaluestore.StreamID{}, keyvaluestore.MaxStreamID, 0)
+	s.Nil(err)
+	s.Equal([]keyvaluestore.StreamEntry{second}, entries)
+}
+
+func (s *RedisBackendTestSuite) TestStreamRangeShouldReturnNoEntriesIfKeyDoesNotExist() {
+	entries, err := s.backend.StreamRange(KEY, keyvaluestore.StreamID{}, keyvaluestore.MaxStreamID, 0)
+	s.Nil(err)
+	s.Empty(entries)
+}
+
 func (s *RedisBackendTestSuite) TestExistsShouldReturnTrueForExistingKey() {
 	s.Nil(s.db.Set(KEY, VALUE))
 	exists, err := s.backend.Exists(KEY)
@@ -167,6 +373,399 @@ func (s *RedisBackendTestSuite) TestExistsShouldReturnTrueForNonExistingKey() {
 	s.False(exists)
 }
 
+func (s *RedisBackendTestSuite) TestTypeShouldReturnTypeOfKey() {
+	s.Nil(s.db.Set(KEY, VALUE))
+	_, err := s.db.ZAdd(KEY2, 1, "holder")
+	s.Nil(err)
+
+	keyType, err := s.backend.Type(KEY)
+	s.Nil(err)
+	s.Equal("string", keyType)
+	keyType, err = s.backend.Type(KEY2)
+	s.Nil(err)
+	s.Equal("zset", keyType)
+}
+
+func (s *RedisBackendTestSuite) TestTypeShouldReturnNotFoundForNonExistingKey() {
+	_, err := s.backend.Type(KEY)
+	s.Equal(keyvaluestore.ErrNotFound, err)
+}
+
+func (s *RedisBackendTestSuite) TestGetManyShouldReturnOnlyExistingKeys() {
+	s.Nil(s.db.Set(KEY, VALUE))
+	values, err := s.backend.GetMany([]string{KEY, KEY2})
+	s.Nil(err)
+	s.Equal(map[string][]byte{KEY: []byte(VALUE)}, values)
+}
+
+func (s *RedisBackendTestSuite) TestDeleteManyShouldReturnNumberOfDeletedKeys() {
+	s.Nil(s.db.Set(KEY, VALUE))
+	deleted, err := s.backend.DeleteMany([]string{KEY, KEY2})
+	s.Nil(err)
+	s.Equal(int64(1), deleted)
+	s.False(s.db.Exists(KEY))
+}
+
+func (s *RedisBackendTestSuite) TestTransactionShouldApplyAllOperations() {
+	s.Nil(s.db.Set(KEY2, VALUE2))
+	err := s.backend.Transaction([]keyvaluestore.Op{
+		{Type: keyvaluestore.OpSet, Key: KEY, Data: []byte(VALUE), Expiration: time.Minute},
+		{Type: keyvaluestore.OpDelete, Key: KEY2},
+	})
+	s.Nil(err)
+	s.db.CheckGet(s.T(), KEY, VALUE)
+	s.Equal(time.Minute, s.db.TTL(KEY))
+	s.False(s.db.Exists(KEY2))
+}
+
+func (s *RedisBackendTestSuite) TestTransactionWithUnknownOperationShouldApplyNothing() {
+	err := s.backend.Transaction([]keyvaluestore.Op{
+		{Type: keyvaluestore.OpSet, Key: KEY, Data: []byte(VALUE)},
+		{Type: keyvaluestore.OpType(-1), Key: KEY2},
+	})
+	s.Equal(keyvaluestore.ErrInvalidOp, err)
+	s.False(s.db.Exists(KEY))
+}
+
+func (s *RedisBackendTestSuite) TestTransactionOnClusterShouldRejectKeysOfDifferentSlots() {
+	backend := redisBackend.New(redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs: []string{s.db.Addr()},
+	}), "localhost")
+	defer backend.Close()
+
+	err := backend.Transaction([]keyvaluestore.Op{
+		{Type: keyvaluestore.OpSet, Key: "{user1}.name", Data: []byte(VALUE)},
+		{Type: keyvaluestore.OpSet, Key: "{user2}.name", Data: []byte(VALUE)},
+	})
+	s.Equal(keyvaluestore.ErrCrossShard, err)
+}
+
+func (s *RedisBackendTestSuite) TestOptimisticTransactionShouldApplyOpsComputedFromValues() {
+	s.Nil(s.db.Set(KEY, "2"))
+	ops, err := s.backend.OptimisticTransaction([]string{KEY, KEY2},
+		func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			s.Equal(map[string][]byte{KEY: []byte("2")}, values)
+			return []keyvaluestore.Op{
+				{Type: keyvaluestore.OpSet, Key: KEY, Data: []byte("1")},
+				{Type: keyvaluestore.OpSet, Key: KEY2, Data: []byte(VALUE2)},
+			}, nil
+		})
+	s.Nil(err)
+	s.Len(ops, 2)
+	s.db.CheckGet(s.T(), KEY, "1")
+	s.db.CheckGet(s.T(), KEY2, VALUE2)
+}
+
+func (s *RedisBackendTestSuite) TestOptimisticTransactionWithoutOpsShouldKeepValues() {
+	s.Nil(s.db.Set(KEY, VALUE))
+	ops, err := s.backend.OptimisticTransaction([]string{KEY},
+		func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			s.Equal(map[string][]byte{KEY: []byte(VALUE)}, values)
+			return nil, nil
+		})
+	s.Nil(err)
+	s.Empty(ops)
+	s.db.CheckGet(s.T(), KEY, VALUE)
+}
+
+func (s *RedisBackendTestSuite) TestOptimisticTransactionShouldReturnErrorsOfApply() {
+	applyErr := errors.New("out of stock")
+	_, err := s.backend.OptimisticTransaction([]string{KEY},
+		func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			return nil, applyErr
+		})
+	s.Equal(applyErr, err)
+}
+
+func (s *RedisBackendTestSuite) TestScanShouldReturnMatchingKeys() {
+	s.Nil(s.db.Set(KEY, VALUE))
+	s.Nil(s.db.Set(KEY2, VALUE2))
+	s.Nil(s.db.Set("other", VALUE))
+	keys, cursor, err := s.backend.Scan(0, "key*", 10)
+	s.Nil(err)
+	s.Zero(cursor)
+	s.ElementsMatch([]string{KEY, KEY2}, keys)
+}
+
+func (s *RedisBackendTestSuite) TestRandomKeyShouldReturnExistingKey() {
+	_, err := s.backend.RandomKey()
+	s.Equal(keyvaluestore.ErrNotFound, err)
+
+	s.Nil(s.db.Set(KEY, VALUE))
+	key, err := s.backend.RandomKey()
+	s.Nil(err)
+	s.Equal(KEY, key)
+}
+
+func (s *RedisBackendTestSuite) TestGetWithTTLShouldReturnValueAndTTL() {
+	s.Nil(s.backend.Set(KEY, []byte(VALUE), 1*time.Hour))
+	result, err := s.backend.GetWithTTL(KEY)
+	s.Nil(err)
+	s.Equal(VALUE, string(result.Data))
+	s.NotNil(result.TTL)
+	s.True(*result.TTL > 59*time.Minute)
+}
+
+func (s *RedisBackendTestSuite) TestGetWithTTLShouldReturnNilTTLForPersistentKey() {
+	s.Nil(s.db.Set(KEY, VALUE))
+	result, err := s.backend.GetWithTTL(KEY)
+	s.Nil(err)
+	s.Equal(VALUE, string(result.Data))
+	s.Nil(result.TTL)
+}
+
+func (s *RedisBackendTestSuite) TestGetWithTTLShouldReturnNotFoundIfKeyDoesNotExist() {
+	_, err := s.backend.GetWithTTL(KEY)
+	s.Equal(keyvaluestore.ErrNotFound, err)
+}
+
+func (s *RedisBackendTestSuite) TestDBSizeShouldReturnNumberOfKeys() {
+	s.Nil(s.db.Set(KEY, VALUE))
+	s.Nil(s.db.Set(KEY2, VALUE2))
+	size, err := s.backend.DBSize()
+	s.Nil(err)
+	s.Equal(int64(2), size)
+}
+
+func (s *RedisBackendTestSuite) TestDBSizeOnClosedBackendShouldReturnErrClosed() {
+	s.Nil(s.backend.Close())
+	_, err := s.backend.DBSize()
+	s.Equal(keyvaluestore.ErrClosed, err)
+}
+
+func (s *RedisBackendTestSuite) TestPingShouldSucceedOnLiveDatabase() {
+	s.Nil(s.backend.Ping())
+}
+
+func (s *RedisBackendTestSuite) TestPingShouldFailOnClosedDatabase() {
+	s.db.Close()
+	s.NotNil(s.backend.Ping())
+}
+
+func (s *RedisBackendTestSuite) TestWatchShouldFailOnClosedDatabase() {
+	s.db.Close()
+	_, err := s.backend.Watch(context.Background(), KEY)
+	s.NotNil(err)
+}
+
+func (s *RedisBackendTestSuite) TestWatchOnClusterShouldForwardEventsOfEveryMaster() {
+	var masters []*fakeRedis
+	for i := 0; i < 2; i++ {
+		master, err := newFakeRedis("ERR unknown command")
+		s.Nil(err)
+		defer master.Close()
+
+		masters = append(masters, master)
+	}
+
+	backend := redisBackend.New(redis.NewClusterClient(&redis.ClusterOptions{
+		ClusterSlots: func() ([]redis.ClusterSlot, error) {
+			return []redis.ClusterSlot{
+				{Start: 0, End: 8191, Nodes: []redis.ClusterNode{{Addr: masters[0].Addr()}}},
+				{Start: 8192, End: 16383, Nodes: []redis.ClusterNode{{Addr: masters[1].Addr()}}},
+			}, nil
+		},
+	}), "localhost")
+	defer backend.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := backend.Watch(ctx, "*")
+	s.Nil(err)
+
+	masters[0].publish("__keyspace@0__:"+KEY, "set")
+	masters[1].publish("__keyspace@0__:"+KEY2, "del")
+
+	var received []keyvaluestore.Event
+	for len(received) < 2 {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-time.After(time.Second):
+			s.FailNow("missing events", "%v", received)
+		}
+	}
+
+	s.ElementsMatch([]keyvaluestore.Event{
+		{Type: keyvaluestore.EventSet, Key: KEY},
+		{Type: keyvaluestore.EventDelete, Key: KEY2},
+	}, received)
+
+	cancel()
+	for range events {
+	}
+}
+
+func (s *RedisBackendTestSuite) TestCrossSlotRepliesShouldBeReportedAsCrossShard() {
+	node, err := newFakeRedis("CROSSSLOT Keys in request don't hash to the same slot")
+	s.Nil(err)
+	defer node.Close()
+
+	backend := redisBackend.New(redis.NewClient(&redis.Options{Addr: node.Addr()}), "localhost")
+	defer backend.Close()
+
+	_, err = backend.DeleteMany([]string{KEY, KEY2})
+	s.Equal(keyvaluestore.ErrCrossShard, err)
+	_, err = backend.GetMany([]string{KEY, KEY2})
+	s.Equal(keyvaluestore.ErrCrossShard, err)
+}
+
+func (s *RedisBackendTestSuite) TestOtherErrorRepliesShouldNotBeReportedAsCrossShard() {
+	node, err := newFakeRedis("ERR unknown command")
+	s.Nil(err)
+	defer node.Close()
+
+	backend := redisBackend.New(redis.NewClient(&redis.Options{Addr: node.Addr()}), "localhost")
+	defer backend.Close()
+
+	_, err = backend.DeleteMany([]string{KEY, KEY2})
+	s.NotNil(err)
+	s.NotEqual(keyvaluestore.ErrCrossShard, err)
+}
+
+func (s *RedisBackendTestSuite) TestShouldReconnectAfterServerRestart() {
+	client := redis.NewClient(&redis.Options{
+		Addr:            s.db.Addr(),
+		MaxRetries:      3,
+		MinRetryBackoff: time.Millisecond,
+		MaxRetryBackoff: 10 * time.Millisecond,
+	})
+	backend := redisBackend.New(client, "localhost")
+	defer backend.Close()
+
+	s.Nil(backend.Set(KEY, []byte(VALUE), 0))
+
+	s.db.Close()
+	s.NotNil(backend.Ping())
+	s.Nil(s.db.Restart())
+
+	value, err := backend.Get(KEY)
+	s.Nil(err)
+	s.Equal(VALUE, string(value))
+}
+
+func (s *RedisBackendTestSuite) TestShouldClassifyLogicalErrors() {
+	client := redis.NewClient(&redis.Options{Addr: s.db.Addr()})
+	backend := redisBackend.New(client, "logical-node")
+	defer backend.Close()
+
+	s.Nil(backend.Set(KEY, []byte(VALUE), 0))
+	s.NotNil(client.LPush(KEY, VALUE).Err())
+
+	_, err := backend.Get(KEY2)
+	s.Equal(keyvaluestore.ErrNotFound, err)
+
+	s.Equal(1.0, s.nodeErrors("logical-node", metrics.ErrorClassLogical))
+}
+
+func (s *RedisBackendTestSuite) TestShouldClassifyAuthErrors() {
+	backend := redisBackend.New(redis.NewClient(&redis.Options{Addr: s.db.Addr()}), "auth-node")
+	defer backend.Close()
+
+	s.db.RequireAuth("secret")
+	s.NotNil(backend.Ping())
+	s.Equal(1.0, s.nodeErrors("auth-node", metrics.ErrorClassAuth))
+}
+
+func (s *RedisBackendTestSuite) TestShouldClassifyRefusedConnections() {
+	backend := redisBackend.New(redis.NewClient(&redis.Options{Addr: s.db.Addr()}), "refused-node")
+	defer backend.Close()
+
+	s.db.Close()
+	s.NotNil(backend.Ping())
+	s.Equal(1.0, s.nodeErrors("refused-node", metrics.ErrorClassConnectionRefused))
+}
+
+func (s *RedisBackendTestSuite) TestShouldClassifyTimeouts() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().Nil(err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	backend := redisBackend.New(redis.NewClient(&redis.Options{
+		Addr:        listener.Addr().String(),
+		ReadTimeout: 10 * time.Millisecond,
+	}), "slow-node")
+	defer backend.Close()
+
+	s.NotNil(backend.Ping())
+	s.Equal(1.0, s.nodeErrors("slow-node", metrics.ErrorClassTimeout))
+}
+
+func (s *RedisBackendTestSuite) TestShouldRecordCommandDurationsByNodeAndCommand() {
+	backend := redisBackend.New(redis.NewClient(&redis.Options{Addr: s.db.Addr()}), "timed-node")
+	defer backend.Close()
+
+	s.Nil(backend.Set(KEY, []byte(VALUE), 0))
+	_, err := backend.Get(KEY)
+	s.Nil(err)
+	_, err = backend.Get(KEY2)
+	s.Equal(keyvaluestore.ErrNotFound, err)
+
+	s.Equal(uint64(1), s.commandCount("timed-node", "set"))
+	s.Equal(uint64(2), s.commandCount("timed-node", "get"))
+}
+
+func (s *RedisBackendTestSuite) acquire(holder string, maxHolders int, expiration time.Duration) {
+	_, err := s.backend.Acquire(KEY, holder, maxHolders, expiration)
+	s.Nil(err)
+}
+
+func (s *RedisBackendTestSuite) commandCount(node string, command string) uint64 {
+	var metric dto.Metric
+	s.Nil(metrics.NodeCommandDuration.WithLabelValues(node, command).(prometheus.Histogram).Write(&metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+func (s *RedisBackendTestSuite) nodeErrors(node string, class string) float64 {
+	return testutil.ToFloat64(metrics.NodeErrorsTotal.WithLabelValues(node, class))
+}
+
+func (s *RedisBackendTestSuite) TestDialerShouldConnectToRedis() {
+	client := redis.NewClient(&redis.Options{
+		Addr:   s.db.Addr(),
+		Dialer: redisBackend.NewDialer(s.db.Addr(), time.Second, time.Second, false),
+	})
+	backend := redisBackend.New(client, "localhost")
+	defer backend.Close()
+
+	s.Nil(backend.Set(KEY, []byte(VALUE), 0))
+	s.db.CheckGet(s.T(), KEY, VALUE)
+}
+
+func (s *RedisBackendTestSuite) TestDialerShouldFailIfRedisIsNotListening() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().Nil(err)
+	address := listener.Addr().String()
+	s.Nil(listener.Close())
+
+	_, err = redisBackend.NewDialer(address, time.Second, -1, true)()
+	s.NotNil(err)
+}
+
+func (s *RedisBackendTestSuite) TestShouldUseSelectedDatabase() {
+	s.Nil(s.db.Set(KEY2, VALUE2))
+
+	backend := redisBackend.New(redis.NewClient(&redis.Options{Addr: s.db.Addr(), DB: 2}), "localhost")
+	defer backend.Close()
+
+	s.Nil(backend.Set(KEY, []byte(VALUE), 0))
+	s.Equal([]string{KEY}, s.db.DB(2).Keys())
+	s.False(s.db.Exists(KEY))
+
+	s.Nil(backend.FlushDB())
+	s.Empty(s.db.DB(2).Keys())
+	s.True(s.db.Exists(KEY2))
+}
+
 func (s *RedisBackendTestSuite) SetupTest() {
 	var err error
 
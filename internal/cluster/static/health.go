@@ -0,0 +1,106 @@
+package static
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// healthTracker periodically pings backends and keeps track of the ones
+// which are known to be down. Backends are considered up until proven
+// otherwise.
+type healthTracker struct {
+	mutex  sync.RWMutex
+	down   map[keyvaluestore.Backend]bool
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		down:   make(map[keyvaluestore.Backend]bool),
+		closed: make(chan struct{}),
+	}
+}
+
+// start polls nodes returned by backends, which is called upon every poll so
+// that nodes added to the cluster are monitored as well.
+func (h *healthTracker) start(backends func() []keyvaluestore.Backend, interval time.Duration) {
+	h.poll(backends())
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.closed:
+				return
+
+			case <-ticker.C:
+				h.poll(backends())
+			}
+		}
+	}()
+}
+
+func (h *healthTracker) poll(backends []keyvaluestore.Backend) {
+	var wg sync.WaitGroup
+
+	for _, backend := range backends {
+		wg.Add(1)
+		go func(backend keyvaluestore.Backend) {
+			defer wg.Done()
+			h.update(backend, backend.Ping())
+		}(backend)
+	}
+
+	wg.Wait()
+}
+
+func (h *healthTracker) update(backend keyvaluestore.Backend, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	wasDown := h.down[backend]
+
+	switch {
+	case err != nil && !wasDown:
+		logrus.WithError(err).WithField("node", backend.Address()).Warn("node is down")
+		h.down[backend] = true
+
+	case err == nil && wasDown:
+		logrus.WithField("node", backend.Address()).Info("node is up again")
+		delete(h.down, backend)
+	}
+}
+
+func (h *healthTracker) forget(backend keyvaluestore.Backend) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.down, backend)
+}
+
+func (h *healthTracker) isUp(backend keyvaluestore.Backend) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return !h.down[backend]
+}
+
+func (h *healthTracker) close() {
+	select {
+	case <-h.closed:
+	default:
+		close(h.closed)
+	}
+
+	h.wg.Wait()
+}
@@ -2,6 +2,10 @@ package static
 
 import (
 	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -14,9 +18,23 @@ var (
 )
 
 type staticCluster struct {
+	// lock guards membership, i.e. backends, weights, readOnly and
+	// localIndex. backends is never modified in place, as views share it.
+	lock          sync.RWMutex
 	local         keyvaluestore.Backend
 	backends      []keyvaluestore.Backend
 	readOnePolicy keyvaluestore.Policy
+	weights       map[keyvaluestore.Backend]int
+	cursor        *uint64
+	readAllRepair bool
+	health        *healthTracker
+	healthCheck   time.Duration
+	writeUpNodes  bool
+	readOnly      map[keyvaluestore.Backend]bool
+	preferLocal   bool
+	localIndex    int
+	quorumMode    keyvaluestore.QuorumMode
+	minWritable   int
 }
 
 type Option func(s *staticCluster)
@@ -27,6 +45,83 @@ func WithLocal(local keyvaluestore.Backend) Option {
 	}
 }
 
+// WithWeight makes random single-node reads pick backend proportional to
+// weight. Backends without a weight have a weight of 1.
+func WithWeight(backend keyvaluestore.Backend, weight int) Option {
+	return func(s *staticCluster) {
+		if weight < 0 {
+			logrus.WithField("weight", weight).Panic("negative node weight")
+		}
+
+		if s.weights == nil {
+			s.weights = make(map[keyvaluestore.Backend]int)
+		}
+
+		s.weights[backend] = weight
+	}
+}
+
+// WithReadAllRepair enables read-repair for reads under PolicyReadAll,
+// which only reports divergence by default.
+func WithReadAllRepair(repair bool) Option {
+	return func(s *staticCluster) {
+		s.readAllRepair = repair
+	}
+}
+
+// WithQuorumMode decides how many of an even number of nodes make up the
+// majority of Majority reads and writes.
+func WithQuorumMode(mode keyvaluestore.QuorumMode) Option {
+	return func(s *staticCluster) {
+		s.quorumMode = mode
+	}
+}
+
+// WithMinWritableNodes keeps RemoveNode from leaving fewer writable nodes than
+// count, e.g. the replicas which fixed consistencies such as Three require.
+func WithMinWritableNodes(count int) Option {
+	return func(s *staticCluster) {
+		s.minWritable = count
+	}
+}
+
+// WithHealthCheckInterval pings every backend periodically. Backends which
+// fail to respond are avoided by single-node reads until they recover.
+func WithHealthCheckInterval(interval time.Duration) Option {
+	return func(s *staticCluster) {
+		s.healthCheck = interval
+	}
+}
+
+// WithWritesToUpNodesOnly drops nodes known to be down from write views and
+// computes the required acknowledgements over the remaining nodes.
+func WithWritesToUpNodesOnly(writeUpNodes bool) Option {
+	return func(s *staticCluster) {
+		s.writeUpNodes = writeUpNodes
+	}
+}
+
+// WithReadOnly marks backend as a read-only replica, which serves reads but
+// is never written to. Write quorums are computed over writable nodes only.
+func WithReadOnly(backend keyvaluestore.Backend) Option {
+	return func(s *staticCluster) {
+		if s.readOnly == nil {
+			s.readOnly = make(map[keyvaluestore.Backend]bool)
+		}
+
+		s.readOnly[backend] = true
+	}
+}
+
+// WithPreferLocalReads makes **One** reads served by the local node alone.
+// Other reads only reach the node of the same address through the local
+// connection instead, which does not change the nodes they vote among.
+func WithPreferLocalReads(prefer bool) Option {
+	return func(s *staticCluster) {
+		s.preferLocal = prefer
+	}
+}
+
 func WithPolicy(policy keyvaluestore.Policy) Option {
 	return func(s *staticCluster) {
 		switch policy {
@@ -36,6 +131,12 @@ func WithPolicy(policy keyvaluestore.Policy) Option {
 		case keyvaluestore.PolicyReadOneLocalOrRandomNode:
 			s.readOnePolicy = policy
 
+		case keyvaluestore.PolicyReadOneRoundRobin:
+			s.readOnePolicy = policy
+
+		case keyvaluestore.PolicyReadAll:
+			s.readOnePolicy = policy
+
 		default:
 			logrus.WithField("policy", policy).Panic("unknown cluster policy")
 		}
@@ -43,49 +144,105 @@ func WithPolicy(policy keyvaluestore.Policy) Option {
 }
 
 func New(backends []keyvaluestore.Backend, options ...Option) keyvaluestore.Cluster {
-	result := staticCluster{
+	result := &staticCluster{
 		backends:      backends,
 		readOnePolicy: defaultReadOnePolicy,
+		cursor:        new(uint64),
+		health:        newHealthTracker(),
+		localIndex:    -1,
 	}
 
 	for _, option := range options {
-		option(&result)
+		option(result)
+	}
+
+	if result.preferLocal && result.local != nil {
+		result.localIndex = result.indexOfLocal()
+		if result.localIndex < 0 {
+			logrus.WithField("address", result.local.Address()).
+				Warn("local connection is not among the nodes, quorum reads will not prefer it")
+		} else if result.readOnly[backends[result.localIndex]] {
+			WithReadOnly(result.local)(result)
+		}
+	}
+
+	if result.healthCheck > 0 {
+		result.health.start(result.currentMonitoredNodes, result.healthCheck)
 	}
 
 	return result
 }
 
-func (s staticCluster) Read(key string,
+func (s *staticCluster) Read(key string,
 	consistency keyvaluestore.ConsistencyLevel) (keyvaluestore.ReadClusterView, error) {
 
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
 	votingMode, err := s.readVotingMode(consistency)
 	if err != nil {
 		return keyvaluestore.ReadClusterView{}, err
 	}
 
+	if err := s.checkAvailable(key, s.monitoredNodes()); err != nil {
+		return keyvaluestore.ReadClusterView{}, err
+	}
+
 	switch consistency {
 	case keyvaluestore.ConsistencyLevel_ALL:
-		allNodes := s.allNodes()
+		allNodes := s.readNodes()
 		return keyvaluestore.ReadClusterView{
 			Backends:     allNodes,
 			VoteRequired: len(allNodes),
 			VotingMode:   votingMode,
+			ReadOnly:     s.readOnlyNodes(),
 		}, nil
 
 	case keyvaluestore.ConsistencyLevel_MAJORITY:
-		allNodes := s.allNodes()
+		allNodes := s.readNodes()
 		return keyvaluestore.ReadClusterView{
 			Backends:     allNodes,
 			VoteRequired: s.majority(len(allNodes)),
 			VotingMode:   votingMode,
+			ReadOnly:     s.readOnlyNodes(),
+		}, nil
+
+	case keyvaluestore.ConsistencyLevel_TWO, keyvaluestore.ConsistencyLevel_THREE:
+		allNodes := s.readNodes()
+		required := s.fixedReplicas(consistency)
+		if required > len(allNodes) {
+			return keyvaluestore.ReadClusterView{}, keyvaluestore.ErrConsistency
+		}
+
+		return keyvaluestore.ReadClusterView{
+			Backends:     allNodes,
+			VoteRequired: required,
+			VotingMode:   votingMode,
+			ReadOnly:     s.readOnlyNodes(),
 		}, nil
 
 	case keyvaluestore.ConsistencyLevel_ONE:
+		if s.readOnePolicy == keyvaluestore.PolicyReadAll {
+			return keyvaluestore.ReadClusterView{
+				Backends:     s.readNodes(),
+				VoteRequired: 1,
+				VotingMode:   votingMode,
+				SkipRepair:   !s.readAllRepair,
+				ReadOnly:     s.readOnlyNodes(),
+			}, nil
+		}
+
 		var nodes []keyvaluestore.Backend
 
-		switch s.readOnePolicy {
-		case keyvaluestore.PolicyReadOneFirstAvailable:
-			nodes = s.allNodes()
+		switch {
+		case s.readOnePolicy == keyvaluestore.PolicyReadOneFirstAvailable:
+			nodes = s.readNodes()
+
+		case s.prefersLocal():
+			nodes = []keyvaluestore.Backend{s.local}
+
+		case s.readOnePolicy == keyvaluestore.PolicyReadOneRoundRobin:
+			nodes = s.nextNode()
 
 		default:
 			nodes = s.localNodeOrRandomNode()
@@ -95,6 +252,7 @@ func (s staticCluster) Read(key string,
 			Backends:     nodes,
 			VoteRequired: 1,
 			VotingMode:   votingMode,
+			ReadOnly:     s.readOnlyNodes(),
 		}, nil
 
 	default:
@@ -102,7 +260,7 @@ func (s staticCluster) Read(key string,
 	}
 }
 
-func (s staticCluster) readVotingMode(
+func (s *staticCluster) readVotingMode(
 	consistency keyvaluestore.ConsistencyLevel) (keyvaluestore.VotingMode, error) {
 
 	switch consistency {
@@ -112,6 +270,9 @@ func (s staticCluster) readVotingMode(
 	case keyvaluestore.ConsistencyLevel_MAJORITY:
 		return keyvaluestore.VotingModeVoteOnNotFound, nil
 
+	case keyvaluestore.ConsistencyLevel_TWO, keyvaluestore.ConsistencyLevel_THREE:
+		return keyvaluestore.VotingModeVoteOnNotFound, nil
+
 	case keyvaluestore.ConsistencyLevel_ONE:
 		switch s.readOnePolicy {
 		case keyvaluestore.PolicyReadOneLocalOrRandomNode:
@@ -120,6 +281,12 @@ func (s staticCluster) readVotingMode(
 		case keyvaluestore.PolicyReadOneFirstAvailable:
 			return keyvaluestore.VotingModeSkipVoteOnNotFound, nil
 
+		case keyvaluestore.PolicyReadOneRoundRobin:
+			return keyvaluestore.VotingModeVoteOnNotFound, nil
+
+		case keyvaluestore.PolicyReadAll:
+			return keyvaluestore.VotingModeVoteOnNotFound, nil
+
 		default:
 			return 0, errors.Errorf("unknown readone policy: %v", s.readOnePolicy)
 		}
@@ -129,10 +296,20 @@ func (s staticCluster) readVotingMode(
 	}
 }
 
-func (s staticCluster) Write(key string,
+func (s *staticCluster) Write(key string,
 	consistency keyvaluestore.ConsistencyLevel) (keyvaluestore.WriteClusterView, error) {
 
-	allNodes := s.allNodes()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if err := s.checkAvailable(key, s.writableNodes()); err != nil {
+		return keyvaluestore.WriteClusterView{}, err
+	}
+
+	allNodes := s.randomize(s.writableNodes())
+	if s.writeUpNodes {
+		allNodes = s.randomize(s.upNodesOf(s.writableNodes()))
+	}
 
 	switch consistency {
 	case keyvaluestore.ConsistencyLevel_ALL:
@@ -147,6 +324,17 @@ func (s staticCluster) Write(key string,
 			AcknowledgeRequired: s.majority(len(allNodes)),
 		}, nil
 
+	case keyvaluestore.ConsistencyLevel_TWO, keyvaluestore.ConsistencyLevel_THREE:
+		required := s.fixedReplicas(consistency)
+		if required > len(allNodes) {
+			return keyvaluestore.WriteClusterView{}, keyvaluestore.ErrConsistency
+		}
+
+		return keyvaluestore.WriteClusterView{
+			Backends:            allNodes,
+			AcknowledgeRequired: required,
+		}, nil
+
 	case keyvaluestore.ConsistencyLevel_ONE:
 		return keyvaluestore.WriteClusterView{
 			Backends:            allNodes,
@@ -158,15 +346,23 @@ func (s staticCluster) Write(key string,
 	}
 }
 
-func (s staticCluster) FlushDB() (keyvaluestore.WriteClusterView, error) {
-	allNodes := s.allNodes()
+func (s *staticCluster) FlushDB() (keyvaluestore.WriteClusterView, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	allNodes := s.randomize(s.writableNodes())
 	return keyvaluestore.WriteClusterView{
 		Backends:            allNodes,
 		AcknowledgeRequired: len(allNodes),
 	}, nil
 }
 
-func (s staticCluster) Close() error {
+func (s *staticCluster) Close() error {
+	s.health.close()
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
 	var lastErr error
 
 	if s.local != nil {
@@ -186,19 +382,270 @@ func (s staticCluster) Close() error {
 	return lastErr
 }
 
-func (s staticCluster) localNodeOrRandomNode() []keyvaluestore.Backend {
-	if s.local != nil {
+func (s *staticCluster) Nodes() []keyvaluestore.Backend {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return append([]keyvaluestore.Backend{}, s.backends...)
+}
+
+func (s *staticCluster) AddNode(backend keyvaluestore.Backend) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.indexOf(backend.Address()) >= 0 {
+		return keyvaluestore.ErrNodeExists
+	}
+
+	s.backends = append(append([]keyvaluestore.Backend{}, s.backends...), backend)
+	s.updateLocalIndex()
+
+	logrus.WithField("node", backend.Address()).Info("node added to the cluster")
+	return nil
+}
+
+// RemoveNode refuses to remove a writable node if the remaining writable
+// nodes would not make up a majority of the current ones, so that the
+// remaining nodes still form a quorum of the current membership.
+func (s *staticCluster) RemoveNode(address string) error {
+	s.lock.Lock()
+
+	index := s.indexOf(address)
+	if index < 0 {
+		s.lock.Unlock()
+		return keyvaluestore.ErrUnknownNode
+	}
+
+	backend := s.backends[index]
+	if !s.readOnly[backend] {
+		writable := len(s.writableNodes())
+		if writable-1 < s.majority(writable) {
+			s.lock.Unlock()
+			return keyvaluestore.ErrQuorumLoss
+		}
+		if writable-1 < s.minWritable {
+			s.lock.Unlock()
+			return keyvaluestore.ErrTooFewReplicas
+		}
+	}
+
+	backends := append([]keyvaluestore.Backend{}, s.backends[:index]...)
+	s.backends = append(backends, s.backends[index+1:]...)
+	delete(s.weights, backend)
+	delete(s.readOnly, backend)
+	s.updateLocalIndex()
+	s.lock.Unlock()
+
+	s.health.forget(backend)
+
+	logrus.WithField("node", address).Info("node removed from the cluster")
+
+	if backend != s.local {
+		if err := backend.Close(); err != nil {
+			logrus.WithError(err).WithField("node", address).Error("unexpected error while closing removed node")
+		}
+	}
+
+	return nil
+}
+
+func (s *staticCluster) indexOf(address string) int {
+	for i, backend := range s.backends {
+		if backend.Address() == address {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *staticCluster) updateLocalIndex() {
+	if s.preferLocal && s.local != nil {
+		s.localIndex = s.indexOfLocal()
+	}
+}
+
+func (s *staticCluster) localNodeOrRandomNode() []keyvaluestore.Backend {
+	if s.local != nil && s.health.isUp(s.local) {
 		return []keyvaluestore.Backend{s.local}
 	}
 
-	return s.allNodes()[:1]
+	candidates := s.upNodes()
+
+	if len(s.weights) > 0 {
+		return s.weightedRandomNode(candidates)
+	}
+
+	return s.randomize(candidates)[:1]
+}
+
+func (s *staticCluster) nextNode() []keyvaluestore.Backend {
+	if len(s.backends) == 0 {
+		return nil
+	}
+
+	var index uint64
+	for i := 0; i < len(s.backends); i++ {
+		index = (atomic.AddUint64(s.cursor, 1) - 1) % uint64(len(s.backends))
+		if s.health.isUp(s.backends[index]) {
+			break
+		}
+	}
+
+	return []keyvaluestore.Backend{s.backends[index]}
+}
+
+// upNodes returns backends which are not known to be down. If every backend
+// is down, all of them are returned as there is nothing better to try.
+func (s *staticCluster) upNodes() []keyvaluestore.Backend {
+	return s.upNodesOf(s.backends)
+}
+
+func (s *staticCluster) upNodesOf(backends []keyvaluestore.Backend) []keyvaluestore.Backend {
+	var result []keyvaluestore.Backend
+
+	for _, backend := range backends {
+		if s.health.isUp(backend) {
+			result = append(result, backend)
+		}
+	}
+
+	if len(result) == 0 {
+		return backends
+	}
+
+	return result
+}
+
+// checkAvailable fails fast if every node owning a key is known to be down,
+// rather than letting the request wait for nodes which will not answer.
+func (s *staticCluster) checkAvailable(key string, backends []keyvaluestore.Backend) error {
+	if len(backends) == 0 {
+		return nil
+	}
+
+	addresses := make([]string, 0, len(backends))
+	for _, backend := range backends {
+		if s.health.isUp(backend) {
+			return nil
+		}
+
+		addresses = append(addresses, backend.Address())
+	}
+	sort.Strings(addresses)
+
+	return &keyvaluestore.NoAvailableNodesError{Key: key, Nodes: addresses}
+}
+
+func (s *staticCluster) writableNodes() []keyvaluestore.Backend {
+	if len(s.readOnly) == 0 {
+		return s.backends
+	}
+
+	var result []keyvaluestore.Backend
+	for _, backend := range s.backends {
+		if !s.readOnly[backend] {
+			result = append(result, backend)
+		}
+	}
+
+	return result
+}
+
+func (s *staticCluster) readOnlyNodes() []keyvaluestore.Backend {
+	var result []keyvaluestore.Backend
+	for backend := range s.readOnly {
+		result = append(result, backend)
+	}
+
+	return result
+}
+
+func (s *staticCluster) currentMonitoredNodes() []keyvaluestore.Backend {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.monitoredNodes()
+}
+
+func (s *staticCluster) monitoredNodes() []keyvaluestore.Backend {
+	result := append([]keyvaluestore.Backend{}, s.backends...)
+
+	if s.local != nil {
+		for _, backend := range s.backends {
+			if backend == s.local {
+				return result
+			}
+		}
+
+		result = append(result, s.local)
+	}
+
+	return result
 }
 
-func (s staticCluster) allNodes() []keyvaluestore.Backend {
+func (s *staticCluster) weightedRandomNode(backends []keyvaluestore.Backend) []keyvaluestore.Backend {
+	total := 0
+	for _, backend := range backends {
+		total += s.weight(backend)
+	}
+
+	if total == 0 {
+		return s.randomize(backends)[:1]
+	}
+
+	pick := rand.Intn(total)
+	for _, backend := range backends {
+		pick -= s.weight(backend)
+		if pick < 0 {
+			return []keyvaluestore.Backend{backend}
+		}
+	}
+
+	return backends[len(backends)-1:]
+}
+
+func (s *staticCluster) weight(backend keyvaluestore.Backend) int {
+	if weight, ok := s.weights[backend]; ok {
+		return weight
+	}
+
+	return 1
+}
+
+func (s *staticCluster) allNodes() []keyvaluestore.Backend {
 	return s.randomize(s.backends)
 }
 
-func (s staticCluster) randomize(backends []keyvaluestore.Backend) []keyvaluestore.Backend {
+func (s *staticCluster) prefersLocal() bool {
+	return s.preferLocal && s.local != nil && s.health.isUp(s.local)
+}
+
+// readNodes returns every node in random order, except that the local node
+// comes first in place of its remote counterpart if reads prefer it.
+func (s *staticCluster) readNodes() []keyvaluestore.Backend {
+	if !s.prefersLocal() || s.localIndex < 0 {
+		return s.allNodes()
+	}
+
+	others := append([]keyvaluestore.Backend{}, s.backends[:s.localIndex]...)
+	others = append(others, s.backends[s.localIndex+1:]...)
+
+	return append([]keyvaluestore.Backend{s.local}, s.randomize(others)...)
+}
+
+func (s *staticCluster) indexOfLocal() int {
+	address := s.local.Address()
+	for i, backend := range s.backends {
+		if backend == s.local || backend.Address() == address {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (s *staticCluster) randomize(backends []keyvaluestore.Backend) []keyvaluestore.Backend {
 	result := append([]keyvaluestore.Backend{}, backends...)
 
 	for i := 0; i < len(result); i++ {
@@ -211,6 +658,19 @@ func (s staticCluster) randomize(backends []keyvaluestore.Backend) []keyvaluesto
 	return result
 }
 
-func (s staticCluster) majority(count int) int {
-	return (count / 2) + 1
+func (s *staticCluster) fixedReplicas(consistency keyvaluestore.ConsistencyLevel) int {
+	switch consistency {
+	case keyvaluestore.ConsistencyLevel_TWO:
+		return 2
+
+	case keyvaluestore.ConsistencyLevel_THREE:
+		return 3
+
+	default:
+		return 1
+	}
+}
+
+func (s *staticCluster) majority(count int) int {
+	return s.quorumMode.Majority(count)
 }
@@ -1,9 +1,13 @@
 package static_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/cafebazaar/keyvalue-store/internal/cluster/static"
+	"github.com/cafebazaar/keyvalue-store/internal/engine"
+	"github.com/cafebazaar/keyvalue-store/internal/voting"
 	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
 	"github.com/stretchr/testify/suite"
 )
@@ -52,6 +56,24 @@ func (s *StaticClusterTestSuite) TestReadVoteShouldCountLocalConnection() {
 	s.Equal(3, view.VoteRequired)
 }
 
+func (s *StaticClusterTestSuite) TestReadVoteShouldReturnTwoForConsistencyTwo() {
+	view, err := s.makeCluster(3, false).Read("", keyvaluestore.ConsistencyLevel_TWO)
+	s.Nil(err)
+	s.Equal(2, view.VoteRequired)
+	s.Equal(3, len(view.Backends))
+}
+
+func (s *StaticClusterTestSuite) TestReadVoteShouldReturnThreeForConsistencyThree() {
+	view, err := s.makeCluster(4, false).Read("", keyvaluestore.ConsistencyLevel_THREE)
+	s.Nil(err)
+	s.Equal(3, view.VoteRequired)
+}
+
+func (s *StaticClusterTestSuite) TestReadShouldFailIfConsistencyRequiresMoreNodesThanExist() {
+	_, err := s.makeCluster(2, false).Read("", keyvaluestore.ConsistencyLevel_THREE)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+}
+
 func (s *StaticClusterTestSuite) TestWriteAcknowledgeShouldReturnNodeCountForConsistencyAll() {
 	view, err := s.makeCluster(3, false).Write("", keyvaluestore.ConsistencyLevel_ALL)
 	s.Nil(err)
@@ -70,6 +92,33 @@ func (s *StaticClusterTestSuite) TestWriteAcknowledgeShouldReturnMajorityWithOdd
 	s.Equal(3, view.AcknowledgeRequired)
 }
 
+func (s *StaticClusterTestSuite) TestMajorityShouldDependOnQuorumMode() {
+	cases := []struct {
+		nodes  int
+		mode   keyvaluestore.QuorumMode
+		quorum int
+	}{
+		{nodes: 2, mode: keyvaluestore.QuorumModeStrictMajority, quorum: 2},
+		{nodes: 3, mode: keyvaluestore.QuorumModeStrictMajority, quorum: 2},
+		{nodes: 4, mode: keyvaluestore.QuorumModeStrictMajority, quorum: 3},
+		{nodes: 2, mode: keyvaluestore.QuorumModeHalf, quorum: 1},
+		{nodes: 3, mode: keyvaluestore.QuorumModeHalf, quorum: 2},
+		{nodes: 4, mode: keyvaluestore.QuorumModeHalf, quorum: 2},
+	}
+
+	for _, c := range cases {
+		cluster := s.makeCluster(c.nodes, false, static.WithQuorumMode(c.mode))
+
+		readView, err := cluster.Read("", keyvaluestore.ConsistencyLevel_MAJORITY)
+		s.Nil(err)
+		s.Equal(c.quorum, readView.VoteRequired, "nodes: %v, mode: %v", c.nodes, c.mode)
+
+		writeView, err := cluster.Write("", keyvaluestore.ConsistencyLevel_MAJORITY)
+		s.Nil(err)
+		s.Equal(c.quorum, writeView.AcknowledgeRequired, "nodes: %v, mode: %v", c.nodes, c.mode)
+	}
+}
+
 func (s *StaticClusterTestSuite) TestWriteAcknowledgeShouldReturnOneWithConsistencyOne() {
 	view, err := s.makeCluster(3, false).Write("", keyvaluestore.ConsistencyLevel_ONE)
 	s.Nil(err)
@@ -82,6 +131,18 @@ func (s *StaticClusterTestSuite) TestWriteAcknowledgeShouldCountLocalConnection(
 	s.Equal(2, view.AcknowledgeRequired)
 }
 
+func (s *StaticClusterTestSuite) TestWriteAcknowledgeShouldReturnTwoWithConsistencyTwo() {
+	view, err := s.makeCluster(3, false).Write("", keyvaluestore.ConsistencyLevel_TWO)
+	s.Nil(err)
+	s.Equal(2, view.AcknowledgeRequired)
+	s.Equal(3, len(view.Backends))
+}
+
+func (s *StaticClusterTestSuite) TestWriteShouldFailIfConsistencyRequiresMoreNodesThanExist() {
+	_, err := s.makeCluster(1, false).Write("", keyvaluestore.ConsistencyLevel_TWO)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+}
+
 func (s *StaticClusterTestSuite) TestWriteBackendsShouldReturnAllNodesInConsistencyAll() {
 	view, err := s.makeCluster(3, false).Write("", keyvaluestore.ConsistencyLevel_ALL)
 	s.Nil(err)
@@ -226,6 +287,37 @@ func (s *StaticClusterTestSuite) TestReadOneLocalOrRandomNodePolicyShouldApplyTo
 	s.Equal(keyvaluestore.VotingModeVoteOnNotFound, policyView.VotingMode)
 }
 
+func (s *StaticClusterTestSuite) TestReadOneRoundRobinPolicyShouldCycleThroughNodes() {
+	cluster := s.makeCluster(3, false,
+		static.WithPolicy(keyvaluestore.PolicyReadOneRoundRobin))
+	var picked []keyvaluestore.Backend
+	for i := 0; i < 4; i++ {
+		view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ONE)
+		s.Nil(err)
+		s.Equal(1, len(view.Backends))
+		s.Equal(keyvaluestore.VotingModeVoteOnNotFound, view.VotingMode)
+		picked = append(picked, view.Backends[0])
+	}
+	s.Equal([]keyvaluestore.Backend{s.node1, s.node2, s.node3, s.node1}, picked)
+}
+
+func (s *StaticClusterTestSuite) TestReadAllPolicyShouldReadAllNodesWithoutRepair() {
+	view, err := s.makeCluster(3, false,
+		static.WithPolicy(keyvaluestore.PolicyReadAll)).Read("", keyvaluestore.ConsistencyLevel_ONE)
+	s.Nil(err)
+	s.Equal(3, len(view.Backends))
+	s.Equal(1, view.VoteRequired)
+	s.True(view.SkipRepair)
+}
+
+func (s *StaticClusterTestSuite) TestReadAllPolicyShouldRepairIfEnabled() {
+	view, err := s.makeCluster(3, false,
+		static.WithPolicy(keyvaluestore.PolicyReadAll),
+		static.WithReadAllRepair(true)).Read("", keyvaluestore.ConsistencyLevel_ONE)
+	s.Nil(err)
+	s.False(view.SkipRepair)
+}
+
 func (s *StaticClusterTestSuite) TestReadOneConsistencyAllShouldHaveDefaultVotingMode() {
 	policyView, err := s.makeCluster(3, true).Read("", keyvaluestore.ConsistencyLevel_ALL)
 	s.Nil(err)
@@ -267,6 +359,313 @@ func (s *StaticClusterTestSuite) TestReadBackendsShouldReturnOnlyLocalConnection
 	s.Equal(s.local, view.Backends[0])
 }
 
+func (s *StaticClusterTestSuite) TestReadOneShouldQueryOnlyHealthyLocalNode() {
+	s.mockHealth(s.local, nil)
+	s.mockHealth(s.node1, nil)
+	s.mockHealth(s.node2, nil)
+	s.mockHealth(s.node3, nil)
+	s.local.(*keyvaluestore.Mock_Backend).On("Get", "key").Once().Return([]byte("value"), nil)
+	cluster := s.makeCluster(3, true, static.WithHealthCheckInterval(time.Hour))
+	defer cluster.Close()
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	for i := 0; i < 5; i++ {
+		view, err := cluster.Read("key", keyvaluestore.ConsistencyLevel_ONE)
+		s.Nil(err)
+		s.Len(view.Backends, 1)
+		s.Same(s.local, view.Backends[0])
+	}
+
+	view, err := cluster.Read("key", keyvaluestore.ConsistencyLevel_ONE)
+	s.Nil(err)
+	value, err := realEngine.Read(view.Backends, view.VoteRequired, func(node keyvaluestore.Backend) (interface{}, error) {
+		return node.Get("key")
+	}, nil, func(x, y interface{}) bool {
+		return string(x.([]byte)) == string(y.([]byte))
+	}, view.VotingMode, nil)
+	s.Nil(err)
+	s.Equal([]byte("value"), value)
+
+	s.local.(*keyvaluestore.Mock_Backend).AssertNumberOfCalls(s.T(), "Get", 1)
+	for _, node := range []keyvaluestore.Backend{s.node1, s.node2, s.node3} {
+		node.(*keyvaluestore.Mock_Backend).AssertNotCalled(s.T(), "Get", "key")
+	}
+}
+
+func (s *StaticClusterTestSuite) TestReadOneShouldNeverPickZeroWeightNode() {
+	cluster := s.makeCluster(2, false,
+		static.WithWeight(s.node1, 0),
+		static.WithWeight(s.node2, 1))
+	for i := 0; i < 20; i++ {
+		view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ONE)
+		s.Nil(err)
+		s.Equal(1, len(view.Backends))
+		s.Equal(s.node2, view.Backends[0])
+	}
+}
+
+func (s *StaticClusterTestSuite) TestReadOneShouldPreferHeavierNodes() {
+	cluster := s.makeCluster(2, false,
+		static.WithWeight(s.node1, 1),
+		static.WithWeight(s.node2, 9))
+	picks := map[keyvaluestore.Backend]int{}
+	for i := 0; i < 1000; i++ {
+		view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ONE)
+		s.Nil(err)
+		picks[view.Backends[0]]++
+	}
+	s.True(picks[s.node2] > 3*picks[s.node1])
+}
+
+func (s *StaticClusterTestSuite) TestReadOneShouldAvoidNodesWhichAreDown() {
+	s.mockHealth(s.node1, errors.New("connection refused"))
+	s.mockHealth(s.node2, nil)
+	cluster := s.makeCluster(2, false, static.WithHealthCheckInterval(time.Hour))
+	defer cluster.Close()
+
+	for i := 0; i < 20; i++ {
+		view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ONE)
+		s.Nil(err)
+		s.Equal([]keyvaluestore.Backend{s.node2}, view.Backends)
+	}
+}
+
+func (s *StaticClusterTestSuite) TestReadOneShouldAvoidLocalNodeIfDown() {
+	s.mockHealth(s.local, errors.New("connection refused"))
+	s.mockHealth(s.node1, nil)
+	cluster := s.makeCluster(1, true, static.WithHealthCheckInterval(time.Hour))
+	defer cluster.Close()
+
+	view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ONE)
+	s.Nil(err)
+	s.Equal([]keyvaluestore.Backend{s.node1}, view.Backends)
+}
+
+func (s *StaticClusterTestSuite) TestReadOneRoundRobinPolicyShouldSkipNodesWhichAreDown() {
+	s.mockHealth(s.node1, nil)
+	s.mockHealth(s.node2, errors.New("connection refused"))
+	s.mockHealth(s.node3, nil)
+	cluster := s.makeCluster(3, false,
+		static.WithPolicy(keyvaluestore.PolicyReadOneRoundRobin),
+		static.WithHealthCheckInterval(time.Hour))
+	defer cluster.Close()
+
+	var picked []keyvaluestore.Backend
+	for i := 0; i < 3; i++ {
+		view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ONE)
+		s.Nil(err)
+		picked = append(picked, view.Backends[0])
+	}
+	s.Equal([]keyvaluestore.Backend{s.node1, s.node3, s.node1}, picked)
+}
+
+func (s *StaticClusterTestSuite) TestShouldFailFastIfEveryNodeIsDown() {
+	s.mockAddress(s.node1, "node1")
+	s.mockAddress(s.node2, "node2")
+	s.mockHealth(s.node1, errors.New("connection refused"))
+	s.mockHealth(s.node2, errors.New("connection refused"))
+	cluster := s.makeCluster(2, false, static.WithHealthCheckInterval(time.Hour))
+	defer cluster.Close()
+
+	_, err := cluster.Read("key", keyvaluestore.ConsistencyLevel_ONE)
+	s.Equal(&keyvaluestore.NoAvailableNodesError{Key: "key", Nodes: []string{"node1", "node2"}}, err)
+
+	_, err = cluster.Write("key", keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.Equal(&keyvaluestore.NoAvailableNodesError{Key: "key", Nodes: []string{"node1", "node2"}}, err)
+}
+
+func (s *StaticClusterTestSuite) TestShouldNotFailFastIfSomeNodeIsUp() {
+	s.mockHealth(s.node1, errors.New("connection refused"))
+	s.mockHealth(s.node2, nil)
+	cluster := s.makeCluster(2, false, static.WithHealthCheckInterval(time.Hour))
+	defer cluster.Close()
+
+	_, err := cluster.Read("key", keyvaluestore.ConsistencyLevel_ALL)
+	s.Nil(err)
+
+	_, err = cluster.Write("key", keyvaluestore.ConsistencyLevel_ALL)
+	s.Nil(err)
+}
+
+func (s *StaticClusterTestSuite) TestWriteShouldKeepNodesWhichAreDownByDefault() {
+	s.mockHealth(s.node1, errors.New("connection refused"))
+	s.mockHealth(s.node2, nil)
+	s.mockHealth(s.node3, nil)
+	cluster := s.makeCluster(3, false, static.WithHealthCheckInterval(time.Hour))
+	defer cluster.Close()
+
+	view, err := cluster.Write("", keyvaluestore.ConsistencyLevel_ALL)
+	s.Nil(err)
+	s.Equal(3, len(view.Backends))
+	s.Equal(3, view.AcknowledgeRequired)
+}
+
+func (s *StaticClusterTestSuite) TestWriteToUpNodesOnlyShouldRecomputeQuorum() {
+	s.mockHealth(s.node1, errors.New("connection refused"))
+	s.mockHealth(s.node2, nil)
+	s.mockHealth(s.node3, nil)
+	cluster := s.makeCluster(3, false,
+		static.WithHealthCheckInterval(time.Hour),
+		static.WithWritesToUpNodesOnly(true))
+	defer cluster.Close()
+
+	view, err := cluster.Write("", keyvaluestore.ConsistencyLevel_ALL)
+	s.Nil(err)
+	s.ElementsMatch([]keyvaluestore.Backend{s.node2, s.node3}, view.Backends)
+	s.Equal(2, view.AcknowledgeRequired)
+
+	view, err = cluster.Write("", keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.Nil(err)
+	s.Equal(2, view.AcknowledgeRequired)
+
+	_, err = cluster.Write("", keyvaluestore.ConsistencyLevel_THREE)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+}
+
+func (s *StaticClusterTestSuite) TestWriteShouldExcludeReadOnlyNodes() {
+	cluster := s.makeCluster(3, false, static.WithReadOnly(s.node3))
+
+	view, err := cluster.Write("", keyvaluestore.ConsistencyLevel_ALL)
+	s.Nil(err)
+	s.ElementsMatch([]keyvaluestore.Backend{s.node1, s.node2}, view.Backends)
+	s.Equal(2, view.AcknowledgeRequired)
+
+	view, err = cluster.Write("", keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.Nil(err)
+	s.Equal(2, view.AcknowledgeRequired)
+
+	_, err = cluster.Write("", keyvaluestore.ConsistencyLevel_THREE)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+
+	view, err = cluster.FlushDB()
+	s.Nil(err)
+	s.ElementsMatch([]keyvaluestore.Backend{s.node1, s.node2}, view.Backends)
+}
+
+func (s *StaticClusterTestSuite) TestReadShouldIncludeReadOnlyNodes() {
+	cluster := s.makeCluster(3, false, static.WithReadOnly(s.node3))
+
+	view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ALL)
+	s.Nil(err)
+	s.ElementsMatch([]keyvaluestore.Backend{s.node1, s.node2, s.node3}, view.Backends)
+	s.Equal(3, view.VoteRequired)
+	s.Equal([]keyvaluestore.Backend{s.node3}, view.ReadOnly)
+}
+
+func (s *StaticClusterTestSuite) TestHealthCheckShouldNoticeRecoveredNodes() {
+	node1 := s.node1.(*keyvaluestore.Mock_Backend)
+	node1.On("Ping").Once().Return(errors.New("connection refused"))
+	s.mockHealth(s.node1, nil)
+	s.mockHealth(s.node2, nil)
+	cluster := s.makeCluster(2, false, static.WithHealthCheckInterval(5*time.Millisecond))
+	defer cluster.Close()
+
+	s.Eventually(func() bool {
+		view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ONE)
+		return err == nil && view.Backends[0] == s.node1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func (s *StaticClusterTestSuite) TestWriteToUpNodesOnlyShouldReaddRecoveredNodes() {
+	node1 := s.node1.(*keyvaluestore.Mock_Backend)
+	node1.On("Ping").Once().Return(errors.New("connection refused"))
+	s.mockHealth(s.node1, nil)
+	s.mockHealth(s.node2, nil)
+	cluster := s.makeCluster(2, false,
+		static.WithHealthCheckInterval(5*time.Millisecond),
+		static.WithWritesToUpNodesOnly(true))
+	defer cluster.Close()
+
+	s.Eventually(func() bool {
+		view, err := cluster.Write("", keyvaluestore.ConsistencyLevel_ALL)
+		return err == nil && len(view.Backends) == 2 && view.AcknowledgeRequired == 2
+	}, time.Second, 5*time.Millisecond)
+}
+
+func (s *StaticClusterTestSuite) TestRemoveNodeShouldKeepMinWritableNodes() {
+	s.mockAddresses()
+	cluster := s.makeCluster(3, false, static.WithMinWritableNodes(3))
+
+	s.Equal(keyvaluestore.ErrTooFewReplicas, cluster.RemoveNode("node1"))
+	s.Equal([]keyvaluestore.Backend{s.node1, s.node2, s.node3}, cluster.Nodes())
+}
+
+func (s *StaticClusterTestSuite) TestRemoveNodeShouldAllowRemovingReadOnlyNodesBelowQuorum() {
+	s.mockAddresses()
+	s.node3.(*keyvaluestore.Mock_Backend).On("Close").Return(nil)
+	cluster := s.makeCluster(3, false, static.WithReadOnly(s.node3))
+
+	s.Equal(keyvaluestore.ErrQuorumLoss, cluster.RemoveNode("node1"))
+	s.Nil(cluster.RemoveNode("node3"))
+	s.Equal([]keyvaluestore.Backend{s.node1, s.node2}, cluster.Nodes())
+
+	view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ALL)
+	s.Nil(err)
+	s.Empty(view.ReadOnly)
+}
+
+func (s *StaticClusterTestSuite) TestHealthCheckShouldMonitorAddedNodes() {
+	s.mockAddresses()
+	s.node1.(*keyvaluestore.Mock_Backend).On("Ping").Return(nil)
+	s.node2.(*keyvaluestore.Mock_Backend).On("Ping").Return(errors.New("connection refused"))
+	s.node1.(*keyvaluestore.Mock_Backend).On("Close").Return(nil)
+	s.node2.(*keyvaluestore.Mock_Backend).On("Close").Return(nil)
+	cluster := s.makeCluster(1, false,
+		static.WithHealthCheckInterval(5*time.Millisecond),
+		static.WithWritesToUpNodesOnly(true))
+	defer cluster.Close()
+
+	s.Nil(cluster.AddNode(s.node2))
+	s.Equal(keyvaluestore.ErrNodeExists, cluster.AddNode(s.node2))
+
+	s.Eventually(func() bool {
+		view, err := cluster.Write("", keyvaluestore.ConsistencyLevel_ALL)
+		return err == nil && len(view.Backends) == 1 && view.Backends[0] == s.node1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func (s *StaticClusterTestSuite) TestPreferLocalReadsShouldReplaceLocalCounterpartInQuorumReads() {
+	s.mockAddresses()
+	cluster := s.makeCluster(3, true, static.WithPreferLocalReads(true))
+
+	for _, consistency := range []keyvaluestore.ConsistencyLevel{
+		keyvaluestore.ConsistencyLevel_ALL,
+		keyvaluestore.ConsistencyLevel_MAJORITY,
+		keyvaluestore.ConsistencyLevel_TWO,
+	} {
+		view, err := cluster.Read("", consistency)
+		s.Nil(err)
+		s.Equal(3, len(view.Backends))
+		s.Equal(s.local, view.Backends[0])
+		s.ElementsMatch([]keyvaluestore.Backend{s.local, s.node1, s.node3}, view.Backends)
+	}
+}
+
+func (s *StaticClusterTestSuite) TestPreferLocalReadsShouldReadOneFromLocalRegardlessOfPolicy() {
+	s.mockAddresses()
+	cluster := s.makeCluster(3, true, static.WithPreferLocalReads(true),
+		static.WithPolicy(keyvaluestore.PolicyReadOneRoundRobin))
+
+	for i := 0; i < 5; i++ {
+		view, err := cluster.Read("", keyvaluestore.ConsistencyLevel_ONE)
+		s.Nil(err)
+		s.Equal([]keyvaluestore.Backend{s.local}, view.Backends)
+	}
+}
+
+func (s *StaticClusterTestSuite) TestPreferLocalReadsShouldKeepNodesIfLocalIsNotAmongThem() {
+	s.mockAddress(s.local, "local")
+	s.mockAddress(s.node1, "node1")
+	s.mockAddress(s.node2, "node2")
+
+	view, err := s.makeCluster(2, true, static.WithPreferLocalReads(true)).
+		Read("", keyvaluestore.ConsistencyLevel_ALL)
+	s.Nil(err)
+	s.ElementsMatch([]keyvaluestore.Backend{s.node1, s.node2}, view.Backends)
+}
+
 func (s *StaticClusterTestSuite) TestCloseShouldCloseAllBackends() {
 	node := &keyvaluestore.Mock_Backend{}
 	node.On("Close").Once().Return(nil)
@@ -285,6 +684,26 @@ func (s *StaticClusterTestSuite) TestCloseShouldCloseLocalConnectionIfProvided()
 	node.AssertExpectations(s.T())
 }
 
+func (s *StaticClusterTestSuite) mockHealth(backend keyvaluestore.Backend, err error) {
+	node := backend.(*keyvaluestore.Mock_Backend)
+	node.On("Ping").Return(err)
+	node.On("Address").Return("node")
+	node.On("Close").Return(nil)
+}
+
+func (s *StaticClusterTestSuite) mockAddress(backend keyvaluestore.Backend, address string) {
+	backend.(*keyvaluestore.Mock_Backend).On("Address").Return(address)
+}
+
+// mockAddresses makes the local connection point to the same node as node2.
+func (s *StaticClusterTestSuite) mockAddresses() {
+	s.mockAddress(s.local, "node2")
+	s.mockAddress(s.node1, "node1")
+	s.mockAddress(s.node2, "node2")
+	s.mockAddress(s.node3, "node3")
+	s.mockAddress(s.node4, "node4")
+}
+
 func (s *StaticClusterTestSuite) makeCluster(nodes int, local bool,
 	clusterOptions ...static.Option) keyvaluestore.Cluster {
 
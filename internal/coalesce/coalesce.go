@@ -0,0 +1,283 @@
+package coalesce
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// batch is a write of key which is delayed until the window passes, during
+// which later compatible writes of the key replace its request.
+type batch struct {
+	ctx     context.Context
+	request *keyvaluestore.SetRequest
+	done    chan struct{}
+	err     error
+	flush   sync.Once
+}
+
+type coalescingService struct {
+	keyvaluestore.Service
+
+	window   time.Duration
+	prefixes []string
+
+	lock    sync.Mutex
+	pending map[string]*batch
+}
+
+type Option func(s *coalescingService)
+
+// WithKeyPrefix restricts coalescing to keys starting with prefix. It may be
+// given multiple times, and without it, writes of every key are coalesced.
+func WithKeyPrefix(prefix string) Option {
+	return func(s *coalescingService) {
+		s.prefixes = append(s.prefixes, prefix)
+	}
+}
+
+// New wraps service so that Set requests of the same key arriving within
+// window are written once, with the value of the last of them. Every
+// coalesced caller gets the result of that single write. Since intermediate
+// values are never written, this is only safe for keys whose writers rely on
+// last-write-wins semantics, e.g. hot counters and caches.
+func New(service keyvaluestore.Service, window time.Duration, options ...Option) keyvaluestore.Service {
+	result := &coalescingService{
+		Service: service,
+		window:  window,
+		pending: make(map[string]*batch),
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	return result
+}
+
+func (s *coalescingService) Set(ctx context.Context, request *keyvaluestore.SetRequest) error {
+	if !s.coalescable(request) {
+		s.flushKeys(request.Key)
+		return s.Service.Set(ctx, request)
+	}
+
+	metrics.BufferedWritesTotal.Inc()
+
+	s.lock.Lock()
+	pending, ok := s.pending[request.Key]
+	if ok && pending.request.Options == request.Options {
+		pending.ctx = ctx
+		pending.request = request
+		s.lock.Unlock()
+
+		metrics.CoalescedWritesTotal.Inc()
+		return s.wait(ctx, pending)
+	}
+
+	if ok {
+		// Writes of different options cannot share a batch, e.g. a write of
+		// consistency ALL must not succeed by a MAJORITY one.
+		s.lock.Unlock()
+		s.flush(pending)
+		return s.Service.Set(ctx, request)
+	}
+
+	created := &batch{
+		ctx:     ctx,
+		request: request,
+		done:    make(chan struct{}),
+	}
+	s.pending[request.Key] = created
+	s.lock.Unlock()
+
+	go s.flushAfterWindow(created)
+
+	return s.wait(ctx, created)
+}
+
+func (s *coalescingService) MSet(ctx context.Context, request *keyvaluestore.MSetRequest) error {
+	for _, item := range request.Items {
+		s.flushKeys(item.Key)
+	}
+
+	return s.Service.MSet(ctx, request)
+}
+
+func (s *coalescingService) Delete(ctx context.Context, request *keyvaluestore.DeleteRequest) error {
+	s.flushKeys(request.Key)
+	return s.Service.Delete(ctx, request)
+}
+
+func (s *coalescingService) DeleteMany(ctx context.Context,
+	request *keyvaluestore.DeleteManyRequest) (*keyvaluestore.DeleteManyResponse, error) {
+
+	s.flushKeys(request.Keys...)
+	return s.Service.DeleteMany(ctx, request)
+}
+
+func (s *coalescingService) Transaction(ctx context.Context, request *keyvaluestore.TransactionRequest) error {
+	for _, op := range request.Ops {
+		s.flushKeys(op.Key)
+	}
+
+	return s.Service.Transaction(ctx, request)
+}
+
+func (s *coalescingService) Optimistic(ctx context.Context, request *keyvaluestore.OptimisticRequest) error {
+	s.flushKeys(request.Keys...)
+	return s.Service.Optimistic(ctx, request)
+}
+
+func (s *coalescingService) Import(ctx context.Context,
+	request *keyvaluestore.ImportRequest) (*keyvaluestore.ImportResponse, error) {
+
+	s.flushAll()
+	return s.Service.Import(ctx, request)
+}
+
+func (s *coalescingService) DeletePattern(ctx context.Context,
+	request *keyvaluestore.DeletePatternRequest) (*keyvaluestore.DeletePatternResponse, error) {
+
+	s.flushAll()
+	return s.Service.DeletePattern(ctx, request)
+}
+
+func (s *coalescingService) Lock(ctx context.Context, request *keyvaluestore.LockRequest) error {
+	s.flushKeys(request.Key)
+	return s.Service.Lock(ctx, request)
+}
+
+func (s *coalescingService) Unlock(ctx context.Context, request *keyvaluestore.UnlockRequest) error {
+	s.flushKeys(request.Key)
+	return s.Service.Unlock(ctx, request)
+}
+
+func (s *coalescingService) RenewLock(ctx context.Context, request *keyvaluestore.RenewLockRequest) error {
+	s.flushKeys(request.Key)
+	return s.Service.RenewLock(ctx, request)
+}
+
+func (s *coalescingService) Acquire(ctx context.Context, request *keyvaluestore.AcquireRequest) error {
+	s.flushKeys(request.Key)
+	return s.Service.Acquire(ctx, request)
+}
+
+func (s *coalescingService) Release(ctx context.Context, request *keyvaluestore.ReleaseRequest) error {
+	s.flushKeys(request.Key)
+	return s.Service.Release(ctx, request)
+}
+
+func (s *coalescingService) Expire(ctx context.Context,
+	request *keyvaluestore.ExpireRequest) (*keyvaluestore.ExpireResponse, error) {
+
+	s.flushKeys(request.Key)
+	return s.Service.Expire(ctx, request)
+}
+
+func (s *coalescingService) Touch(ctx context.Context,
+	request *keyvaluestore.TouchRequest) (*keyvaluestore.TouchResponse, error) {
+
+	s.flushKeys(request.Key)
+	return s.Service.Touch(ctx, request)
+}
+
+func (s *coalescingService) StreamAdd(ctx context.Context,
+	request *keyvaluestore.StreamAddRequest) (*keyvaluestore.StreamAddResponse, error) {
+
+	s.flushKeys(request.Key)
+	return s.Service.StreamAdd(ctx, request)
+}
+
+func (s *coalescingService) FlushDB(ctx context.Context,
+	request *keyvaluestore.FlushDBRequest) (*keyvaluestore.FlushDBResponse, error) {
+
+	s.flushAll()
+	return s.Service.FlushDB(ctx, request)
+}
+
+func (s *coalescingService) flushAfterWindow(pending *batch) {
+	time.Sleep(s.window)
+	s.flush(pending)
+}
+
+// flush writes pending right away, unless it has been written already, and
+// returns once it has been written. Other writes of its key flush it first,
+// so that the delayed write does not overwrite them.
+func (s *coalescingService) flush(pending *batch) {
+	pending.flush.Do(func() {
+		s.lock.Lock()
+		if s.pending[pending.request.Key] == pending {
+			delete(s.pending, pending.request.Key)
+		}
+		ctx, request := pending.ctx, pending.request
+		s.lock.Unlock()
+
+		// The write is shared by every coalesced caller, so it must not fail
+		// because the last one of them has gone away
+		detached := keyvaluestore.NewCorrelationContext(context.Background(),
+			keyvaluestore.CorrelationIDFromContext(ctx))
+
+		pending.err = s.Service.Set(detached, request)
+		close(pending.done)
+	})
+}
+
+func (s *coalescingService) flushKeys(keys ...string) {
+	for _, key := range keys {
+		s.lock.Lock()
+		pending, ok := s.pending[key]
+		s.lock.Unlock()
+
+		if ok {
+			s.flush(pending)
+		}
+	}
+}
+
+func (s *coalescingService) flushAll() {
+	s.lock.Lock()
+	var batches []*batch
+	for _, pending := range s.pending {
+		batches = append(batches, pending)
+	}
+	s.lock.Unlock()
+
+	for _, pending := range batches {
+		s.flush(pending)
+	}
+}
+
+func (s *coalescingService) wait(ctx context.Context, pending *batch) error {
+	select {
+	case <-pending.done:
+		return pending.err
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// coalescable excludes requests whose result is specific to themselves.
+func (s *coalescingService) coalescable(request *keyvaluestore.SetRequest) bool {
+	if request.Options.IdempotencyKey != "" || request.Options.DryRun != nil ||
+		request.Acknowledgement != nil || request.Version != 0 {
+
+		return false
+	}
+
+	if len(s.prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(request.Key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,197 @@
+package coalesce_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/internal/coalesce"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	KEY    = "counter"
+	WINDOW = 100 * time.Millisecond
+)
+
+type CoalesceTestSuite struct {
+	suite.Suite
+
+	service *keyvaluestore.Mock_Service
+}
+
+func TestCoalesceTestSuite(t *testing.T) {
+	suite.Run(t, new(CoalesceTestSuite))
+}
+
+func (s *CoalesceTestSuite) SetupTest() {
+	s.service = &keyvaluestore.Mock_Service{}
+}
+
+func (s *CoalesceTestSuite) TestShouldWriteLastValueOnceForWritesWithinWindow() {
+	s.service.On("Set", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.SetRequest) bool {
+		return string(request.Data) == "3"
+	})).Once().Return(nil)
+
+	errs := s.setConcurrently(coalesce.New(s.service, WINDOW),
+		s.request(KEY, "1"), s.request(KEY, "2"), s.request(KEY, "3"))
+
+	s.Equal([]error{nil, nil, nil}, errs)
+	s.service.AssertNumberOfCalls(s.T(), "Set", 1)
+}
+
+func (s *CoalesceTestSuite) TestShouldReturnErrorOfWriteToEveryCoalescedCaller() {
+	writeErr := errors.New("some error")
+	s.service.On("Set", mock.Anything, mock.Anything).Once().Return(writeErr)
+
+	errs := s.setConcurrently(coalesce.New(s.service, WINDOW), s.request(KEY, "1"), s.request(KEY, "2"))
+
+	s.Equal([]error{writeErr, writeErr}, errs)
+}
+
+func (s *CoalesceTestSuite) TestShouldNotCoalesceWritesOfDifferentKeys() {
+	s.service.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	s.setConcurrently(coalesce.New(s.service, WINDOW), s.request(KEY, "1"), s.request("other", "2"))
+
+	s.service.AssertNumberOfCalls(s.T(), "Set", 2)
+}
+
+func (s *CoalesceTestSuite) TestShouldNotCoalesceWritesOfDifferentConsistency() {
+	s.service.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	strong := s.request(KEY, "2")
+	strong.Options.Consistency = keyvaluestore.ConsistencyLevel_ALL
+	s.setConcurrently(coalesce.New(s.service, WINDOW), s.request(KEY, "1"), strong)
+
+	s.service.AssertNumberOfCalls(s.T(), "Set", 2)
+}
+
+func (s *CoalesceTestSuite) TestShouldNotCoalesceIdempotentWrites() {
+	s.service.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	idempotent := s.request(KEY, "2")
+	idempotent.Options.IdempotencyKey = "token"
+	s.setConcurrently(coalesce.New(s.service, WINDOW), s.request(KEY, "1"), idempotent)
+
+	s.service.AssertNumberOfCalls(s.T(), "Set", 2)
+}
+
+func (s *CoalesceTestSuite) TestShouldOnlyCoalesceKeysWithGivenPrefix() {
+	s.service.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	start := time.Now()
+	svc := coalesce.New(s.service, time.Hour, coalesce.WithKeyPrefix("hot:"))
+	s.Nil(svc.Set(context.Background(), s.request(KEY, "1")))
+
+	s.True(time.Since(start) < time.Hour)
+	s.service.AssertNumberOfCalls(s.T(), "Set", 1)
+}
+
+func (s *CoalesceTestSuite) TestWaitingCallerShouldGiveUpUponContextCancellation() {
+	s.service.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := coalesce.New(s.service, WINDOW).Set(ctx, s.request(KEY, "1"))
+	s.Equal(context.DeadlineExceeded, err)
+}
+
+func (s *CoalesceTestSuite) TestDelayedWriteShouldOutliveCallerGivingUp() {
+	written := make(chan struct{})
+	s.service.On("Set", mock.MatchedBy(func(ctx context.Context) bool {
+		return ctx.Err() == nil
+	}), mock.Anything).Once().Run(func(mock.Arguments) {
+		close(written)
+	}).Return(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	s.Equal(context.DeadlineExceeded, coalesce.New(s.service, WINDOW).Set(ctx, s.request(KEY, "1")))
+	<-written
+}
+
+func (s *CoalesceTestSuite) TestOtherWritesShouldFlushPendingWriteOfKeyFirst() {
+	var lock sync.Mutex
+	var calls []string
+	record := func(call string) func(mock.Arguments) {
+		return func(mock.Arguments) {
+			lock.Lock()
+			defer lock.Unlock()
+			calls = append(calls, call)
+		}
+	}
+	s.service.On("Set", mock.Anything, mock.Anything).Run(record("set")).Return(nil)
+	s.service.On("Delete", mock.Anything, mock.Anything).Run(record("delete")).Return(nil)
+
+	svc := coalesce.New(s.service, time.Hour)
+	done := make(chan error)
+	go func() {
+		done <- svc.Set(context.Background(), s.request(KEY, "1"))
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	s.Nil(svc.Delete(context.Background(), &keyvaluestore.DeleteRequest{Key: KEY}))
+	s.Nil(<-done)
+
+	lock.Lock()
+	defer lock.Unlock()
+	s.Equal([]string{"set", "delete"}, calls)
+}
+
+func (s *CoalesceTestSuite) TestUncoalescableWriteShouldFlushPendingWriteOfKeyFirst() {
+	s.service.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	svc := coalesce.New(s.service, time.Hour)
+	done := make(chan error)
+	go func() {
+		done <- svc.Set(context.Background(), s.request(KEY, "1"))
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	idempotent := s.request(KEY, "2")
+	idempotent.Options.IdempotencyKey = "token"
+	s.Nil(svc.Set(context.Background(), idempotent))
+	s.Nil(<-done)
+
+	s.service.AssertNumberOfCalls(s.T(), "Set", 2)
+	s.Equal(idempotent, s.service.Calls[1].Arguments.Get(1))
+}
+
+func (s *CoalesceTestSuite) request(key string, data string) *keyvaluestore.SetRequest {
+	return &keyvaluestore.SetRequest{
+		Key:  key,
+		Data: []byte(data),
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+		},
+	}
+}
+
+// setConcurrently sends requests in order, a few milliseconds apart, and
+// returns their results once all of them are done.
+func (s *CoalesceTestSuite) setConcurrently(svc keyvaluestore.Service,
+	requests ...*keyvaluestore.SetRequest) []error {
+
+	var wg sync.WaitGroup
+	result := make([]error, len(requests))
+
+	for i, request := range requests {
+		wg.Add(1)
+		go func(i int, request *keyvaluestore.SetRequest) {
+			defer wg.Done()
+			result[i] = svc.Set(context.Background(), request)
+		}(i, request)
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	wg.Wait()
+	return result
+}
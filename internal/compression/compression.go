@@ -0,0 +1,41 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+var magic = []byte{0x00, 'K', 'V', 'Z', 0x01}
+
+// Compress gzips data and prepends a header marking it as compressed.
+func Compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.Write(magic)
+
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Decompress reverses Compress. Data which has not been compressed is
+// returned as is.
+func Decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, magic) {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data[len(magic):]))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(reader)
+}
@@ -0,0 +1,42 @@
+package compression_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cafebazaar/keyvalue-store/internal/compression"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompressionTestSuite struct {
+	suite.Suite
+}
+
+func TestCompressionTestSuite(t *testing.T) {
+	suite.Run(t, new(CompressionTestSuite))
+}
+
+func (s *CompressionTestSuite) TestDecompressShouldReverseCompress() {
+	data := []byte(strings.Repeat("hello", 100))
+	compressed, err := compression.Compress(data)
+	s.Nil(err)
+	s.True(len(compressed) < len(data))
+
+	decompressed, err := compression.Decompress(compressed)
+	s.Nil(err)
+	s.Equal(data, decompressed)
+}
+
+func (s *CompressionTestSuite) TestDecompressShouldReturnRawDataAsIs() {
+	decompressed, err := compression.Decompress([]byte("hello"))
+	s.Nil(err)
+	s.Equal("hello", string(decompressed))
+}
+
+func (s *CompressionTestSuite) TestDecompressShouldFailOnCorruptData() {
+	compressed, err := compression.Compress([]byte("hello"))
+	s.Nil(err)
+
+	_, err = compression.Decompress(compressed[:len(compressed)-4])
+	s.NotNil(err)
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// readBatch holds keys of GetMany which share the nodes of their read view,
+// so that each node is read once for all of them instead of once per key.
+// Keys are still read one by one through the usual path, voting and repairs
+// included, whose Get is served from the batch of the node.
+type readBatch struct {
+	keys   []string
+	member map[string]bool
+
+	lock  sync.Mutex
+	nodes map[keyvaluestore.Backend]*nodeBatch
+}
+
+type nodeBatch struct {
+	lock    sync.Mutex
+	fetched bool
+	values  map[string][]byte
+}
+
+type readBatchKey struct{}
+
+func newReadBatch() *readBatch {
+	return &readBatch{
+		member: make(map[string]bool),
+		nodes:  make(map[keyvaluestore.Backend]*nodeBatch),
+	}
+}
+
+func (b *readBatch) add(key string) {
+	if !b.member[key] {
+		b.member[key] = true
+		b.keys = append(b.keys, key)
+	}
+}
+
+func withReadBatch(ctx context.Context, batch *readBatch) context.Context {
+	return context.WithValue(ctx, readBatchKey{}, batch)
+}
+
+// batchedGet reads key from node through the read batch of ctx, if key
+// belongs to it, or on its own otherwise.
+func batchedGet(ctx context.Context, node keyvaluestore.Backend, key string) ([]byte, error) {
+	batch, _ := ctx.Value(readBatchKey{}).(*readBatch)
+	if batch == nil || !batch.member[key] {
+		return node.Get(key)
+	}
+
+	return batch.get(node, key)
+}
+
+// get reads every key of the batch from node on first use. Failures are not
+// kept, so that a retry reads the node again.
+func (b *readBatch) get(node keyvaluestore.Backend, key string) ([]byte, error) {
+	origin := keyvaluestore.Origin(node)
+
+	b.lock.Lock()
+	current := b.nodes[origin]
+	if current == nil {
+		current = &nodeBatch{}
+		b.nodes[origin] = current
+	}
+	b.lock.Unlock()
+
+	current.lock.Lock()
+	defer current.lock.Unlock()
+
+	if !current.fetched {
+		values, err := node.GetMany(b.keys)
+		if err != nil {
+			return nil, err
+		}
+
+		current.values = values
+		current.fetched = true
+	}
+
+	value, ok := current.values[key]
+	if !ok {
+		return nil, keyvaluestore.ErrNotFound
+	}
+
+	return value, nil
+}
+
+// readBatches groups keys by the nodes of their read view. Keys alone in
+// their group, or whose view is not available, are read on their own.
+func (s *coreService) readBatches(keys []string, options keyvaluestore.ReadOptions) map[string]*readBatch {
+	type group struct {
+		nodes []keyvaluestore.Backend
+		batch *readBatch
+	}
+
+	var groups []*group
+	consistency := s.readConsistency(s.operationReadOptions(OperationGet, options))
+
+	for _, key := range keys {
+		view, err := s.readView(key, consistency, options.RequiredNodes)
+		if err != nil {
+			continue
+		}
+
+		var current *group
+		for _, candidate := range groups {
+			if s.sameNodes(candidate.nodes, view.Backends) {
+				current = candidate
+				break
+			}
+		}
+
+		if current == nil {
+			current = &group{nodes: view.Backends, batch: newReadBatch()}
+			groups = append(groups, current)
+		}
+
+		current.batch.add(key)
+	}
+
+	result := make(map[string]*readBatch, len(keys))
+	for _, current := range groups {
+		if len(current.batch.keys) < 2 {
+			continue
+		}
+
+		for _, key := range current.batch.keys {
+			result[key] = current.batch
+		}
+	}
+
+	return result
+}
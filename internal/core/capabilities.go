@@ -0,0 +1,46 @@
+package core
+
+import (
+	"context"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// supportedOperations lists the methods of the service, named as in
+// operationConsistency and rate limits.
+var supportedOperations = []string{
+	"set", "mset", "get", "getmany", "delete", "deletemany", "deletepattern",
+	"transaction", "optimistic", "import", "export", "lock", "unlock", "renewlock",
+	"acquire", "release", "exists", "getttl", "expire", "touch", "ratelimitcheck",
+	"streamadd", "streamrange", "flushdb", "stats", "capabilities", "inspect", "watch",
+}
+
+var supportedConsistencyLevels = []keyvaluestore.ConsistencyLevel{
+	keyvaluestore.ConsistencyLevel_ONE,
+	keyvaluestore.ConsistencyLevel_TWO,
+	keyvaluestore.ConsistencyLevel_THREE,
+	keyvaluestore.ConsistencyLevel_MAJORITY,
+	keyvaluestore.ConsistencyLevel_ALL,
+}
+
+func (s *coreService) Capabilities(ctx context.Context) (*keyvaluestore.CapabilitiesResponse, error) {
+	result := &keyvaluestore.CapabilitiesResponse{
+		Operations:              append([]string(nil), supportedOperations...),
+		DefaultReadConsistency:  s.defaultReadConsistency.String(),
+		DefaultWriteConsistency: s.defaultWriteConsistency.String(),
+		Nodes:                   len(s.cluster.Nodes()),
+		ValueVersioning:         s.valueVersioning,
+		ValueMetadata:           s.valueMetadata,
+		Compression:             s.compression,
+		Encryption:              s.keyring != nil,
+		SessionConsistency:      s.sessions != nil,
+		MaxKeyBytes:             s.maxKeyBytes,
+		MaxValueBytes:           s.maxValueBytes,
+	}
+
+	for _, consistency := range supportedConsistencyLevels {
+		result.ConsistencyLevels = append(result.ConsistencyLevels, consistency.String())
+	}
+
+	return result, nil
+}
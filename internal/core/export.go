@@ -0,0 +1,246 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cafebazaar/go-redisproto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// exportCursor is the position of an export, i.e. the SCAN cursor of a node
+// of the read view sorted by address.
+type exportCursor struct {
+	node int
+	scan uint64
+}
+
+func (c exportCursor) String() string {
+	return fmt.Sprintf("%d:%d", c.node, c.scan)
+}
+
+func parseExportCursor(cursor string, nodes int) (exportCursor, error) {
+	var result exportCursor
+	if cursor == "" {
+		return result, nil
+	}
+
+	_, err := fmt.Sscanf(cursor, "%d:%d", &result.node, &result.scan)
+	if err != nil || result.node < 0 || result.node >= nodes {
+		return result, keyvaluestore.ErrInvalidCursor
+	}
+
+	return result, nil
+}
+
+func (s *coreService) Export(ctx context.Context,
+	request *keyvaluestore.ExportRequest) (*keyvaluestore.ExportResponse, error) {
+
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	pattern := request.Pattern
+	if pattern == "" {
+		pattern = "*"
+	}
+
+	view, err := s.readView(pattern, s.readConsistency(request.Options), request.Options.RequiredNodes)
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	// Nodes are sorted so that cursors stay valid across exports, and a key
+	// is only exported from the first node holding it.
+	nodes := append([]keyvaluestore.Backend(nil), view.Backends...)
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Address() < nodes[j].Address()
+	})
+
+	position, err := parseExportCursor(request.Cursor, len(nodes))
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	var throttle <-chan time.Time
+	if request.RateLimit > 0 {
+		ticker := time.NewTicker(time.Second / time.Duration(request.RateLimit))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	buffer := bufio.NewWriter(request.Writer)
+	writer := redisproto.NewWriter(buffer)
+	response := &keyvaluestore.ExportResponse{}
+
+	// stop flushes the keys exported so far, and reports where to resume.
+	stop := func(err error) (*keyvaluestore.ExportResponse, error) {
+		buffer.Flush()
+		response.Cursor = position.String()
+		return response, err
+	}
+
+	for ; position.node < len(nodes); position = (exportCursor{node: position.node + 1}) {
+		node := nodes[position.node]
+
+		for {
+			keys, next, err := node.Scan(position.scan, pattern, s.scanBatchSize)
+			if err != nil {
+				return stop(s.convertErrorToGRPC(err))
+			}
+
+			for _, key := range keys {
+				if strings.HasPrefix(key, internalKeyPrefix) || s.heldByAnyNode(key, nodes[:position.node]) {
+					continue
+				}
+
+				// Only strings can be written back by SET, whereas semaphores
+				// and streams are sorted sets.
+				keyType, err := node.Type(key)
+				if err == keyvaluestore.ErrNotFound || (err == nil && keyType != "string") {
+					continue
+				} else if err != nil {
+					return stop(s.convertErrorToGRPC(err))
+				}
+
+				if throttle != nil {
+					select {
+					case <-throttle:
+					case <-ctx.Done():
+						return stop(s.convertErrorToGRPC(ctx.Err()))
+					}
+				}
+
+				exported, err := s.exportKey(ctx, writer, key, request.Options)
+				if err != nil {
+					return stop(err)
+				}
+				if exported {
+					response.Exported++
+				}
+			}
+
+			// The cursor only moves past keys which have been written out.
+			if err := buffer.Flush(); err != nil {
+				return stop(s.convertErrorToGRPC(err))
+			}
+
+			if next == 0 {
+				break
+			}
+			position.scan = next
+
+			if err := ctx.Err(); err != nil {
+				return stop(s.convertErrorToGRPC(err))
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// heldByAnyNode tells whether key exists on any of nodes. Nodes which fail
+// to tell are assumed not to hold it, so that the key is exported twice
+// rather than not at all.
+func (s *coreService) heldByAnyNode(key string, nodes []keyvaluestore.Backend) bool {
+	for _, node := range nodes {
+		if exists, err := node.Exists(key); err == nil && exists {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exportKey writes key as a SET command, with PX if it expires. Keys which
+// have expired or been removed since the scan are skipped.
+func (s *coreService) exportKey(ctx context.Context, writer *redisproto.Writer, key string,
+	options keyvaluestore.ReadOptions) (bool, error) {
+
+	value, err := s.Get(ctx, &keyvaluestore.GetRequest{Key: key, Options: options})
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	ttl, err := s.GetTTL(ctx, &keyvaluestore.GetTTLRequest{Key: key, Options: options})
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	command := [][]byte{[]byte("SET"), []byte(key), value.Data}
+	if ttl.TTL != nil {
+		milliseconds := ttl.TTL.Milliseconds()
+		if milliseconds < 1 {
+			milliseconds = 1
+		}
+		command = append(command, []byte("PX"), []byte(strconv.FormatInt(milliseconds, 10)))
+	}
+
+	if err := writer.WriteBulks(command...); err != nil {
+		return false, s.convertErrorToGRPC(err)
+	}
+
+	return true, nil
+}
+
+// decodeExport sends every entry written by Export to entries, and closes it
+// once source is exhausted or ctx is done.
+func decodeExport(ctx context.Context, source io.Reader, entries chan<- keyvaluestore.KeyValue) error {
+	defer close(entries)
+
+	parser := redisproto.NewParser(source)
+	for {
+		command, err := parser.ReadCommand()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return keyvaluestore.ErrInvalidDump
+		}
+
+		entry, err := decodeExportCommand(command)
+		if err != nil {
+			return err
+		}
+
+		select {
+		case entries <- entry:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func decodeExportCommand(command *redisproto.Command) (keyvaluestore.KeyValue, error) {
+	var result keyvaluestore.KeyValue
+
+	count := command.ArgCount()
+	if (count != 3 && count != 5) || string(command.Get(0)) != "SET" {
+		return result, keyvaluestore.ErrInvalidDump
+	}
+
+	// Arguments refer to the buffer of the parser, which is reused.
+	result.Key = string(command.Get(1))
+	result.Data = append([]byte(nil), command.Get(2)...)
+
+	if count == 5 {
+		milliseconds, err := strconv.ParseInt(string(command.Get(4)), 10, 64)
+		if string(command.Get(3)) != "PX" || err != nil || milliseconds <= 0 {
+			return result, keyvaluestore.ErrInvalidDump
+		}
+		result.Expiration = time.Duration(milliseconds) * time.Millisecond
+	}
+
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// hintedWrite hands nodes which fail a write of key off to the hint store,
+// once the write has been acknowledged by the quorum. Failures of writes
+// missing the quorum are rolled back instead.
+type hintedWrite struct {
+	s        *coreService
+	key      string
+	operator keyvaluestore.WriteOperator
+
+	lock         sync.Mutex
+	acknowledged bool
+	completed    bool
+	failed       map[keyvaluestore.Backend]bool
+}
+
+func (s *coreService) hintedWrite(key string, operator keyvaluestore.WriteOperator) *hintedWrite {
+	return &hintedWrite{
+		s:        s,
+		key:      key,
+		operator: operator,
+		failed:   make(map[keyvaluestore.Backend]bool),
+	}
+}
+
+// Operator is the operator of the write, which should be passed to the
+// engine in place of the original one.
+func (w *hintedWrite) Operator() keyvaluestore.WriteOperator {
+	if w.s.hints == nil {
+		return w.operator
+	}
+
+	return func(node keyvaluestore.Backend) error {
+		err := w.operator(node)
+
+		// Hints are replayed on the node itself rather than on a backend
+		// bound to the request
+		node = keyvaluestore.Origin(node)
+
+		w.lock.Lock()
+		defer w.lock.Unlock()
+
+		if err == nil {
+			delete(w.failed, node)
+			w.s.hints.Forget(node, w.key)
+			return nil
+		}
+
+		// Nodes might keep failing after the engine has returned
+		if w.acknowledged {
+			w.s.hints.Hint(node, w.key, w.operator)
+		} else if !w.completed {
+			w.failed[node] = true
+		}
+
+		return err
+	}
+}
+
+// Complete is called with the result of the write once the engine returns.
+func (w *hintedWrite) Complete(err error) {
+	if w.s.hints == nil {
+		return
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.completed = true
+	w.acknowledged = err == nil
+	if w.acknowledged {
+		for node := range w.failed {
+			w.s.hints.Hint(node, w.key, w.operator)
+		}
+	}
+
+	w.failed = nil
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// defaultImportConcurrency is the number of entries written at once by an
+// import which does not specify it.
+const defaultImportConcurrency = 64
+
+func (s *coreService) Import(ctx context.Context,
+	request *keyvaluestore.ImportRequest) (*keyvaluestore.ImportResponse, error) {
+
+	concurrency := request.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultImportConcurrency
+	}
+
+	var lock sync.Mutex
+	response := &keyvaluestore.ImportResponse{}
+
+	record := func(key string, skipped bool, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err != nil {
+			response.Failed++
+			response.Errors = append(response.Errors, keyvaluestore.ImportError{Key: key, Err: err})
+		} else if skipped {
+			response.Skipped++
+		} else {
+			response.Imported++
+		}
+
+		if request.Progress != nil {
+			request.Progress(response.ImportProgress)
+		}
+	}
+
+	entries := request.Entries
+	var decoded chan error
+	if request.Source != nil {
+		source := make(chan keyvaluestore.KeyValue)
+		decoded = make(chan error, 1)
+		go func() {
+			decoded <- decodeExport(ctx, request.Source, source)
+		}()
+		entries = source
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	err := s.forEachImportEntry(ctx, entries, slots, func(item keyvaluestore.KeyValue) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			options := keyvaluestore.WriteOptions{
+				Consistency: request.Options.Consistency,
+				DryRun:      request.Options.DryRun,
+				Session:     request.Options.Session,
+				Timeout:     request.Options.Timeout,
+			}
+
+			if request.SkipExisting {
+				skipped, err := s.importIfNotExists(ctx, item, options)
+				record(item.Key, skipped, err)
+				return
+			}
+
+			record(item.Key, false, s.Set(ctx, &keyvaluestore.SetRequest{
+				Key:        item.Key,
+				Data:       item.Data,
+				Expiration: item.Expiration,
+				Options:    options,
+			}))
+		}()
+	})
+
+	wg.Wait()
+
+	// Entries are only closed once decoding is over, whereas the decoder
+	// might still be blocked on the source if ctx is done.
+	if decoded != nil && err == nil {
+		err = <-decoded
+	}
+
+	return response, s.convertErrorToGRPC(err)
+}
+
+// forEachImportEntry calls write for every entry once a slot is free, and
+// stops reading entries if ctx is done before they are closed.
+func (s *coreService) forEachImportEntry(ctx context.Context, entries <-chan keyvaluestore.KeyValue,
+	slots chan struct{}, write func(item keyvaluestore.KeyValue)) error {
+
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		select {
+		case item, ok := <-entries:
+			if !ok {
+				<-slots
+				return nil
+			}
+
+			write(item)
+
+		case <-ctx.Done():
+			<-slots
+			return ctx.Err()
+		}
+	}
+}
+
+// importIfNotExists writes item as Set does, but with SETNX. Nodes which
+// already hold the key count towards the consistency as they are, and are
+// never rolled back. If only some nodes held the key, their value is copied
+// over the item on the rest once the write succeeds, so that the replicas
+// agree. It tells whether any of them held the key.
+func (s *coreService) importIfNotExists(ctx context.Context, item keyvaluestore.KeyValue,
+	options keyvaluestore.WriteOptions) (bool, error) {
+
+	ctx, cancel := withTimeout(ctx, options.Timeout)
+	defer cancel()
+
+	if err := s.validateKeyValue(item.Key, item.Data); err != nil {
+		return false, s.convertErrorToGRPC(err)
+	}
+
+	data, err := s.encodeValue(item.Key, item.Data, "", 0)
+	if err != nil {
+		return false, s.convertErrorToGRPC(err)
+	}
+
+	expiration, err := s.writeExpiration(item.Expiration, false)
+	if err != nil {
+		return false, s.convertErrorToGRPC(err)
+	}
+
+	var lock sync.Mutex
+	var existing, writtenNodes []keyvaluestore.Backend
+	written := make(map[keyvaluestore.Backend]bool)
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		err := node.Lock(item.Key, data, expiration)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err == keyvaluestore.ErrNotAcquired {
+			existing = append(existing, node)
+			return nil
+		}
+		if err == nil {
+			written[keyvaluestore.Origin(node)] = true
+			writtenNodes = append(writtenNodes, node)
+		}
+
+		return err
+	}
+
+	deleteOperator := func(node keyvaluestore.Backend) error {
+		return node.Delete(item.Key)
+	}
+
+	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+		lock.Lock()
+		var nodes []keyvaluestore.Backend
+		for _, node := range args.Nodes {
+			if written[keyvaluestore.Origin(node)] {
+				nodes = append(nodes, node)
+			}
+		}
+		lock.Unlock()
+
+		err := s.engine.Write(nodes, 0, deleteOperator, deleteRollbackOperator,
+			keyvaluestore.OperationModeConcurrent)
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during IMPORT rollback")
+		}
+	}
+
+	err = s.performWrite(ctx, item.Key, s.operationWriteOptions(OperationSet, options),
+		writeOperator, s.valueRollback(rollbackOperator), keyvaluestore.OperationModeConcurrent)
+
+	// Nodes which have not answered by the time the engine returns are left
+	// to read repairs
+	lock.Lock()
+	winners := append([]keyvaluestore.Backend(nil), existing...)
+	losers := append([]keyvaluestore.Backend(nil), writtenNodes...)
+	lock.Unlock()
+
+	if err == nil && len(winners) > 0 && len(losers) > 0 {
+		s.repairLosersFromWinners(ctx, item.Key, metrics.RepairValue, keyvaluestore.RepairArgs{
+			Winners: winners,
+			Losers:  losers,
+		})
+	}
+
+	return len(winners) > 0, s.convertErrorToGRPC(err)
+}
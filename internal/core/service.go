@@ -3,7 +3,13 @@ package core
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
 	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc/codes"
@@ -11,22 +17,112 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/cafebazaar/keyvalue-store/internal/compression"
+	"github.com/cafebazaar/keyvalue-store/internal/encryption"
+	"github.com/cafebazaar/keyvalue-store/internal/envelope"
+	"github.com/cafebazaar/keyvalue-store/internal/handoff"
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/internal/ratelimit"
+	"github.com/cafebazaar/keyvalue-store/internal/resolver"
+	"github.com/cafebazaar/keyvalue-store/internal/slowlog"
 	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
 )
 
 const (
-	acceptableDurationDiff = 2 * time.Second
+	defaultTTLTolerance      = 2 * time.Second
+	defaultScanBatchSize     = 100
+	defaultScanBatchInterval = 10 * time.Millisecond
+	defaultIdempotencyTTL    = 5 * time.Minute
+	defaultIdempotencyLease  = 30 * time.Second
+	defaultLockPollInterval  = 50 * time.Millisecond
+
+	// Keys of internal records start with internalKeyPrefix, so that they
+	// are not exported.
+	internalKeyPrefix    = "__kvs_"
+	idempotencyKeyPrefix = internalKeyPrefix + "idempotency:"
+	rateLimitKeyPrefix   = internalKeyPrefix + "ratelimit:"
+	idempotencyPending   = "pending"
+	idempotencyDone      = "done:"
 )
 
+// Writes whose idempotency records are kept apart from the operations above.
+const (
+	idempotentGetDel      = "getdel"
+	idempotentDeleteMany  = "deletemany"
+	idempotentTransaction = "transaction"
+	idempotentStreamAdd   = "streamadd"
+)
+
+// Operations whose default consistency can be set by WithOperationConsistency.
+// OperationLock also applies to Unlock and RenewLock.
+const (
+	OperationGet    = "get"
+	OperationExists = "exists"
+	OperationGetTTL = "getttl"
+	OperationSet    = "set"
+	OperationDelete = "delete"
+	OperationLock   = "lock"
+)
+
+var operations = map[string]bool{
+	OperationGet: true, OperationExists: true, OperationGetTTL: true,
+	OperationSet: true, OperationDelete: true, OperationLock: true,
+}
+
+var readOperations = map[string]bool{
+	OperationGet: true, OperationExists: true, OperationGetTTL: true,
+}
+
 type coreService struct {
 	cluster                 keyvaluestore.Cluster
 	engine                  keyvaluestore.Engine
 	defaultWriteConsistency keyvaluestore.ConsistencyLevel
 	defaultReadConsistency  keyvaluestore.ConsistencyLevel
+	defaultLockConsistency  keyvaluestore.ConsistencyLevel
+	operationConsistency    map[string]keyvaluestore.ConsistencyLevel
+	operationVotingModes    map[string]keyvaluestore.VotingMode
+	nodeIDs                 map[string]string
+	scanBatchSize           int64
+	scanBatchInterval       time.Duration
+	conflictResolver        keyvaluestore.ConflictResolver
+	valueVersioning         bool
+	valueMetadata           bool
+	lastVersion             int64
+	idempotencyTTL          time.Duration
+	idempotencyLease        time.Duration
+	logRepairs              bool
+	maxKeyBytes             int
+	maxValueBytes           int
+	compression             bool
+	compressionMinBytes     int
+	keyring                 *encryption.Keyring
+	lockPollInterval        time.Duration
+	defaultWriteTTL         time.Duration
+	ttlJitterPercent        float64
+	maxWriteTTL             time.Duration
+	rejectLongTTL           bool
+	sessions                *sessionTracker
+	revalidationLock        sync.Mutex
+	revalidating            map[string]bool
+	quorumMode              keyvaluestore.QuorumMode
+	writeRollback           bool
+	ttlTolerance            time.Duration
+	maxRepairsPerRead       int
+	repairLimit             *ratelimit.Bucket
+	retryAttempts           int
+	retryDelay              time.Duration
+	streamLock              sync.Mutex
+	lastStreamID            keyvaluestore.StreamID
+	hints                   *handoff.Store
 }
 
 type Option func(s *coreService)
 
+type writeGroup struct {
+	view keyvaluestore.WriteClusterView
+	keys []string
+}
+
 func New(cluster keyvaluestore.Cluster,
 	engine keyvaluestore.Engine,
 	options ...Option) keyvaluestore.Service {
@@ -36,12 +132,31 @@ func New(cluster keyvaluestore.Cluster,
 		engine:                  engine,
 		defaultReadConsistency:  keyvaluestore.ConsistencyLevel_MAJORITY,
 		defaultWriteConsistency: keyvaluestore.ConsistencyLevel_ALL,
+		scanBatchSize:           defaultScanBatchSize,
+		scanBatchInterval:       defaultScanBatchInterval,
+		idempotencyTTL:          defaultIdempotencyTTL,
+		idempotencyLease:        defaultIdempotencyLease,
+		lockPollInterval:        defaultLockPollInterval,
+		revalidating:            make(map[string]bool),
+		operationConsistency:    make(map[string]keyvaluestore.ConsistencyLevel),
+		operationVotingModes:    make(map[string]keyvaluestore.VotingMode),
+		nodeIDs:                 make(map[string]string),
+		writeRollback:           true,
+		ttlTolerance:            defaultTTLTolerance,
 	}
 
 	for _, option := range options {
 		option(result)
 	}
 
+	if result.sessions != nil {
+		result.sessions.majoritiesOverlap = result.quorumMode == keyvaluestore.QuorumModeStrictMajority
+	}
+
+	if result.valueVersioning && result.conflictResolver == nil {
+		result.conflictResolver = resolver.LastWriteWins
+	}
+
 	return result
 }
 
@@ -57,9 +172,270 @@ func WithDefaultWriteConsistency(defaultWriteConsistency keyvaluestore.Consisten
 	}
 }
 
+// WithDefaultLockConsistency sets the consistency of Lock, Unlock and
+// RenewLock requests which have not specified any, which otherwise default to
+// the write consistency.
+func WithDefaultLockConsistency(defaultLockConsistency keyvaluestore.ConsistencyLevel) Option {
+	return func(s *coreService) {
+		s.defaultLockConsistency = defaultLockConsistency
+	}
+}
+
+// WithOperationConsistency sets the consistency of requests of operation which
+// have not specified any, taking precedence over the default read, write and
+// lock consistencies.
+func WithOperationConsistency(operation string, consistency keyvaluestore.ConsistencyLevel) Option {
+	return func(s *coreService) {
+		if !operations[operation] {
+			logrus.WithField("operation", operation).Panic("unknown operation")
+		}
+
+		s.operationConsistency[operation] = consistency
+	}
+}
+
+// WithOperationVotingMode decides whether not-found answers of nodes count as
+// votes in reads of operation, which is one of get, exists and getttl. By
+// default, the voting mode of the read view, which depends on the cluster's
+// read policy, is used.
+func WithOperationVotingMode(operation string, mode keyvaluestore.VotingMode) Option {
+	return func(s *coreService) {
+		if !readOperations[operation] {
+			logrus.WithField("operation", operation).Panic("unknown read operation")
+		}
+
+		s.operationVotingModes[operation] = mode
+	}
+}
+
+// WithNodeID identifies the node at address by id, rather than its address,
+// when ordering nodes of sequential writes such as locks. Proxies whose
+// addresses of the same nodes differ, e.g. IP and hostname, still lock nodes
+// in the same order as long as they share IDs.
+func WithNodeID(address string, id string) Option {
+	return func(s *coreService) {
+		s.nodeIDs[address] = id
+	}
+}
+
+func WithConflictResolver(conflictResolver keyvaluestore.ConflictResolver) Option {
+	return func(s *coreService) {
+		s.conflictResolver = conflictResolver
+	}
+}
+
+// WithWriteRollback tells whether values set on some nodes by a write which
+// fails to meet its consistency are removed again, which is the default.
+// Without rollback, such values are left for read-repair to converge, which
+// avoids deleting values from nodes whose acknowledgement was merely lost.
+// Locks and semaphores are always rolled back.
+func WithWriteRollback(rollback bool) Option {
+	return func(s *coreService) {
+		s.writeRollback = rollback
+	}
+}
+
+// WithTTLTolerance sets how far apart the TTLs of a key on different nodes
+// may be to still agree, since nodes read them at slightly different times.
+// It defaults to 2 seconds, which is too coarse for keys living shorter.
+func WithTTLTolerance(tolerance time.Duration) Option {
+	return func(s *coreService) {
+		s.ttlTolerance = tolerance
+	}
+}
+
+// WithValueVersioning stores values in an envelope carrying a version, which
+// is used to resolve conflicts in favor of the newest write.
+func WithValueVersioning(valueVersioning bool) Option {
+	return func(s *coreService) {
+		s.valueVersioning = valueVersioning
+	}
+}
+
+// WithValueMetadata stores values in an envelope carrying their content type
+// and creation time, which are reported by Get.
+func WithValueMetadata(valueMetadata bool) Option {
+	return func(s *coreService) {
+		s.valueMetadata = valueMetadata
+	}
+}
+
+func WithIdempotencyTTL(idempotencyTTL time.Duration) Option {
+	return func(s *coreService) {
+		s.idempotencyTTL = idempotencyTTL
+	}
+}
+
+// WithIdempotencyLease sets how long the record of a write in progress is
+// kept, so that retries are not blocked for long by a proxy which has died
+// during the write. Writes with a longer timeout keep it for their timeout.
+func WithIdempotencyLease(idempotencyLease time.Duration) Option {
+	return func(s *coreService) {
+		s.idempotencyLease = idempotencyLease
+	}
+}
+
+func WithMaxKeyBytes(maxKeyBytes int) Option {
+	return func(s *coreService) {
+		s.maxKeyBytes = maxKeyBytes
+	}
+}
+
+func WithMaxValueBytes(maxValueBytes int) Option {
+	return func(s *coreService) {
+		s.maxValueBytes = maxValueBytes
+	}
+}
+
+// WithCompression compresses values of at least minBytes bytes before
+// storing them. Values are decompressed transparently on reads.
+func WithCompression(minBytes int) Option {
+	return func(s *coreService) {
+		s.compression = true
+		s.compressionMinBytes = minBytes
+	}
+}
+
+// WithEncryption encrypts values at rest using keyring. Values are decrypted
+// transparently on reads.
+func WithEncryption(keyring *encryption.Keyring) Option {
+	return func(s *coreService) {
+		s.keyring = keyring
+	}
+}
+
+// WithRepairLimits caps the loser nodes repaired by a single read to
+// maxPerRead, and the loser nodes repaired by all reads to perSecond. Either
+// is disabled if not positive. Losers left over are repaired by later reads
+// of the same key.
+func WithRepairLimits(maxPerRead int, perSecond int) Option {
+	return func(s *coreService) {
+		s.maxRepairsPerRead = maxPerRead
+		s.repairLimit = nil
+		if perSecond > 0 {
+			s.repairLimit = ratelimit.NewBucket(perSecond)
+		}
+	}
+}
+
+// WithConsistencyRetry makes reads which fail with ErrConsistency, e.g.
+// because a node blipped, take up to attempts attempts in total, waiting a
+// jittered delay of between delay and twice delay in between. Writes are
+// only retried if they carry an idempotency key, since they might have been
+// partially applied.
+func WithConsistencyRetry(attempts int, delay time.Duration) Option {
+	return func(s *coreService) {
+		s.retryAttempts = attempts
+		s.retryDelay = delay
+	}
+}
+
+// WithHintedHandoff stores nodes which miss a SET or DEL acknowledged by the
+// quorum as hints, which hints replays once the nodes are back. The service
+// closes hints along with itself.
+func WithHintedHandoff(hints *handoff.Store) Option {
+	return func(s *coreService) {
+		s.hints = hints
+	}
+}
+
+func WithRepairLogging(logRepairs bool) Option {
+	return func(s *coreService) {
+		s.logRepairs = logRepairs
+	}
+}
+
+func WithScanBatchSize(scanBatchSize int64) Option {
+	return func(s *coreService) {
+		s.scanBatchSize = scanBatchSize
+	}
+}
+
+func WithScanBatchInterval(scanBatchInterval time.Duration) Option {
+	return func(s *coreService) {
+		s.scanBatchInterval = scanBatchInterval
+	}
+}
+
+// WithDefaultWriteTTL sets the expiration of values set without one, unless
+// they are explicitly requested to be persistent.
+func WithDefaultWriteTTL(defaultWriteTTL time.Duration) Option {
+	return func(s *coreService) {
+		s.defaultWriteTTL = defaultWriteTTL
+	}
+}
+
+// WithTTLJitter randomly shifts expirations of Set by up to the given percent
+// in either direction, so that keys written together do not expire together.
+func WithTTLJitter(percent float64) Option {
+	return func(s *coreService) {
+		s.ttlJitterPercent = percent
+	}
+}
+
+// WithMaxWriteTTL caps expirations of Set, Expire and Touch, including values
+// which would never expire, to maxWriteTTL. Longer expirations are rejected
+// with InvalidArgument if reject is set, otherwise they are clamped.
+func WithMaxWriteTTL(maxWriteTTL time.Duration, reject bool) Option {
+	return func(s *coreService) {
+		s.maxWriteTTL = maxWriteTTL
+		s.rejectLongTTL = reject
+	}
+}
+
+// WithSessionConsistency remembers keys written by each session for ttl, and
+// raises the consistency of reads of those keys by the same session so that
+// they observe the write.
+func WithSessionConsistency(ttl time.Duration) Option {
+	return func(s *coreService) {
+		s.sessions = newSessionTracker(ttl)
+	}
+}
+
+// WithQuorumMode decides how many of an even number of nodes make up a
+// majority, and should match the quorum mode of the cluster.
+func WithQuorumMode(mode keyvaluestore.QuorumMode) Option {
+	return func(s *coreService) {
+		s.quorumMode = mode
+	}
+}
+
+// WithLockPollInterval sets how often a held lock is retried by Lock requests
+// willing to wait for it.
+func WithLockPollInterval(lockPollInterval time.Duration) Option {
+	return func(s *coreService) {
+		s.lockPollInterval = lockPollInterval
+	}
+}
+
 func (s *coreService) Set(ctx context.Context, request *keyvaluestore.SetRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	if err := s.validateKeyValue(request.Key, request.Data); err != nil {
+		return s.convertErrorToGRPC(err)
+	}
+
+	if request.KeepTTL && request.Expiration != 0 {
+		return s.convertErrorToGRPC(keyvaluestore.ErrKeepTTLConflict)
+	}
+
+	data, err := s.encodeValue(request.Key, request.Data, request.ContentType, request.Version)
+	if err != nil {
+		return s.convertErrorToGRPC(err)
+	}
+
+	expiration, err := s.writeExpiration(request.Expiration, request.Persistent)
+	if err != nil {
+		return s.convertErrorToGRPC(err)
+	}
+
 	writeOperator := func(node keyvaluestore.Backend) error {
-		return node.Set(request.Key, request.Data, request.Expiration)
+		if request.KeepTTL {
+			return node.SetKeepTTL(request.Key, data, expiration)
+		}
+
+		return node.Set(request.Key, data, expiration)
 	}
 
 	deleteOperator := func(backend keyvaluestore.Backend) error {
@@ -73,153 +449,191 @@ func (s *coreService) Set(ctx context.Context, request *keyvaluestore.SetRequest
 		err := s.engine.Write(args.Nodes, 0, deleteOperator, deleteRollbackOperator,
 			keyvaluestore.OperationModeConcurrent)
 		if err != nil {
-			logrus.WithError(err).Error("unexpected error during SET rollback")
+			logEntry(ctx).WithError(err).Error("unexpected error during SET rollback")
 		}
 	}
 
-	return s.convertErrorToGRPC(s.performWrite(request.Key, request.Options,
-		writeOperator, rollbackOperator, keyvaluestore.OperationModeConcurrent))
-}
+	hinted := s.hintedWrite(request.Key, writeOperator)
+	options := s.operationWriteOptions(OperationSet, request.Options)
 
-func (s *coreService) Get(ctx context.Context, request *keyvaluestore.GetRequest) (*keyvaluestore.GetResponse, error) {
-	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
-		return node.Get(request.Key)
+	// Acknowledgements of idempotent writes are recorded, so that retries
+	// report those of the original write
+	acknowledgement := request.Acknowledgement
+	if acknowledgement == nil && options.IdempotencyKey != "" {
+		acknowledgement = &keyvaluestore.WriteAcknowledgement{}
 	}
 
-	deleteOperator := func(node keyvaluestore.Backend) error {
-		return node.Delete(request.Key)
-	}
+	result, err := s.performIdempotentWrite(ctx, OperationSet, []string{request.Key}, options,
+		func(ctx context.Context) ([]byte, error) {
+			if acknowledgement != nil {
+				err := s.performAcknowledgedWrite(ctx, request.Key, options,
+					hinted.Operator(), s.valueRollback(rollbackOperator), acknowledgement)
+				return encodeAcknowledgement(acknowledgement), err
+			}
 
-	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
-	}
+			return nil, s.performWrite(ctx, request.Key, options,
+				hinted.Operator(), s.valueRollback(rollbackOperator), keyvaluestore.OperationModeConcurrent)
+		})
+	hinted.Complete(err)
 
-	ttlOperator := func(node keyvaluestore.Backend) (interface{}, error) {
-		return node.TTL(request.Key)
+	if err == nil && request.Acknowledgement != nil {
+		*request.Acknowledgement = decodeAcknowledgement(result)
 	}
 
-	repairOperator := func(args keyvaluestore.RepairArgs) {
-		if args.Err == keyvaluestore.ErrNotFound {
-			err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
-				keyvaluestore.OperationModeConcurrent)
-			if err != nil {
-				logrus.WithError(err).Error("unexpected error during read repair")
-			}
+	return s.convertErrorToGRPC(err)
+}
 
-			return
+// encodeValue prepares data to be stored on the nodes, applying metadata,
+// compression, encryption and versioning in that order, whichever enabled.
+func (s *coreService) encodeValue(key string, data []byte, contentType string, version int64) ([]byte, error) {
+	if s.valueMetadata {
+		data = envelope.EncodeMetadata(contentType, time.Now(), data)
+	}
+
+	if s.compression && len(data) >= s.compressionMinBytes {
+		compressed, err := compression.Compress(data)
+		if err != nil {
+			return nil, err
 		}
 
-		ttlValue, err := s.engine.Read(args.Winners, s.majority(len(args.Winners)),
-			ttlOperator, nil, s.durationComparer, keyvaluestore.VotingModeSkipVoteOnNotFound)
+		data = compressed
+	}
+
+	if s.keyring != nil {
+		encrypted, err := s.keyring.Encrypt(key, data)
 		if err != nil {
-			logrus.WithError(err).Error("unexpected error during read repair")
-			return
+			return nil, err
 		}
 
-		var ttl time.Duration
-		shouldRepair := true
-		if ttlValue != nil {
-			ttl = *(ttlValue.(*time.Duration))
-			if ttl == 0 {
-				shouldRepair = false
-			}
+		data = encrypted
+	}
+
+	if s.valueVersioning {
+		if version == 0 {
+			version = s.nextVersion()
 		}
 
-		if shouldRepair {
-			setOperator := func(node keyvaluestore.Backend) error {
-				return node.Set(request.Key, args.Value.([]byte), ttl)
-			}
+		data = envelope.Encode(version, data)
+	}
 
-			setRollbackOperator := func(rollbackArgs keyvaluestore.RollbackArgs) {
-				err := s.engine.Write(rollbackArgs.Nodes, 0, deleteOperator, deleteRollbackOperator,
-					keyvaluestore.OperationModeConcurrent)
-				if err != nil {
-					logrus.WithError(err).Error("unexpected error during SET rollback")
-				}
-			}
+	return data, nil
+}
 
-			err = s.engine.Write(args.Losers, 0, setOperator, setRollbackOperator, keyvaluestore.OperationModeConcurrent)
-			if err != nil {
-				logrus.WithError(err).Error("unexpected error during read repair")
-			}
-		}
+func (s *coreService) writeExpiration(expiration time.Duration, persistent bool) (time.Duration, error) {
+	if expiration == 0 && !persistent {
+		expiration = s.defaultWriteTTL
 	}
 
-	rawResult, err := s.performRead(request.Key, request.Options, readOperator,
-		repairOperator, s.byteComparer)
+	expiration, err := s.capExpiration(expiration)
 	if err != nil {
-		return nil, s.convertErrorToGRPC(err)
+		return 0, err
 	}
 
-	data := rawResult.([]byte)
+	// Computed once for all nodes, so that replicas agree on the TTL. Jitter
+	// is not a reason to reject a write, so it is clamped silently.
+	expiration = s.jitter(expiration)
+	if s.maxWriteTTL > 0 && expiration > s.maxWriteTTL {
+		expiration = s.maxWriteTTL
+	}
 
-	return &keyvaluestore.GetResponse{Data: data}, nil
+	return expiration, nil
 }
 
-func (s *coreService) Delete(ctx context.Context, request *keyvaluestore.DeleteRequest) error {
-	writeOperator := func(node keyvaluestore.Backend) error {
-		return node.Delete(request.Key)
-	}
+func (s *coreService) MSet(ctx context.Context, request *keyvaluestore.MSetRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
 
-	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	for _, item := range request.Items {
+		if err := s.validateKeyValue(item.Key, item.Data); err != nil {
+			return s.convertErrorToGRPC(err)
+		}
 	}
 
-	return s.convertErrorToGRPC(s.performWrite(request.Key, request.Options,
-		writeOperator, rollbackOperator, keyvaluestore.OperationModeConcurrent))
-}
+	var (
+		wg      sync.WaitGroup
+		lock    sync.Mutex
+		written []string
+	)
+	errorChannel := make(chan error, 1)
+
+	for _, item := range request.Items {
+		wg.Add(1)
+
+		go func(item keyvaluestore.KeyValue) {
+			defer wg.Done()
+
+			err := s.Set(ctx, &keyvaluestore.SetRequest{
+				Key:        item.Key,
+				Data:       item.Data,
+				Expiration: item.Expiration,
+				Options:    request.Options,
+			})
+			if err != nil {
+				select {
+				case errorChannel <- err:
+				default:
+				}
 
-func (s *coreService) FlushDB(ctx context.Context) error {
-	return s.convertErrorToGRPC(s.performFlushDb())
-}
+				return
+			}
 
-func (s *coreService) Lock(ctx context.Context, request *keyvaluestore.LockRequest) error {
-	writeOperator := func(node keyvaluestore.Backend) error {
-		return node.Lock(request.Key, request.Data, request.Expiration)
+			lock.Lock()
+			written = append(written, item.Key)
+			lock.Unlock()
+		}(item)
 	}
 
-	unlockOperator := func(node keyvaluestore.Backend) error {
-		return node.Unlock(request.Key)
+	wg.Wait()
+
+	select {
+	case err := <-errorChannel:
+		s.rollbackMSet(ctx, written, request.Options)
+		return err
+
+	default:
+		return nil
 	}
+}
 
-	unlockRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+// rollbackMSet deletes items of a failed MSet which have been written, along
+// with their idempotency records, so that retrying the batch writes them again.
+func (s *coreService) rollbackMSet(ctx context.Context, keys []string, options keyvaluestore.WriteOptions) {
+	if !s.writeRollback || options.DryRun != nil {
+		return
 	}
 
-	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
-		err := s.engine.Write(args.Nodes, 0, unlockOperator, unlockRollbackOperator,
-			keyvaluestore.OperationModeConcurrent)
+	for _, key := range keys {
+		s.rollbackMSetKey(ctx, key)
 
-		if err != nil {
-			logrus.WithError(err).Error("unexpected error during LOCK rollback")
+		if options.IdempotencyKey != "" {
+			s.rollbackMSetKey(ctx, idempotencyRecordKey(OperationSet, []string{key}, options.IdempotencyKey))
 		}
 	}
-
-	// Use sequential (ordered) write sequence to prevent dining philosopher problem
-	// (a.k.a chance of deadlock)
-	return s.convertErrorToGRPC(s.performWrite(request.Key, request.Options, writeOperator,
-		rollbackOperator, keyvaluestore.OperationModeSequential))
 }
 
-func (s *coreService) Unlock(ctx context.Context, request *keyvaluestore.UnlockRequest) error {
-	writeOperator := func(backend keyvaluestore.Backend) error {
-		return backend.Unlock(request.Key)
+func (s *coreService) rollbackMSetKey(ctx context.Context, key string) {
+	view, err := s.cluster.Write(key, keyvaluestore.ConsistencyLevel_ALL)
+	if err != nil {
+		logEntry(ctx).WithError(err).Error("unexpected error during MSET rollback")
+		return
 	}
 
-	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	deleteOperator := func(node keyvaluestore.Backend) error {
+		return node.Delete(key)
 	}
 
-	return s.convertErrorToGRPC(s.performWrite(request.Key, request.Options, writeOperator,
-		rollbackOperator, keyvaluestore.OperationModeConcurrent))
+	err = s.engine.Write(view.Backends, 0, deleteOperator, nil, keyvaluestore.OperationModeConcurrent)
+	if err != nil {
+		logEntry(ctx).WithError(err).Error("unexpected error during MSET rollback")
+	}
 }
 
-func (s *coreService) Expire(ctx context.Context,
-	request *keyvaluestore.ExpireRequest) (*keyvaluestore.ExpireResponse, error) {
+func (s *coreService) Get(ctx context.Context, request *keyvaluestore.GetRequest) (*keyvaluestore.GetResponse, error) {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
 
 	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
-		err := node.Expire(request.Key, request.Expiration)
-		if err != nil {
-			return false, err
-		}
-
-		return true, nil
+		return batchedGet(ctx, node, request.Key)
 	}
 
 	deleteOperator := func(node keyvaluestore.Backend) error {
@@ -233,401 +647,2698 @@ func (s *coreService) Expire(ctx context.Context,
 		return node.TTL(request.Key)
 	}
 
-	getOperator := func(node keyvaluestore.Backend) (interface{}, error) {
-		return node.Get(request.Key)
-	}
-
 	repairOperator := func(args keyvaluestore.RepairArgs) {
 		if args.Err == keyvaluestore.ErrNotFound {
+			s.recordRepair(ctx, request.Key, metrics.RepairStaleDelete, args.Losers)
+
 			err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
 				keyvaluestore.OperationModeConcurrent)
 			if err != nil {
-				logrus.WithError(err).Error("unexpected error during read repair")
+				logEntry(ctx).WithError(err).Error("unexpected error during read repair")
 			}
 
 			return
 		}
 
 		ttlValue, err := s.engine.Read(args.Winners, s.majority(len(args.Winners)),
-			ttlOperator, nil, s.durationComparer, keyvaluestore.VotingModeSkipVoteOnNotFound)
+			ttlOperator, nil, s.durationComparer, keyvaluestore.VotingModeSkipVoteOnNotFound, nil)
 		if err != nil {
-			logrus.WithError(err).Error("unexpected error during read repair")
+			logEntry(ctx).WithError(err).Error("unexpected error during read repair")
 			return
 		}
 
 		var ttl time.Duration
-		if args.Value != nil {
+		shouldRepair := true
+		if ttlValue != nil {
 			ttl = *(ttlValue.(*time.Duration))
 			if ttl == 0 {
-				err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
-					keyvaluestore.OperationModeConcurrent)
-				if err != nil {
-					logrus.WithError(err).Error("unexpected error during read repair")
-				}
-
-				return
+				shouldRepair = false
 			}
 		}
 
-		rawValue, err := s.engine.Read(args.Winners, s.majority(len(args.Winners)),
-			getOperator, nil, s.byteComparer, keyvaluestore.VotingModeSkipVoteOnNotFound)
-		if err != nil {
-			logrus.WithError(err).Error("unexpected error during read repair")
-			return
-		}
+		if shouldRepair {
+			setOperator := func(node keyvaluestore.Backend) error {
+				return node.Set(request.Key, args.Value.([]byte), ttl)
+			}
 
-		setOperator := func(node keyvaluestore.Backend) error {
-			return node.Set(request.Key, rawValue.([]byte), ttl)
-		}
+			setRollbackOperator := func(rollbackArgs keyvaluestore.RollbackArgs) {
+				err := s.engine.Write(rollbackArgs.Nodes, 0, deleteOperator, deleteRollbackOperator,
+					keyvaluestore.OperationModeConcurrent)
+				if err != nil {
+					logEntry(ctx).WithError(err).Error("unexpected error during SET rollback")
+				}
+			}
 
-		setRollbackOperator := func(rollbackArgs keyvaluestore.RollbackArgs) {
-			err := s.engine.Write(rollbackArgs.Nodes, 0, deleteOperator, deleteRollbackOperator,
-				keyvaluestore.OperationModeConcurrent)
+			s.recordRepair(ctx, request.Key, metrics.RepairValue, args.Losers)
+
+			err = s.engine.Write(args.Losers, 0, setOperator, setRollbackOperator, keyvaluestore.OperationModeConcurrent)
 			if err != nil {
-				logrus.WithError(err).Error("unexpected error during SET rollback")
+				logEntry(ctx).WithError(err).Error("unexpected error during read repair")
 			}
 		}
-
-		err = s.engine.Write(args.Losers, 0, setOperator, setRollbackOperator, keyvaluestore.OperationModeConcurrent)
-		if err != nil {
-			logrus.WithError(err).Error("unexpected error during read repair")
-		}
 	}
 
-	rawResult, err := s.performRead(request.Key, keyvaluestore.ReadOptions{
-		Consistency: request.Options.Consistency,
-	}, readOperator, repairOperator, s.booleanComparer)
+	var rawResult interface{}
+	var err error
+	options := s.operationReadOptions(OperationGet, request.Options)
+	if options.StaleWhileRevalidate {
+		rawResult, err = s.performStaleRead(ctx, OperationGet, request.Key, options, readOperator,
+			repairOperator, s.storedValueComparer(request.Key))
+	} else {
+		rawResult, err = s.performOperationRead(ctx, OperationGet, request.Key, options, readOperator,
+			repairOperator, s.storedValueComparer(request.Key))
+	}
 	if err != nil {
-		if err == keyvaluestore.ErrNotFound {
-			return &keyvaluestore.ExpireResponse{Exists: false}, nil
-		}
+		return nil, s.convertErrorToGRPC(err)
+	}
 
+	value, err := s.decodeStoredValue(request.Key, rawResult.([]byte))
+	if err != nil {
 		return nil, s.convertErrorToGRPC(err)
 	}
 
-	return &keyvaluestore.ExpireResponse{Exists: rawResult.(bool)}, nil
+	if !s.valueVersioning {
+		return &keyvaluestore.GetResponse{Data: value.data, Metadata: value.metadata}, nil
+	}
+
+	version := value.versionTag()
+	if request.IfVersionNot != "" && request.IfVersionNot == version {
+		return &keyvaluestore.GetResponse{Version: version, NotModified: true}, nil
+	}
+
+	return &keyvaluestore.GetResponse{Data: value.data, Metadata: value.metadata, Version: version}, nil
 }
 
-func (s *coreService) Exists(ctx context.Context,
-	request *keyvaluestore.ExistsRequest) (*keyvaluestore.ExistsResponse, error) {
+func (s *coreService) GetMany(ctx context.Context,
+	request *keyvaluestore.GetManyRequest) (*keyvaluestore.GetManyResponse, error) {
 
-	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
-		result, err := node.Exists(request.Key)
-		if err != nil {
-			return false, err
-		}
-		if !result {
-			return false, keyvaluestore.ErrNotFound
-		}
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
 
-		return true, nil
-	}
+	items := make([]*keyvaluestore.GetManyItem, len(request.Keys))
 
-	deleteOperator := func(node keyvaluestore.Backend) error {
-		return node.Delete(request.Key)
+	// Values are read along with their TTLs one key at a time
+	var batches map[string]*readBatch
+	if !request.WithTTL {
+		batches = s.readBatches(request.Keys, request.Options)
 	}
 
-	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
-	}
+	var wg sync.WaitGroup
+	errorChannel := make(chan error, 1)
 
-	ttlOperator := func(node keyvaluestore.Backend) (interface{}, error) {
-		return node.TTL(request.Key)
-	}
+	for i, key := range request.Keys {
+		wg.Add(1)
 
-	getOperator := func(node keyvaluestore.Backend) (interface{}, error) {
-		return node.Get(request.Key)
-	}
+		go func(index int, key string) {
+			defer wg.Done()
 
-	repairOperator := func(args keyvaluestore.RepairArgs) {
+			itemCtx := ctx
+			if batch := batches[key]; batch != nil {
+				itemCtx = withReadBatch(ctx, batch)
+			}
 
-		if args.Err == keyvaluestore.ErrNotFound {
-			err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
-				keyvaluestore.OperationModeConcurrent)
+			item, err := s.getManyItem(itemCtx, key, request.WithTTL, request.Options)
 			if err != nil {
-				logrus.WithError(err).Error("unexpected error during read repair")
+				select {
+				case errorChannel <- err:
+				default:
+				}
+
+				return
 			}
 
-			return
-		}
+			items[index] = item
+		}(i, key)
+	}
 
-		ttlValue, err := s.engine.Read(args.Winners, s.majority(len(args.Winners)),
-			ttlOperator, nil, s.durationComparer, keyvaluestore.VotingModeSkipVoteOnNotFound)
-		if err != nil {
-			logrus.WithError(err).Error("unexpected error during read repair")
-			return
-		}
+	wg.Wait()
 
-		var ttl time.Duration
-		if args.Value != nil {
-			ttl = *(ttlValue.(*time.Duration))
-			if ttl == 0 {
-				err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
-					keyvaluestore.OperationModeConcurrent)
-				if err != nil {
-					logrus.WithError(err).Error("unexpected error during read repair")
-				}
+	select {
+	case err := <-errorChannel:
+		return nil, err
 
-				return
-			}
-		}
+	default:
+	}
 
-		rawValue, err := s.engine.Read(args.Winners, s.majority(len(args.Winners)),
-			getOperator, nil, s.byteComparer, keyvaluestore.VotingModeSkipVoteOnNotFound)
-		if err != nil {
-			logrus.WithError(err).Error("unexpected error during read repair")
-			return
-		}
+	result := &keyvaluestore.GetManyResponse{
+		Items: make(map[string]*keyvaluestore.GetManyItem, len(request.Keys)),
+	}
+	for i, key := range request.Keys {
+		result.Items[key] = items[i]
+	}
 
-		setOperator := func(node keyvaluestore.Backend) error {
-			return node.Set(request.Key, rawValue.([]byte), ttl)
-		}
+	return result, nil
+}
 
-		setRollbackOperator := func(rollbackArgs keyvaluestore.RollbackArgs) {
-			err := s.engine.Write(rollbackArgs.Nodes, 0, deleteOperator, deleteRollbackOperator,
-				keyvaluestore.OperationModeConcurrent)
-			if err != nil {
-				logrus.WithError(err).Error("unexpected error during SET rollback")
-			}
-		}
+func (s *coreService) getManyItem(ctx context.Context, key string, withTTL bool,
+	options keyvaluestore.ReadOptions) (*keyvaluestore.GetManyItem, error) {
 
-		err = s.engine.Write(args.Losers, 0, setOperator, setRollbackOperator, keyvaluestore.OperationModeConcurrent)
-		if err != nil {
-			logrus.WithError(err).Error("unexpected error during read repair")
-		}
+	if withTTL {
+		return s.getManyItemWithTTL(ctx, key, options)
 	}
 
-	rawResult, err := s.performRead(request.Key, request.Options, readOperator,
-		repairOperator, s.booleanComparer)
+	value, err := s.Get(ctx, &keyvaluestore.GetRequest{Key: key, Options: options})
+	if status.Code(err) == codes.NotFound {
+		return &keyvaluestore.GetManyItem{}, nil
+	}
 	if err != nil {
-		if err == keyvaluestore.ErrNotFound {
-			return &keyvaluestore.ExistsResponse{Exists: false}, nil
-		}
-
-		return nil, s.convertErrorToGRPC(err)
+		return nil, err
 	}
 
-	return &keyvaluestore.ExistsResponse{Exists: rawResult.(bool)}, nil
+	return &keyvaluestore.GetManyItem{Found: true, Data: value.Data, Metadata: value.Metadata}, nil
 }
 
-// nolint: golint
-func (s *coreService) GetTTL(ctx context.Context,
-	request *keyvaluestore.GetTTLRequest) (*keyvaluestore.GetTTLResponse, error) {
+// getManyItemWithTTL reads the value of key along with its TTL in a single
+// round-trip per node, so that both are voted on and repaired together.
+func (s *coreService) getManyItemWithTTL(ctx context.Context, key string,
+	options keyvaluestore.ReadOptions) (*keyvaluestore.GetManyItem, error) {
 
 	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
-		return node.TTL(request.Key)
+		return node.GetWithTTL(key)
 	}
 
 	deleteOperator := func(node keyvaluestore.Backend) error {
-		return node.Delete(request.Key)
+		return node.Delete(key)
 	}
 
 	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
 	}
 
-	getOperator := func(node keyvaluestore.Backend) (interface{}, error) {
-		return node.Get(request.Key)
-	}
-
 	repairOperator := func(args keyvaluestore.RepairArgs) {
 		if args.Err == keyvaluestore.ErrNotFound {
+			s.recordRepair(ctx, key, metrics.RepairStaleDelete, args.Losers)
+
 			err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
 				keyvaluestore.OperationModeConcurrent)
 			if err != nil {
-				logrus.WithError(err).Error("unexpected error during read repair")
+				logEntry(ctx).WithError(err).Error("unexpected error during read repair")
 			}
 
 			return
 		}
 
+		value := args.Value.(*keyvaluestore.ValueWithTTL)
+
 		var ttl time.Duration
-		if args.Value != nil {
-			ttl = *(args.Value.(*time.Duration))
+		if value.TTL != nil {
+			ttl = *value.TTL
 			if ttl == 0 {
-				err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
-					keyvaluestore.OperationModeConcurrent)
-				if err != nil {
-					logrus.WithError(err).Error("unexpected error during read repair")
-				}
-
 				return
 			}
 		}
 
-		rawValue, err := s.engine.Read(args.Winners, s.majority(len(args.Winners)),
-			getOperator, nil, s.byteComparer, keyvaluestore.VotingModeSkipVoteOnNotFound)
-		if err != nil {
-			logrus.WithError(err).Error("unexpected error during read repair")
-			return
-		}
-
 		setOperator := func(node keyvaluestore.Backend) error {
-			return node.Set(request.Key, rawValue.([]byte), ttl)
+			return node.Set(key, value.Data, ttl)
 		}
 
 		setRollbackOperator := func(rollbackArgs keyvaluestore.RollbackArgs) {
 			err := s.engine.Write(rollbackArgs.Nodes, 0, deleteOperator, deleteRollbackOperator,
 				keyvaluestore.OperationModeConcurrent)
 			if err != nil {
-				logrus.WithError(err).Error("unexpected error during SET rollback")
+				logEntry(ctx).WithError(err).Error("unexpected error during SET rollback")
 			}
 		}
 
-		err = s.engine.Write(args.Losers, 0, setOperator, setRollbackOperator, keyvaluestore.OperationModeConcurrent)
+		s.recordRepair(ctx, key, metrics.RepairValue, args.Losers)
+
+		err := s.engine.Write(args.Losers, 0, setOperator, setRollbackOperator, keyvaluestore.OperationModeConcurrent)
 		if err != nil {
-			logrus.WithError(err).Error("unexpected error during read repair")
+			logEntry(ctx).WithError(err).Error("unexpected error during read repair")
 		}
 	}
 
-	rawResult, err := s.performRead(request.Key, request.Options, readOperator,
-		repairOperator, s.durationComparer)
+	var rawResult interface{}
+	var err error
+	options = s.operationReadOptions(OperationGet, options)
+	if options.StaleWhileRevalidate {
+		rawResult, err = s.performStaleRead(ctx, OperationGet, key, options, readOperator,
+			repairOperator, s.storedValueWithTTLComparer(key))
+	} else {
+		rawResult, err = s.performOperationRead(ctx, OperationGet, key, options, readOperator,
+			repairOperator, s.storedValueWithTTLComparer(key))
+	}
+	if err == keyvaluestore.ErrNotFound {
+		return &keyvaluestore.GetManyItem{}, nil
+	}
 	if err != nil {
 		return nil, s.convertErrorToGRPC(err)
 	}
-	if rawResult == nil {
-		return &keyvaluestore.GetTTLResponse{}, nil
+
+	stored := rawResult.(*keyvaluestore.ValueWithTTL)
+	value, err := s.decodeStoredValue(key, stored.Data)
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
 	}
 
-	return &keyvaluestore.GetTTLResponse{
-		TTL: rawResult.(*time.Duration),
-	}, nil
+	return &keyvaluestore.GetManyItem{Found: true, Data: value.data, Metadata: value.metadata, TTL: stored.TTL}, nil
 }
 
-func (s *coreService) performWrite(key string,
-	options keyvaluestore.WriteOptions,
-	operator keyvaluestore.WriteOperator,
-	rollback keyvaluestore.RollbackOperator,
-	mode keyvaluestore.OperationMode) error {
+func (s *coreService) Delete(ctx context.Context, request *keyvaluestore.DeleteRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
 
-	consistency := s.writeConsistency(options)
-	view, err := s.cluster.Write(key, consistency)
-	if err != nil {
-		return err
+	writeOperator := func(node keyvaluestore.Backend) error {
+		return node.Delete(request.Key)
 	}
 
-	// Use sequential (ordered)[deterministic order guarantee]
-	// write sequence to prevent dining philosopher problem
-	// (a.k.a chance of deadlock)
-	if mode == keyvaluestore.OperationModeSequential {
-		view.Backends = s.sortNodes(view.Backends)
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
 	}
 
-	return s.engine.Write(view.Backends, view.AcknowledgeRequired, operator, rollback, mode)
+	options := s.operationWriteOptions(OperationDelete, request.Options)
+	if request.Previous != nil && options.DryRun == nil {
+		return s.getDel(ctx, request, options)
+	}
+
+	hinted := s.hintedWrite(request.Key, writeOperator)
+	_, err := s.performIdempotentWrite(ctx, OperationDelete, []string{request.Key}, options,
+		func(ctx context.Context) ([]byte, error) {
+			return nil, s.performWrite(ctx, request.Key, options,
+				hinted.Operator(), rollbackOperator, keyvaluestore.OperationModeConcurrent)
+		})
+	hinted.Complete(err)
+
+	return s.convertErrorToGRPC(err)
 }
 
-func (s *coreService) performFlushDb() error {
+// getDel deletes the key from the nodes of its write view, which vote on its
+// previous value. Deletion cannot be undone, so the key is deleted from every
+// node even if they fail to agree.
+func (s *coreService) getDel(ctx context.Context, request *keyvaluestore.DeleteRequest,
+	options keyvaluestore.WriteOptions) error {
 
-	operator := func(node keyvaluestore.Backend) error {
-		return node.FlushDB()
+	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
+		return node.GetDel(request.Key)
 	}
 
-	rollback := func(args keyvaluestore.RollbackArgs) {
+	// The stored value is recorded by idempotent writes, so that retries
+	// return it as well.
+	stored, err := s.performIdempotentWrite(ctx, idempotentGetDel, []string{request.Key}, options,
+		func(ctx context.Context) ([]byte, error) {
+			consistency := s.writeConsistency(options)
+			view, err := s.writeView(request.Key, options)
+			if err != nil {
+				return nil, err
+			}
+
+			s.recordSessionWrite(options, request.Key, consistency)
+			slowlog.FromContext(ctx).RecordKey(request.Key, consistency)
+
+			rawResult, err := s.engine.Read(view.Backends, view.AcknowledgeRequired, readOperator, nil,
+				s.storedValueComparer(request.Key), keyvaluestore.VotingModeVoteOnNotFound, nil)
+			if err != nil {
+				return nil, err
+			}
+
+			return rawResult.([]byte), nil
+		})
+	if err != nil {
+		return s.convertErrorToGRPC(err)
 	}
 
-	view, err := s.cluster.FlushDB()
+	value, err := s.decodeStoredValue(request.Key, stored)
 	if err != nil {
-		return err
+		return s.convertErrorToGRPC(err)
 	}
-	return s.engine.Write(view.Backends, view.AcknowledgeRequired, operator, rollback,
-		keyvaluestore.OperationModeConcurrent)
+
+	*request.Previous = keyvaluestore.GetResponse{Data: value.data, Metadata: value.metadata}
+	return nil
 }
 
-func (s *coreService) performRead(key string,
-	options keyvaluestore.ReadOptions,
-	readOperator keyvaluestore.ReadOperator,
-	repairOperator keyvaluestore.RepairOperator,
-	comparer keyvaluestore.ValueComparer) (interface{}, error) {
+func (s *coreService) DeleteMany(ctx context.Context,
+	request *keyvaluestore.DeleteManyRequest) (*keyvaluestore.DeleteManyResponse, error) {
 
-	consistency := s.readConsistency(options)
-	view, err := s.cluster.Read(key, consistency)
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	result, err := s.performIdempotentWrite(ctx, idempotentDeleteMany, request.Keys, request.Options,
+		func(context.Context) ([]byte, error) {
+			deleted, err := s.deleteMany(request)
+			if err != nil {
+				return nil, err
+			}
+
+			return []byte(strconv.FormatInt(deleted, 10)), nil
+		})
 	if err != nil {
-		return nil, err
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	deleted, err := strconv.ParseInt(string(result), 10, 64)
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
 	}
 
-	return s.engine.Read(view.Backends, view.VoteRequired, readOperator, repairOperator, comparer,
-		view.VotingMode)
+	return &keyvaluestore.DeleteManyResponse{Deleted: deleted}, nil
 }
 
-func (s *coreService) sortNodes(nodes []keyvaluestore.Backend) []keyvaluestore.Backend {
-	var result []keyvaluestore.Backend
-	result = append(result, nodes...)
+func (s *coreService) deleteMany(request *keyvaluestore.DeleteManyRequest) (int64, error) {
+	groups, err := s.groupKeysByWriteView(request.Keys, request.Options)
+	if err != nil {
+		return 0, err
+	}
 
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Address() < result[j].Address()
-	})
+	var deleted int64
 
-	return result
-}
+	for _, group := range groups {
+		var lock sync.Mutex
+		var groupDeleted int64
+		keys := group.keys
 
-func (s *coreService) Close() error {
-	lastErr := s.cluster.Close()
-	if err := s.engine.Close(); err != nil {
-		if lastErr != nil {
-			logrus.WithError(lastErr).Error("unexpected error while closing core service")
+		// Every replica reports the number of keys it has removed, the
+		// most up-to-date replica is the one which has removed the most.
+		writeOperator := func(node keyvaluestore.Backend) error {
+			count, err := node.DeleteMany(keys)
+			if err != nil {
+				return err
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if count > groupDeleted {
+				groupDeleted = count
+			}
+
+			return nil
 		}
 
-		lastErr = err
-	}
+		rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+		}
 
-	return lastErr
-}
+		if request.Options.DryRun != nil {
+			if err := s.performDryRun(group.view, request.Options.DryRun); err != nil {
+				return 0, err
+			}
 
-func (s *coreService) writeConsistency(writeOptions keyvaluestore.WriteOptions) keyvaluestore.ConsistencyLevel {
-	if writeOptions.Consistency == keyvaluestore.ConsistencyLevel_DEFAULT {
-		return s.defaultWriteConsistency
-	}
+			continue
+		}
 
-	return writeOptions.Consistency
-}
+		for _, key := range keys {
+			s.recordSessionWrite(request.Options, key, s.writeConsistency(request.Options))
+		}
 
-func (s *coreService) readConsistency(readOptions keyvaluestore.ReadOptions) keyvaluestore.ConsistencyLevel {
-	if readOptions.Consistency == keyvaluestore.ConsistencyLevel_DEFAULT {
-		return s.defaultReadConsistency
+		err := s.engine.Write(group.view.Backends, group.view.AcknowledgeRequired, writeOperator,
+			rollbackOperator, keyvaluestore.OperationModeConcurrent)
+		if err != nil {
+			return 0, err
+		}
+
+		lock.Lock()
+		deleted += groupDeleted
+		lock.Unlock()
 	}
 
-	return readOptions.Consistency
+	return deleted, nil
 }
 
-func (s *coreService) byteComparer(x, y interface{}) bool {
-	return bytes.Equal(x.([]byte), y.([]byte))
-}
+func (s *coreService) Transaction(ctx context.Context, request *keyvaluestore.TransactionRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
 
-func (s *coreService) durationComparer(x, y interface{}) bool {
-	if x == nil {
-		return y == nil
-	}
-	if y == nil {
-		return false
+	if len(request.Ops) == 0 {
+		return s.convertErrorToGRPC(keyvaluestore.ErrEmptyTransaction)
 	}
 
-	diff := *(x.(*time.Duration)) - *(y.(*time.Duration))
-	if diff < 0 {
-		diff = -diff
+	ops, err := s.encodeOps(request.Ops)
+	if err != nil {
+		return s.convertErrorToGRPC(err)
 	}
 
-	return diff < acceptableDurationDiff
-}
+	var keys []string
+	var setKeys []string
 
-func (s *coreService) booleanComparer(x, y interface{}) bool {
-	return x.(bool) == y.(bool)
-}
+	for _, op := range ops {
+		if op.Type == keyvaluestore.OpSet {
+			setKeys = append(setKeys, op.Key)
+		}
 
-func (s *coreService) convertErrorToGRPC(err error) error {
+		keys = append(keys, op.Key)
+	}
+
+	groups, err := s.groupKeysByWriteView(keys, request.Options)
+	if err != nil {
+		return s.convertErrorToGRPC(err)
+	}
+
+	if len(groups) > 1 {
+		return s.convertErrorToGRPC(keyvaluestore.ErrCrossShard)
+	}
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		return node.Transaction(ops)
+	}
+
+	// Deleted keys can not be restored, so rolling back only removes the
+	// keys the transaction has set, like a failed SET does.
+	deleteOperator := func(node keyvaluestore.Backend) error {
+		_, err := node.DeleteMany(setKeys)
+		return err
+	}
+
+	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+		if len(setKeys) == 0 {
+			return
+		}
+
+		err := s.engine.Write(args.Nodes, 0, deleteOperator, deleteRollbackOperator,
+			keyvaluestore.OperationModeConcurrent)
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during transaction rollback")
+		}
+	}
+
+	_, err = s.performIdempotentWrite(ctx, idempotentTransaction, keys, request.Options,
+		func(ctx context.Context) ([]byte, error) {
+			return nil, s.performWriteOnView(ctx, keys, groups[0].view, request.Options,
+				writeOperator, s.valueRollback(rollbackOperator), keyvaluestore.OperationModeConcurrent)
+		})
+
+	return s.convertErrorToGRPC(err)
+}
+
+// encodeOps validates ops and encodes the values and expirations of set ops
+// as Set does.
+func (s *coreService) encodeOps(ops []keyvaluestore.Op) ([]keyvaluestore.Op, error) {
+	result := make([]keyvaluestore.Op, len(ops))
+
+	for i, op := range ops {
+		if err := s.validateKeyValue(op.Key, op.Data); err != nil {
+			return nil, err
+		}
+
+		switch op.Type {
+		case keyvaluestore.OpSet:
+			data, err := s.encodeValue(op.Key, op.Data, "", 0)
+			if err != nil {
+				return nil, err
+			}
+
+			expiration, err := s.writeExpiration(op.Expiration, false)
+			if err != nil {
+				return nil, err
+			}
+
+			op.Data = data
+			op.Expiration = expiration
+
+		case keyvaluestore.OpDelete:
+
+		default:
+			return nil, keyvaluestore.ErrInvalidOp
+		}
+
+		result[i] = op
+	}
+
+	return result, nil
+}
+
+// Optimistic runs the transaction on the first node of the write view, as
+// ordered for locks, so that concurrent optimistic transactions of every
+// proxy conflict on the same node. Other nodes only replay the applied ops,
+// which are never rolled back since the first node has already committed
+// them.
+func (s *coreService) Optimistic(ctx context.Context, request *keyvaluestore.OptimisticRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	if len(request.Keys) == 0 {
+		return s.convertErrorToGRPC(keyvaluestore.ErrNoKeys)
+	}
+
+	for _, key := range request.Keys {
+		if err := s.validateKeyValue(key, nil); err != nil {
+			return s.convertErrorToGRPC(err)
+		}
+	}
+
+	groups, err := s.groupKeysByWriteView(request.Keys, request.Options)
+	if err != nil {
+		return s.convertErrorToGRPC(err)
+	}
+
+	if len(groups) > 1 {
+		return s.convertErrorToGRPC(keyvaluestore.ErrCrossShard)
+	}
+
+	if request.Options.DryRun != nil {
+		return s.convertErrorToGRPC(s.performDryRun(groups[0].view, request.Options.DryRun))
+	}
+
+	nodes := s.sortNodes(groups[0].view.Backends)
+	if len(nodes) == 0 {
+		return s.convertErrorToGRPC(keyvaluestore.ErrConsistency)
+	}
+
+	var applyErr error
+	apply := func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+		decoded := make(map[string][]byte, len(values))
+		for key, value := range values {
+			stored, err := s.decodeStoredValue(key, value)
+			if err != nil {
+				return nil, err
+			}
+
+			decoded[key] = stored.data
+		}
+
+		ops, err := request.Apply(decoded)
+		if err != nil {
+			applyErr = err
+			return nil, err
+		}
+
+		ops, err = s.encodeOps(ops)
+		if err != nil {
+			return nil, err
+		}
+
+		opGroups, err := s.groupKeysByWriteView(append(keyvaluestore.OpKeys(ops), request.Keys...), request.Options)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(opGroups) > 1 {
+			return nil, keyvaluestore.ErrCrossShard
+		}
+
+		return ops, nil
+	}
+
+	var ops []keyvaluestore.Op
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return s.convertErrorToGRPC(err)
+		}
+
+		applyErr = nil
+		ops, err = nodes[0].OptimisticTransaction(request.Keys, apply)
+		if err != keyvaluestore.ErrOptimisticConflict || attempt >= request.MaxRetries {
+			break
+		}
+	}
+
+	if err != nil {
+		// Apply may pick the code of its errors by returning a status error
+		if _, ok := status.FromError(err); ok && err == applyErr {
+			return err
+		}
+
+		return s.convertErrorToGRPC(err)
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	view := keyvaluestore.WriteClusterView{Backends: nodes[1:]}
+	if required := groups[0].view.AcknowledgeRequired - 1; required > 0 {
+		view.AcknowledgeRequired = required
+	}
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		return node.Transaction(ops)
+	}
+
+	return s.convertErrorToGRPC(s.performWriteOnView(ctx, keyvaluestore.OpKeys(ops), view, request.Options,
+		writeOperator, nil, keyvaluestore.OperationModeConcurrent))
+}
+func (s *coreService) DeletePattern(ctx context.Context,
+	request *keyvaluestore.DeletePatternRequest) (*keyvaluestore.DeletePatternResponse, error) {
+
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	// Keys matching the pattern might be placed on any node, rather than on
+	// the nodes of a view of the pattern as a key
+	view, err := s.cluster.FlushDB()
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	if request.Options.DryRun != nil {
+		return &keyvaluestore.DeletePatternResponse{},
+			s.convertErrorToGRPC(s.performDryRun(view, request.Options.DryRun))
+	}
+
+	var lock sync.Mutex
+	var deleted int64
+	nodeErrors := make(map[string]error)
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		count, err := s.deletePatternOnNode(ctx, node, request.Pattern)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if count > deleted {
+			deleted = count
+		}
+		if err != nil {
+			nodeErrors[node.Address()] = err
+		}
+
+		return err
+	}
+
+	// Wait for every node to complete, deleting by pattern is best-effort and
+	// the caller is interested in all errors rather than the first quorum.
+	// Keys deleted from some nodes are not restored if others fail.
+	err = s.performOnEveryNode(view.Backends, writeOperator)
+
+	if err != nil && len(nodeErrors) >= len(view.Backends) {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	return &keyvaluestore.DeletePatternResponse{
+		Deleted: deleted,
+		Errors:  nodeErrors,
+	}, nil
+}
+
+func (s *coreService) FlushDB(ctx context.Context,
+	request *keyvaluestore.FlushDBRequest) (*keyvaluestore.FlushDBResponse, error) {
+
+	response, err := s.performFlushDb(request.Nodes)
+	if err != nil {
+		return response, s.convertErrorToGRPC(err)
+	}
+
+	return response, nil
+}
+
+func (s *coreService) Stats(ctx context.Context) (*keyvaluestore.StatsResponse, error) {
+	// The view of FlushDB covers every node of the cluster
+	view, err := s.cluster.FlushDB()
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	result := &keyvaluestore.StatsResponse{
+		Nodes: make([]keyvaluestore.NodeStats, len(view.Backends)),
+	}
+
+	var wg sync.WaitGroup
+	for i, node := range view.Backends {
+		wg.Add(1)
+
+		go func(stats *keyvaluestore.NodeStats, node keyvaluestore.Backend) {
+			defer wg.Done()
+
+			stats.Address = node.Address()
+
+			var err error
+			stats.Keys, err = node.DBSize()
+			if err == nil {
+				stats.UsedMemoryBytes, err = node.UsedMemory()
+			}
+			if err != nil {
+				stats.Error = err.Error()
+			}
+		}(&result.Nodes[i], node)
+	}
+
+	wg.Wait()
+
+	return result, nil
+}
+
+func (s *coreService) Inspect(ctx context.Context,
+	request *keyvaluestore.InspectRequest) (*keyvaluestore.InspectResponse, error) {
+
+	nodes := s.cluster.Nodes()
+	result := &keyvaluestore.InspectResponse{
+		Nodes: make([]keyvaluestore.NodeValue, len(nodes)),
+	}
+
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+
+		go func(value *keyvaluestore.NodeValue, node keyvaluestore.Backend) {
+			defer wg.Done()
+
+			value.Address = node.Address()
+
+			stored, err := node.GetWithTTL(request.Key)
+			switch err {
+			case nil:
+				value.Exists = true
+				value.Data = stored.Data
+				if stored.TTL != nil {
+					ttl := stored.TTL.Milliseconds()
+					value.TTLMs = &ttl
+				}
+
+			case keyvaluestore.ErrNotFound:
+
+			default:
+				value.Error = err.Error()
+			}
+		}(&result.Nodes[i], node)
+	}
+
+	wg.Wait()
+
+	return result, nil
+}
+
+func (s *coreService) Lock(ctx context.Context, request *keyvaluestore.LockRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	if err := s.validateKeyValue(request.Key, request.Data); err != nil {
+		return s.convertErrorToGRPC(err)
+	}
+
+	if request.WaitTimeout <= 0 {
+		_, err := s.lock(ctx, request)
+		return s.convertErrorToGRPC(err)
+	}
+
+	deadline := time.NewTimer(request.WaitTimeout)
+	defer deadline.Stop()
+
+	for {
+		held, err := s.lock(ctx, request)
+		if err != keyvaluestore.ErrConsistency || !held {
+			return s.convertErrorToGRPC(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return s.convertErrorToGRPC(ctx.Err())
+
+		case <-deadline.C:
+			return s.convertErrorToGRPC(err)
+
+		case <-time.After(s.lockPollInterval):
+		}
+	}
+}
+
+// lock takes the lock once. held is true if the lock was not taken only
+// because other clients hold it, rather than because nodes have failed.
+func (s *coreService) lock(ctx context.Context, request *keyvaluestore.LockRequest) (held bool, err error) {
+	var taken, failed int32
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		err := node.Lock(request.Key, request.Data, request.Expiration)
+		if err == keyvaluestore.ErrNotAcquired {
+			atomic.StoreInt32(&taken, 1)
+		} else if err != nil {
+			atomic.StoreInt32(&failed, 1)
+		}
+
+		return err
+	}
+
+	unlockOperator := func(node keyvaluestore.Backend) error {
+		return node.Unlock(request.Key)
+	}
+
+	unlockRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+		err := s.engine.Write(args.Nodes, 0, unlockOperator, unlockRollbackOperator,
+			keyvaluestore.OperationModeConcurrent)
+
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during LOCK rollback")
+		}
+	}
+
+	// Use sequential (ordered) write sequence to prevent dining philosopher problem
+	// (a.k.a chance of deadlock)
+	err = s.performWrite(ctx, request.Key, s.lockOptions(request.Options), writeOperator,
+		rollbackOperator, keyvaluestore.OperationModeSequential)
+
+	return atomic.LoadInt32(&taken) == 1 && atomic.LoadInt32(&failed) == 0, err
+}
+
+func (s *coreService) Unlock(ctx context.Context, request *keyvaluestore.UnlockRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	writeOperator := func(backend keyvaluestore.Backend) error {
+		return backend.Unlock(request.Key)
+	}
+
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	return s.convertErrorToGRPC(s.performWrite(ctx, request.Key, s.lockOptions(request.Options),
+		writeOperator, rollbackOperator, keyvaluestore.OperationModeConcurrent))
+}
+
+func (s *coreService) RenewLock(ctx context.Context, request *keyvaluestore.RenewLockRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	var lost, failed int32
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		err := node.RenewLock(request.Key, request.Data, request.Expiration)
+		if err == keyvaluestore.ErrLockLost {
+			atomic.StoreInt32(&lost, 1)
+		} else if err != nil {
+			atomic.StoreInt32(&failed, 1)
+		}
+
+		return err
+	}
+
+	// A renewal cannot be undone, the renewed nodes simply keep the lock
+	// until the new expiration.
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	err := s.performWrite(ctx, request.Key, s.lockOptions(request.Options), writeOperator,
+		rollbackOperator, keyvaluestore.OperationModeConcurrent)
+
+	// The lock is only reported lost if no node failed otherwise, as the
+	// renewal might have succeeded had they been available
+	if err == keyvaluestore.ErrConsistency && atomic.LoadInt32(&lost) == 1 &&
+		atomic.LoadInt32(&failed) == 0 {
+
+		err = keyvaluestore.ErrLockLost
+	}
+
+	return s.convertErrorToGRPC(err)
+}
+
+func (s *coreService) Acquire(ctx context.Context, request *keyvaluestore.AcquireRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	if err := s.validateKeyValue(request.Key, []byte(request.Holder)); err != nil {
+		return s.convertErrorToGRPC(err)
+	}
+	if request.MaxHolders < 1 || request.Expiration <= 0 {
+		return s.convertErrorToGRPC(keyvaluestore.ErrInvalidHolders)
+	}
+
+	var lock sync.Mutex
+	var full, failed bool
+	renewed := make(map[keyvaluestore.Backend]bool)
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		renewal, err := node.Acquire(request.Key, request.Holder, request.MaxHolders, request.Expiration)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch err {
+		case nil:
+			renewed[keyvaluestore.Origin(node)] = renewal
+
+		case keyvaluestore.ErrNotAcquired:
+			full = true
+
+		default:
+			failed = true
+		}
+
+		return err
+	}
+
+	releaseOperator := func(node keyvaluestore.Backend) error {
+		return node.Release(request.Key, request.Holder)
+	}
+
+	releaseRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	// Nodes on which holder already had a slot keep it, since releasing it
+	// would drop the slot the holder has acquired before
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+		lock.Lock()
+		var nodes []keyvaluestore.Backend
+		for _, node := range args.Nodes {
+			if !renewed[keyvaluestore.Origin(node)] {
+				nodes = append(nodes, node)
+			}
+		}
+		lock.Unlock()
+
+		if len(nodes) == 0 {
+			return
+		}
+
+		err := s.engine.Write(nodes, 0, releaseOperator, releaseRollbackOperator,
+			keyvaluestore.OperationModeConcurrent)
+
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during ACQUIRE rollback")
+		}
+	}
+
+	// Same as Lock, acquire in order to prevent concurrent acquirers from
+	// each taking the last slot on a different minority of nodes
+	err := s.performWrite(ctx, request.Key, request.Options, writeOperator,
+		rollbackOperator, keyvaluestore.OperationModeSequential)
+
+	// The semaphore is only reported full if no node failed otherwise, as
+	// the acquisition might have succeeded had they been available
+	lock.Lock()
+	if err == keyvaluestore.ErrConsistency && full && !failed {
+		err = keyvaluestore.ErrNotAcquired
+	}
+	lock.Unlock()
+
+	return s.convertErrorToGRPC(err)
+}
+
+func (s *coreService) Release(ctx context.Context, request *keyvaluestore.ReleaseRequest) error {
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	writeOperator := func(backend keyvaluestore.Backend) error {
+		return backend.Release(request.Key, request.Holder)
+	}
+
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	return s.convertErrorToGRPC(s.performWrite(ctx, request.Key, request.Options, writeOperator,
+		rollbackOperator, keyvaluestore.OperationModeConcurrent))
+}
+
+func (s *coreService) Expire(ctx context.Context,
+	request *keyvaluestore.ExpireRequest) (*keyvaluestore.ExpireResponse, error) {
+
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	// Non-positive expirations delete the key rather than keeping it forever
+	expiration := request.Expiration
+	if expiration > 0 {
+		var err error
+		if expiration, err = s.capExpiration(expiration); err != nil {
+			return nil, s.convertErrorToGRPC(err)
+		}
+	}
+
+	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
+		err := node.Expire(request.Key, expiration)
+		if err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	deleteOperator := func(node keyvaluestore.Backend) error {
+		return node.Delete(request.Key)
+	}
+
+	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	repairOperator := func(args keyvaluestore.RepairArgs) {
+		if args.Err == keyvaluestore.ErrNotFound {
+			s.recordRepair(ctx, request.Key, metrics.RepairStaleDelete, args.Losers)
+
+			err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
+				keyvaluestore.OperationModeConcurrent)
+			if err != nil {
+				logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+			}
+
+			return
+		}
+
+		s.repairLosersFromWinners(ctx, request.Key, metrics.RepairTTL, args)
+	}
+
+	rawResult, err := s.performRead(ctx, request.Key, keyvaluestore.ReadOptions{
+		Consistency:   request.Options.Consistency,
+		RequiredNodes: request.Options.RequiredNodes,
+		Session:       request.Options.Session,
+	}, readOperator, repairOperator, s.booleanComparer)
+	if err == keyvaluestore.ErrNotFound && request.MissingKey == keyvaluestore.MissingKeyNoop {
+		return &keyvaluestore.ExpireResponse{Exists: false}, nil
+	}
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	return &keyvaluestore.ExpireResponse{Exists: rawResult.(bool)}, nil
+}
+
+func (s *coreService) Touch(ctx context.Context,
+	request *keyvaluestore.TouchRequest) (*keyvaluestore.TouchResponse, error) {
+
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	expiration := request.Expiration
+	if expiration > 0 {
+		var err error
+		if expiration, err = s.capExpiration(expiration); err != nil {
+			return nil, s.convertErrorToGRPC(err)
+		}
+	}
+
+	var found int32
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		err := node.Expire(request.Key, expiration)
+		if err == keyvaluestore.ErrNotFound {
+			return nil
+		}
+		if err == nil {
+			atomic.AddInt32(&found, 1)
+		}
+
+		return err
+	}
+
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	err := s.performWrite(ctx, request.Key, request.Options, writeOperator, rollbackOperator,
+		keyvaluestore.OperationModeConcurrent)
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	return &keyvaluestore.TouchResponse{Exists: atomic.LoadInt32(&found) > 0}, nil
+}
+
+func (s *coreService) RateLimitCheck(ctx context.Context,
+	request *keyvaluestore.RateLimitCheckRequest) (*keyvaluestore.RateLimitCheckResponse, error) {
+
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	if err := s.validateKeyValue(request.Key, nil); err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+	if request.Limit < 1 || request.Window <= 0 {
+		return nil, s.convertErrorToGRPC(keyvaluestore.ErrInvalidLimit)
+	}
+
+	counterKey := rateLimitKeyPrefix + request.Key
+
+	var countLock sync.Mutex
+	var count int64
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		nodeCount, err := node.IncrWindow(counterKey, request.Window)
+		if err != nil {
+			return err
+		}
+
+		countLock.Lock()
+		if nodeCount > count {
+			count = nodeCount
+		}
+		countLock.Unlock()
+
+		return nil
+	}
+
+	// Hits counted by a failed check are not taken back, which errs on the
+	// side of limiting.
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	err := s.performWrite(ctx, counterKey, request.Options, writeOperator, rollbackOperator,
+		keyvaluestore.OperationModeConcurrent)
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	countLock.Lock()
+	defer countLock.Unlock()
+
+	remaining := request.Limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &keyvaluestore.RateLimitCheckResponse{
+		Allowed:   count <= request.Limit,
+		Remaining: remaining,
+	}, nil
+}
+
+func (s *coreService) Exists(ctx context.Context,
+	request *keyvaluestore.ExistsRequest) (*keyvaluestore.ExistsResponse, error) {
+
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
+		result, err := node.Exists(request.Key)
+		if err != nil {
+			return false, err
+		}
+		if !result {
+			return false, keyvaluestore.ErrNotFound
+		}
+
+		return true, nil
+	}
+
+	deleteOperator := func(node keyvaluestore.Backend) error {
+		return node.Delete(request.Key)
+	}
+
+	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	repairOperator := func(args keyvaluestore.RepairArgs) {
+		if args.Err == keyvaluestore.ErrNotFound {
+			s.recordRepair(ctx, request.Key, metrics.RepairStaleDelete, args.Losers)
+
+			err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
+				keyvaluestore.OperationModeConcurrent)
+			if err != nil {
+				logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+			}
+
+			return
+		}
+
+		s.repairLosersFromWinners(ctx, request.Key, metrics.RepairValue, args)
+	}
+
+	rawResult, err := s.performOperationRead(ctx, OperationExists, request.Key,
+		s.operationReadOptions(OperationExists, request.Options), readOperator,
+		repairOperator, s.booleanComparer)
+	if err != nil {
+		if err == keyvaluestore.ErrNotFound {
+			return &keyvaluestore.ExistsResponse{Exists: false}, nil
+		}
+
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	return &keyvaluestore.ExistsResponse{Exists: rawResult.(bool)}, nil
+}
+
+// nolint: golint
+func (s *coreService) GetTTL(ctx context.Context,
+	request *keyvaluestore.GetTTLRequest) (*keyvaluestore.GetTTLResponse, error) {
+
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
+		return node.TTL(request.Key)
+	}
+
+	deleteOperator := func(node keyvaluestore.Backend) error {
+		return node.Delete(request.Key)
+	}
+
+	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	getOperator := func(node keyvaluestore.Backend) (interface{}, error) {
+		return node.Get(request.Key)
+	}
+
+	repairOperator := func(args keyvaluestore.RepairArgs) {
+		if args.Err == keyvaluestore.ErrNotFound {
+			s.recordRepair(ctx, request.Key, metrics.RepairStaleDelete, args.Losers)
+
+			err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
+				keyvaluestore.OperationModeConcurrent)
+			if err != nil {
+				logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+			}
+
+			return
+		}
+
+		var ttl time.Duration
+		if value, _ := args.Value.(*time.Duration); value != nil {
+			ttl = *value
+			if ttl == 0 {
+				s.recordRepair(ctx, request.Key, metrics.RepairStaleDelete, args.Losers)
+
+				err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
+					keyvaluestore.OperationModeConcurrent)
+				if err != nil {
+					logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+				}
+
+				return
+			}
+		}
+
+		rawValue, err := s.engine.Read(args.Winners, s.majority(len(args.Winners)),
+			getOperator, nil, s.byteComparer, keyvaluestore.VotingModeSkipVoteOnNotFound, nil)
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+			return
+		}
+
+		setOperator := func(node keyvaluestore.Backend) error {
+			return node.Set(request.Key, rawValue.([]byte), ttl)
+		}
+
+		setRollbackOperator := func(rollbackArgs keyvaluestore.RollbackArgs) {
+			err := s.engine.Write(rollbackArgs.Nodes, 0, deleteOperator, deleteRollbackOperator,
+				keyvaluestore.OperationModeConcurrent)
+			if err != nil {
+				logEntry(ctx).WithError(err).Error("unexpected error during SET rollback")
+			}
+		}
+
+		s.recordRepair(ctx, request.Key, metrics.RepairTTL, args.Losers)
+
+		err = s.engine.Write(args.Losers, 0, setOperator, setRollbackOperator, keyvaluestore.OperationModeConcurrent)
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+		}
+	}
+
+	rawResult, err := s.performOperationRead(ctx, OperationGetTTL, request.Key,
+		s.operationReadOptions(OperationGetTTL, request.Options), readOperator,
+		repairOperator, s.durationComparer)
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+	if rawResult == nil || rawResult.(*time.Duration) == nil {
+		return &keyvaluestore.GetTTLResponse{}, nil
+	}
+
+	ttl := rawResult.(*time.Duration).Truncate(time.Millisecond)
+	return &keyvaluestore.GetTTLResponse{
+		TTL: &ttl,
+	}, nil
+}
+
+// repairLosersFromWinners fetches value along with its TTL from winners in a
+// single round-trip per node and applies them to losers.
+func (s *coreService) repairLosersFromWinners(ctx context.Context, key string, repairType string,
+	args keyvaluestore.RepairArgs) {
+
+	deleteOperator := func(node keyvaluestore.Backend) error {
+		return node.Delete(key)
+	}
+
+	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	getWithTTLOperator := func(node keyvaluestore.Backend) (interface{}, error) {
+		return node.GetWithTTL(key)
+	}
+
+	rawValue, err := s.engine.Read(args.Winners, s.majority(len(args.Winners)),
+		getWithTTLOperator, nil, s.valueWithTTLComparer, keyvaluestore.VotingModeSkipVoteOnNotFound, nil)
+	if err != nil {
+		logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+		return
+	}
+
+	value := rawValue.(*keyvaluestore.ValueWithTTL)
+
+	var ttl time.Duration
+	if value.TTL != nil {
+		ttl = *value.TTL
+		if ttl == 0 {
+			s.recordRepair(ctx, key, metrics.RepairStaleDelete, args.Losers)
+
+			err := s.engine.Write(args.Losers, 0, deleteOperator, deleteRollbackOperator,
+				keyvaluestore.OperationModeConcurrent)
+			if err != nil {
+				logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+			}
+
+			return
+		}
+	}
+
+	setOperator := func(node keyvaluestore.Backend) error {
+		return node.Set(key, value.Data, ttl)
+	}
+
+	setRollbackOperator := func(rollbackArgs keyvaluestore.RollbackArgs) {
+		err := s.engine.Write(rollbackArgs.Nodes, 0, deleteOperator, deleteRollbackOperator,
+			keyvaluestore.OperationModeConcurrent)
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during SET rollback")
+		}
+	}
+
+	s.recordRepair(ctx, key, repairType, args.Losers)
+
+	err = s.engine.Write(args.Losers, 0, setOperator, setRollbackOperator, keyvaluestore.OperationModeConcurrent)
+	if err != nil {
+		logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+	}
+}
+
+// resolveConflict fetches the value from every node, lets the conflict
+// resolver pick a winner among them and copies the winner to the rest of the
+// nodes. Repair is forfeited if any of the nodes fails to respond.
+func (s *coreService) resolveConflict(ctx context.Context, key string, nodes []keyvaluestore.Backend) {
+	var lock sync.Mutex
+	values := make(map[keyvaluestore.Backend]*keyvaluestore.ValueWithTTL)
+
+	getWithTTLOperator := func(node keyvaluestore.Backend) error {
+		value, err := node.GetWithTTL(key)
+		if err != nil && err != keyvaluestore.ErrNotFound {
+			return err
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		values[node] = value
+		return nil
+	}
+
+	if err := s.performOnEveryNode(nodes, getWithTTLOperator); err != nil {
+		logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+		return
+	}
+
+	var candidates []*keyvaluestore.ValueWithTTL
+	for _, value := range values {
+		if value != nil {
+			candidates = append(candidates, value)
+		}
+	}
+
+	winner := s.conflictResolver(candidates)
+	if winner == nil {
+		return
+	}
+
+	var ttl time.Duration
+	if winner.TTL != nil {
+		ttl = *winner.TTL
+		if ttl == 0 {
+			return
+		}
+	}
+
+	var losers []keyvaluestore.Backend
+	for _, node := range nodes {
+		if value := values[node]; value == nil || !bytes.Equal(value.Data, winner.Data) {
+			losers = append(losers, node)
+		}
+	}
+
+	if s.maxRepairsPerRead > 0 || s.repairLimit != nil {
+		losers = s.admitRepairs(losers)
+	}
+	if len(losers) == 0 {
+		return
+	}
+
+	setOperator := func(node keyvaluestore.Backend) error {
+		return node.Set(key, winner.Data, ttl)
+	}
+
+	s.recordRepair(ctx, key, metrics.RepairValue, losers)
+
+	err := s.engine.Write(losers, 0, setOperator, nil, keyvaluestore.OperationModeConcurrent)
+	if err != nil {
+		logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+	}
+}
+
+// performIdempotentWrite runs write of operation on keys at most once per
+// idempotency key. A record of the write is kept in the backends themselves,
+// so that retries of a successful write return its original result instead
+// of re-applying it.
+func (s *coreService) performIdempotentWrite(ctx context.Context, operation string, keys []string,
+	options keyvaluestore.WriteOptions, write func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+
+	if options.IdempotencyKey == "" || options.DryRun != nil {
+		return write(ctx)
+	}
+
+	recordKey := idempotencyRecordKey(operation, keys, options.IdempotencyKey)
+	recordOptions := keyvaluestore.WriteOptions{Consistency: options.Consistency}
+
+	// The pending record only outlives the write if the proxy dies meanwhile
+	lease := s.idempotencyLease
+	if options.Timeout > lease {
+		lease = options.Timeout
+	}
+
+	lockOperator := func(node keyvaluestore.Backend) error {
+		return node.Lock(recordKey, []byte(idempotencyPending), lease)
+	}
+
+	deleteOperator := func(node keyvaluestore.Backend) error {
+		return node.Delete(recordKey)
+	}
+
+	deleteRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	lockRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+		err := s.engine.Write(args.Nodes, 0, deleteOperator, deleteRollbackOperator,
+			keyvaluestore.OperationModeConcurrent)
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during idempotency record rollback")
+		}
+	}
+
+	err := s.performWrite(ctx, recordKey, recordOptions, lockOperator, lockRollbackOperator,
+		keyvaluestore.OperationModeSequential)
+	if err != nil {
+		return s.idempotencyRecordResult(ctx, recordKey, s.writeConsistency(options), err)
+	}
+
+	rollbacks := &pendingRollbacks{}
+	attemptCtx := context.WithValue(ctx, pendingRollbacksKey{}, rollbacks)
+
+	result, err := write(attemptCtx)
+	for attempt := 1; s.retryConsistency(ctx, attempt, err, metrics.RetryWrite); attempt++ {
+		// Rolling back the failed attempt must not undo the next one
+		rollbacks.Wait()
+		result, err = write(attemptCtx)
+	}
+	if err != nil {
+		if releaseErr := s.performWrite(ctx, recordKey, recordOptions, deleteOperator, deleteRollbackOperator,
+			keyvaluestore.OperationModeConcurrent); releaseErr != nil {
+			logEntry(ctx).WithError(releaseErr).Error("unexpected error while releasing idempotency record")
+		}
+
+		return nil, err
+	}
+
+	doneOperator := func(node keyvaluestore.Backend) error {
+		return node.Set(recordKey, append([]byte(idempotencyDone), result...), s.idempotencyTTL)
+	}
+
+	if err := s.performWrite(ctx, recordKey, recordOptions, doneOperator, deleteRollbackOperator,
+		keyvaluestore.OperationModeConcurrent); err != nil {
+		logEntry(ctx).WithError(err).Error("unexpected error while completing idempotency record")
+	}
+
+	return result, nil
+}
+
+// pendingRollbacks tracks rollbacks of writes which have failed, but whose
+// rollback might not have run yet, since the engine returns early.
+type pendingRollbacks struct {
+	sync.WaitGroup
+}
+
+type pendingRollbacksKey struct{}
+
+// trackRollback makes rollback known to the pending rollbacks of ctx, if any.
+// done should be called with the result of the engine, which runs rollback
+// only if the write has failed.
+func trackRollback(ctx context.Context,
+	rollback keyvaluestore.RollbackOperator) (keyvaluestore.RollbackOperator, func(err error)) {
+
+	rollbacks, _ := ctx.Value(pendingRollbacksKey{}).(*pendingRollbacks)
+	if rollbacks == nil || rollback == nil {
+		return rollback, func(err error) {}
+	}
+
+	rollbacks.Add(1)
+	trackedRollback := func(args keyvaluestore.RollbackArgs) {
+		defer rollbacks.Done()
+		rollback(args)
+	}
+
+	return trackedRollback, func(err error) {
+		if err == nil {
+			rollbacks.Done()
+		}
+	}
+}
+
+// retryConsistency tells whether to make another attempt of an operation
+// which has failed with err, after waiting for the retry delay.
+func (s *coreService) retryConsistency(ctx context.Context, attempt int, err error, retryType string) bool {
+	if err != keyvaluestore.ErrConsistency || attempt >= s.retryAttempts {
+		return false
+	}
+
+	delay := s.retryDelay
+	if delay > 0 {
+		delay += time.Duration(rand.Int63n(int64(delay)))
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return false
+	}
+
+	metrics.ConsistencyRetriesTotal.WithLabelValues(retryType).Inc()
+	return true
+}
+
+// idempotencyRecordKey is the key of the record of writes of operation on
+// keys, so that an idempotency key reused for another write does not replay
+// the result of the first one.
+func idempotencyRecordKey(operation string, keys []string, idempotencyKey string) string {
+	digest := sha256.New()
+	for _, key := range keys {
+		digest.Write([]byte(strconv.Itoa(len(key)) + ":" + key))
+	}
+
+	return idempotencyKeyPrefix + operation + ":" + hex.EncodeToString(digest.Sum(nil)[:16]) + ":" +
+		idempotencyKey
+}
+
+// encodeAcknowledgement encodes acknowledgement as the recorded result of an
+// idempotent write.
+func encodeAcknowledgement(acknowledgement *keyvaluestore.WriteAcknowledgement) []byte {
+	return []byte(strconv.Itoa(acknowledgement.Acknowledged) + "/" + strconv.Itoa(acknowledgement.Nodes))
+}
+
+// decodeAcknowledgement is the reverse of encodeAcknowledgement. Records which
+// do not hold an acknowledgement decode to zero counts.
+func decodeAcknowledgement(result []byte) keyvaluestore.WriteAcknowledgement {
+	var acknowledgement keyvaluestore.WriteAcknowledgement
+
+	parts := bytes.SplitN(result, []byte("/"), 2)
+	if len(parts) != 2 {
+		return acknowledgement
+	}
+
+	acknowledged, err := strconv.Atoi(string(parts[0]))
+	if err != nil {
+		return acknowledgement
+	}
+	nodes, err := strconv.Atoi(string(parts[1]))
+	if err != nil {
+		return acknowledgement
+	}
+
+	acknowledgement.Acknowledged = acknowledged
+	acknowledgement.Nodes = nodes
+	return acknowledgement
+}
+
+// idempotencyRecordResult returns the result of a previous write recorded
+// under recordKey, or acquireErr if there is no such record. The record is
+// read at the consistency of the write, which has been recorded at it.
+func (s *coreService) idempotencyRecordResult(ctx context.Context, recordKey string,
+	consistency keyvaluestore.ConsistencyLevel, acquireErr error) ([]byte, error) {
+
+	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
+		return node.Get(recordKey)
+	}
+
+	rawRecord, err := s.performRead(ctx, recordKey, keyvaluestore.ReadOptions{Consistency: consistency},
+		readOperator, nil, s.byteComparer)
+	if err != nil {
+		return nil, acquireErr
+	}
+
+	record := rawRecord.([]byte)
+	if !bytes.HasPrefix(record, []byte(idempotencyDone)) {
+		return nil, keyvaluestore.ErrWriteInProgress
+	}
+
+	return record[len(idempotencyDone):], nil
+}
+
+func (s *coreService) validateKeyValue(key string, data []byte) error {
+	if s.maxKeyBytes > 0 && len(key) > s.maxKeyBytes {
+		return keyvaluestore.ErrKeyTooLarge
+	}
+
+	if s.maxValueBytes > 0 && len(data) > s.maxValueBytes {
+		return keyvaluestore.ErrValueTooLarge
+	}
+
+	return nil
+}
+
+// logEntry carries the correlation ID of ctx, if any, into log entries.
+func logEntry(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if id := keyvaluestore.CorrelationIDFromContext(ctx); id != "" {
+		return entry.WithField("correlation_id", id)
+	}
+
+	return entry
+}
+
+// admitRepairs returns the losers which may be repaired within the repair
+// limits, picked at random so that no loser is left behind for good.
+func (s *coreService) admitRepairs(losers []keyvaluestore.Backend) []keyvaluestore.Backend {
+	admitted := append([]keyvaluestore.Backend(nil), losers...)
+	rand.Shuffle(len(admitted), func(i, j int) {
+		admitted[i], admitted[j] = admitted[j], admitted[i]
+	})
+
+	if s.maxRepairsPerRead > 0 && len(admitted) > s.maxRepairsPerRead {
+		admitted = admitted[:s.maxRepairsPerRead]
+	}
+
+	if s.repairLimit != nil {
+		count := 0
+		for count < len(admitted) && s.repairLimit.Allow() {
+			count++
+		}
+		admitted = admitted[:count]
+	}
+
+	if deferred := len(losers) - len(admitted); deferred > 0 {
+		metrics.DeferredRepairsTotal.Add(float64(deferred))
+	}
+
+	return admitted
+}
+
+func (s *coreService) recordRepair(ctx context.Context, key string, repairType string,
+	losers []keyvaluestore.Backend) {
+
+	if len(losers) == 0 {
+		return
+	}
+
+	metrics.RepairsTotal.WithLabelValues(repairType).Inc()
+
+	if s.logRepairs {
+		var nodes []string
+		for _, loser := range losers {
+			nodes = append(nodes, loser.Address())
+		}
+
+		logEntry(ctx).WithFields(logrus.Fields{
+			"key":    key,
+			"type":   repairType,
+			"losers": nodes,
+		}).Info("read repair")
+	}
+}
+
+// valueRollback returns rollback of a write of values, unless write rollback
+// is disabled.
+func (s *coreService) valueRollback(rollback keyvaluestore.RollbackOperator) keyvaluestore.RollbackOperator {
+	if !s.writeRollback {
+		return nil
+	}
+
+	return rollback
+}
+
+func (s *coreService) performWrite(ctx context.Context, key string,
+	options keyvaluestore.WriteOptions,
+	operator keyvaluestore.WriteOperator,
+	rollback keyvaluestore.RollbackOperator,
+	mode keyvaluestore.OperationMode) error {
+
+	view, err := s.writeView(key, options)
+	if err != nil {
+		return err
+	}
+
+	return s.performWriteOnView(ctx, []string{key}, view, options, operator, rollback, mode)
+}
+
+// performAcknowledgedWrite is performWrite which also reports how many nodes
+// acknowledged the write by the time it completed.
+func (s *coreService) performAcknowledgedWrite(ctx context.Context, key string,
+	options keyvaluestore.WriteOptions,
+	operator keyvaluestore.WriteOperator,
+	rollback keyvaluestore.RollbackOperator,
+	acknowledgement *keyvaluestore.WriteAcknowledgement) error {
+
+	view, err := s.writeView(key, options)
+	if err != nil {
+		return err
+	}
+
+	var acknowledged int32
+	countingOperator := func(node keyvaluestore.Backend) error {
+		err := operator(node)
+		if err == nil {
+			atomic.AddInt32(&acknowledged, 1)
+		}
+
+		return err
+	}
+
+	err = s.performWriteOnView(ctx, []string{key}, view, options, countingOperator, rollback,
+		keyvaluestore.OperationModeConcurrent)
+
+	acknowledgement.Acknowledged = int(atomic.LoadInt32(&acknowledged))
+	acknowledgement.Nodes = len(view.Backends)
+
+	return err
+}
+
+// performWriteOnView writes keys which are known to share view, e.g. the
+// keys of a transaction. Same as performRead, it returns once ctx is done,
+// leaving the rollback of nodes already written to the engine.
+func (s *coreService) performWriteOnView(ctx context.Context, keys []string,
+	view keyvaluestore.WriteClusterView,
+	options keyvaluestore.WriteOptions,
+	operator keyvaluestore.WriteOperator,
+	rollback keyvaluestore.RollbackOperator,
+	mode keyvaluestore.OperationMode) error {
+
+	if options.DryRun != nil {
+		return s.performDryRun(view, options.DryRun)
+	}
+
+	// Use sequential (ordered)[deterministic order guarantee]
+	// write sequence to prevent dining philosopher problem
+	// (a.k.a chance of deadlock)
+	if mode == keyvaluestore.OperationModeSequential {
+		view.Backends = s.sortNodes(view.Backends)
+	}
+
+	consistency := s.writeConsistency(options)
+	trace := slowlog.FromContext(ctx)
+
+	for _, key := range keys {
+		s.recordSessionWrite(options, key, consistency)
+		trace.RecordKey(key, consistency)
+	}
+
+	if trace != nil {
+		writeOperator := operator
+		operator = func(node keyvaluestore.Backend) error {
+			start := time.Now()
+			err := writeOperator(node)
+			trace.RecordTiming(node.Address(), slowlog.PhaseWrite, time.Since(start), err)
+
+			return err
+		}
+
+		if rollback != nil {
+			rollbackOperator := rollback
+			rollback = func(args keyvaluestore.RollbackArgs) {
+				start := time.Now()
+				rollbackOperator(args)
+				trace.RecordTiming("", slowlog.PhaseRollback, time.Since(start), nil)
+			}
+		}
+	}
+
+	contextAwareOperator := func(node keyvaluestore.Backend) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		return operator(keyvaluestore.BindContext(ctx, node))
+	}
+
+	rollback, rollbackDone := trackRollback(ctx, rollback)
+
+	resultChannel := make(chan error, 1)
+	go func() {
+		err := s.engine.Write(view.Backends, view.AcknowledgeRequired, contextAwareOperator, rollback, mode)
+		rollbackDone(err)
+		resultChannel <- err
+	}()
+
+	select {
+	case err := <-resultChannel:
+		// Nodes skipped due to cancellation fail the write, which should be
+		// reported as the cancellation itself
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return err
+
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recordSessionWrite is called before writing, since even a failed write
+// might have reached some of the nodes.
+func (s *coreService) recordSessionWrite(options keyvaluestore.WriteOptions,
+	key string, consistency keyvaluestore.ConsistencyLevel) {
+
+	if s.sessions != nil && options.Session != "" {
+		s.sessions.recordWrite(options.Session, key, consistency, time.Now())
+	}
+}
+
+// performDryRun pings every node of view instead of writing to it, and fails
+// if fewer nodes than required respond.
+func (s *coreService) performDryRun(view keyvaluestore.WriteClusterView, result *keyvaluestore.DryRunResult) error {
+	var reachable int32
+
+	operator := func(node keyvaluestore.Backend) error {
+		err := node.Ping()
+		result.Add(node.Address(), err == nil)
+		if err == nil {
+			atomic.AddInt32(&reachable, 1)
+		}
+
+		return err
+	}
+
+	_ = s.performOnEveryNode(view.Backends, operator)
+
+	if int(atomic.LoadInt32(&reachable)) < view.AcknowledgeRequired {
+		return keyvaluestore.ErrConsistency
+	}
+
+	return nil
+}
+
+// performOnEveryNode writes to every node of nodes, and returns only after
+// all of them have completed. The engine might return earlier, as soon as the
+// write fails on a single node.
+func (s *coreService) performOnEveryNode(nodes []keyvaluestore.Backend,
+	operator keyvaluestore.WriteOperator) error {
+
+	var wg sync.WaitGroup
+	wg.Add(len(nodes))
+
+	err := s.engine.Write(nodes, len(nodes), func(node keyvaluestore.Backend) error {
+		defer wg.Done()
+		return operator(node)
+	}, nil, keyvaluestore.OperationModeConcurrent)
+
+	wg.Wait()
+
+	return err
+}
+
+func (s *coreService) deletePatternOnNode(ctx context.Context,
+	node keyvaluestore.Backend, pattern string) (int64, error) {
+
+	var cursor uint64
+	var deleted int64
+
+	for {
+		keys, next, err := node.Scan(cursor, pattern, s.scanBatchSize)
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(keys) > 0 {
+			count, err := node.DeleteMany(keys)
+			deleted += count
+			if err != nil {
+				return deleted, err
+			}
+		}
+
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+
+		select {
+		case <-ctx.Done():
+			return deleted, ctx.Err()
+
+		case <-time.After(s.scanBatchInterval):
+		}
+	}
+}
+
+func (s *coreService) groupKeysByWriteView(keys []string,
+	options keyvaluestore.WriteOptions) ([]*writeGroup, error) {
+
+	var groups []*writeGroup
+
+	for _, key := range keys {
+		view, err := s.writeView(key, options)
+		if err != nil {
+			return nil, err
+		}
+
+		var group *writeGroup
+		for _, candidate := range groups {
+			if candidate.view.AcknowledgeRequired == view.AcknowledgeRequired &&
+				s.sameNodes(candidate.view.Backends, view.Backends) {
+
+				group = candidate
+				break
+			}
+		}
+
+		if group == nil {
+			group = &writeGroup{view: view}
+			groups = append(groups, group)
+		}
+
+		group.keys = append(group.keys, key)
+	}
+
+	return groups, nil
+}
+
+func (s *coreService) sameNodes(x, y []keyvaluestore.Backend) bool {
+	if len(x) != len(y) {
+		return false
+	}
+
+	for _, left := range x {
+		found := false
+		for _, right := range y {
+			if left == right {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *coreService) performFlushDb(nodes []string) (*keyvaluestore.FlushDBResponse, error) {
+	view, err := s.cluster.FlushDB()
+	if err != nil {
+		return nil, err
+	}
+
+	backends := view.Backends
+	if len(nodes) > 0 {
+		backends = nil
+		for _, backend := range view.Backends {
+			for _, address := range nodes {
+				if backend.Address() == address {
+					backends = append(backends, backend)
+					break
+				}
+			}
+		}
+
+		if len(backends) != len(nodes) {
+			return nil, keyvaluestore.ErrUnknownNode
+		}
+	}
+
+	var lock sync.Mutex
+	response := &keyvaluestore.FlushDBResponse{
+		Errors: make(map[string]error),
+	}
+
+	operator := func(node keyvaluestore.Backend) error {
+		err := node.FlushDB()
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err != nil {
+			response.Errors[node.Address()] = err
+		} else {
+			response.Flushed = append(response.Flushed, node.Address())
+		}
+
+		return err
+	}
+
+	err = s.performOnEveryNode(backends, operator)
+	if err != nil || len(response.Errors) > 0 {
+		return response, &keyvaluestore.FlushDBError{Errors: response.Errors}
+	}
+
+	return response, nil
+}
+
+// performRead returns as soon as the read is either satisfied or ctx is done.
+// Nodes are read through backends bound to ctx, so no more commands are sent
+// to them once ctx is done, although commands already sent run to completion.
+func (s *coreService) performRead(ctx context.Context,
+	key string,
+	options keyvaluestore.ReadOptions,
+	readOperator keyvaluestore.ReadOperator,
+	repairOperator keyvaluestore.RepairOperator,
+	comparer keyvaluestore.ValueComparer) (interface{}, error) {
+
+	return s.performOperationRead(ctx, "", key, options, readOperator, repairOperator, comparer)
+}
+
+// performOperationRead is performRead using the voting mode configured for
+// operation, if any.
+func (s *coreService) performOperationRead(ctx context.Context,
+	operation string,
+	key string,
+	options keyvaluestore.ReadOptions,
+	readOperator keyvaluestore.ReadOperator,
+	repairOperator keyvaluestore.RepairOperator,
+	comparer keyvaluestore.ValueComparer) (interface{}, error) {
+
+	for attempt := 1; ; attempt++ {
+		result, err := s.performOperationReadOnce(ctx, operation, key, options, readOperator,
+			repairOperator, comparer)
+		if !s.retryConsistency(ctx, attempt, err, metrics.RetryRead) {
+			return result, err
+		}
+	}
+}
+
+func (s *coreService) performOperationReadOnce(ctx context.Context,
+	operation string,
+	key string,
+	options keyvaluestore.ReadOptions,
+	readOperator keyvaluestore.ReadOperator,
+	repairOperator keyvaluestore.RepairOperator,
+	comparer keyvaluestore.ValueComparer) (interface{}, error) {
+
+	consistency := s.readConsistency(options)
+	requiredNodes := options.RequiredNodes
+	if s.sessions != nil && options.Session != "" {
+		nodes := len(s.cluster.Nodes())
+		if requiredNodes > 0 {
+			var err error
+			requiredNodes, err = s.sessionRequiredNodes(options.Session, key, requiredNodes, nodes)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		consistency = s.sessions.readConsistency(options.Session, key, consistency, nodes, time.Now())
+	}
+
+	view, err := s.readView(key, consistency, requiredNodes)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode, ok := s.operationVotingModes[operation]; ok {
+		view.VotingMode = mode
+	}
+
+	if view.SkipRepair {
+		repairOperator = func(args keyvaluestore.RepairArgs) {
+			s.reportDivergence(ctx, key, args)
+		}
+	}
+
+	if len(view.ReadOnly) > 0 && !view.SkipRepair && repairOperator != nil {
+		repair := repairOperator
+		repairOperator = func(args keyvaluestore.RepairArgs) {
+			args.Losers = excludeNodes(args.Losers, view.ReadOnly)
+			if len(args.Losers) > 0 {
+				repair(args)
+			}
+		}
+	}
+
+	if (s.maxRepairsPerRead > 0 || s.repairLimit != nil) && !view.SkipRepair && repairOperator != nil {
+		repair := repairOperator
+		repairOperator = func(args keyvaluestore.RepairArgs) {
+			args.Losers = s.admitRepairs(args.Losers)
+			if len(args.Losers) > 0 {
+				repair(args)
+			}
+		}
+	}
+
+	if s.conflictResolver != nil && operation == OperationGet && !view.SkipRepair && repairOperator != nil {
+		// Resolvers pick among stored values, so other reads, e.g. of streams
+		// or TTLs, keep repairing their own way. Nodes losing to the resolver
+		// might differ from the ones losing the vote, so resolveConflict
+		// excludes read-only nodes and admits repairs by itself.
+		repair := repairOperator
+		repairOperator = func(args keyvaluestore.RepairArgs) {
+			// Keys missing from the majority have been deleted, and resolving
+			// among the remaining values would resurrect them
+			if args.Err == keyvaluestore.ErrNotFound {
+				repair(args)
+				return
+			}
+
+			nodes := append(append([]keyvaluestore.Backend{}, args.Winners...), args.Losers...)
+			s.resolveConflict(ctx, key, excludeNodes(nodes, view.ReadOnly))
+		}
+	}
+
+	trace := slowlog.FromContext(ctx)
+	trace.RecordKey(key, consistency)
+
+	if trace != nil && repairOperator != nil {
+		repair := repairOperator
+		repairOperator = func(args keyvaluestore.RepairArgs) {
+			start := time.Now()
+			repair(args)
+			trace.RecordTiming("", slowlog.PhaseRepair, time.Since(start), nil)
+		}
+	}
+
+	contextAwareReadOperator := func(node keyvaluestore.Backend) (interface{}, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		bound := keyvaluestore.BindContext(ctx, node)
+		if trace == nil {
+			return readOperator(bound)
+		}
+
+		start := time.Now()
+		value, err := readOperator(bound)
+		trace.RecordTiming(node.Address(), slowlog.PhaseRead, time.Since(start), err)
+
+		return value, err
+	}
+
+	observer := func(outcome keyvaluestore.VoteOutcome) {
+		metrics.ReadVotesTotal.WithLabelValues(consistency.String(), outcome.String()).Inc()
+	}
+
+	type readResult struct {
+		value interface{}
+		err   error
+	}
+
+	resultChannel := make(chan readResult, 1)
+	go func() {
+		value, err := s.engine.Read(view.Backends, view.VoteRequired, contextAwareReadOperator,
+			repairOperator, comparer, view.VotingMode, observer)
+		resultChannel <- readResult{value: value, err: err}
+	}()
+
+	select {
+	case result := <-resultChannel:
+		// Nodes skipped due to cancellation fail the vote, which should be
+		// reported as the cancellation itself
+		if result.err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return result.value, result.err
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// performStaleRead returns the value of a single node, and then revalidates
+// key using a read of the requested consistency in the background, which
+// repairs diverged nodes. Concurrent stale reads of a key share a single
+// revalidation. If the single node has no value to serve, the read waits for
+// the requested consistency instead.
+func (s *coreService) performStaleRead(ctx context.Context,
+	operation string,
+	key string,
+	options keyvaluestore.ReadOptions,
+	readOperator keyvaluestore.ReadOperator,
+	repairOperator keyvaluestore.RepairOperator,
+	comparer keyvaluestore.ValueComparer) (interface{}, error) {
+
+	staleOptions := options
+	staleOptions.Consistency = keyvaluestore.ConsistencyLevel_ONE
+	staleOptions.RequiredNodes = 0
+
+	value, err := s.performOperationRead(ctx, operation, key, staleOptions, readOperator, nil, comparer)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return s.performOperationRead(ctx, operation, key, options, readOperator, repairOperator, comparer)
+	}
+
+	s.revalidationLock.Lock()
+	if s.revalidating[key] {
+		s.revalidationLock.Unlock()
+		return value, nil
+	}
+	s.revalidating[key] = true
+	s.revalidationLock.Unlock()
+
+	go func() {
+		defer func() {
+			s.revalidationLock.Lock()
+			delete(s.revalidating, key)
+			s.revalidationLock.Unlock()
+		}()
+
+		// The revalidation outlives the request, but keeps its correlation ID.
+		background := keyvaluestore.NewCorrelationContext(context.Background(),
+			keyvaluestore.CorrelationIDFromContext(ctx))
+
+		_, err := s.performOperationRead(background, operation, key, options,
+			readOperator, repairOperator, comparer)
+		if err != nil && err != keyvaluestore.ErrNotFound {
+			logEntry(ctx).WithError(err).WithField("key", key).Debug("failed to revalidate stale read")
+		}
+	}()
+
+	return value, nil
+}
+
+func excludeNodes(nodes []keyvaluestore.Backend, excluded []keyvaluestore.Backend) []keyvaluestore.Backend {
+	var result []keyvaluestore.Backend
+
+	for _, node := range nodes {
+		skip := false
+		for _, other := range excluded {
+			if node == other {
+				skip = true
+				break
+			}
+		}
+
+		if !skip {
+			result = append(result, node)
+		}
+	}
+
+	return result
+}
+
+func (s *coreService) reportDivergence(ctx context.Context, key string, args keyvaluestore.RepairArgs) {
+	var losers []string
+	for _, loser := range args.Losers {
+		losers = append(losers, loser.Address())
+		metrics.DivergentReplicasTotal.WithLabelValues(loser.Address()).Inc()
+	}
+
+	logEntry(ctx).WithFields(logrus.Fields{
+		"key":     key,
+		"winners": len(args.Winners),
+		"losers":  losers,
+	}).Warn("replicas disagree on key")
+}
+
+func (s *coreService) sortNodes(nodes []keyvaluestore.Backend) []keyvaluestore.Backend {
+	var result []keyvaluestore.Backend
+	result = append(result, nodes...)
+
+	sort.Slice(result, func(i, j int) bool {
+		return s.nodeID(result[i]) < s.nodeID(result[j])
+	})
+
+	return result
+}
+
+func (s *coreService) nodeID(node keyvaluestore.Backend) string {
+	address := node.Address()
+	if id, ok := s.nodeIDs[address]; ok {
+		return id
+	}
+
+	return address
+}
+
+// nextVersion returns the current time in nanoseconds, while making sure that
+// versions issued by this instance are strictly increasing.
+func (s *coreService) nextVersion() int64 {
+	for {
+		last := atomic.LoadInt64(&s.lastVersion)
+		version := time.Now().UnixNano()
+		if version <= last {
+			version = last + 1
+		}
+
+		if atomic.CompareAndSwapInt64(&s.lastVersion, last, version) {
+			return version
+		}
+	}
+}
+
+func (s *coreService) Close() error {
+	if s.hints != nil {
+		s.hints.Close()
+	}
+
+	lastErr := s.cluster.Close()
+	if err := s.engine.Close(); err != nil {
+		if lastErr != nil {
+			logrus.WithError(lastErr).Error("unexpected error while closing core service")
+		}
+
+		lastErr = err
+	}
+
+	return lastErr
+}
+
+// withTimeout bounds ctx by the timeout of a request, if it has one.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// readView is the read view of key for consistency, unless requiredNodes
+// is positive, in which case that many votes out of every node are required.
+func (s *coreService) readView(key string, consistency keyvaluestore.ConsistencyLevel,
+	requiredNodes int) (keyvaluestore.ReadClusterView, error) {
+
+	if requiredNodes <= 0 {
+		return s.cluster.Read(key, consistency)
+	}
+
+	view, err := s.cluster.Read(key, keyvaluestore.ConsistencyLevel_ALL)
+	if err != nil {
+		return view, err
+	}
+
+	if err := s.checkRequiredNodes(requiredNodes, len(view.Backends)); err != nil {
+		return keyvaluestore.ReadClusterView{}, err
+	}
+
+	view.VoteRequired = requiredNodes
+	return view, nil
+}
+
+// writeView is the write view of key for the consistency of options, unless
+// RequiredNodes is positive, in which case that many acknowledgements out of
+// every writable node are required.
+func (s *coreService) writeView(key string,
+	options keyvaluestore.WriteOptions) (keyvaluestore.WriteClusterView, error) {
+
+	if options.RequiredNodes <= 0 {
+		return s.cluster.Write(key, s.writeConsistency(options))
+	}
+
+	view, err := s.cluster.Write(key, keyvaluestore.ConsistencyLevel_ALL)
+	if err != nil {
+		return view, err
+	}
+
+	if err := s.checkRequiredNodes(options.RequiredNodes, len(view.Backends)); err != nil {
+		return keyvaluestore.WriteClusterView{}, err
+	}
+
+	view.AcknowledgeRequired = options.RequiredNodes
+	return view, nil
+}
+
+// checkRequiredNodes rejects requests requiring more nodes than the cluster
+// has, while requests requiring more nodes than currently available, e.g.
+// because of nodes which are down, fail the same as named levels do.
+func (s *coreService) checkRequiredNodes(required int, available int) error {
+	if required <= available {
+		return nil
+	}
+
+	if required > len(s.cluster.Nodes()) {
+		return keyvaluestore.ErrTooManyNodes
+	}
+
+	return keyvaluestore.ErrConsistency
+}
+
+func (s *coreService) writeConsistency(writeOptions keyvaluestore.WriteOptions) keyvaluestore.ConsistencyLevel {
+	if writeOptions.Consistency == keyvaluestore.ConsistencyLevel_DEFAULT {
+		return s.defaultWriteConsistency
+	}
+
+	return writeOptions.Consistency
+}
+
+func (s *coreService) lockOptions(writeOptions keyvaluestore.WriteOptions) keyvaluestore.WriteOptions {
+	writeOptions = s.operationWriteOptions(OperationLock, writeOptions)
+	if writeOptions.Consistency == keyvaluestore.ConsistencyLevel_DEFAULT {
+		writeOptions.Consistency = s.defaultLockConsistency
+	}
+
+	return writeOptions
+}
+
+// operationWriteOptions fills in the consistency configured for operation, if
+// any, unless the request has specified one.
+func (s *coreService) operationWriteOptions(operation string,
+	writeOptions keyvaluestore.WriteOptions) keyvaluestore.WriteOptions {
+
+	if writeOptions.Consistency == keyvaluestore.ConsistencyLevel_DEFAULT {
+		writeOptions.Consistency = s.operationConsistency[operation]
+	}
+
+	return writeOptions
+}
+
+func (s *coreService) operationReadOptions(operation string,
+	readOptions keyvaluestore.ReadOptions) keyvaluestore.ReadOptions {
+
+	if readOptions.Consistency == keyvaluestore.ConsistencyLevel_DEFAULT {
+		readOptions.Consistency = s.operationConsistency[operation]
+	}
+
+	return readOptions
+}
+
+func (s *coreService) readConsistency(readOptions keyvaluestore.ReadOptions) keyvaluestore.ConsistencyLevel {
+	if readOptions.Consistency == keyvaluestore.ConsistencyLevel_DEFAULT {
+		return s.defaultReadConsistency
+	}
+
+	return readOptions.Consistency
+}
+
+// capExpiration enforces the maximum TTL on expiration, where non-positive
+// expirations never expire.
+func (s *coreService) capExpiration(expiration time.Duration) (time.Duration, error) {
+	if s.maxWriteTTL <= 0 || (expiration > 0 && expiration <= s.maxWriteTTL) {
+		return expiration, nil
+	}
+
+	if s.rejectLongTTL {
+		return 0, keyvaluestore.ErrTTLTooLong
+	}
+
+	return s.maxWriteTTL, nil
+}
+
+func (s *coreService) jitter(expiration time.Duration) time.Duration {
+	if expiration <= 0 || s.ttlJitterPercent <= 0 {
+		return expiration
+	}
+
+	maxJitter := float64(expiration) * s.ttlJitterPercent / 100
+	result := expiration + time.Duration((rand.Float64()*2-1)*maxJitter)
+	if result < time.Millisecond {
+		return time.Millisecond
+	}
+
+	return result
+}
+
+func (s *coreService) byteComparer(x, y interface{}) bool {
+	return bytes.Equal(x.([]byte), y.([]byte))
+}
+
+type storedValue struct {
+	version  int64
+	metadata *keyvaluestore.ValueMetadata
+	data     []byte
+}
+
+// versionTag identifies the value for conditional reads by its version, or by
+// a hash of its data if it has been written before versioning was enabled.
+func (v storedValue) versionTag() string {
+	if v.version != 0 {
+		return "v" + strconv.FormatInt(v.version, 10)
+	}
+
+	sum := sha256.Sum256(v.data)
+	return "h" + hex.EncodeToString(sum[:16])
+}
+
+// decodeStoredValue strips the version envelope, decrypts, decompresses and
+// strips the metadata envelope of value as written by Set under key.
+func (s *coreService) decodeStoredValue(key string, value []byte) (storedValue, error) {
+	var result storedValue
+	if s.valueVersioning {
+		result.version, value = envelope.Decode(value)
+	}
+
+	if s.keyring != nil {
+		decrypted, err := s.keyring.Decrypt(key, value)
+		if err != nil {
+			return storedValue{}, err
+		}
+
+		value = decrypted
+	}
+
+	if s.compression {
+		decompressed, err := compression.Decompress(value)
+		if err != nil {
+			return storedValue{}, err
+		}
+
+		value = decompressed
+	}
+
+	if s.valueMetadata {
+		contentType, createdAt, payload, ok := envelope.DecodeMetadata(value)
+		if ok {
+			result.metadata = &keyvaluestore.ValueMetadata{
+				ContentType: contentType,
+				CreatedAt:   createdAt,
+				Version:     result.version,
+			}
+		}
+
+		value = payload
+	}
+
+	result.data = value
+
+	return result, nil
+}
+
+// storedValueComparer compares values of key as clients see them, so that
+// equal values agree regardless of how they were compressed or encrypted, or
+// when they were created.
+func (s *coreService) storedValueComparer(key string) keyvaluestore.ValueComparer {
+	if !s.compression && s.keyring == nil && !s.valueMetadata {
+		return s.byteComparer
+	}
+
+	return func(x, y interface{}) bool {
+		xValue, xErr := s.decodeStoredValue(key, x.([]byte))
+		yValue, yErr := s.decodeStoredValue(key, y.([]byte))
+		if xErr != nil || yErr != nil {
+			return s.byteComparer(x, y)
+		}
+
+		if xValue.version != yValue.version || !bytes.Equal(xValue.data, yValue.data) {
+			return false
+		}
+
+		if xValue.metadata == nil || yValue.metadata == nil {
+			return xValue.metadata == yValue.metadata
+		}
+
+		return xValue.metadata.ContentType == yValue.metadata.ContentType
+	}
+}
+
+// durationComparer compares TTLs, which are nil for keys which never expire,
+// whether as a nil interface or a nil *time.Duration as nodes return them.
+func (s *coreService) durationComparer(x, y interface{}) bool {
+	left, _ := x.(*time.Duration)
+	right, _ := y.(*time.Duration)
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	diff := *left - *right
+	if diff < 0 {
+		diff = -diff
+	}
+
+	return diff <= s.ttlTolerance
+}
+
+// storedValueWithTTLComparer compares values of key along with their TTLs, the
+// values as storedValueComparer does.
+func (s *coreService) storedValueWithTTLComparer(key string) keyvaluestore.ValueComparer {
+	compareValues := s.storedValueComparer(key)
+
+	return func(x, y interface{}) bool {
+		left := x.(*keyvaluestore.ValueWithTTL)
+		right := y.(*keyvaluestore.ValueWithTTL)
+
+		return compareValues(left.Data, right.Data) && s.durationComparer(left.TTL, right.TTL)
+	}
+}
+
+func (s *coreService) valueWithTTLComparer(x, y interface{}) bool {
+	left := x.(*keyvaluestore.ValueWithTTL)
+	right := y.(*keyvaluestore.ValueWithTTL)
+
+	return bytes.Equal(left.Data, right.Data) && s.durationComparer(left.TTL, right.TTL)
+}
+
+func (s *coreService) booleanComparer(x, y interface{}) bool {
+	return x.(bool) == y.(bool)
+}
+
+func (s *coreService) convertErrorToGRPC(err error) error {
 	if err == nil {
 		return nil
 	}
 
+	if flushErr, ok := err.(*keyvaluestore.FlushDBError); ok {
+		return status.Error(codes.Unavailable, flushErr.Error())
+	}
+
+	if nodesErr, ok := err.(*keyvaluestore.NoAvailableNodesError); ok {
+		return status.Error(codes.Unavailable, nodesErr.Error())
+	}
+
 	switch err {
+	case keyvaluestore.ErrUnknownNode:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrUnknownNode.Error())
+
 	case keyvaluestore.ErrNotFound:
 		return status.Error(codes.NotFound, keyvaluestore.ErrNotFound.Error())
 
 	case keyvaluestore.ErrConsistency:
 		return status.Error(codes.Unavailable, keyvaluestore.ErrConsistency.Error())
 
+	case keyvaluestore.ErrWriteInProgress:
+		return status.Error(codes.Aborted, keyvaluestore.ErrWriteInProgress.Error())
+
+	case keyvaluestore.ErrKeyTooLarge:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrKeyTooLarge.Error())
+
+	case keyvaluestore.ErrValueTooLarge:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrValueTooLarge.Error())
+
+	case keyvaluestore.ErrInvalidHolders:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrInvalidHolders.Error())
+
+	case keyvaluestore.ErrEmptyTransaction:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrEmptyTransaction.Error())
+
+	case keyvaluestore.ErrInvalidOp:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrInvalidOp.Error())
+
+	case keyvaluestore.ErrCrossShard:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrCrossShard.Error())
+
+	case keyvaluestore.ErrNoKeys:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrNoKeys.Error())
+
+	case keyvaluestore.ErrInvalidCursor:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrInvalidCursor.Error())
+
+	case keyvaluestore.ErrInvalidDump:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrInvalidDump.Error())
+
+	case keyvaluestore.ErrInvalidStreamID:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrInvalidStreamID.Error())
+
+	case keyvaluestore.ErrTooManyNodes:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrTooManyNodes.Error())
+
+	case keyvaluestore.ErrOptimisticConflict:
+		return status.Error(codes.Aborted, keyvaluestore.ErrOptimisticConflict.Error())
+
+	case keyvaluestore.ErrKeepTTLConflict:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrKeepTTLConflict.Error())
+
+	case keyvaluestore.ErrTTLTooLong:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrTTLTooLong.Error())
+
+	case keyvaluestore.ErrInvalidLimit:
+		return status.Error(codes.InvalidArgument, keyvaluestore.ErrInvalidLimit.Error())
+
+	case keyvaluestore.ErrLockLost:
+		return status.Error(codes.FailedPrecondition, keyvaluestore.ErrLockLost.Error())
+
+	case keyvaluestore.ErrNotAcquired:
+		return status.Error(codes.ResourceExhausted, keyvaluestore.ErrNotAcquired.Error())
+
 	case context.Canceled:
 		return status.Error(codes.Canceled, context.Canceled.Error())
 
 	case context.DeadlineExceeded:
-		return status.Error(codes.DeadlineExceeded, context.Canceled.Error())
+		return status.Error(codes.DeadlineExceeded, context.DeadlineExceeded.Error())
 
 	default:
 		return status.Error(codes.Internal, err.Error())
@@ -635,5 +3346,5 @@ func (s *coreService) convertErrorToGRPC(err error) error {
 }
 
 func (s *coreService) majority(n int) int {
-	return (n / 2) + 1
+	return s.quorumMode.Majority(n)
 }
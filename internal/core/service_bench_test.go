@@ -0,0 +1,102 @@
+package core_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+
+	redisBackend "github.com/cafebazaar/keyvalue-store/internal/backend/redis"
+	"github.com/cafebazaar/keyvalue-store/internal/cluster/static"
+	"github.com/cafebazaar/keyvalue-store/internal/core"
+	"github.com/cafebazaar/keyvalue-store/internal/engine"
+	"github.com/cafebazaar/keyvalue-store/internal/voting"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+var benchmarkKeyCounts = []int{10, 100}
+
+// runGetManyBenchmarks runs read over three redis nodes holding keys, for
+// each count of keys.
+func runGetManyBenchmarks(b *testing.B, read func(b *testing.B, service keyvaluestore.Service, keys []string)) {
+	for _, count := range benchmarkKeyCounts {
+		b.Run(fmt.Sprintf("keys=%v", count), func(b *testing.B) {
+			var nodes []keyvaluestore.Backend
+			for i := 0; i < 3; i++ {
+				db, err := miniredis.Run()
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer db.Close()
+
+				node := redisBackend.New(redis.NewClient(&redis.Options{Addr: db.Addr()}), db.Addr())
+				defer node.Close()
+
+				nodes = append(nodes, node)
+			}
+
+			realEngine := engine.New(voting.New)
+			defer realEngine.Close()
+
+			service := core.New(static.New(nodes), realEngine)
+
+			keys := make([]string, count)
+			for i := range keys {
+				keys[i] = fmt.Sprintf("key%v", i)
+				err := service.Set(context.Background(), &keyvaluestore.SetRequest{Key: keys[i], Data: []byte(VALUE)})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			read(b, service, keys)
+		})
+	}
+}
+
+func BenchmarkGetMany(b *testing.B) {
+	runGetManyBenchmarks(b, func(b *testing.B, service keyvaluestore.Service, keys []string) {
+		for i := 0; i < b.N; i++ {
+			_, err := service.GetMany(context.Background(), &keyvaluestore.GetManyRequest{Keys: keys})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkGetPerKey reads the same keys as BenchmarkGetMany, issuing one Get
+// per key concurrently, which is how GetMany used to read them.
+func BenchmarkGetPerKey(b *testing.B) {
+	runGetManyBenchmarks(b, func(b *testing.B, service keyvaluestore.Service, keys []string) {
+		for i := 0; i < b.N; i++ {
+			var wg sync.WaitGroup
+			errs := make(chan error, len(keys))
+
+			for _, key := range keys {
+				wg.Add(1)
+				go func(key string) {
+					defer wg.Done()
+
+					_, err := service.Get(context.Background(), &keyvaluestore.GetRequest{Key: key})
+					errs <- err
+				}(key)
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
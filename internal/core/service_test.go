@@ -1,19 +1,35 @@
 package core_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"github.com/cafebazaar/keyvalue-store/internal/compression"
 	"github.com/cafebazaar/keyvalue-store/internal/core"
+	"github.com/cafebazaar/keyvalue-store/internal/encryption"
+	"github.com/cafebazaar/keyvalue-store/internal/engine"
+	"github.com/cafebazaar/keyvalue-store/internal/envelope"
+	"github.com/cafebazaar/keyvalue-store/internal/handoff"
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/internal/resolver"
+	"github.com/cafebazaar/keyvalue-store/internal/slowlog"
+	"github.com/cafebazaar/keyvalue-store/internal/voting"
 	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
@@ -65,11 +81,17 @@ func (s *CoreServiceTestSuite) TestSetShouldEncodeStringData() {
 	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestSetShouldNotUseDefaultWriteConsistencyIfRequestHasProvided() {
-	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyWriteToEngineOnce(0)
-	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+func (s *CoreServiceTestSuite) TestSetShouldRecordNodeTimingsIntoSlowLogTrace() {
+	s.node1.On("Set", KEY, mock.Anything, mock.Anything).Once().Return(nil)
+	s.node1.On("Address").Return("node1")
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+
+	hook := test.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+
+	err := slowlog.New(s.core, 0).Set(context.Background(), &keyvaluestore.SetRequest{
 		Data: s.dataStr,
 		Key:  KEY,
 		Options: keyvaluestore.WriteOptions{
@@ -77,524 +99,3159 @@ func (s *CoreServiceTestSuite) TestSetShouldNotUseDefaultWriteConsistencyIfReque
 		},
 	})
 	s.Nil(err)
+	s.Equal(KEY, hook.LastEntry().Data["keys"])
+	s.Equal("all", hook.LastEntry().Data["consistency"])
+	s.Contains(hook.LastEntry().Data["nodes"], "node1/write=")
 }
 
-func (s *CoreServiceTestSuite) TestSetShouldUseDefaultWriteConsistencyIfRequestIsEmpty() {
-	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
-	s.applyWriteToEngineOnce(0)
+func (s *CoreServiceTestSuite) TestSetShouldStoreClientVersionIfValueVersioningIsEnabled() {
+	s.node1.On("Set", KEY, envelope.Encode(42, s.dataStr), time.Duration(0)).Once().Return(nil)
+	s.applyCore(core.WithValueVersioning(true))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:    s.dataStr,
+		Key:     KEY,
+		Version: 42,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldStoreServerTimeAsVersionByDefault() {
+	before := time.Now().UnixNano()
+	s.node1.On("Set", KEY, mock.MatchedBy(func(data []byte) bool {
+		version, payload := envelope.Decode(data)
+		return version >= before && string(payload) == VALUE
+	}), time.Duration(0)).Once().Return(nil)
+	s.applyCore(core.WithValueVersioning(true))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
 	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
 		Data: s.dataStr,
 		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
 	})
 	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestSetShouldNotEmployTTLIfRequestHasNotProvided() {
-	s.node1.On("Set", KEY, mock.Anything, time.Duration(0)).Return(nil)
-	s.applyCore()
+func (s *CoreServiceTestSuite) TestSetShouldReportAcknowledgementsOfPartialSuccess() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node2.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node3.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(errors.New("some error"))
+	s.cluster.On("Write", KEY, keyvaluestore.ConsistencyLevel_MAJORITY).Return(keyvaluestore.WriteClusterView{
+		Backends:            []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+		AcknowledgeRequired: 2,
+	}, nil)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	acknowledgement := &keyvaluestore.WriteAcknowledgement{}
+	err := core.New(s.cluster, realEngine).Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:            s.dataStr,
+		Key:             KEY,
+		Acknowledgement: acknowledgement,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+		},
+	})
+	s.Nil(err)
+	s.Equal(2, acknowledgement.Acknowledged)
+	s.Equal(3, acknowledgement.Nodes)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldNotRollbackPartialWriteIfWriteRollbackIsDisabled() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node2.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node3.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(errors.New("some error"))
+	s.cluster.On("Write", KEY, keyvaluestore.ConsistencyLevel_ALL).Return(keyvaluestore.WriteClusterView{
+		Backends:            []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+		AcknowledgeRequired: 3,
+	}, nil)
+
+	realEngine := engine.New(voting.New)
+
+	err := core.New(s.cluster, realEngine, core.WithWriteRollback(false)).Set(context.Background(),
+		&keyvaluestore.SetRequest{
+			Data: s.dataStr,
+			Key:  KEY,
+			Options: keyvaluestore.WriteOptions{
+				Consistency: keyvaluestore.ConsistencyLevel_ALL,
+			},
+		})
+	s.assertStatusCode(err, codes.Unavailable)
+
+	realEngine.Close()
+	s.node1.AssertNotCalled(s.T(), "Delete", KEY)
+	s.node2.AssertNotCalled(s.T(), "Delete", KEY)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldKeepTTLIfRequested() {
+	s.node1.On("SetKeepTTL", KEY, s.dataStr, 1*time.Minute).Once().Return(nil)
+	s.applyCore(core.WithDefaultWriteTTL(1 * time.Minute))
 	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
 	s.applyWriteToEngineOnce(1)
 	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
-		Key: KEY,
+		Data:    s.dataStr,
+		Key:     KEY,
+		KeepTTL: true,
 		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestSetShouldEmployTTLIfRequestHasProvided() {
-	s.node1.On("Set", KEY, mock.Anything, 1*time.Minute).Return(nil)
+func (s *CoreServiceTestSuite) TestSetShouldRejectKeepTTLAlongWithExpiration() {
 	s.applyCore()
-	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyWriteToEngineOnce(1)
 	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
 		Data:       s.dataStr,
 		Key:        KEY,
+		KeepTTL:    true,
 		Expiration: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.InvalidArgument)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldRecordIdempotencyKey() {
+	recordKey := idempotencyRecordKey("set", "token", KEY)
+	s.node1.On("Lock", recordKey, []byte("pending"), 30*time.Second).Once().Return(nil)
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Set", recordKey, []byte("done:1/1"), 5*time.Minute).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.applyWriteToEngineOnce(1, WithMode(keyvaluestore.OperationModeSequential))
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
 		Options: keyvaluestore.WriteOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+			Consistency:    keyvaluestore.ConsistencyLevel_ALL,
+			IdempotencyKey: "token",
 		},
 	})
 	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestGetShouldCallGetUponBackends() {
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+func (s *CoreServiceTestSuite) TestSetShouldKeepPendingRecordForTimeoutOfWrite() {
+	recordKey := idempotencyRecordKey("set", "token", KEY)
+	s.node1.On("Lock", recordKey, []byte("pending"), time.Minute).Once().Return(nil)
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Set", recordKey, []byte("done:1/1"), 5*time.Minute).Once().Return(nil)
 	s.applyCore()
 	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(s.dataStr, nil, nil, 1,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	s.cluster.On("Write", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.applyWriteToEngineOnce(1, WithMode(keyvaluestore.OperationModeSequential))
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:    keyvaluestore.ConsistencyLevel_ALL,
+			IdempotencyKey: "token",
+			Timeout:        time.Minute,
 		},
 	})
 	s.Nil(err)
-	s.Equal(VALUE, string(value.Data))
+	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestGetShouldNotUseDefaultConsistencyLevelIfRequestProvidesIt() {
-	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(s.dataStr, nil, nil, 0,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+func (s *CoreServiceTestSuite) TestDeleteShouldNotShareIdempotencyRecordWithSet() {
+	recordKey := idempotencyRecordKey("delete", "token", KEY)
+	s.node1.On("Lock", recordKey, []byte("pending"), 30*time.Second).Once().Return(nil)
+	s.node1.On("Delete", KEY).Once().Return(nil)
+	s.node1.On("Set", recordKey, []byte("done:"), 5*time.Minute).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.applyWriteToEngineOnce(1, WithMode(keyvaluestore.OperationModeSequential))
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Delete(context.Background(), &keyvaluestore.DeleteRequest{
 		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:    keyvaluestore.ConsistencyLevel_ALL,
+			IdempotencyKey: "token",
 		},
 	})
 	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestGetShouldUseDefaultConsistencyLevelIfRequestDoesNotProvidesIt() {
-	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
-	s.applyReadToEngineOnce(s.dataStr, nil, nil, 0,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
-		Key: KEY,
+func (s *CoreServiceTestSuite) TestSetShouldNotReapplyRetriedWrite() {
+	recordKey := idempotencyRecordKey("set", "token", KEY)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.cluster.On("Read", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.ReadClusterView{Backends: s.nodes, VoteRequired: 1}, nil)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeSequential).Once().Return(keyvaluestore.ErrConsistency)
+	s.node1.On("Get", recordKey).Once().Return([]byte("done:"), nil)
+	s.applyReadToEngineOnce([]byte("done:"), nil, nil, 1, keyvaluestore.VotingModeVoteOnNotFound)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:    keyvaluestore.ConsistencyLevel_ALL,
+			IdempotencyKey: "token",
+		},
 	})
 	s.Nil(err)
+	s.node1.AssertNotCalled(s.T(), "Set", KEY, mock.Anything, mock.Anything)
 }
 
-func (s *CoreServiceTestSuite) TestGetShouldRepairWithDeleteIfResultIsNotFound() {
-	s.node1.On("Delete", KEY).Once().Return(nil)
+func (s *CoreServiceTestSuite) TestSetShouldReportAcknowledgementsOfRetriedWrite() {
+	recordKey := idempotencyRecordKey("set", "token", KEY)
 	s.applyCore()
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyWriteToEngineOnce(0)
-	s.applyReadToEngineOnce(s.dataStr, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
-		Err:    keyvaluestore.ErrNotFound,
-		Losers: []keyvaluestore.Backend{s.node1},
-	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.cluster.On("Read", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.ReadClusterView{Backends: s.nodes, VoteRequired: 1}, nil)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeSequential).Once().Return(keyvaluestore.ErrConsistency)
+	s.node1.On("Get", recordKey).Once().Return([]byte("done:2/3"), nil)
+	s.applyReadToEngineOnce([]byte("done:2/3"), nil, nil, 1, keyvaluestore.VotingModeVoteOnNotFound)
+
+	acknowledgement := &keyvaluestore.WriteAcknowledgement{}
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:            s.dataStr,
+		Key:             KEY,
+		Acknowledgement: acknowledgement,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:    keyvaluestore.ConsistencyLevel_ALL,
+			IdempotencyKey: "token",
 		},
 	})
-	s.assertStatusCode(err, codes.NotFound)
-	s.node1.AssertExpectations(s.T())
+	s.Nil(err)
+	s.Equal(keyvaluestore.WriteAcknowledgement{Acknowledged: 2, Nodes: 3}, *acknowledgement)
+	s.node1.AssertNotCalled(s.T(), "Set", KEY, mock.Anything, mock.Anything)
 }
 
-func (s *CoreServiceTestSuite) TestGetShouldForfeitRepairIfTTLHitsError() {
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+func (s *CoreServiceTestSuite) TestSetShouldAbortIfRetriedWriteIsInProgress() {
+	recordKey := idempotencyRecordKey("set", "token", KEY)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.cluster.On("Read", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.ReadClusterView{Backends: s.nodes, VoteRequired: 1}, nil)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeSequential).Once().Return(keyvaluestore.ErrConsistency)
+	s.node1.On("Get", recordKey).Once().Return([]byte("pending"), nil)
+	s.applyReadToEngineOnce([]byte("pending"), nil, nil, 1, keyvaluestore.VotingModeVoteOnNotFound)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:    keyvaluestore.ConsistencyLevel_ALL,
+			IdempotencyKey: "token",
+		},
+	})
+	s.assertStatusCode(err, codes.Aborted)
+}
 
-	s.node1.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node3.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
+func (s *CoreServiceTestSuite) TestSetShouldRetryFailedWriteIfIdempotencyKeyIsGiven() {
+	counter := metrics.ConsistencyRetriesTotal.WithLabelValues(metrics.RetryWrite)
+	before := testutil.ToFloat64(counter)
 
-	s.applyCore()
-	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
-		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
-		Value:   s.dataStr,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(time.Duration(0), errors.New("some error"), nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	recordKey := idempotencyRecordKey("set", "token", KEY)
+	s.applyCore(core.WithConsistencyRetry(2, time.Millisecond))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeSequential).Once().Return(nil)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Once().Run(func(args mock.Arguments) {
+		args.Get(3).(keyvaluestore.RollbackOperator)(keyvaluestore.RollbackArgs{})
+	}).Return(keyvaluestore.ErrConsistency)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Return(nil)
+	s.engine.On("Write", mock.Anything, 0, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Return(nil)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:    keyvaluestore.ConsistencyLevel_ALL,
+			IdempotencyKey: "token",
 		},
 	})
 	s.Nil(err)
-	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
+	s.Equal(before+1, testutil.ToFloat64(counter))
 }
 
-func (s *CoreServiceTestSuite) TestGetShouldAcquireTTLAndApplyToLosers() {
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+func (s *CoreServiceTestSuite) TestSetShouldRetryOnlyAfterFailedWriteIsRolledBack() {
+	var lock sync.Mutex
+	var calls []string
+	record := func(call string) func(mock.Arguments) {
+		return func(mock.Arguments) {
+			lock.Lock()
+			defer lock.Unlock()
+			calls = append(calls, call)
+		}
+	}
 
-	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+	recordKey := idempotencyRecordKey("set", "token", KEY)
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2, s.node3} {
+		node.On("Address").Return("node")
+		node.On("Lock", recordKey, mock.Anything, mock.Anything).Return(nil)
+		node.On("Set", recordKey, mock.Anything, mock.Anything).Return(nil)
+	}
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Run(record("set")).Return(nil)
+	s.node1.On("Delete", KEY).Run(record("delete")).Return(nil)
+	s.node2.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(errors.New("some error"))
+	s.node2.On("Set", KEY, s.dataStr, time.Duration(0)).Return(nil)
+	s.node3.On("Set", KEY, s.dataStr, time.Duration(0)).Once().After(50 * time.Millisecond).Return(nil)
+	s.node3.On("Set", KEY, s.dataStr, time.Duration(0)).Return(nil)
+	s.node3.On("Delete", KEY).Return(nil)
+	for _, key := range []string{KEY, recordKey} {
+		s.cluster.On("Write", key, keyvaluestore.ConsistencyLevel_ALL).Return(keyvaluestore.WriteClusterView{
+			Backends:            []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+			AcknowledgeRequired: 3,
+		}, nil)
+	}
 
-	s.node3.On("Set", KEY, s.dataStr, time.Duration(1*time.Minute)).Once().Return(nil)
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	err := core.New(s.cluster, realEngine, core.WithConsistencyRetry(2, time.Millisecond)).Set(
+		context.Background(), &keyvaluestore.SetRequest{
+			Data: s.dataStr,
+			Key:  KEY,
+			Options: keyvaluestore.WriteOptions{
+				Consistency:    keyvaluestore.ConsistencyLevel_ALL,
+				IdempotencyKey: "token",
+			},
+		})
+	s.Nil(err)
 
-	s.applyCore()
-	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
-		Losers:  []keyvaluestore.Backend{s.node3},
-		Winners: []keyvaluestore.Backend{s.node1, s.node2},
-		Value:   s.dataStr,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	s.applyWriteToEngineOnce(0)
+	lock.Lock()
+	defer lock.Unlock()
+	s.Equal([]string{"set", "delete", "set"}, calls)
+}
 
-	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
+func (s *CoreServiceTestSuite) TestSetShouldNotRetryFailedWriteWithoutIdempotencyKey() {
+	s.applyCore(core.WithConsistencyRetry(2, time.Millisecond))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Once().Return(keyvaluestore.ErrConsistency)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Return(nil)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
-	s.Nil(err)
-	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
+	s.assertStatusCode(err, codes.Unavailable)
+	s.engine.AssertNumberOfCalls(s.T(), "Write", 1)
 }
 
-func (s *CoreServiceTestSuite) TestGetShouldNotApplyTTLDuringRepairIfItDoesNotExist() {
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
-
-	s.node1.On("TTL", KEY).Once().Return(nil, nil)
-	s.node2.On("TTL", KEY).Once().Return(nil, nil)
-
-	s.node3.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
-
+func (s *CoreServiceTestSuite) TestSetShouldRequireRequestedNumberOfAcknowledgements() {
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2, s.node3} {
+		node.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	}
 	s.applyCore()
 	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
-		Losers:  []keyvaluestore.Backend{s.node3},
-		Winners: []keyvaluestore.Backend{s.node1, s.node2},
-		Value:   s.dataStr,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(nil, nil, nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	s.applyWriteToEngineOnce(0)
-
-	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	s.applyWriteToEngineOnce(2)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:   keyvaluestore.ConsistencyLevel_ONE,
+			RequiredNodes: 2,
 		},
 	})
 	s.Nil(err)
-	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
+	s.engine.AssertCalled(s.T(), "Write", mock.Anything, 2, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent)
 }
 
-func (s *CoreServiceTestSuite) TestGetShouldForfeitRepairIfTTLIsZero() {
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
-
-	s.node1.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-
+func (s *CoreServiceTestSuite) TestSetShouldRejectMoreRequiredNodesThanCluster() {
 	s.applyCore()
 	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
-		Losers:  []keyvaluestore.Backend{s.node3},
-		Winners: []keyvaluestore.Backend{s.node1, s.node2},
-		Value:   s.dataStr,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(&ZERO_MINUTE, nil, nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-
-	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	s.cluster.On("Nodes").Return(s.nodes)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			RequiredNodes: 4,
 		},
 	})
-	s.Nil(err)
-	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
+	s.assertStatusCode(err, codes.InvalidArgument)
 }
 
-func (s *CoreServiceTestSuite) TestGetShouldUseVotingModeFromClusterView() {
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-
+func (s *CoreServiceTestSuite) TestSetShouldFailIfRequiredNodesAreNotAvailable() {
 	s.applyCore()
-	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL,
-		s.withVotingMode(keyvaluestore.VotingModeSkipVoteOnNotFound))
-	s.applyReadToEngineOnce(s.dataStr, nil, nil, 1,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-
-	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Nodes").Return([]keyvaluestore.Backend{s.node1, s.node2, s.node3})
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			RequiredNodes: 3,
 		},
 	})
-	s.Nil(err)
-	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
+	s.assertStatusCode(err, codes.Unavailable)
 }
 
-func (s *CoreServiceTestSuite) TestGetTTLShouldCallTTLUponBackends() {
-	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-	s.applyCore()
-	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 1,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	value, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
-		},
+func (s *CoreServiceTestSuite) TestSetShouldRejectOversizedValue() {
+	s.applyCore(core.WithMaxValueBytes(4))
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
 	})
-	s.Nil(err)
-	s.NotNil(value.TTL)
-	if value.TTL != nil {
-		s.Equal(1*time.Minute, *value.TTL)
-	}
+	s.assertStatusCode(err, codes.InvalidArgument)
 }
 
-func (s *CoreServiceTestSuite) TestGetTTLShouldNotUseDefaultConsistencyLevelIfRequestProvidesIt() {
-	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 0,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
-		},
+func (s *CoreServiceTestSuite) TestSetShouldRejectOversizedKey() {
+	s.applyCore(core.WithMaxKeyBytes(2))
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
 	})
-	s.Nil(err)
+	s.assertStatusCode(err, codes.InvalidArgument)
 }
 
-func (s *CoreServiceTestSuite) TestGetTTLShouldUseDefaultConsistencyLevelIfRequestDoesNotProvidesIt() {
-	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 0,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
-		Key: KEY,
+func (s *CoreServiceTestSuite) TestSetShouldHintNodesFailingAcknowledgedWrite() {
+	hints := handoff.New(time.Hour)
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node2.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node3.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(errors.New("connection refused"))
+	s.applyCore(core.WithHintedHandoff(hints))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(3)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+		},
 	})
 	s.Nil(err)
+	s.Zero(hints.Pending(s.node1))
+	s.Zero(hints.Pending(s.node2))
+	s.Equal(1, hints.Pending(s.node3))
 }
 
-func (s *CoreServiceTestSuite) TestGetTTLShouldRepairWithDeleteIfResultIsNotFound() {
-	s.node1.On("Delete", KEY).Once().Return(nil)
-	s.applyCore()
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyWriteToEngineOnce(0)
-	s.applyReadToEngineOnce(1*time.Second, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
-		Err:    keyvaluestore.ErrNotFound,
-		Losers: []keyvaluestore.Backend{s.node1},
-	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
+func (s *CoreServiceTestSuite) TestSetShouldNotHintNodesOfWriteMissingQuorum() {
+	hints := handoff.New(time.Hour)
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(errors.New("connection refused"))
+	s.applyCore(core.WithHintedHandoff(hints), core.WithWriteRollback(false))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1, WithWriteError(keyvaluestore.ErrConsistency))
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
-	s.assertStatusCode(err, codes.NotFound)
-	s.node1.AssertExpectations(s.T())
+	s.assertStatusCode(err, codes.Unavailable)
+	s.Zero(hints.Pending(s.node1))
 }
 
-func (s *CoreServiceTestSuite) TestGetTTLShouldForfeitRepairIfGetHitsError() {
-	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-	s.node3.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
-
+func (s *CoreServiceTestSuite) TestMSetShouldSetAllItems() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Set", "other", s.dataStr, time.Duration(0)).Once().Return(nil)
 	s.applyCore()
-	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, &keyvaluestore.RepairArgs{
-		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
-		Value:   &ONE_MINUTE,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(nil, errors.New("some error"), nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", "other", keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.MSet(context.Background(), &keyvaluestore.MSetRequest{
+		Items: []keyvaluestore.KeyValue{
+			{Key: KEY, Data: s.dataStr},
+			{Key: "other", Data: s.dataStr},
+		},
+		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
 	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestGetTTLShouldAcquireDataAndApplyToLosers() {
-	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-	s.node3.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
-
-	s.node3.On("Set", KEY, s.dataStr, 1*time.Minute).Once().Return(nil)
-
+func (s *CoreServiceTestSuite) TestMSetShouldForwardRequiredNodesToItems() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Set", "other", s.dataStr, time.Duration(0)).Once().Return(nil)
 	s.applyCore()
-	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, &keyvaluestore.RepairArgs{
-		Losers:  []keyvaluestore.Backend{s.node3},
-		Winners: []keyvaluestore.Backend{s.node1, s.node2},
-		Value:   &ONE_MINUTE,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(s.dataStr, nil, nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	s.applyWriteToEngineOnce(0)
-
-	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", "other", keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.MSet(context.Background(), &keyvaluestore.MSetRequest{
+		Items: []keyvaluestore.KeyValue{
+			{Key: KEY, Data: s.dataStr},
+			{Key: "other", Data: s.dataStr},
+		},
+		Options: keyvaluestore.WriteOptions{
+			Consistency:   keyvaluestore.ConsistencyLevel_ONE,
+			RequiredNodes: 1,
 		},
 	})
 	s.Nil(err)
 	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExpireShouldCallExpireUponBackends() {
-	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+func (s *CoreServiceTestSuite) TestMSetShouldRecordIdempotencyKeyOfEachItem() {
+	recordKey := idempotencyRecordKey("set", "token", KEY)
+	s.node1.On("Lock", recordKey, []byte("pending"), 30*time.Second).Once().Return(nil)
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Set", recordKey, []byte("done:1/1"), 5*time.Minute).Once().Return(nil)
 	s.applyCore()
 	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(true, nil, nil, 1,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	value, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
-		Key:        KEY,
-		Expiration: 1 * time.Minute,
+	s.cluster.On("Write", recordKey, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.applyWriteToEngineOnce(1, WithMode(keyvaluestore.OperationModeSequential))
+	s.applyWriteToEngineOnce(1)
+	err := s.core.MSet(context.Background(), &keyvaluestore.MSetRequest{
+		Items: []keyvaluestore.KeyValue{
+			{Key: KEY, Data: s.dataStr},
+		},
 		Options: keyvaluestore.WriteOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+			Consistency:    keyvaluestore.ConsistencyLevel_ALL,
+			IdempotencyKey: "token",
 		},
 	})
 	s.Nil(err)
-	s.Equal(true, value.Exists)
+	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExpireShouldNotUseDefaultConsistencyLevelIfRequestProvidesIt() {
-	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(true, nil, nil, 0,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
-		Key:        KEY,
-		Expiration: 1 * time.Minute,
+func (s *CoreServiceTestSuite) TestMSetShouldRollbackWrittenItemsIfAnyItemFails() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Delete", KEY).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", "other", keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: []keyvaluestore.Backend{s.node2}, AcknowledgeRequired: 1}, nil)
+	s.engine.On("Write", []keyvaluestore.Backend{s.node2}, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Once().Return(keyvaluestore.ErrConsistency)
+	s.applyWriteToEngineOnce(1)
+	s.applyWriteToEngineOnce(0)
+	err := s.core.MSet(context.Background(), &keyvaluestore.MSetRequest{
+		Items: []keyvaluestore.KeyValue{
+			{Key: KEY, Data: s.dataStr},
+			{Key: "other", Data: s.dataStr},
+		},
 		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
-	s.Nil(err)
+	s.assertStatusCode(err, codes.Unavailable)
+	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExpireShouldUseDefaultConsistencyLevelIfRequestDoesNotProvidesIt() {
-	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
-	s.applyReadToEngineOnce(true, nil, nil, 0,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
-		Key:        KEY,
-		Expiration: 1 * time.Minute,
+func (s *CoreServiceTestSuite) TestMSetShouldApplyPerItemExpiration() {
+	s.node1.On("Set", KEY, s.dataStr, 1*time.Second).Once().Return(nil)
+	s.node1.On("Set", "other", s.dataStr, 1*time.Hour).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", "other", keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.MSet(context.Background(), &keyvaluestore.MSetRequest{
+		Items: []keyvaluestore.KeyValue{
+			{Key: KEY, Data: s.dataStr, Expiration: 1 * time.Second},
+			{Key: "other", Data: s.dataStr, Expiration: 1 * time.Hour},
+		},
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
 	})
 	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExpireShouldRepairWithDeleteIfResultIsNotFound() {
-	s.node1.On("Delete", KEY).Once().Return(nil)
+func (s *CoreServiceTestSuite) TestImportShouldSetEntriesAndReportFailuresOfSingleKeys() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
 	s.applyCore()
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyWriteToEngineOnce(0)
-	s.applyReadToEngineOnce(false, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
-		Value:  false,
-		Losers: []keyvaluestore.Backend{s.node1},
-		Err:    keyvaluestore.ErrNotFound,
-	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
-		Key:        KEY,
-		Expiration: 1 * time.Minute,
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", "other", keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{}, keyvaluestore.ErrConsistency)
+	s.applyWriteToEngineOnce(1)
+
+	entries := make(chan keyvaluestore.KeyValue, 2)
+	entries <- keyvaluestore.KeyValue{Key: KEY, Data: s.dataStr}
+	entries <- keyvaluestore.KeyValue{Key: "other", Data: s.dataStr}
+	close(entries)
+
+	var progress []keyvaluestore.ImportProgress
+	response, err := s.core.Import(context.Background(), &keyvaluestore.ImportRequest{
+		Entries:     entries,
+		Concurrency: 1,
+		Progress: func(p keyvaluestore.ImportProgress) {
+			progress = append(progress, p)
+		},
 		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
+	s.Equal(keyvaluestore.ImportProgress{Imported: 1, Failed: 1}, response.ImportProgress)
+	s.Equal(2, len(progress))
+	s.Equal(response.ImportProgress, progress[1])
+	s.Equal(1, len(response.Errors))
+	s.Equal("other", response.Errors[0].Key)
+	s.assertStatusCode(response.Errors[0].Err, codes.Unavailable)
 	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExpireShouldForfeitRepairIfTTLHitsError() {
-	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
-	s.node2.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
-	s.node3.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+func (s *CoreServiceTestSuite) TestImportShouldSkipKeysWhichAlreadyExistIfRequested() {
+	s.node1.On("Lock", KEY, s.dataStr, time.Duration(0)).Once().Return(keyvaluestore.ErrNotAcquired)
+	s.node2.On("Lock", KEY, s.dataStr, time.Duration(0)).Once().Return(keyvaluestore.ErrNotAcquired)
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(2)
 
-	s.node1.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node3.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
+	entries := make(chan keyvaluestore.KeyValue, 1)
+	entries <- keyvaluestore.KeyValue{Key: KEY, Data: s.dataStr}
+	close(entries)
 
-	s.applyCore()
-	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
-		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
-		Value:   true,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(&ZERO_MINUTE, errors.New("some error"), nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
-		Key:        KEY,
-		Expiration: 1 * time.Minute,
+	response, err := s.core.Import(context.Background(), &keyvaluestore.ImportRequest{
+		Entries:      entries,
+		SkipExisting: true,
 		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
+	s.Equal(keyvaluestore.ImportProgress{Skipped: 1}, response.ImportProgress)
 	s.node1.AssertExpectations(s.T())
 	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
+	s.node1.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
+	s.node2.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
 }
 
-func (s *CoreServiceTestSuite) TestExpireShouldForfeitRepairIfGetHitsError() {
-	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
-	s.node2.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
-	s.node3.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
-
-	s.node1.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node3.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
+func (s *CoreServiceTestSuite) TestImportShouldCopyExistingValueToNodesLackingKeyWhenSkippingExisting() {
+	existing := &keyvaluestore.ValueWithTTL{Data: []byte("existing")}
+	s.node1.On("Lock", KEY, s.dataStr, time.Duration(0)).Once().Return(keyvaluestore.ErrNotAcquired)
+	s.node2.On("Lock", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("GetWithTTL", KEY).Once().Return(existing, nil)
+	s.node2.On("Set", KEY, []byte("existing"), time.Duration(0)).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(2)
+	s.applyReadToEngineOnce(existing, nil, nil, 1, keyvaluestore.VotingModeSkipVoteOnNotFound)
+	s.applyWriteToEngineOnce(0)
 
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+	entries := make(chan keyvaluestore.KeyValue, 1)
+	entries <- keyvaluestore.KeyValue{Key: KEY, Data: s.dataStr}
+	close(entries)
 
-	s.applyCore()
-	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
-		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
-		Value:   true,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	s.applyReadToEngineOnce(nil, errors.New("some error"), nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
-		Key:        KEY,
-		Expiration: 1 * time.Minute,
+	response, err := s.core.Import(context.Background(), &keyvaluestore.ImportRequest{
+		Entries:      entries,
+		SkipExisting: true,
 		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
+	s.Equal(keyvaluestore.ImportProgress{Skipped: 1}, response.ImportProgress)
 	s.node1.AssertExpectations(s.T())
 	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExpireShouldAcquireTTLWithDataAndApplyToLosers() {
-	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
-	s.node2.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
-	s.node3.On("Expire", KEY, 1*time.Minute).Once().Return(keyvaluestore.ErrNotFound)
-
-	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
-
-	s.node3.On("Set", KEY, s.dataStr, 1*time.Minute).Once().Return(nil)
-
+func (s *CoreServiceTestSuite) TestImportShouldOnlyRollbackKeysItHasWrittenWhenSkippingExisting() {
+	s.node1.On("Lock", KEY, s.dataStr, time.Duration(0)).Once().Return(keyvaluestore.ErrNotAcquired)
+	s.node2.On("Lock", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node3.On("Lock", KEY, s.dataStr, time.Duration(0)).Once().Return(errors.New("some error"))
+	s.node2.On("Delete", KEY).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(3,
+		WithRollbackArgs(keyvaluestore.RollbackArgs{Nodes: []keyvaluestore.Backend{s.node1, s.node2}}),
+		WithWriteError(keyvaluestore.ErrConsistency))
+	s.applyWriteToEngineOnce(0)
+
+	entries := make(chan keyvaluestore.KeyValue, 1)
+	entries <- keyvaluestore.KeyValue{Key: KEY, Data: s.dataStr}
+	close(entries)
+
+	response, err := s.core.Import(context.Background(), &keyvaluestore.ImportRequest{
+		Entries:      entries,
+		SkipExisting: true,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(keyvaluestore.ImportProgress{Failed: 1}, response.ImportProgress)
+	s.node2.AssertExpectations(s.T())
+	s.node1.AssertNotCalled(s.T(), "Delete", KEY)
+}
+
+func (s *CoreServiceTestSuite) TestImportShouldStopOnceContextIsDone() {
+	s.applyCore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	response, err := s.core.Import(ctx, &keyvaluestore.ImportRequest{
+		Entries: make(chan keyvaluestore.KeyValue),
+	})
+	s.assertStatusCode(err, codes.Canceled)
+	s.Equal(keyvaluestore.ImportProgress{}, response.ImportProgress)
+}
+
+func (s *CoreServiceTestSuite) TestExportShouldWriteEachKeyOnceAcrossNodes() {
+	ttl := time.Hour
+	s.node1.On("Address").Return("10.0.0.1:6379")
+	s.node2.On("Address").Return("10.0.0.2:6379")
+	s.node1.On("Scan", uint64(0), "*", mock.Anything).Return([]string{KEY}, uint64(0), nil)
+	s.node2.On("Scan", uint64(0), "*", mock.Anything).Return([]string{KEY, "other"}, uint64(0), nil)
+	s.node1.On("Exists", KEY).Return(true, nil)
+	s.node1.On("Exists", "other").Return(false, nil)
+	s.node1.On("Type", KEY).Return("string", nil)
+	s.node2.On("Type", "other").Return("string", nil)
+	s.node1.On("Get", KEY).Return(s.dataStr, nil)
+	s.node1.On("TTL", KEY).Return(nil, nil)
+	s.node2.On("Get", "other").Return(s.dataStr, nil)
+	s.node2.On("TTL", "other").Return(&ttl, nil)
+	s.cluster.On("Read", "*", keyvaluestore.ConsistencyLevel_ONE).Return(keyvaluestore.ReadClusterView{
+		Backends:     []keyvaluestore.Backend{s.node2, s.node1},
+		VoteRequired: 1,
+	}, nil)
+	s.cluster.On("Read", KEY, keyvaluestore.ConsistencyLevel_ONE).Return(keyvaluestore.ReadClusterView{
+		Backends:     []keyvaluestore.Backend{s.node1},
+		VoteRequired: 1,
+	}, nil)
+	s.cluster.On("Read", "other", keyvaluestore.ConsistencyLevel_ONE).Return(keyvaluestore.ReadClusterView{
+		Backends:     []keyvaluestore.Backend{s.node2},
+		VoteRequired: 1,
+	}, nil)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	var dump bytes.Buffer
+	response, err := core.New(s.cluster, realEngine).Export(context.Background(), &keyvaluestore.ExportRequest{
+		Writer: &dump,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+		},
+	})
+	s.Nil(err)
+	s.Equal(int64(2), response.Exported)
+	s.Equal("", response.Cursor)
+	s.Equal("*3\r\n$3\r\nSET\r\n$5\r\nmykey\r\n$13\r\nHello, World!\r\n"+
+		"*5\r\n$3\r\nSET\r\n$5\r\nother\r\n$13\r\nHello, World!\r\n$2\r\nPX\r\n$7\r\n3600000\r\n",
+		dump.String())
+}
+
+func (s *CoreServiceTestSuite) TestExportShouldSkipInternalAndNonStringKeys() {
+	s.node1.On("Address").Return("10.0.0.1:6379")
+	s.node1.On("Scan", uint64(0), "*", mock.Anything).Return(
+		[]string{"__kvs_idempotency:request", "semaphore", KEY}, uint64(0), nil)
+	s.node1.On("Type", "semaphore").Return("zset", nil)
+	s.node1.On("Type", KEY).Return("string", nil)
+	s.node1.On("Get", KEY).Return(s.dataStr, nil)
+	s.node1.On("TTL", KEY).Return(nil, nil)
+	view := keyvaluestore.ReadClusterView{
+		Backends:     []keyvaluestore.Backend{s.node1},
+		VoteRequired: 1,
+	}
+	s.cluster.On("Read", "*", keyvaluestore.ConsistencyLevel_ONE).Return(view, nil)
+	s.cluster.On("Read", KEY, keyvaluestore.ConsistencyLevel_ONE).Return(view, nil)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	var dump bytes.Buffer
+	response, err := core.New(s.cluster, realEngine).Export(context.Background(), &keyvaluestore.ExportRequest{
+		Writer: &dump,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+		},
+	})
+	s.Nil(err)
+	s.Equal(int64(1), response.Exported)
+	s.Equal("*3\r\n$3\r\nSET\r\n$5\r\nmykey\r\n$13\r\nHello, World!\r\n", dump.String())
+	s.node1.AssertNotCalled(s.T(), "Type", "__kvs_idempotency:request")
+	s.node1.AssertNotCalled(s.T(), "Get", "semaphore")
+}
+
+func (s *CoreServiceTestSuite) TestExportShouldResumeFromCursor() {
+	s.node1.On("Address").Return("10.0.0.1:6379")
+	s.node2.On("Address").Return("10.0.0.2:6379")
+	s.node2.On("Scan", uint64(42), "*", mock.Anything).Return([]string{}, uint64(0), nil)
+	s.cluster.On("Read", "*", keyvaluestore.ConsistencyLevel_ONE).Return(keyvaluestore.ReadClusterView{
+		Backends:     []keyvaluestore.Backend{s.node1, s.node2},
+		VoteRequired: 1,
+	}, nil)
+	s.applyCore()
+
+	var dump bytes.Buffer
+	response, err := s.core.Export(context.Background(), &keyvaluestore.ExportRequest{
+		Writer: &dump,
+		Cursor: "1:42",
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+		},
+	})
+	s.Nil(err)
+	s.Equal(int64(0), response.Exported)
+	s.node1.AssertNotCalled(s.T(), "Scan", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err = s.core.Export(context.Background(), &keyvaluestore.ExportRequest{
+		Writer: &dump,
+		Cursor: "2:0",
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+		},
+	})
+	s.assertStatusCode(err, codes.InvalidArgument)
+}
+
+func (s *CoreServiceTestSuite) TestImportShouldReadEntriesFromExportedSource() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Set", KEY, s.dataStr, time.Hour).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	s.applyWriteToEngineOnce(1)
+
+	source := bytes.NewBufferString("*3\r\n$3\r\nSET\r\n$5\r\nmykey\r\n$13\r\nHello, World!\r\n" +
+		"*5\r\n$3\r\nSET\r\n$5\r\nmykey\r\n$13\r\nHello, World!\r\n$2\r\nPX\r\n$7\r\n3600000\r\n")
+	response, err := s.core.Import(context.Background(), &keyvaluestore.ImportRequest{
+		Source:      source,
+		Concurrency: 1,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(keyvaluestore.ImportProgress{Imported: 2}, response.ImportProgress)
+	s.node1.AssertExpectations(s.T())
+
+	_, err = s.core.Import(context.Background(), &keyvaluestore.ImportRequest{
+		Source: bytes.NewBufferString("*2\r\n$3\r\nDEL\r\n$5\r\nmykey\r\n"),
+	})
+	s.assertStatusCode(err, codes.InvalidArgument)
+}
+
+func (s *CoreServiceTestSuite) TestCapabilitiesShouldDescribeConfiguration() {
+	s.cluster.On("Nodes").Return([]keyvaluestore.Backend{s.node1, s.node2, s.node3})
+	s.applyCore(
+		core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_ONE),
+		core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_ALL),
+		core.WithValueVersioning(true),
+		core.WithMaxKeyBytes(256))
+
+	response, err := s.core.Capabilities(context.Background())
+	s.Nil(err)
+	s.Contains(response.Operations, "capabilities")
+	s.Equal([]string{"one", "two", "three", "majority", "all"}, response.ConsistencyLevels)
+	s.Equal("one", response.DefaultReadConsistency)
+	s.Equal("all", response.DefaultWriteConsistency)
+	s.Equal(3, response.Nodes)
+	s.False(response.Sharding)
+	s.True(response.ValueVersioning)
+	s.False(response.Compression)
+	s.Equal(256, response.MaxKeyBytes)
+	s.node1.AssertNotCalled(s.T(), "Ping")
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldRaiseConsistencyOfKeysWrittenBySession() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.applyCore(core.WithSessionConsistency(time.Minute))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ONE)
+	s.cluster.On("Write", KEY, keyvaluestore.ConsistencyLevel_MAJORITY).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.cluster.On("Read", KEY, keyvaluestore.ConsistencyLevel_MAJORITY).Once().Return(
+		keyvaluestore.ReadClusterView{Backends: s.nodes, VoteRequired: 1}, nil)
+	s.cluster.On("Nodes").Return(s.nodes)
+	s.applyWriteToEngineOnce(1)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 1, keyvaluestore.VotingModeVoteOnNotFound)
+
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+			Session:     "session",
+		},
+	})
+	s.Nil(err)
+
+	_, err = s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+			Session:     "session",
+		},
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Read", KEY, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.cluster.AssertNotCalled(s.T(), "Read", KEY, keyvaluestore.ConsistencyLevel_ONE)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldRaiseRequiredNodesOfKeysWrittenBySession() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node2.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node3.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.applyCore(core.WithSessionConsistency(time.Minute))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", KEY, keyvaluestore.ConsistencyLevel_MAJORITY).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 2}, nil)
+	s.cluster.On("Read", KEY, keyvaluestore.ConsistencyLevel_MAJORITY).Once().Return(
+		keyvaluestore.ReadClusterView{Backends: s.nodes, VoteRequired: 2}, nil)
+	s.cluster.On("Nodes").Return(s.nodes)
+	s.applyWriteToEngineOnce(2)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 2, keyvaluestore.VotingModeVoteOnNotFound)
+
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+			Session:     "session",
+		},
+	})
+	s.Nil(err)
+
+	_, err = s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			RequiredNodes: 1,
+			Session:       "session",
+		},
+	})
+	s.Nil(err)
+	s.engine.AssertNotCalled(s.T(), "Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldKeepStrongerFixedConsistencyOfSessions() {
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2, s.node3} {
+		node.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+		node.On("Get", KEY).Once().Return(s.dataStr, nil)
+	}
+	s.applyCore(core.WithSessionConsistency(time.Minute))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_THREE)
+	s.cluster.On("Write", KEY, keyvaluestore.ConsistencyLevel_MAJORITY).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 3}, nil)
+	s.cluster.On("Nodes").Return(s.nodes)
+	s.applyWriteToEngineOnce(3)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 3, keyvaluestore.VotingModeVoteOnNotFound)
+
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+			Session:     "session",
+		},
+	})
+	s.Nil(err)
+
+	_, err = s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_THREE,
+			Session:     "session",
+		},
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Read", KEY, keyvaluestore.ConsistencyLevel_THREE)
+	s.cluster.AssertNotCalled(s.T(), "Read", KEY, keyvaluestore.ConsistencyLevel_MAJORITY)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldRaiseMajorityReadsOfSessionsToAllWithHalfQuorums() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.applyCore(core.WithSessionConsistency(time.Minute), core.WithQuorumMode(keyvaluestore.QuorumModeHalf))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", KEY, keyvaluestore.ConsistencyLevel_MAJORITY).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.cluster.On("Nodes").Return(s.nodes)
+	s.applyWriteToEngineOnce(1)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 1, keyvaluestore.VotingModeVoteOnNotFound)
+
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+			Session:     "session",
+		},
+	})
+	s.Nil(err)
+
+	_, err = s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+			Session:     "session",
+		},
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Read", KEY, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.AssertNotCalled(s.T(), "Read", KEY, keyvaluestore.ConsistencyLevel_MAJORITY)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotRaiseConsistencyForOtherSessions() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.applyCore(core.WithSessionConsistency(time.Minute))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ONE)
+	s.cluster.On("Nodes").Return(s.nodes)
+	s.applyWriteToEngineOnce(1)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 1, keyvaluestore.VotingModeVoteOnNotFound)
+
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+			Session:     "session",
+		},
+	})
+	s.Nil(err)
+
+	_, err = s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+			Session:     "other",
+		},
+	})
+	s.Nil(err)
+	s.cluster.AssertNotCalled(s.T(), "Read", KEY, keyvaluestore.ConsistencyLevel_ALL)
+}
+
+func (s *CoreServiceTestSuite) TestMSetShouldNotSetAnythingIfAnyItemIsOversized() {
+	s.applyCore(core.WithMaxValueBytes(4))
+	err := s.core.MSet(context.Background(), &keyvaluestore.MSetRequest{
+		Items: []keyvaluestore.KeyValue{
+			{Key: KEY, Data: []byte("tiny")},
+			{Key: "other", Data: s.dataStr},
+		},
+	})
+	s.assertStatusCode(err, codes.InvalidArgument)
+	s.node1.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldNotUseDefaultWriteConsistencyIfRequestHasProvided() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldUseDefaultWriteConsistencyIfRequestIsEmpty() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(0)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldPreferOperationConsistencyOverDefaultWriteConsistency() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithOperationConsistency(core.OperationSet, keyvaluestore.ConsistencyLevel_ALL))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Write", KEY, keyvaluestore.ConsistencyLevel_ALL)
+}
+
+func (s *CoreServiceTestSuite) TestOperationConsistencyShouldPanicForUnknownOperation() {
+	s.Panics(func() {
+		s.applyCore(core.WithOperationConsistency("unknown", keyvaluestore.ConsistencyLevel_ALL))
+	})
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldNotEmployTTLIfRequestHasNotProvided() {
+	s.node1.On("Set", KEY, mock.Anything, time.Duration(0)).Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Key: KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldEmployTTLIfRequestHasProvided() {
+	s.node1.On("Set", KEY, mock.Anything, 1*time.Minute).Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:       s.dataStr,
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldClampTTLAboveMaximum() {
+	s.node1.On("Set", KEY, mock.Anything, 1*time.Hour).Once().Return(nil)
+	s.applyCore(core.WithMaxWriteTTL(1*time.Hour, false))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:       s.dataStr,
+		Key:        KEY,
+		Expiration: 24 * time.Hour,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldClampPersistentValueToMaximumTTL() {
+	s.node1.On("Set", KEY, mock.Anything, 1*time.Hour).Once().Return(nil)
+	s.applyCore(core.WithMaxWriteTTL(1*time.Hour, false))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:       s.dataStr,
+		Key:        KEY,
+		Persistent: true,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldRejectTTLAboveMaximumIfConfigured() {
+	s.applyCore(core.WithMaxWriteTTL(1*time.Hour, true))
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:       s.dataStr,
+		Key:        KEY,
+		Expiration: 24 * time.Hour,
+	})
+	s.assertStatusCode(err, codes.InvalidArgument)
+	s.node1.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldRejectMissingTTLIfConfigured() {
+	s.applyCore(core.WithMaxWriteTTL(1*time.Hour, true))
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+	})
+	s.assertStatusCode(err, codes.InvalidArgument)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldAcceptTTLWithinMaximum() {
+	s.node1.On("Set", KEY, mock.Anything, 1*time.Minute).Once().Return(nil)
+	s.applyCore(core.WithMaxWriteTTL(1*time.Hour, true))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:       s.dataStr,
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestStaleGetShouldReturnSingleNodeValueAndRevalidateInBackground() {
+	revalidated := make(chan struct{})
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.cluster.On("Read", KEY, keyvaluestore.ConsistencyLevel_ONE).Return(
+		keyvaluestore.ReadClusterView{Backends: s.nodes[:1], VoteRequired: 1}, nil)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Once().Return([]byte("stale"), nil)
+	s.engine.On("Read", mock.Anything, 3, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Once().Run(func(args mock.Arguments) {
+		close(revalidated)
+	}).Return(s.dataStr, nil)
+
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency:          keyvaluestore.ConsistencyLevel_MAJORITY,
+			StaleWhileRevalidate: true,
+		},
+	})
+	s.Nil(err)
+	s.Equal("stale", string(value.Data))
+
+	select {
+	case <-revalidated:
+	case <-time.After(time.Second):
+		s.Fail("key has not been revalidated")
+	}
+}
+
+func (s *CoreServiceTestSuite) TestStaleGetShouldWaitForConsistencyIfSingleNodeHasNoValue() {
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.cluster.On("Read", KEY, keyvaluestore.ConsistencyLevel_ONE).Return(
+		keyvaluestore.ReadClusterView{Backends: s.nodes[:1], VoteRequired: 1}, nil)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Once().Return(nil, keyvaluestore.ErrNotFound)
+	s.engine.On("Read", mock.Anything, 3, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Once().Return(s.dataStr, nil)
+
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency:          keyvaluestore.ConsistencyLevel_MAJORITY,
+			StaleWhileRevalidate: true,
+		},
+	})
+	s.Nil(err)
+	s.Equal(VALUE, string(value.Data))
+	s.engine.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestStaleGetShouldReadSingleNodeRegardlessOfRequiredNodes() {
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Read", KEY, keyvaluestore.ConsistencyLevel_ONE).Return(
+		keyvaluestore.ReadClusterView{Backends: s.nodes[:1], VoteRequired: 1}, nil)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Once().Return(nil, keyvaluestore.ErrNotFound)
+	s.engine.On("Read", mock.Anything, 2, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Once().Return(s.dataStr, nil)
+
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency:          keyvaluestore.ConsistencyLevel_MAJORITY,
+			RequiredNodes:        2,
+			StaleWhileRevalidate: true,
+		},
+	})
+	s.Nil(err)
+	s.Equal(VALUE, string(value.Data))
+	s.engine.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldFailWithUnavailableIfEveryNodeOfKeyIsDown() {
+	s.applyCore()
+	s.cluster.On("Read", KEY, keyvaluestore.ConsistencyLevel_ONE).Return(keyvaluestore.ReadClusterView{},
+		&keyvaluestore.NoAvailableNodesError{Key: KEY, Nodes: []string{"node1", "node2"}})
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+		},
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+	s.Contains(err.Error(), "node1, node2")
+	s.engine.AssertNotCalled(s.T(), "Read", mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldCallGetUponBackends() {
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(VALUE, string(value.Data))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldStripEnvelopeIfValueVersioningIsEnabled() {
+	stored := envelope.Encode(42, s.dataStr)
+	s.node1.On("Get", KEY).Once().Return(stored, nil)
+	s.applyCore(core.WithValueVersioning(true))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(stored, nil, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(VALUE, string(value.Data))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldFailWithDeadlineExceededAfterRequestTimeout() {
+	release := make(chan struct{})
+	defer close(release)
+
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything).Once().
+		Run(func(args mock.Arguments) {
+			<-release
+		}).Return(s.dataStr, nil)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+			Timeout:     10 * time.Millisecond,
+		},
+	})
+	s.assertStatusCode(err, codes.DeadlineExceeded)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldSkipNodesOnceRequestTimeoutFires() {
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything).Once().
+		Run(func(args mock.Arguments) {
+			time.Sleep(20 * time.Millisecond)
+			operator := args.Get(2).(keyvaluestore.WriteOperator)
+			s.Equal(context.DeadlineExceeded, operator(s.node1))
+		}).Return(keyvaluestore.ErrConsistency)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+			Timeout:     10 * time.Millisecond,
+		},
+	})
+	s.assertStatusCode(err, codes.DeadlineExceeded)
+	s.node1.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldReportNotModifiedIfVersionMatches() {
+	stored := envelope.Encode(42, s.dataStr)
+	s.applyCore(core.WithValueVersioning(true))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Return(stored, nil)
+
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal("v42", value.Version)
+	s.False(value.NotModified)
+
+	value, err = s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key:          KEY,
+		IfVersionNot: "v42",
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.True(value.NotModified)
+	s.Nil(value.Data)
+
+	value, err = s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key:          KEY,
+		IfVersionNot: "v41",
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.False(value.NotModified)
+	s.Equal(VALUE, string(value.Data))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldHashValuesWithoutVersionForConditionalReads() {
+	s.applyCore(core.WithValueVersioning(true))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Once().Return(s.dataStr, nil)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything).Once().Return([]byte("changed"), nil)
+
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal("h", value.Version[:1])
+
+	changed, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key:          KEY,
+		IfVersionNot: value.Version,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.False(changed.NotModified)
+	s.Equal("changed", string(changed.Data))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotTouchBackendsIfContextIsCanceled() {
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, context.Canceled, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.core.Get(ctx, &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.Canceled)
+	s.Equal(context.Canceled.Error(), status.Convert(err).Message())
+	s.node1.AssertNotCalled(s.T(), "Get", KEY)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotTouchBackendsIfDeadlineIsExceeded() {
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, context.DeadlineExceeded, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := s.core.Get(ctx, &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.DeadlineExceeded)
+	s.Equal(context.DeadlineExceeded.Error(), status.Convert(err).Message())
+	s.node1.AssertNotCalled(s.T(), "Get", KEY)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldReturnUponDeadlineWithoutWaitingForBackends() {
+	release := make(chan struct{})
+	defer close(release)
+
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything).Once().
+		Run(func(args mock.Arguments) {
+			<-release
+		}).Return(s.dataStr, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := s.core.Get(ctx, &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.DeadlineExceeded)
+	s.Equal(context.DeadlineExceeded.Error(), status.Convert(err).Message())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldReturnUponCancellationWithoutWaitingForBackends() {
+	release := make(chan struct{})
+	defer close(release)
+
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything).Once().
+		Run(func(args mock.Arguments) {
+			cancel()
+			<-release
+		}).Return(s.dataStr, nil)
+	_, err := s.core.Get(ctx, &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.Canceled)
+	s.Equal(context.Canceled.Error(), status.Convert(err).Message())
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldNotWaitForEngineBeyondTimeout() {
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Once().After(time.Second).Return(nil)
+	start := time.Now()
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+			Timeout:     10 * time.Millisecond,
+		},
+	})
+	s.assertStatusCode(err, codes.DeadlineExceeded)
+	s.True(time.Since(start) < 500*time.Millisecond)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldOnlyPingNodesInDryRun() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("Ping").Once().Return(nil)
+	s.node2.On("Address").Return("node2")
+	s.node2.On("Ping").Once().Return(errors.New("some error"))
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ONE, func(o *clusterOptionContext) {
+		o.writeView.AcknowledgeRequired = 1
+	})
+	s.applyWriteToEngineOnce(2)
+	result := &keyvaluestore.DryRunResult{}
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+			DryRun:      result,
+		},
+	})
+	s.Nil(err)
+	s.ElementsMatch([]string{"node1", "node2"}, result.Nodes)
+	s.Equal([]string{"node1"}, result.Reachable)
+	s.node1.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldWaitForSlowNodesInDryRun() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("Ping").Once().After(50 * time.Millisecond).Return(nil)
+	s.node2.On("Address").Return("node2")
+	s.node2.On("Ping").Once().Return(errors.New("some error"))
+	s.cluster.On("Write", KEY, keyvaluestore.ConsistencyLevel_ONE).Return(keyvaluestore.WriteClusterView{
+		Backends:            []keyvaluestore.Backend{s.node1, s.node2},
+		AcknowledgeRequired: 1,
+	}, nil)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	result := &keyvaluestore.DryRunResult{}
+	err := core.New(s.cluster, realEngine).Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+			DryRun:      result,
+		},
+	})
+	s.Nil(err)
+	s.ElementsMatch([]string{"node1", "node2"}, result.Nodes)
+	s.Equal([]string{"node1"}, result.Reachable)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldFailInDryRunIfConsistencyIsNotAchievable() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("Ping").Once().Return(errors.New("some error"))
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+			DryRun:      &keyvaluestore.DryRunResult{},
+		},
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldApplyDefaultWriteTTLIfExpirationIsNotProvided() {
+	s.node1.On("Set", KEY, s.dataStr, 1*time.Hour).Once().Return(nil)
+	s.applyCore(core.WithDefaultWriteTTL(1 * time.Hour))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldPreferProvidedExpirationOverDefaultWriteTTL() {
+	s.node1.On("Set", KEY, s.dataStr, 1*time.Minute).Once().Return(nil)
+	s.applyCore(core.WithDefaultWriteTTL(1 * time.Hour))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:       s.dataStr,
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldNotApplyDefaultWriteTTLToPersistentValues() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.applyCore(core.WithDefaultWriteTTL(1 * time.Hour))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:       s.dataStr,
+		Key:        KEY,
+		Persistent: true,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldApplySameJitteredTTLToAllNodes() {
+	var lock sync.Mutex
+	expirations := make(map[time.Duration]struct{})
+	recordExpiration := func(args mock.Arguments) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		expirations[args.Get(2).(time.Duration)] = struct{}{}
+	}
+
+	s.node1.On("Set", KEY, s.dataStr, mock.Anything).Once().Run(recordExpiration).Return(nil)
+	s.node2.On("Set", KEY, s.dataStr, mock.Anything).Once().Run(recordExpiration).Return(nil)
+	s.node3.On("Set", KEY, s.dataStr, mock.Anything).Once().Run(recordExpiration).Return(nil)
+	s.applyCore(core.WithTTLJitter(10))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(3)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:       s.dataStr,
+		Key:        KEY,
+		Expiration: 100 * time.Second,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Len(expirations, 1)
+	for expiration := range expirations {
+		s.True(expiration >= 90*time.Second)
+		s.True(expiration <= 110*time.Second)
+	}
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldNotJitterPersistentValues() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.applyCore(core.WithTTLJitter(10))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldCompressLargeValuesIfCompressionIsEnabled() {
+	s.node1.On("Set", KEY, mock.MatchedBy(func(data []byte) bool {
+		decompressed, err := compression.Decompress(data)
+		return err == nil && !bytes.Equal(data, s.dataStr) && bytes.Equal(decompressed, s.dataStr)
+	}), time.Duration(0)).Once().Return(nil)
+	s.applyCore(core.WithCompression(1))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldNotCompressSmallValues() {
+	s.node1.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+	s.applyCore(core.WithCompression(1024))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldDecompressAndCompareDecompressedValues() {
+	compressed, err := compression.Compress(s.dataStr)
+	s.Nil(err)
+	s.node1.On("Get", KEY).Once().Return(compressed, nil)
+	s.applyCore(core.WithCompression(1))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		keyvaluestore.VotingModeVoteOnNotFound, mock.Anything).Once().Run(func(args mock.Arguments) {
+		comparer := args.Get(4).(keyvaluestore.ValueComparer)
+		s.True(comparer(compressed, s.dataStr))
+	}).Return(compressed, nil)
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(VALUE, string(value.Data))
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldEncryptValuesIfEncryptionIsEnabled() {
+	keyring := s.makeKeyring()
+	s.node1.On("Set", KEY, mock.MatchedBy(func(data []byte) bool {
+		decrypted, err := keyring.Decrypt(KEY, data)
+		return err == nil && !bytes.Equal(data, s.dataStr) && bytes.Equal(decrypted, s.dataStr)
+	}), time.Duration(0)).Once().Return(nil)
+	s.applyCore(core.WithEncryption(keyring))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldDecryptAndComparePlaintextValues() {
+	keyring := s.makeKeyring()
+	encrypted1, err := keyring.Encrypt(KEY, s.dataStr)
+	s.Nil(err)
+	encrypted2, err := keyring.Encrypt(KEY, s.dataStr)
+	s.Nil(err)
+	s.applyCore(core.WithEncryption(keyring))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		keyvaluestore.VotingModeVoteOnNotFound, mock.Anything).Once().Run(func(args mock.Arguments) {
+		comparer := args.Get(4).(keyvaluestore.ValueComparer)
+		s.True(comparer(encrypted1, encrypted2))
+	}).Return(encrypted1, nil)
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(VALUE, string(value.Data))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldRejectValuesEncryptedForOtherKeys() {
+	keyring := s.makeKeyring()
+	encrypted, err := keyring.Encrypt("other", s.dataStr)
+	s.Nil(err)
+	s.applyCore(core.WithEncryption(keyring))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		keyvaluestore.VotingModeVoteOnNotFound, mock.Anything).Once().Return(encrypted, nil)
+	_, err = s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.NotNil(err)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldStoreMetadataIfEnabled() {
+	s.node1.On("Set", KEY, mock.MatchedBy(func(data []byte) bool {
+		contentType, createdAt, payload, ok := envelope.DecodeMetadata(data)
+		return ok && contentType == "text/plain" && !createdAt.IsZero() && bytes.Equal(payload, s.dataStr)
+	}), time.Duration(0)).Once().Return(nil)
+	s.applyCore(core.WithValueMetadata(true))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data:        s.dataStr,
+		Key:         KEY,
+		ContentType: "text/plain",
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldReturnMetadataAndIgnoreCreationTimeInVotes() {
+	createdAt := time.Unix(1600000000, 0)
+	stored1 := envelope.EncodeMetadata("text/plain", createdAt, s.dataStr)
+	stored2 := envelope.EncodeMetadata("text/plain", createdAt.Add(time.Second), s.dataStr)
+	s.applyCore(core.WithValueMetadata(true))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.engine.On("Read", mock.Anything, 1, mock.Anything, mock.Anything, mock.Anything,
+		keyvaluestore.VotingModeVoteOnNotFound, mock.Anything).Once().Run(func(args mock.Arguments) {
+		comparer := args.Get(4).(keyvaluestore.ValueComparer)
+		s.True(comparer(stored1, stored2))
+		s.False(comparer(stored1, envelope.EncodeMetadata("application/json", createdAt, s.dataStr)))
+	}).Return(stored1, nil)
+	value, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(VALUE, string(value.Data))
+	s.Equal("text/plain", value.Metadata.ContentType)
+	s.True(createdAt.Equal(value.Metadata.CreatedAt))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotUseDefaultConsistencyLevelIfRequestProvidesIt() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldUseDefaultConsistencyLevelIfRequestDoesNotProvidesIt() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldPreferOperationConsistencyOverDefaultReadConsistency() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithOperationConsistency(core.OperationGet, keyvaluestore.ConsistencyLevel_ONE))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ONE)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Read", KEY, keyvaluestore.ConsistencyLevel_ONE)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotUseOperationConsistencyIfRequestProvidesIt() {
+	s.applyCore(core.WithOperationConsistency(core.OperationGet, keyvaluestore.ConsistencyLevel_ONE))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldNotUseOperationConsistencyOfGet() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithOperationConsistency(core.OperationGet, keyvaluestore.ConsistencyLevel_ONE))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyReadToEngineOnce(true, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Read", KEY, keyvaluestore.ConsistencyLevel_MAJORITY)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldRepairWithDeleteIfResultIsNotFound() {
+	s.node1.On("Delete", KEY).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	s.applyReadToEngineOnce(s.dataStr, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+		Err:    keyvaluestore.ErrNotFound,
+		Losers: []keyvaluestore.Backend{s.node1},
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.NotFound)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldCountStaleDeleteRepairs() {
+	counter := metrics.RepairsTotal.WithLabelValues(metrics.RepairStaleDelete)
+	before := testutil.ToFloat64(counter)
+
+	s.node1.On("Delete", KEY).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	s.applyReadToEngineOnce(s.dataStr, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+		Err:    keyvaluestore.ErrNotFound,
+		Losers: []keyvaluestore.Backend{s.node1},
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.NotFound)
+	s.Equal(before+1, testutil.ToFloat64(counter))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldRequireRequestedNumberOfVotes() {
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2, s.node3} {
+		node.On("Get", KEY).Once().Return(s.dataStr, nil)
+	}
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 2, keyvaluestore.VotingModeVoteOnNotFound)
+
+	result, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency:   keyvaluestore.ConsistencyLevel_ONE,
+			RequiredNodes: 2,
+		},
+	})
+	s.Nil(err)
+	s.Equal(s.dataStr, result.Data)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldRetryReadFailingConsistency() {
+	counter := metrics.ConsistencyRetriesTotal.WithLabelValues(metrics.RetryRead)
+	before := testutil.ToFloat64(counter)
+
+	s.applyCore(core.WithConsistencyRetry(2, time.Millisecond))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, keyvaluestore.ErrConsistency, nil, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 0, keyvaluestore.VotingModeVoteOnNotFound)
+
+	result, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(s.dataStr, result.Data)
+	s.Equal(before+1, testutil.ToFloat64(counter))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldGiveUpAfterMaxReadAttempts() {
+	s.applyCore(core.WithConsistencyRetry(2, time.Millisecond))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, keyvaluestore.ErrConsistency, nil, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(nil, keyvaluestore.ErrConsistency, nil, 0, keyvaluestore.VotingModeVoteOnNotFound)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+	s.engine.AssertNumberOfCalls(s.T(), "Read", 2)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldCapRepairsPerRead() {
+	before := testutil.ToFloat64(metrics.DeferredRepairsTotal)
+
+	s.applyCore(core.WithRepairLimits(1, 0))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2, s.node3} {
+		node.On("Delete", KEY).Maybe().Return(nil)
+	}
+	s.applyWriteToEngineOnce(0)
+	s.applyReadToEngineOnce(s.dataStr, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+		Err:    keyvaluestore.ErrNotFound,
+		Losers: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.NotFound)
+	s.Equal(1, s.countCalls("Delete"))
+	s.Equal(before+2, testutil.ToFloat64(metrics.DeferredRepairsTotal))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldDeferRepairsBeyondGlobalRepairRate() {
+	s.applyCore(core.WithRepairLimits(0, 2))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2, s.node3} {
+		node.On("Delete", KEY).Maybe().Return(nil)
+	}
+	s.applyWriteToEngineOnce(0)
+
+	for i := 0; i < 2; i++ {
+		s.applyReadToEngineOnce(s.dataStr, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+			Err:    keyvaluestore.ErrNotFound,
+			Losers: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+		}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+
+		_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+			Key: KEY,
+			Options: keyvaluestore.ReadOptions{
+				Consistency: keyvaluestore.ConsistencyLevel_ALL,
+			},
+		})
+		s.assertStatusCode(err, codes.NotFound)
+	}
+
+	s.Equal(2, s.countCalls("Delete"))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotRepairIfClusterViewSkipsRepair() {
+	counter := metrics.DivergentReplicasTotal.WithLabelValues("node1")
+	before := testutil.ToFloat64(counter)
+
+	s.node1.On("Address").Return("node1")
+	s.applyCore()
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ONE, s.withSkipRepair())
+	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
+		Value:  s.dataStr,
+		Losers: []keyvaluestore.Backend{s.node1},
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
+	s.node1.AssertNotCalled(s.T(), "TTL", mock.Anything)
+	s.Equal(before+1, testutil.ToFloat64(counter))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotRepairReadOnlyNodes() {
+	s.applyCore()
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL, s.withReadOnly(s.node1))
+	s.applyReadToEngineOnce(s.dataStr, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+		Err:    keyvaluestore.ErrNotFound,
+		Losers: []keyvaluestore.Backend{s.node1},
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.NotFound)
+	s.node1.AssertNotCalled(s.T(), "Delete", mock.Anything)
+	s.engine.AssertNotCalled(s.T(), "Write", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldRepairUsingConflictResolverIfProvided() {
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("Get", KEY).Once().Return([]byte("other"), nil)
+
+	oneHour := 1 * time.Hour
+	s.node1.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}, nil)
+	s.node2.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}, nil)
+	s.node3.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: []byte("other"), TTL: &oneHour}, nil)
+
+	s.node1.On("Set", KEY, []byte("other"), oneHour).Once().Return(nil)
+	s.node2.On("Set", KEY, []byte("other"), oneHour).Once().Return(nil)
+
+	s.applyCore(core.WithConflictResolver(resolver.LongestTTL))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
+		Value:   s.dataStr,
+		Winners: []keyvaluestore.Backend{s.node1, s.node2},
+		Losers:  []keyvaluestore.Backend{s.node3},
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyWriteToEngineOnce(3)
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldCapRepairsOfConflictResolver() {
+	before := testutil.ToFloat64(metrics.DeferredRepairsTotal)
+
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("Get", KEY).Once().Return([]byte("other"), nil)
+
+	oneHour := 1 * time.Hour
+	s.node1.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}, nil)
+	s.node2.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}, nil)
+	s.node3.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: []byte("other"), TTL: &oneHour}, nil)
+	repaired := 0
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2} {
+		node.On("Set", KEY, []byte("other"), oneHour).Maybe().Run(func(mock.Arguments) {
+			repaired++
+		}).Return(nil)
+	}
+
+	s.applyCore(core.WithConflictResolver(resolver.LongestTTL), core.WithRepairLimits(1, 0))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
+		Value:   s.dataStr,
+		Winners: []keyvaluestore.Backend{s.node1, s.node2},
+		Losers:  []keyvaluestore.Backend{s.node3},
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyWriteToEngineOnce(3)
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(1, repaired)
+	s.Equal(before+1, testutil.ToFloat64(metrics.DeferredRepairsTotal))
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotResolveConflictOnReadOnlyNodes() {
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return([]byte("other"), nil)
+	s.node3.On("Get", KEY).Once().Return([]byte("third"), nil)
+
+	oneHour := 1 * time.Hour
+	s.node1.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}, nil)
+	s.node2.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: []byte("other"), TTL: &oneHour}, nil)
+	s.node1.On("Set", KEY, []byte("other"), oneHour).Once().Return(nil)
+
+	s.applyCore(core.WithConflictResolver(resolver.LongestTTL))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL, s.withReadOnly(s.node3))
+	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
+		Value:   s.dataStr,
+		Winners: []keyvaluestore.Backend{s.node1},
+		Losers:  []keyvaluestore.Backend{s.node2, s.node3},
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyWriteToEngineOnce(2)
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node3.AssertNotCalled(s.T(), "GetWithTTL", KEY)
+	s.node3.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotResolveConflictIfMajorityIsMissingKey() {
+	s.node1.On("Get", KEY).Once().Return(nil, keyvaluestore.ErrNotFound)
+	s.node2.On("Get", KEY).Once().Return(nil, keyvaluestore.ErrNotFound)
+	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("Delete", KEY).Once().Return(nil)
+
+	s.applyCore(core.WithValueVersioning(true))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+		Err:     keyvaluestore.ErrNotFound,
+		Winners: []keyvaluestore.Backend{s.node1, s.node2},
+		Losers:  []keyvaluestore.Backend{s.node3},
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.NotFound)
+	s.node3.AssertExpectations(s.T())
+	s.node3.AssertNotCalled(s.T(), "GetWithTTL", KEY)
+	s.node3.AssertNotCalled(s.T(), "Set", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldForfeitRepairIfTTLHitsError() {
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+
+	s.node1.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
+	s.node2.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
+	s.node3.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
+
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
+		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+		Value:   s.dataStr,
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(time.Duration(0), errors.New("some error"), nil, 2,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldAcquireTTLAndApplyToLosers() {
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+
+	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+	s.node2.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+
+	s.node3.On("Set", KEY, s.dataStr, time.Duration(1*time.Minute)).Once().Return(nil)
+
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
+		Losers:  []keyvaluestore.Backend{s.node3},
+		Winners: []keyvaluestore.Backend{s.node1, s.node2},
+		Value:   s.dataStr,
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 2,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldNotApplyTTLDuringRepairIfItDoesNotExist() {
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+
+	s.node1.On("TTL", KEY).Once().Return(nil, nil)
+	s.node2.On("TTL", KEY).Once().Return(nil, nil)
+
+	s.node3.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
+		Losers:  []keyvaluestore.Backend{s.node3},
+		Winners: []keyvaluestore.Backend{s.node1, s.node2},
+		Value:   s.dataStr,
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(nil, nil, nil, 2,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldForfeitRepairIfTTLIsZero() {
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+
+	s.node1.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
+	s.node2.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
+
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(s.dataStr, nil, &keyvaluestore.RepairArgs{
+		Losers:  []keyvaluestore.Backend{s.node3},
+		Winners: []keyvaluestore.Backend{s.node1, s.node2},
+		Value:   s.dataStr,
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(&ZERO_MINUTE, nil, nil, 2,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetShouldUseVotingModeFromClusterView() {
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL,
+		s.withVotingMode(keyvaluestore.VotingModeSkipVoteOnNotFound))
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 1,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+
+	_, err := s.core.Get(context.Background(), &keyvaluestore.GetRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetManyShouldReturnValueAndTTLOfFoundKeys() {
+	stored := &keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}
+	s.node1.On("GetWithTTL", KEY).Once().Return(stored, nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(stored, nil, nil, 1, keyvaluestore.VotingModeVoteOnNotFound)
+	result, err := s.core.GetMany(context.Background(), &keyvaluestore.GetManyRequest{
+		Keys:    []string{KEY},
+		WithTTL: true,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(&keyvaluestore.GetManyItem{Found: true, Data: s.dataStr, TTL: &ONE_MINUTE}, result.Items[KEY])
+	s.node1.AssertNotCalled(s.T(), "Get", KEY)
+	s.node1.AssertNotCalled(s.T(), "TTL", KEY)
+}
+
+func (s *CoreServiceTestSuite) TestGetManyShouldRepairValueAndTTLTogether() {
+	s.node1.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}, nil)
+	s.node2.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}, nil)
+	s.node3.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: []byte("other"), TTL: &ONE_MINUTE}, nil)
+	repaired := make(chan struct{})
+	s.node3.On("Set", KEY, s.dataStr, ONE_MINUTE).Once().Run(func(args mock.Arguments) {
+		close(repaired)
+	}).Return(nil)
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_MAJORITY, func(o *clusterOptionContext) {
+		o.readView.VoteRequired = 2
+	})
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	result, err := core.New(s.cluster, realEngine).GetMany(context.Background(), &keyvaluestore.GetManyRequest{
+		Keys:    []string{KEY},
+		WithTTL: true,
+		Options: keyvaluestore.ReadOptions{Consistency: keyvaluestore.ConsistencyLevel_MAJORITY},
+	})
+	s.Nil(err)
+	s.Equal(&keyvaluestore.GetManyItem{Found: true, Data: s.dataStr, TTL: &ONE_MINUTE}, result.Items[KEY])
+
+	select {
+	case <-repaired:
+	case <-time.After(time.Second):
+		s.Fail("the node with the other value should be repaired")
+	}
+}
+
+func (s *CoreServiceTestSuite) TestGetManyShouldReportMissingKeysAsNotFound() {
+	s.node1.On("GetWithTTL", KEY).Once().Return(nil, keyvaluestore.ErrNotFound)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, keyvaluestore.ErrNotFound, nil, 1, keyvaluestore.VotingModeVoteOnNotFound)
+	result, err := s.core.GetMany(context.Background(), &keyvaluestore.GetManyRequest{
+		Keys:    []string{KEY},
+		WithTTL: true,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(&keyvaluestore.GetManyItem{}, result.Items[KEY])
+	s.node1.AssertNotCalled(s.T(), "TTL", KEY)
+}
+
+func (s *CoreServiceTestSuite) TestGetManyShouldReadKeysSharingNodesOncePerNode() {
+	keys := []string{KEY, "other", "missing"}
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2} {
+		node.On("GetMany", keys).Once().Return(map[string][]byte{KEY: s.dataStr, "other": s.dataStr}, nil)
+	}
+	view := keyvaluestore.ReadClusterView{
+		Backends:     []keyvaluestore.Backend{s.node1, s.node2},
+		VoteRequired: 2,
+		VotingMode:   keyvaluestore.VotingModeVoteOnNotFound,
+	}
+	for _, key := range keys {
+		s.cluster.On("Read", key, keyvaluestore.ConsistencyLevel_ALL).Return(view, nil)
+	}
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	result, err := core.New(s.cluster, realEngine).GetMany(context.Background(), &keyvaluestore.GetManyRequest{
+		Keys:    keys,
+		Options: keyvaluestore.ReadOptions{Consistency: keyvaluestore.ConsistencyLevel_ALL},
+	})
+	s.Nil(err)
+	s.Equal(&keyvaluestore.GetManyItem{Found: true, Data: s.dataStr}, result.Items[KEY])
+	s.Equal(&keyvaluestore.GetManyItem{Found: true, Data: s.dataStr}, result.Items["other"])
+	s.Equal(&keyvaluestore.GetManyItem{}, result.Items["missing"])
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node1.AssertNotCalled(s.T(), "Get", mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestGetManyShouldFailIfConsistencyIsNotMet() {
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, keyvaluestore.ErrConsistency, nil, 1, keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.GetMany(context.Background(), &keyvaluestore.GetManyRequest{
+		Keys: []string{KEY},
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+}
+
+func (s *CoreServiceTestSuite) TestWatchShouldDeduplicateEventsOfNodes() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events1 := make(chan keyvaluestore.Event, 2)
+	events2 := make(chan keyvaluestore.Event, 2)
+	s.node1.On("Watch", ctx, KEY+"*").Once().Return((<-chan keyvaluestore.Event)(events1), nil)
+	s.node2.On("Watch", ctx, KEY+"*").Once().Return((<-chan keyvaluestore.Event)(events2), nil)
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+
+	result, err := s.core.Watch(ctx, &keyvaluestore.WatchRequest{
+		Key:    KEY,
+		Prefix: true,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+
+	events1 <- keyvaluestore.Event{Key: KEY, Type: keyvaluestore.EventSet}
+	events2 <- keyvaluestore.Event{Key: KEY, Type: keyvaluestore.EventSet}
+	events1 <- keyvaluestore.Event{Key: KEY, Type: keyvaluestore.EventDelete}
+	events2 <- keyvaluestore.Event{Key: KEY, Type: keyvaluestore.EventDelete}
+	close(events1)
+	close(events2)
+
+	var received []keyvaluestore.Event
+	for event := range result {
+		received = append(received, event)
+	}
+	s.ElementsMatch([]keyvaluestore.Event{
+		{Key: KEY, Type: keyvaluestore.EventSet},
+		{Key: KEY, Type: keyvaluestore.EventDelete},
+	}, received)
+}
+
+func (s *CoreServiceTestSuite) TestWatchShouldFailIfNoNodeCanBeWatched() {
+	s.node1.On("Watch", mock.Anything, KEY).Once().Return(nil, keyvaluestore.ErrClosed)
+	s.node1.On("Address").Return("node1")
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+
+	_, err := s.core.Watch(context.Background(), &keyvaluestore.WatchRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+}
+
+func (s *CoreServiceTestSuite) TestGetTTLShouldCallTTLUponBackends() {
+	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	value, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.NotNil(value.TTL)
+	if value.TTL != nil {
+		s.Equal(1*time.Minute, *value.TTL)
+	}
+}
+
+func (s *CoreServiceTestSuite) TestGetTTLShouldCompareSubSecondTTLsWithinTolerance() {
+	short := 300 * time.Millisecond
+	long := 900 * time.Millisecond
+	s.node1.On("TTL", KEY).Return(&short, nil)
+	s.node2.On("TTL", KEY).Return(&long, nil)
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	request := &keyvaluestore.GetTTLRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	}
+
+	response, err := core.New(s.cluster, realEngine).GetTTL(context.Background(), request)
+	s.Nil(err)
+	s.Require().NotNil(response.TTL)
+	s.True(*response.TTL == short || *response.TTL == long)
+
+	_, err = core.New(s.cluster, realEngine, core.WithTTLTolerance(100*time.Millisecond)).
+		GetTTL(context.Background(), request)
+	s.assertStatusCode(err, codes.Unavailable)
+}
+
+func (s *CoreServiceTestSuite) TestGetTTLShouldReturnMillisecondsAndTellShortTTLsFromNoExpiry() {
+	short := 1*time.Millisecond + 400*time.Microsecond
+	s.node1.On("TTL", KEY).Once().Return(&short, nil)
+	s.node1.On("TTL", KEY).Once().Return(nil, nil)
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	service := core.New(s.cluster, realEngine, core.WithTTLTolerance(0))
+	request := &keyvaluestore.GetTTLRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	}
+
+	response, err := service.GetTTL(context.Background(), request)
+	s.Nil(err)
+	s.Require().NotNil(response.TTL)
+	s.Equal(time.Millisecond, *response.TTL)
+
+	response, err = service.GetTTL(context.Background(), request)
+	s.Nil(err)
+	s.Nil(response.TTL)
+}
+
+func (s *CoreServiceTestSuite) TestGetTTLShouldAgreeOnKeysWhichNeverExpire() {
+	s.node1.On("TTL", KEY).Return(nil, nil)
+	s.node2.On("TTL", KEY).Return(nil, nil)
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	response, err := core.New(s.cluster, realEngine).GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Nil(response.TTL)
+}
+
+func (s *CoreServiceTestSuite) TestGetTTLShouldNotUseDefaultConsistencyLevelIfRequestProvidesIt() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestGetTTLShouldUseDefaultConsistencyLevelIfRequestDoesNotProvidesIt() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
+		Key: KEY,
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestGetTTLShouldRepairWithDeleteIfResultIsNotFound() {
+	s.node1.On("Delete", KEY).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	s.applyReadToEngineOnce(1*time.Second, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+		Err:    keyvaluestore.ErrNotFound,
+		Losers: []keyvaluestore.Backend{s.node1},
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.NotFound)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetTTLShouldForfeitRepairIfGetHitsError() {
+	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+	s.node2.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+	s.node3.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(&ONE_MINUTE, nil, &keyvaluestore.RepairArgs{
+		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+		Value:   &ONE_MINUTE,
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(nil, errors.New("some error"), nil, 2,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestGetTTLShouldAcquireDataAndApplyToLosers() {
+	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+	s.node2.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+	s.node3.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+
+	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+
+	s.node3.On("Set", KEY, s.dataStr, 1*time.Minute).Once().Return(nil)
+
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(&ONE_MINUTE, nil, &keyvaluestore.RepairArgs{
+		Losers:  []keyvaluestore.Backend{s.node3},
+		Winners: []keyvaluestore.Backend{s.node1, s.node2},
+		Value:   &ONE_MINUTE,
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(s.dataStr, nil, nil, 2,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.GetTTL(context.Background(), &keyvaluestore.GetTTLRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldCallExpireUponBackends() {
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	value, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(true, value.Exists)
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldRequireRequestedNumberOfVotes() {
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2, s.node3} {
+		node.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+	}
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, nil, 2, keyvaluestore.VotingModeVoteOnNotFound)
+	value, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:   keyvaluestore.ConsistencyLevel_ONE,
+			RequiredNodes: 2,
+		},
+	})
+	s.Nil(err)
+	s.Equal(true, value.Exists)
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldReportMissingKeyByDefault() {
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(keyvaluestore.ErrNotFound)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, keyvaluestore.ErrNotFound, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	value, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.False(value.Exists)
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldFailWithNotFoundForMissingKeyIfRequested() {
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(keyvaluestore.ErrNotFound)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, keyvaluestore.ErrNotFound, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		MissingKey: keyvaluestore.MissingKeyNotFound,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.NotFound)
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldClampTTLAboveMaximum() {
+	s.node1.On("Expire", KEY, 1*time.Hour).Once().Return(nil)
+	s.applyCore(core.WithMaxWriteTTL(1*time.Hour, false))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 24 * time.Hour,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestTouchShouldRejectTTLAboveMaximumIfConfigured() {
+	s.applyCore(core.WithMaxWriteTTL(1*time.Hour, true))
+	_, err := s.core.Touch(context.Background(), &keyvaluestore.TouchRequest{
+		Key:        KEY,
+		Expiration: 24 * time.Hour,
+	})
+	s.assertStatusCode(err, codes.InvalidArgument)
+	s.node1.AssertNotCalled(s.T(), "Expire", mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestTouchShouldExpireUsingWriteConsistency() {
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+	s.node2.On("Expire", KEY, 1*time.Minute).Once().Return(keyvaluestore.ErrNotFound)
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_ALL))
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(2)
+	value, err := s.core.Touch(context.Background(), &keyvaluestore.TouchRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+	})
+	s.Nil(err)
+	s.True(value.Exists)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestTouchShouldReportMissingKey() {
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(keyvaluestore.ErrNotFound)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	value, err := s.core.Touch(context.Background(), &keyvaluestore.TouchRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.False(value.Exists)
+}
+
+func (s *CoreServiceTestSuite) TestTouchShouldFailIfConsistencyIsNotMet() {
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1, WithWriteError(keyvaluestore.ErrConsistency))
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(errors.New("connection refused"))
+	_, err := s.core.Touch(context.Background(), &keyvaluestore.TouchRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+}
+
+func (s *CoreServiceTestSuite) TestRateLimitCheckShouldAllowUpToLimit() {
+	s.node1.On("IncrWindow", "__kvs_ratelimit:"+KEY, 1*time.Minute).Once().Return(int64(3), nil)
+	s.node2.On("IncrWindow", "__kvs_ratelimit:"+KEY, 1*time.Minute).Once().Return(int64(2), nil)
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_ALL))
+	s.applyRateLimitCluster()
+	s.applyWriteToEngineOnce(2)
+	result, err := s.core.RateLimitCheck(context.Background(), &keyvaluestore.RateLimitCheckRequest{
+		Key:    KEY,
+		Limit:  5,
+		Window: 1 * time.Minute,
+	})
+	s.Nil(err)
+	s.True(result.Allowed)
+	s.Equal(int64(2), result.Remaining)
+}
+
+func (s *CoreServiceTestSuite) TestRateLimitCheckShouldDecideUsingHighestCount() {
+	s.node1.On("IncrWindow", "__kvs_ratelimit:"+KEY, 1*time.Minute).Once().Return(int64(4), nil)
+	s.node2.On("IncrWindow", "__kvs_ratelimit:"+KEY, 1*time.Minute).Once().Return(int64(6), nil)
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_ALL))
+	s.applyRateLimitCluster()
+	s.applyWriteToEngineOnce(2)
+	result, err := s.core.RateLimitCheck(context.Background(), &keyvaluestore.RateLimitCheckRequest{
+		Key:    KEY,
+		Limit:  5,
+		Window: 1 * time.Minute,
+	})
+	s.Nil(err)
+	s.False(result.Allowed)
+	s.Equal(int64(0), result.Remaining)
+}
+
+func (s *CoreServiceTestSuite) TestRateLimitCheckShouldRejectInvalidLimit() {
+	s.applyCore()
+	_, err := s.core.RateLimitCheck(context.Background(), &keyvaluestore.RateLimitCheckRequest{
+		Key:    KEY,
+		Window: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.InvalidArgument)
+}
+
+func (s *CoreServiceTestSuite) TestStreamAddShouldReturnIncreasingIDs() {
+	s.node1.On("StreamAdd", KEY, mock.Anything).Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	s.applyWriteToEngineOnce(1)
+
+	request := &keyvaluestore.StreamAddRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	}
+	first, err := s.core.StreamAdd(context.Background(), request)
+	s.Nil(err)
+	second, err := s.core.StreamAdd(context.Background(), request)
+	s.Nil(err)
+
+	s.True(first.ID.Less(second.ID))
+	s.node1.AssertCalled(s.T(), "StreamAdd", KEY, keyvaluestore.StreamEntry{ID: first.ID, Data: s.dataStr})
+}
+
+func (s *CoreServiceTestSuite) TestStreamAddShouldRemoveEntryFromNodesIfWriteFails() {
+	var entry keyvaluestore.StreamEntry
+	s.node1.On("StreamAdd", KEY, mock.Anything).Once().Run(func(args mock.Arguments) {
+		entry = args.Get(1).(keyvaluestore.StreamEntry)
+	}).Return(nil)
+	s.node2.On("StreamAdd", KEY, mock.Anything).Once().Return(errors.New("some error"))
+	s.node1.On("StreamRemove", KEY, mock.Anything).Once().Return(nil)
+	s.cluster.On("Write", KEY, keyvaluestore.ConsistencyLevel_ALL).Return(keyvaluestore.WriteClusterView{
+		Backends:            []keyvaluestore.Backend{s.node1, s.node2},
+		AcknowledgeRequired: 2,
+	}, nil)
+
+	realEngine := engine.New(voting.New)
+
+	_, err := core.New(s.cluster, realEngine).StreamAdd(context.Background(), &keyvaluestore.StreamAddRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+
+	realEngine.Close()
+	s.node1.AssertCalled(s.T(), "StreamRemove", KEY, entry.ID)
+}
+
+func (s *CoreServiceTestSuite) TestStreamRangeShouldRepairLosersWithMissingEntries() {
+	entries := []keyvaluestore.StreamEntry{
+		{ID: keyvaluestore.StreamID{Time: 1}, Data: s.dataStr},
+		{ID: keyvaluestore.StreamID{Time: 1, Sequence: 1}, Data: s.dataStr},
+	}
+	s.node1.On("StreamAdd", KEY, entries[0]).Once().Return(nil)
+	s.node1.On("StreamAdd", KEY, entries[1]).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	s.applyReadToEngineOnce(entries, nil, &keyvaluestore.RepairArgs{
+		Value:  entries,
+		Losers: []keyvaluestore.Backend{s.node1},
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+
+	result, err := s.core.StreamRange(context.Background(), &keyvaluestore.StreamRangeRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(entries, result.Entries)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestStreamRangeShouldNotRepairUsingConflictResolver() {
+	entries := []keyvaluestore.StreamEntry{
+		{ID: keyvaluestore.StreamID{Time: 1}, Data: s.dataStr},
+	}
+	s.node1.On("StreamAdd", KEY, entries[0]).Once().Return(nil)
+	s.applyCore(core.WithValueVersioning(true))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	s.applyReadToEngineOnce(entries, nil, &keyvaluestore.RepairArgs{
+		Value:   entries,
+		Winners: []keyvaluestore.Backend{s.node2},
+		Losers:  []keyvaluestore.Backend{s.node1},
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+
+	_, err := s.core.StreamRange(context.Background(), &keyvaluestore.StreamRangeRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertNotCalled(s.T(), "GetWithTTL", KEY)
+}
+
+func (s *CoreServiceTestSuite) TestStreamRangeShouldReturnNoEntriesIfStreamDoesNotExist() {
+	s.applyCore()
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(nil, keyvaluestore.ErrNotFound, nil, 0, keyvaluestore.VotingModeVoteOnNotFound)
+
+	result, err := s.core.StreamRange(context.Background(), &keyvaluestore.StreamRangeRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Empty(result.Entries)
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldNotUseDefaultConsistencyLevelIfRequestProvidesIt() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldUseDefaultConsistencyLevelIfRequestDoesNotProvidesIt() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyReadToEngineOnce(true, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldRepairWithDeleteIfResultIsNotFound() {
+	s.node1.On("Delete", KEY).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	s.applyReadToEngineOnce(false, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+		Value:  false,
+		Losers: []keyvaluestore.Backend{s.node1},
+		Err:    keyvaluestore.ErrNotFound,
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldForfeitRepairIfGetWithTTLHitsError() {
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+	s.node2.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+	s.node3.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+
+	s.node1.On("GetWithTTL", KEY).Once().Return(nil, errors.New("some error"))
+	s.node2.On("GetWithTTL", KEY).Once().Return(nil, errors.New("some error"))
+	s.node3.On("GetWithTTL", KEY).Once().Return(nil, errors.New("some error"))
+
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
+		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+		Value:   true,
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(nil, errors.New("some error"), nil, 2,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldAcquireTTLWithDataAndApplyToLosers() {
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+	s.node2.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+	s.node3.On("Expire", KEY, 1*time.Minute).Once().Return(keyvaluestore.ErrNotFound)
+
+	value := &keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}
+	s.node1.On("GetWithTTL", KEY).Once().Return(value, nil)
+	s.node2.On("GetWithTTL", KEY).Once().Return(value, nil)
+
+	s.node3.On("Set", KEY, s.dataStr, 1*time.Minute).Once().Return(nil)
+
 	s.applyCore()
 	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
 	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
@@ -602,37 +3259,474 @@ func (s *CoreServiceTestSuite) TestExpireShouldAcquireTTLWithDataAndApplyToLoser
 		Winners: []keyvaluestore.Backend{s.node1, s.node2},
 		Value:   true,
 	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 2,
+	s.applyReadToEngineOnce(value, nil, nil, 2,
 		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	s.applyReadToEngineOnce(s.dataStr, nil, nil, 2,
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldRepairWithoutTTLIfValueDoesNotExpire() {
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+	s.node2.On("Expire", KEY, 1*time.Minute).Once().Return(keyvaluestore.ErrNotFound)
+
+	value := &keyvaluestore.ValueWithTTL{Data: s.dataStr}
+	s.node1.On("GetWithTTL", KEY).Once().Return(value, nil)
+
+	s.node2.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
+		Losers:  []keyvaluestore.Backend{s.node2},
+		Winners: []keyvaluestore.Backend{s.node1},
+		Value:   true,
+	}, 2, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(value, nil, nil, 1,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExpireShouldUseVotingModeFromClusterView() {
+	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL,
+		s.withVotingMode(keyvaluestore.VotingModeSkipVoteOnNotFound))
+	s.applyReadToEngineOnce(true, nil, nil, 1,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+
+	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
+		Key:        KEY,
+		Expiration: 1 * time.Minute,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldCallExistsUponBackends() {
+	s.node1.On("Exists", KEY).Once().Return(true, nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, nil, 1,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	value, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(true, value.Exists)
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldNotUseDefaultConsistencyLevelIfRequestProvidesIt() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldUseDefaultConsistencyLevelIfRequestDoesNotProvidesIt() {
+	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyReadToEngineOnce(true, nil, nil, 0,
+		keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldRepairWithDeleteIfResultIsNotFound() {
+	s.node1.On("Delete", KEY).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	s.applyReadToEngineOnce(false, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+		Value:  false,
+		Losers: []keyvaluestore.Backend{s.node1},
+		Err:    keyvaluestore.ErrNotFound,
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldKeepItsRepairIfConflictResolverIsProvided() {
+	s.node1.On("Delete", KEY).Once().Return(nil)
+	s.applyCore(core.WithConflictResolver(resolver.LongestTTL))
+	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(0)
+	s.applyReadToEngineOnce(false, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
+		Value:   false,
+		Winners: []keyvaluestore.Backend{s.node2},
+		Losers:  []keyvaluestore.Backend{s.node1},
+		Err:     keyvaluestore.ErrNotFound,
+	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertNotCalled(s.T(), "GetWithTTL", KEY)
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldForfeitRepairIfGetWithTTLHitsError() {
+	s.node1.On("Exists", KEY).Once().Return(true, nil)
+	s.node2.On("Exists", KEY).Once().Return(true, nil)
+	s.node3.On("Exists", KEY).Once().Return(true, nil)
+
+	s.node1.On("GetWithTTL", KEY).Once().Return(nil, errors.New("some error"))
+	s.node2.On("GetWithTTL", KEY).Once().Return(nil, errors.New("some error"))
+	s.node3.On("GetWithTTL", KEY).Once().Return(nil, errors.New("some error"))
+
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
+		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+		Value:   true,
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(nil, errors.New("some error"), nil, 2,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldAcquireTTLWithDataAndApplyToLosers() {
+	s.node1.On("Exists", KEY).Once().Return(true, nil)
+	s.node2.On("Exists", KEY).Once().Return(true, nil)
+	s.node3.On("Exists", KEY).Once().Return(false, nil)
+
+	value := &keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}
+	s.node1.On("GetWithTTL", KEY).Once().Return(value, nil)
+	s.node2.On("GetWithTTL", KEY).Once().Return(value, nil)
+
+	s.node3.On("Set", KEY, s.dataStr, 1*time.Minute).Once().Return(nil)
+
+	s.applyCore()
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
+		Losers:  []keyvaluestore.Backend{s.node3},
+		Winners: []keyvaluestore.Backend{s.node1, s.node2},
+		Value:   true,
+	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(value, nil, nil, 2,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	s.applyWriteToEngineOnce(0)
+
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+	s.node3.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldRepairWithoutTTLIfValueDoesNotExpire() {
+	s.node1.On("Exists", KEY).Once().Return(true, nil)
+	s.node2.On("Exists", KEY).Once().Return(false, nil)
+
+	value := &keyvaluestore.ValueWithTTL{Data: s.dataStr}
+	s.node1.On("GetWithTTL", KEY).Once().Return(value, nil)
+
+	s.node2.On("Set", KEY, s.dataStr, time.Duration(0)).Once().Return(nil)
+
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
+		Losers:  []keyvaluestore.Backend{s.node2},
+		Winners: []keyvaluestore.Backend{s.node1},
+		Value:   true,
+	}, 2, keyvaluestore.VotingModeVoteOnNotFound)
+	s.applyReadToEngineOnce(value, nil, nil, 1,
 		keyvaluestore.VotingModeSkipVoteOnNotFound)
 	s.applyWriteToEngineOnce(0)
 
-	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
-		Key:        KEY,
-		Expiration: 1 * time.Minute,
-		Options: keyvaluestore.WriteOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+	s.node2.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldUseVotingModeFromClusterView() {
+	s.node1.On("Exists", KEY).Once().Return(true, nil)
+
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL,
+		s.withVotingMode(keyvaluestore.VotingModeSkipVoteOnNotFound))
+	s.applyReadToEngineOnce(true, nil, nil, 1,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldPreferOperationVotingModeOverClusterView() {
+	s.node1.On("Exists", KEY).Once().Return(true, nil)
+
+	s.applyCore(core.WithOperationVotingMode(core.OperationExists,
+		keyvaluestore.VotingModeSkipVoteOnNotFound))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyReadToEngineOnce(true, nil, nil, 1,
+		keyvaluestore.VotingModeSkipVoteOnNotFound)
+
+	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+}
+
+func (s *CoreServiceTestSuite) TestOperationVotingModeShouldPanicForWriteOperations() {
+	s.Panics(func() {
+		s.applyCore(core.WithOperationVotingMode(core.OperationSet,
+			keyvaluestore.VotingModeSkipVoteOnNotFound))
+	})
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldReturnFalseIfQuorumReportsNotFound() {
+	s.node1.On("Exists", KEY).Return(false, nil)
+	s.node2.On("Exists", KEY).Return(false, nil)
+	s.node3.On("Exists", KEY).Return(true, nil)
+	repaired := make(chan struct{})
+	s.node3.On("Delete", KEY).Once().Run(func(args mock.Arguments) {
+		close(repaired)
+	}).Return(nil)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	response, err := s.existsOnRealEngine(realEngine, keyvaluestore.VotingModeVoteOnNotFound)
+	s.Nil(err)
+	s.False(response.Exists)
+
+	select {
+	case <-repaired:
+	case <-time.After(time.Second):
+		s.Fail("the node which has the key should be repaired")
+	}
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldReturnTrueIfOnlyMinorityReportsNotFound() {
+	s.node1.On("Exists", KEY).Return(true, nil)
+	s.node2.On("Exists", KEY).Return(false, nil)
+	s.node3.On("Exists", KEY).Return(true, nil)
+	s.node2.On("TTL", KEY).Return(nil, keyvaluestore.ErrNotFound).Maybe()
+	s.node1.On("GetWithTTL", KEY).Return(&keyvaluestore.ValueWithTTL{Data: s.dataStr}, nil).Maybe()
+	s.node3.On("GetWithTTL", KEY).Return(&keyvaluestore.ValueWithTTL{Data: s.dataStr}, nil).Maybe()
+	s.node2.On("Set", KEY, s.dataStr, mock.Anything).Return(nil).Maybe()
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	response, err := s.existsOnRealEngine(realEngine, keyvaluestore.VotingModeVoteOnNotFound)
+	s.Nil(err)
+	s.True(response.Exists)
+}
+
+func (s *CoreServiceTestSuite) TestExistsShouldNotCountNotFoundAsVotesInSkipMode() {
+	s.node1.On("Exists", KEY).Return(false, nil)
+	s.node2.On("Exists", KEY).Return(false, nil)
+	s.node3.On("Exists", KEY).Return(true, nil)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	_, err := s.existsOnRealEngine(realEngine, keyvaluestore.VotingModeSkipVoteOnNotFound)
+	s.Equal(codes.Unavailable, status.Code(err))
+}
+
+// existsOnRealEngine runs Exists on all three nodes requiring a majority,
+// voting by a real engine rather than the mocked one.
+func (s *CoreServiceTestSuite) existsOnRealEngine(realEngine keyvaluestore.Engine,
+	mode keyvaluestore.VotingMode) (*keyvaluestore.ExistsResponse, error) {
+
+	s.cluster.On("Read", KEY, keyvaluestore.ConsistencyLevel_MAJORITY).Return(keyvaluestore.ReadClusterView{
+		Backends:     []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+		VoteRequired: 2,
+	}, nil)
+
+	service := core.New(s.cluster, realEngine,
+		core.WithOperationVotingMode(core.OperationExists, mode))
+
+	return service.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+		Key: KEY,
+		Options: keyvaluestore.ReadOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
 		},
 	})
+}
+
+func (s *CoreServiceTestSuite) TestInspectShouldReportRawValueOfEveryNode() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("GetWithTTL", KEY).Once().Return(
+		&keyvaluestore.ValueWithTTL{Data: s.dataStr, TTL: &ONE_MINUTE}, nil)
+	s.node2.On("Address").Return("node2")
+	s.node2.On("GetWithTTL", KEY).Once().Return(nil, keyvaluestore.ErrNotFound)
+	s.node3.On("Address").Return("node3")
+	s.node3.On("GetWithTTL", KEY).Once().Return(nil, errors.New("some error"))
+	s.cluster.On("Nodes").Return([]keyvaluestore.Backend{s.node1, s.node2, s.node3})
+	s.applyCore()
+
+	response, err := s.core.Inspect(context.Background(), &keyvaluestore.InspectRequest{Key: KEY})
+	s.Nil(err)
+
+	ttl := ONE_MINUTE.Milliseconds()
+	s.Equal([]keyvaluestore.NodeValue{
+		{Address: "node1", Exists: true, Data: s.dataStr, TTLMs: &ttl},
+		{Address: "node2"},
+		{Address: "node3", Error: "some error"},
+	}, response.Nodes)
+	s.engine.AssertNotCalled(s.T(), "Read", mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestFlushDbShouldCallDeleteOnNodes() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("FlushDB").Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	_, err := s.core.FlushDB(context.Background(), &keyvaluestore.FlushDBRequest{})
 	s.Nil(err)
 	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExpireShouldUseVotingModeFromClusterView() {
-	s.node1.On("Expire", KEY, 1*time.Minute).Once().Return(nil)
+func (s *CoreServiceTestSuite) TestFlushDbShouldRequireEveryNodeAndReportFailures() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("FlushDB").Once().Return(nil)
+	s.node2.On("Address").Return("node2")
+	s.node2.On("FlushDB").Once().Return(errors.New("some error"))
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL, func(o *clusterOptionContext) {
+		o.writeView.AcknowledgeRequired = 1
+	})
+	s.engine.On("Write", mock.Anything, 2, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Once().Run(func(args mock.Arguments) {
+		operator := args.Get(2).(keyvaluestore.WriteOperator)
+		for _, backend := range args.Get(0).([]keyvaluestore.Backend) {
+			_ = operator(backend)
+		}
+	}).Return(keyvaluestore.ErrConsistency)
+	response, err := s.core.FlushDB(context.Background(), &keyvaluestore.FlushDBRequest{})
+	s.assertStatusCode(err, codes.Unavailable)
+	s.Contains(err.Error(), "node2")
+	s.Equal([]string{"node1"}, response.Flushed)
+	s.Contains(response.Errors, "node2")
+}
+
+func (s *CoreServiceTestSuite) TestFlushDbShouldWaitForSlowNodes() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("FlushDB").Once().After(50 * time.Millisecond).Return(nil)
+	s.node2.On("Address").Return("node2")
+	s.node2.On("FlushDB").Once().Return(errors.New("some error"))
+	s.cluster.On("FlushDB").Return(keyvaluestore.WriteClusterView{
+		Backends:            []keyvaluestore.Backend{s.node1, s.node2},
+		AcknowledgeRequired: 2,
+	}, nil)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	response, err := core.New(s.cluster, realEngine).FlushDB(context.Background(), &keyvaluestore.FlushDBRequest{})
+	s.assertStatusCode(err, codes.Unavailable)
+	s.Equal([]string{"node1"}, response.Flushed)
+	s.Len(response.Errors, 1)
+}
 
+func (s *CoreServiceTestSuite) TestFlushDbShouldOnlyFlushRequestedNodes() {
+	s.node1.On("Address").Return("node1")
+	s.node2.On("Address").Return("node2")
+	s.node2.On("FlushDB").Once().Return(nil)
 	s.applyCore()
-	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL,
-		s.withVotingMode(keyvaluestore.VotingModeSkipVoteOnNotFound))
-	s.applyReadToEngineOnce(true, nil, nil, 1,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1, WithOrdering(s.node2))
+	_, err := s.core.FlushDB(context.Background(), &keyvaluestore.FlushDBRequest{Nodes: []string{"node2"}})
+	s.Nil(err)
+	s.node1.AssertNotCalled(s.T(), "FlushDB")
+	s.node2.AssertExpectations(s.T())
+}
 
-	_, err := s.core.Expire(context.Background(), &keyvaluestore.ExpireRequest{
-		Key:        KEY,
-		Expiration: 1 * time.Minute,
+func (s *CoreServiceTestSuite) TestFlushDbShouldRejectUnknownNodes() {
+	s.node1.On("Address").Return("node1")
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	_, err := s.core.FlushDB(context.Background(), &keyvaluestore.FlushDBRequest{Nodes: []string{"node9"}})
+	s.assertStatusCode(err, codes.InvalidArgument)
+}
+
+func (s *CoreServiceTestSuite) TestDeleteShouldCallDeleteOnNodes() {
+	s.node1.On("Delete", KEY).Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Delete(context.Background(), &keyvaluestore.DeleteRequest{
+		Key: KEY,
 		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
@@ -641,234 +3735,387 @@ func (s *CoreServiceTestSuite) TestExpireShouldUseVotingModeFromClusterView() {
 	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExistsShouldCallExistsUponBackends() {
-	s.node1.On("Exists", KEY).Once().Return(true, nil)
-	s.applyCore()
-	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(true, nil, nil, 1,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	value, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+func (s *CoreServiceTestSuite) TestDeleteShouldReturnPreviousValueAgreedByQuorum() {
+	s.node1.On("GetDel", KEY).Once().Return(s.dataStr, nil)
+	s.node2.On("GetDel", KEY).Once().Return(s.dataStr, nil)
+	s.node3.On("GetDel", KEY).Once().Return([]byte("stale"), nil)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	previous := &keyvaluestore.GetResponse{}
+	err := s.getDelOnRealEngine(realEngine, previous)
+	s.Nil(err)
+	s.Equal(s.dataStr, previous.Data)
+}
+
+func (s *CoreServiceTestSuite) TestDeleteShouldReturnNotFoundIfThereIsNoPreviousValue() {
+	s.node1.On("GetDel", KEY).Once().Return(nil, keyvaluestore.ErrNotFound)
+	s.node2.On("GetDel", KEY).Once().Return(nil, keyvaluestore.ErrNotFound)
+	s.node3.On("GetDel", KEY).Once().Return(s.dataStr, nil)
+
+	realEngine := engine.New(voting.New)
+	defer realEngine.Close()
+
+	err := s.getDelOnRealEngine(realEngine, &keyvaluestore.GetResponse{})
+	s.assertStatusCode(err, codes.NotFound)
+}
+
+// getDelOnRealEngine deletes KEY from all three nodes requiring a majority,
+// voting on its previous value by a real engine rather than the mocked one.
+func (s *CoreServiceTestSuite) getDelOnRealEngine(realEngine keyvaluestore.Engine,
+	previous *keyvaluestore.GetResponse) error {
+
+	s.cluster.On("Write", KEY, keyvaluestore.ConsistencyLevel_MAJORITY).Return(keyvaluestore.WriteClusterView{
+		Backends:            []keyvaluestore.Backend{s.node1, s.node2, s.node3},
+		AcknowledgeRequired: 2,
+	}, nil)
+
+	return core.New(s.cluster, realEngine).Delete(context.Background(), &keyvaluestore.DeleteRequest{
+		Key:      KEY,
+		Previous: previous,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
 		},
 	})
-	s.Nil(err)
-	s.Equal(true, value.Exists)
 }
 
-func (s *CoreServiceTestSuite) TestExistsShouldNotUseDefaultConsistencyLevelIfRequestProvidesIt() {
-	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+func (s *CoreServiceTestSuite) TestDeleteShouldNotUseDefaultWriteConsistencyIfProvidedByRequest() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
 	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(true, nil, nil, 0,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+	s.applyWriteToEngineOnce(0)
+	err := s.core.Delete(context.Background(), &keyvaluestore.DeleteRequest{
 		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
+		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
 }
 
-func (s *CoreServiceTestSuite) TestExistsShouldUseDefaultConsistencyLevelIfRequestDoesNotProvidesIt() {
-	s.applyCore(core.WithDefaultReadConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+func (s *CoreServiceTestSuite) TestDeleteShouldUseDefaultWriteConsistencyIfNotProvidedByRequest() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
 	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
-	s.applyReadToEngineOnce(true, nil, nil, 0,
-		keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
+	s.applyWriteToEngineOnce(0)
+	err := s.core.Delete(context.Background(), &keyvaluestore.DeleteRequest{
 		Key: KEY,
 	})
 	s.Nil(err)
 }
 
-func (s *CoreServiceTestSuite) TestExistsShouldRepairWithDeleteIfResultIsNotFound() {
-	s.node1.On("Delete", KEY).Once().Return(nil)
+func (s *CoreServiceTestSuite) TestDeleteManyShouldCallDeleteManyOnNodes() {
+	s.node1.On("DeleteMany", []string{KEY}).Once().Return(int64(1), nil)
 	s.applyCore()
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyWriteToEngineOnce(0)
-	s.applyReadToEngineOnce(false, keyvaluestore.ErrNotFound, &keyvaluestore.RepairArgs{
-		Value:  false,
-		Losers: []keyvaluestore.Backend{s.node1},
-		Err:    keyvaluestore.ErrNotFound,
-	}, 0, keyvaluestore.VotingModeVoteOnNotFound)
-	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	response, err := s.core.DeleteMany(context.Background(), &keyvaluestore.DeleteManyRequest{
+		Keys: []string{KEY},
+		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
+	s.Equal(int64(1), response.Deleted)
 	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExistsShouldForfeitRepairIfTTLHitsError() {
-	s.node1.On("Exists", KEY).Once().Return(true, nil)
-	s.node2.On("Exists", KEY).Once().Return(true, nil)
-	s.node3.On("Exists", KEY).Once().Return(true, nil)
+func (s *CoreServiceTestSuite) TestDeleteManyShouldReportMaximumDeletedAmongNodes() {
+	s.node1.On("DeleteMany", []string{KEY}).Once().Return(int64(0), nil)
+	s.node2.On("DeleteMany", []string{KEY}).Once().Return(int64(1), nil)
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(2)
+	response, err := s.core.DeleteMany(context.Background(), &keyvaluestore.DeleteManyRequest{
+		Keys: []string{KEY},
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Nil(err)
+	s.Equal(int64(1), response.Deleted)
+}
 
-	s.node1.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node3.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
+func (s *CoreServiceTestSuite) TestDeleteManyShouldReturnZeroForEmptyKeys() {
+	s.applyCore()
+	response, err := s.core.DeleteMany(context.Background(), &keyvaluestore.DeleteManyRequest{})
+	s.Nil(err)
+	s.Zero(response.Deleted)
+}
 
+func (s *CoreServiceTestSuite) TestTransactionShouldApplyAllOpsOnNodes() {
+	s.node1.On("Transaction", []keyvaluestore.Op{
+		{Type: keyvaluestore.OpSet, Key: KEY, Data: s.dataStr, Expiration: time.Minute},
+		{Type: keyvaluestore.OpDelete, Key: "other"},
+	}).Once().Return(nil)
 	s.applyCore()
-	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
-		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
-		Value:   true,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(&ZERO_MINUTE, errors.New("some error"), nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", "other", keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes, AcknowledgeRequired: 1}, nil)
+	s.applyWriteToEngineOnce(1)
+	err := s.core.Transaction(context.Background(), &keyvaluestore.TransactionRequest{
+		Ops: []keyvaluestore.Op{
+			{Type: keyvaluestore.OpSet, Key: KEY, Data: s.dataStr, Expiration: time.Minute},
+			{Type: keyvaluestore.OpDelete, Key: "other"},
+		},
+		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
 	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExistsShouldForfeitRepairIfGetHitsError() {
-	s.node1.On("Exists", KEY).Once().Return(true, nil)
-	s.node2.On("Exists", KEY).Once().Return(true, nil)
-	s.node3.On("Exists", KEY).Once().Return(true, nil)
-
-	s.node1.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-	s.node3.On("TTL", KEY).Once().Return(&ZERO_MINUTE, nil)
-
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node3.On("Get", KEY).Once().Return(s.dataStr, nil)
+func (s *CoreServiceTestSuite) TestTransactionShouldRejectKeysOnDifferentNodes() {
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.cluster.On("Write", "other", keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{Backends: s.nodes[1:], AcknowledgeRequired: 1}, nil)
+	err := s.core.Transaction(context.Background(), &keyvaluestore.TransactionRequest{
+		Ops: []keyvaluestore.Op{
+			{Type: keyvaluestore.OpSet, Key: KEY, Data: s.dataStr},
+			{Type: keyvaluestore.OpSet, Key: "other", Data: s.dataStr},
+		},
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+	s.Contains(err.Error(), keyvaluestore.ErrCrossShard.Error())
+	s.engine.AssertNotCalled(s.T(), "Write", mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything)
+}
 
+func (s *CoreServiceTestSuite) TestOptimisticShouldApplyOnFirstNodeAndReplicateToOthers() {
+	s.node1.On("Address").Return("node1")
+	s.node2.On("Address").Return("node2")
+	s.node3.On("Address").Return("node3")
+	s.mockOptimisticTransaction(s.node1, map[string][]byte{KEY: []byte("2")}, nil)
+	ops := []keyvaluestore.Op{{Type: keyvaluestore.OpSet, Key: KEY, Data: []byte("1")}}
+	s.node2.On("Transaction", ops).Once().Return(nil)
+	s.node3.On("Transaction", ops).Once().Return(nil)
 	s.applyCore()
 	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
-		Winners: []keyvaluestore.Backend{s.node1, s.node2, s.node3},
-		Value:   true,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	s.applyReadToEngineOnce(nil, errors.New("some error"), nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	s.applyWriteToEngineOnce(2)
+
+	err := s.core.Optimistic(context.Background(), &keyvaluestore.OptimisticRequest{
+		Keys: []string{KEY},
+		Apply: func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			s.Equal(map[string][]byte{KEY: []byte("2")}, values)
+			return ops, nil
 		},
+		Options: keyvaluestore.WriteOptions{Consistency: keyvaluestore.ConsistencyLevel_ALL},
 	})
 	s.Nil(err)
-	s.node1.AssertExpectations(s.T())
+	s.node1.AssertNumberOfCalls(s.T(), "OptimisticTransaction", 1)
+	s.node1.AssertNotCalled(s.T(), "Transaction", mock.Anything)
 	s.node2.AssertExpectations(s.T())
 	s.node3.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestExistsShouldAcquireTTLWithDataAndApplyToLosers() {
-	s.node1.On("Exists", KEY).Once().Return(true, nil)
-	s.node2.On("Exists", KEY).Once().Return(true, nil)
-	s.node3.On("Exists", KEY).Once().Return(false, nil)
+func (s *CoreServiceTestSuite) TestOptimisticShouldRetryConflictingTransactions() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("OptimisticTransaction", []string{KEY}, mock.Anything).Once().
+		Return(nil, keyvaluestore.ErrOptimisticConflict)
+	s.mockOptimisticTransaction(s.node1, map[string][]byte{KEY: []byte("2")}, nil)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
 
-	s.node1.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
-	s.node2.On("TTL", KEY).Once().Return(&ONE_MINUTE, nil)
+	err := s.core.Optimistic(context.Background(), &keyvaluestore.OptimisticRequest{
+		Keys: []string{KEY},
+		Apply: func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			return nil, nil
+		},
+		MaxRetries: 1,
+		Options:    keyvaluestore.WriteOptions{Consistency: keyvaluestore.ConsistencyLevel_ALL},
+	})
+	s.Nil(err)
+	s.node1.AssertNumberOfCalls(s.T(), "OptimisticTransaction", 2)
+}
 
-	s.node1.On("Get", KEY).Once().Return(s.dataStr, nil)
-	s.node2.On("Get", KEY).Once().Return(s.dataStr, nil)
+func (s *CoreServiceTestSuite) TestOptimisticShouldAbortAfterMaxRetries() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("OptimisticTransaction", []string{KEY}, mock.Anything).
+		Return(nil, keyvaluestore.ErrOptimisticConflict)
+	s.applyCore()
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
 
-	s.node3.On("Set", KEY, s.dataStr, 1*time.Minute).Once().Return(nil)
+	err := s.core.Optimistic(context.Background(), &keyvaluestore.OptimisticRequest{
+		Keys: []string{KEY},
+		Apply: func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			return nil, nil
+		},
+		MaxRetries: 2,
+		Options:    keyvaluestore.WriteOptions{Consistency: keyvaluestore.ConsistencyLevel_ALL},
+	})
+	s.assertStatusCode(err, codes.Aborted)
+	s.node1.AssertNumberOfCalls(s.T(), "OptimisticTransaction", 3)
+}
 
+func (s *CoreServiceTestSuite) TestOptimisticShouldConvertErrorsOfApply() {
+	applyErr := errors.New("out of stock")
+	s.node1.On("Address").Return("node1")
+	s.node2.On("Address").Return("node2")
+	s.mockOptimisticTransaction(s.node1, nil, applyErr)
 	s.applyCore()
-	s.applyCluster(3, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyReadToEngineOnce(true, nil, &keyvaluestore.RepairArgs{
-		Losers:  []keyvaluestore.Backend{s.node3},
-		Winners: []keyvaluestore.Backend{s.node1, s.node2},
-		Value:   true,
-	}, 3, keyvaluestore.VotingModeVoteOnNotFound)
-	s.applyReadToEngineOnce(&ONE_MINUTE, nil, nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	s.applyReadToEngineOnce(s.dataStr, nil, nil, 2,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
-	s.applyWriteToEngineOnce(0)
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
 
-	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+	err := s.core.Optimistic(context.Background(), &keyvaluestore.OptimisticRequest{
+		Keys: []string{KEY},
+		Apply: func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			return nil, applyErr
 		},
+		Options: keyvaluestore.WriteOptions{Consistency: keyvaluestore.ConsistencyLevel_ALL},
 	})
-	s.Nil(err)
-	s.node1.AssertExpectations(s.T())
-	s.node2.AssertExpectations(s.T())
-	s.node3.AssertExpectations(s.T())
+	s.assertStatusCode(err, codes.Internal)
+	s.Contains(err.Error(), "out of stock")
+	s.engine.AssertNotCalled(s.T(), "Write", mock.Anything, mock.Anything, mock.Anything,
+		mock.Anything, mock.Anything)
 }
 
-func (s *CoreServiceTestSuite) TestExistsShouldUseVotingModeFromClusterView() {
-	s.node1.On("Exists", KEY).Once().Return(true, nil)
-
+func (s *CoreServiceTestSuite) TestOptimisticShouldKeepStatusErrorsOfApply() {
+	applyErr := status.Error(codes.FailedPrecondition, "out of stock")
+	s.node1.On("Address").Return("node1")
+	s.node2.On("Address").Return("node2")
+	s.mockOptimisticTransaction(s.node1, nil, applyErr)
 	s.applyCore()
-	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL,
-		s.withVotingMode(keyvaluestore.VotingModeSkipVoteOnNotFound))
-	s.applyReadToEngineOnce(true, nil, nil, 1,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
 
-	_, err := s.core.Exists(context.Background(), &keyvaluestore.ExistsRequest{
-		Key: KEY,
-		Options: keyvaluestore.ReadOptions{
+	err := s.core.Optimistic(context.Background(), &keyvaluestore.OptimisticRequest{
+		Keys: []string{KEY},
+		Apply: func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			return nil, applyErr
+		},
+		Options: keyvaluestore.WriteOptions{Consistency: keyvaluestore.ConsistencyLevel_ALL},
+	})
+	s.Equal(applyErr, err)
+}
+
+func (s *CoreServiceTestSuite) TestOptimisticShouldOnlyPingNodesInDryRun() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("Ping").Once().Return(nil)
+	s.node2.On("Address").Return("node2")
+	s.node2.On("Ping").Once().Return(nil)
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(2)
+
+	result := &keyvaluestore.DryRunResult{}
+	err := s.core.Optimistic(context.Background(), &keyvaluestore.OptimisticRequest{
+		Keys: []string{KEY},
+		Apply: func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			return nil, nil
+		},
+		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+			DryRun:      result,
+		},
+	})
+	s.Nil(err)
+	s.ElementsMatch([]string{"node1", "node2"}, result.Reachable)
+	s.node1.AssertNotCalled(s.T(), "OptimisticTransaction", mock.Anything, mock.Anything)
+}
+
+func (s *CoreServiceTestSuite) TestOptimisticShouldRequireKeys() {
+	s.applyCore()
+	err := s.core.Optimistic(context.Background(), &keyvaluestore.OptimisticRequest{
+		Apply: func(values map[string][]byte) ([]keyvaluestore.Op, error) {
+			return nil, nil
 		},
 	})
-	s.Nil(err)
-	s.node1.AssertExpectations(s.T())
+	s.assertStatusCode(err, codes.InvalidArgument)
 }
 
-func (s *CoreServiceTestSuite) TestFlushDbShouldCallDeleteOnNodes() {
-	s.node1.On("FlushDB").Once().Return(nil)
+func (s *CoreServiceTestSuite) TestTransactionShouldRejectEmptyOps() {
+	s.applyCore()
+	err := s.core.Transaction(context.Background(), &keyvaluestore.TransactionRequest{})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *CoreServiceTestSuite) TestTransactionRollbackShouldDeleteSetKeys() {
+	s.node1.On("Transaction", mock.Anything).Once().Return(nil)
+	s.node1.On("DeleteMany", []string{KEY}).Once().Return(int64(1), nil)
 	s.applyCore()
 	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyWriteToEngineOnce(1)
-	err := s.core.FlushDB(context.Background())
-	s.Nil(err)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Once().Run(func(args mock.Arguments) {
+
+		backends := args.Get(0).([]keyvaluestore.Backend)
+		s.Nil(args.Get(2).(keyvaluestore.WriteOperator)(backends[0]))
+		args.Get(3).(keyvaluestore.RollbackOperator)(keyvaluestore.RollbackArgs{Nodes: backends})
+	}).Return(keyvaluestore.ErrConsistency)
+	s.engine.On("Write", mock.Anything, 0, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeConcurrent).Once().Run(func(args mock.Arguments) {
+
+		backends := args.Get(0).([]keyvaluestore.Backend)
+		s.Nil(args.Get(2).(keyvaluestore.WriteOperator)(backends[0]))
+	}).Return(nil)
+	err := s.core.Transaction(context.Background(), &keyvaluestore.TransactionRequest{
+		Ops: []keyvaluestore.Op{
+			{Type: keyvaluestore.OpSet, Key: KEY, Data: s.dataStr},
+		},
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ALL,
+		},
+	})
+	s.Equal(codes.Unavailable, status.Code(err))
 	s.node1.AssertExpectations(s.T())
 }
 
-func (s *CoreServiceTestSuite) TestDeleteShouldCallDeleteOnNodes() {
-	s.node1.On("Delete", KEY).Once().Return(nil)
-	s.applyCore()
+func (s *CoreServiceTestSuite) TestDeletePatternShouldScanAndDeleteMatchingKeys() {
+	s.node1.On("Scan", uint64(0), "user:*", mock.Anything).Once().Return([]string{"a", "b"}, uint64(7), nil)
+	s.node1.On("Scan", uint64(7), "user:*", mock.Anything).Once().Return([]string{"c"}, uint64(0), nil)
+	s.node1.On("DeleteMany", []string{"a", "b"}).Once().Return(int64(2), nil)
+	s.node1.On("DeleteMany", []string{"c"}).Once().Return(int64(1), nil)
+	s.applyCore(core.WithScanBatchInterval(0))
 	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
 	s.applyWriteToEngineOnce(1)
-	err := s.core.Delete(context.Background(), &keyvaluestore.DeleteRequest{
-		Key: KEY,
+	response, err := s.core.DeletePattern(context.Background(), &keyvaluestore.DeletePatternRequest{
+		Pattern: "user:*",
 		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
+	s.Equal(int64(3), response.Deleted)
+	s.Empty(response.Errors)
 	s.node1.AssertExpectations(s.T())
+	s.cluster.AssertNotCalled(s.T(), "Write", mock.Anything, mock.Anything)
 }
 
-func (s *CoreServiceTestSuite) TestDeleteShouldNotUseDefaultWriteConsistencyIfProvidedByRequest() {
-	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_ALL)
-	s.applyWriteToEngineOnce(0)
-	err := s.core.Delete(context.Background(), &keyvaluestore.DeleteRequest{
-		Key: KEY,
+func (s *CoreServiceTestSuite) TestDeletePatternShouldReportNodeErrors() {
+	s.node1.On("Scan", uint64(0), "user:*", mock.Anything).Once().Return([]string{"a"}, uint64(0), nil)
+	s.node1.On("DeleteMany", []string{"a"}).Once().Return(int64(1), nil)
+	s.node2.On("Scan", uint64(0), "user:*", mock.Anything).Once().Return(nil, uint64(0), errors.New("some error"))
+	s.node2.On("Address").Return("node2")
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(2)
+	response, err := s.core.DeletePattern(context.Background(), &keyvaluestore.DeletePatternRequest{
+		Pattern: "user:*",
 		Options: keyvaluestore.WriteOptions{
 			Consistency: keyvaluestore.ConsistencyLevel_ALL,
 		},
 	})
 	s.Nil(err)
+	s.Equal(int64(1), response.Deleted)
+	s.Equal(1, len(response.Errors))
+	s.NotNil(response.Errors["node2"])
 }
 
-func (s *CoreServiceTestSuite) TestDeleteShouldUseDefaultWriteConsistencyIfNotProvidedByRequest() {
-	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
-	s.applyCluster(0, keyvaluestore.ConsistencyLevel_MAJORITY)
-	s.applyWriteToEngineOnce(0)
-	err := s.core.Delete(context.Background(), &keyvaluestore.DeleteRequest{
-		Key: KEY,
-	})
+func (s *CoreServiceTestSuite) TestStatsShouldReportEveryNode() {
+	s.node1.On("Address").Return("node1")
+	s.node1.On("DBSize").Once().Return(int64(10), nil)
+	s.node1.On("UsedMemory").Once().Return(int64(1024), nil)
+	s.node2.On("Address").Return("node2")
+	s.node2.On("DBSize").Once().Return(int64(0), errors.New("some error"))
+	s.applyCore()
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_ALL)
+	result, err := s.core.Stats(context.Background())
 	s.Nil(err)
+	s.Equal([]keyvaluestore.NodeStats{
+		{Address: "node1", Keys: 10, UsedMemoryBytes: 1024},
+		{Address: "node2", Error: "some error"},
+	}, result.Nodes)
 }
 
 func (s *CoreServiceTestSuite) TestLockShouldCallLockOnNode() {
@@ -883,6 +4130,64 @@ func (s *CoreServiceTestSuite) TestLockShouldCallLockOnNode() {
 	s.Nil(err)
 }
 
+func (s *CoreServiceTestSuite) TestLockShouldUseDefaultLockConsistencyIfRequestIsEmpty() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithDefaultLockConsistency(keyvaluestore.ConsistencyLevel_ALL))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1, WithMode(keyvaluestore.OperationModeSequential))
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Once().Return(nil)
+	err := s.core.Lock(context.Background(), &keyvaluestore.LockRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Write", KEY, keyvaluestore.ConsistencyLevel_ALL)
+}
+
+func (s *CoreServiceTestSuite) TestLockShouldPreferOperationConsistencyOverDefaultLockConsistency() {
+	s.applyCore(core.WithDefaultLockConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithOperationConsistency(core.OperationLock, keyvaluestore.ConsistencyLevel_ALL))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1, WithMode(keyvaluestore.OperationModeSequential))
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Once().Return(nil)
+	err := s.core.Lock(context.Background(), &keyvaluestore.LockRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Write", KEY, keyvaluestore.ConsistencyLevel_ALL)
+}
+
+func (s *CoreServiceTestSuite) TestLockShouldNotUseDefaultLockConsistencyIfRequestHasProvided() {
+	s.applyCore(core.WithDefaultLockConsistency(keyvaluestore.ConsistencyLevel_ALL))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ONE)
+	s.applyWriteToEngineOnce(1, WithMode(keyvaluestore.OperationModeSequential))
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Once().Return(nil)
+	err := s.core.Lock(context.Background(), &keyvaluestore.LockRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+		Options: keyvaluestore.WriteOptions{
+			Consistency: keyvaluestore.ConsistencyLevel_ONE,
+		},
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Write", KEY, keyvaluestore.ConsistencyLevel_ONE)
+}
+
+func (s *CoreServiceTestSuite) TestSetShouldNotUseDefaultLockConsistency() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithDefaultLockConsistency(keyvaluestore.ConsistencyLevel_ALL))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1)
+	s.node1.On("Set", KEY, mock.Anything, mock.Anything).Once().Return(nil)
+	err := s.core.Set(context.Background(), &keyvaluestore.SetRequest{
+		Data: s.dataStr,
+		Key:  KEY,
+	})
+	s.Nil(err)
+	s.cluster.AssertNotCalled(s.T(), "Write", KEY, keyvaluestore.ConsistencyLevel_ALL)
+}
+
 func (s *CoreServiceTestSuite) TestLockShouldPreserveOrder() {
 	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
 	s.applyCluster(3, keyvaluestore.ConsistencyLevel_MAJORITY)
@@ -898,6 +4203,51 @@ func (s *CoreServiceTestSuite) TestLockShouldPreserveOrder() {
 	s.Nil(err)
 }
 
+func (s *CoreServiceTestSuite) TestLockShouldLogRollbackErrorsWithCorrelationID() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1,
+		WithMode(keyvaluestore.OperationModeSequential),
+		WithRollbackArgs(keyvaluestore.RollbackArgs{
+			Nodes: []keyvaluestore.Backend{s.node1},
+		}))
+	s.applyWriteToEngineOnce(0, WithWriteError(errors.New("some error")))
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Once().Return(nil)
+	s.node1.On("Unlock", KEY).Once().Return(nil)
+
+	hook := test.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+
+	ctx := keyvaluestore.NewCorrelationContext(context.Background(), "request-42")
+	_ = s.core.Lock(ctx, &keyvaluestore.LockRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+	})
+	s.Equal("unexpected error during LOCK rollback", hook.LastEntry().Message)
+	s.Equal("request-42", hook.LastEntry().Data["correlation_id"])
+}
+
+func (s *CoreServiceTestSuite) TestLockShouldOrderNodesByIDIfGiven() {
+	s.node1.On("Address").Return("10.0.0.1:6379")
+	s.node2.On("Address").Return("10.0.0.2:6379")
+	s.node3.On("Address").Return("10.0.0.3:6379")
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithNodeID("10.0.0.1:6379", "c"),
+		core.WithNodeID("10.0.0.2:6379", "a"),
+		core.WithNodeID("10.0.0.3:6379", "b"))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(3, WithOrdering(s.node2, s.node3, s.node1),
+		WithMode(keyvaluestore.OperationModeSequential))
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Once().Return(nil)
+	s.node2.On("Lock", KEY, s.dataStr, mock.Anything).Once().Return(nil)
+	s.node3.On("Lock", KEY, s.dataStr, mock.Anything).Once().Return(nil)
+	err := s.core.Lock(context.Background(), &keyvaluestore.LockRequest{
+		Key:  KEY,
+		Data: s.dataStr,
+	})
+	s.Nil(err)
+}
+
 func (s *CoreServiceTestSuite) TestLockShouldRollbackUsingUnlock() {
 	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
 	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
@@ -917,6 +4267,223 @@ func (s *CoreServiceTestSuite) TestLockShouldRollbackUsingUnlock() {
 	s.node1.AssertExpectations(s.T())
 }
 
+func (s *CoreServiceTestSuite) TestAcquireShouldCallAcquireOnNodesInOrder() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1, WithMode(keyvaluestore.OperationModeSequential))
+	s.node1.On("Acquire", KEY, "holder", 2, 1*time.Minute).Once().Return(false, nil)
+	err := s.core.Acquire(context.Background(), &keyvaluestore.AcquireRequest{
+		Key:        KEY,
+		Holder:     "holder",
+		MaxHolders: 2,
+		Expiration: 1 * time.Minute,
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestAcquireShouldReturnNotAcquiredIfSemaphoreIsFull() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1,
+		WithMode(keyvaluestore.OperationModeSequential),
+		WithWriteError(keyvaluestore.ErrConsistency))
+	s.node1.On("Acquire", KEY, "holder", 2, 1*time.Minute).Once().Return(false, keyvaluestore.ErrNotAcquired)
+	err := s.core.Acquire(context.Background(), &keyvaluestore.AcquireRequest{
+		Key:        KEY,
+		Holder:     "holder",
+		MaxHolders: 2,
+		Expiration: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.ResourceExhausted)
+}
+
+func (s *CoreServiceTestSuite) TestAcquireShouldReturnUnavailableIfOtherNodesFailed() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(2,
+		WithMode(keyvaluestore.OperationModeSequential),
+		WithWriteError(keyvaluestore.ErrConsistency))
+	s.node1.On("Address").Return("node1")
+	s.node2.On("Address").Return("node2")
+	s.node1.On("Acquire", KEY, "holder", 2, 1*time.Minute).Once().Return(false, keyvaluestore.ErrNotAcquired)
+	s.node2.On("Acquire", KEY, "holder", 2, 1*time.Minute).Once().Return(false, errors.New("some error"))
+	err := s.core.Acquire(context.Background(), &keyvaluestore.AcquireRequest{
+		Key:        KEY,
+		Holder:     "holder",
+		MaxHolders: 2,
+		Expiration: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+}
+
+func (s *CoreServiceTestSuite) TestAcquireShouldNotReleaseRenewedSlotsOnRollback() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(3, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(3,
+		WithMode(keyvaluestore.OperationModeSequential),
+		WithRollbackArgs(keyvaluestore.RollbackArgs{Nodes: []keyvaluestore.Backend{s.node1, s.node2}}),
+		WithWriteError(keyvaluestore.ErrConsistency))
+	s.applyWriteToEngineOnce(0)
+	s.node1.On("Address").Return("node1")
+	s.node2.On("Address").Return("node2")
+	s.node3.On("Address").Return("node3")
+	s.node1.On("Acquire", KEY, "holder", 2, 1*time.Minute).Once().Return(true, nil)
+	s.node2.On("Acquire", KEY, "holder", 2, 1*time.Minute).Once().Return(false, nil)
+	s.node3.On("Acquire", KEY, "holder", 2, 1*time.Minute).Once().Return(false, errors.New("some error"))
+	s.node2.On("Release", KEY, "holder").Once().Return(nil)
+	err := s.core.Acquire(context.Background(), &keyvaluestore.AcquireRequest{
+		Key:        KEY,
+		Holder:     "holder",
+		MaxHolders: 2,
+		Expiration: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+	s.node2.AssertExpectations(s.T())
+	s.node1.AssertNotCalled(s.T(), "Release", KEY, "holder")
+}
+
+func (s *CoreServiceTestSuite) TestAcquireShouldRejectInvalidHolders() {
+	s.applyCore()
+	err := s.core.Acquire(context.Background(), &keyvaluestore.AcquireRequest{
+		Key:        KEY,
+		Holder:     "holder",
+		Expiration: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.InvalidArgument)
+}
+
+func (s *CoreServiceTestSuite) TestReleaseShouldCallReleaseOnBackends() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1)
+	s.node1.On("Release", KEY, "holder").Once().Return(nil)
+	err := s.core.Release(context.Background(), &keyvaluestore.ReleaseRequest{
+		Key:    KEY,
+		Holder: "holder",
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestLockShouldWaitForHeldLockIfWaitTimeoutIsProvided() {
+	s.applyCore(
+		core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithLockPollInterval(time.Millisecond))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.engine.On("Write", mock.Anything, 1, mock.Anything, mock.Anything,
+		keyvaluestore.OperationModeSequential).Once().Run(func(args mock.Arguments) {
+		_ = args.Get(2).(keyvaluestore.WriteOperator)(s.node1)
+	}).Return(keyvaluestore.ErrConsistency)
+	s.applyWriteToEngineOnce(1, WithMode(keyvaluestore.OperationModeSequential))
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Once().Return(keyvaluestore.ErrNotAcquired)
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Once().Return(nil)
+	err := s.core.Lock(context.Background(), &keyvaluestore.LockRequest{
+		Key:         KEY,
+		Data:        s.dataStr,
+		WaitTimeout: 1 * time.Second,
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestLockShouldFailAfterWaitTimeout() {
+	s.applyCore(
+		core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithLockPollInterval(time.Millisecond))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1,
+		WithMode(keyvaluestore.OperationModeSequential),
+		WithWriteError(keyvaluestore.ErrConsistency))
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Return(keyvaluestore.ErrNotAcquired)
+	err := s.core.Lock(context.Background(), &keyvaluestore.LockRequest{
+		Key:         KEY,
+		Data:        s.dataStr,
+		WaitTimeout: 20 * time.Millisecond,
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+}
+
+func (s *CoreServiceTestSuite) TestLockShouldNotWaitIfNodesFailed() {
+	s.applyCore(
+		core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithLockPollInterval(time.Millisecond))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1,
+		WithMode(keyvaluestore.OperationModeSequential),
+		WithWriteError(keyvaluestore.ErrConsistency))
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Return(errors.New("some error"))
+	err := s.core.Lock(context.Background(), &keyvaluestore.LockRequest{
+		Key:         KEY,
+		Data:        s.dataStr,
+		WaitTimeout: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+	s.node1.AssertNumberOfCalls(s.T(), "Lock", 1)
+}
+
+func (s *CoreServiceTestSuite) TestLockShouldStopWaitingUponContextCancellation() {
+	s.applyCore(
+		core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY),
+		core.WithLockPollInterval(time.Millisecond))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1,
+		WithMode(keyvaluestore.OperationModeSequential),
+		WithWriteError(keyvaluestore.ErrConsistency))
+	s.node1.On("Lock", KEY, s.dataStr, mock.Anything).Return(keyvaluestore.ErrNotAcquired)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	err := s.core.Lock(ctx, &keyvaluestore.LockRequest{
+		Key:         KEY,
+		Data:        s.dataStr,
+		WaitTimeout: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.DeadlineExceeded)
+}
+
+func (s *CoreServiceTestSuite) TestRenewLockShouldCallRenewLockOnNodes() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1)
+	s.node1.On("RenewLock", KEY, s.dataStr, 1*time.Minute).Once().Return(nil)
+	err := s.core.RenewLock(context.Background(), &keyvaluestore.RenewLockRequest{
+		Key:        KEY,
+		Data:       s.dataStr,
+		Expiration: 1 * time.Minute,
+	})
+	s.Nil(err)
+	s.node1.AssertExpectations(s.T())
+}
+
+func (s *CoreServiceTestSuite) TestRenewLockShouldFailIfLockIsLost() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(1, WithWriteError(keyvaluestore.ErrConsistency))
+	s.node1.On("RenewLock", KEY, s.dataStr, 1*time.Minute).Once().Return(keyvaluestore.ErrLockLost)
+	err := s.core.RenewLock(context.Background(), &keyvaluestore.RenewLockRequest{
+		Key:        KEY,
+		Data:       s.dataStr,
+		Expiration: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.FailedPrecondition)
+}
+
+func (s *CoreServiceTestSuite) TestRenewLockShouldReturnUnavailableIfOtherNodesFailed() {
+	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
+	s.applyCluster(2, keyvaluestore.ConsistencyLevel_MAJORITY)
+	s.applyWriteToEngineOnce(2, WithWriteError(keyvaluestore.ErrConsistency))
+	s.node1.On("RenewLock", KEY, s.dataStr, 1*time.Minute).Once().Return(keyvaluestore.ErrLockLost)
+	s.node2.On("RenewLock", KEY, s.dataStr, 1*time.Minute).Once().Return(errors.New("some error"))
+	err := s.core.RenewLock(context.Background(), &keyvaluestore.RenewLockRequest{
+		Key:        KEY,
+		Data:       s.dataStr,
+		Expiration: 1 * time.Minute,
+	})
+	s.assertStatusCode(err, codes.Unavailable)
+}
+
 func (s *CoreServiceTestSuite) TestUnlockShouldCallUnlockOnBackends() {
 	s.applyCore(core.WithDefaultWriteConsistency(keyvaluestore.ConsistencyLevel_MAJORITY))
 	s.applyCluster(1, keyvaluestore.ConsistencyLevel_MAJORITY)
@@ -929,6 +4496,18 @@ func (s *CoreServiceTestSuite) TestUnlockShouldCallUnlockOnBackends() {
 	s.node1.AssertExpectations(s.T())
 }
 
+func (s *CoreServiceTestSuite) TestUnlockShouldUseDefaultLockConsistencyIfRequestIsEmpty() {
+	s.applyCore(core.WithDefaultLockConsistency(keyvaluestore.ConsistencyLevel_ALL))
+	s.applyCluster(1, keyvaluestore.ConsistencyLevel_ALL)
+	s.applyWriteToEngineOnce(1)
+	s.node1.On("Unlock", KEY).Once().Return(nil)
+	err := s.core.Unlock(context.Background(), &keyvaluestore.UnlockRequest{
+		Key: KEY,
+	})
+	s.Nil(err)
+	s.cluster.AssertCalled(s.T(), "Write", KEY, keyvaluestore.ConsistencyLevel_ALL)
+}
+
 func (s *CoreServiceTestSuite) assertStatusCode(err error, c codes.Code) {
 	grpcStatus, ok := status.FromError(err)
 
@@ -987,14 +4566,15 @@ func (s *CoreServiceTestSuite) applyWriteToEngineOnce(nodeCount int, options ...
 		if optionCtx.rollbackArgs != nil {
 			rollbackOperator(*optionCtx.rollbackArgs)
 		}
-	}).Return(nil)
+	}).Return(optionCtx.err)
 }
 
 func (s *CoreServiceTestSuite) applyReadToEngineOnce(result interface{}, err error,
 	repairArgs *keyvaluestore.RepairArgs, nodeCount int,
 	mode keyvaluestore.VotingMode) {
 
-	s.engine.On("Read", mock.Anything, nodeCount, mock.Anything, mock.Anything, mock.Anything, mode).Once().
+	s.engine.On("Read", mock.Anything, nodeCount, mock.Anything, mock.Anything, mock.Anything, mode,
+		mock.Anything).Once().
 		Run(func(args mock.Arguments) {
 			backends := args.Get(0).([]keyvaluestore.Backend)
 			readOperator := args.Get(2).(keyvaluestore.ReadOperator)
@@ -1045,12 +4625,51 @@ func (s *CoreServiceTestSuite) applyCluster(
 	s.cluster.On("FlushDB").Return(optionContext.writeView, nil)
 }
 
+func (s *CoreServiceTestSuite) applyRateLimitCluster() {
+	s.nodes = []keyvaluestore.Backend{s.node1, s.node2}
+	s.cluster.On("Write", "__kvs_ratelimit:"+KEY, keyvaluestore.ConsistencyLevel_ALL).Return(
+		keyvaluestore.WriteClusterView{
+			Backends:            s.nodes,
+			AcknowledgeRequired: len(s.nodes),
+		}, nil)
+}
+
 func (s *CoreServiceTestSuite) withVotingMode(mode keyvaluestore.VotingMode) clusterOption {
 	return func(o *clusterOptionContext) {
 		o.readView.VotingMode = mode
 	}
 }
 
+func (s *CoreServiceTestSuite) withSkipRepair() clusterOption {
+	return func(o *clusterOptionContext) {
+		o.readView.SkipRepair = true
+	}
+}
+
+func (s *CoreServiceTestSuite) withReadOnly(nodes ...keyvaluestore.Backend) clusterOption {
+	return func(o *clusterOptionContext) {
+		o.readView.ReadOnly = nodes
+	}
+}
+
+// mockOptimisticTransaction makes node run apply on values, as redis would
+// without conflicts, expecting it to fail with applyErr.
+func (s *CoreServiceTestSuite) mockOptimisticTransaction(node *keyvaluestore.Mock_Backend,
+	values map[string][]byte, applyErr error) {
+
+	var err error
+	node.On("OptimisticTransaction", []string{KEY}, mock.Anything).Once().Return(
+		func(keys []string, apply keyvaluestore.OptimisticFunc) []keyvaluestore.Op {
+			var ops []keyvaluestore.Op
+			ops, err = apply(values)
+			return ops
+		},
+		func(keys []string, apply keyvaluestore.OptimisticFunc) error {
+			s.Equal(applyErr, err)
+			return err
+		})
+}
+
 func (s *CoreServiceTestSuite) applyCore(options ...core.Option) {
 	s.core = core.New(s.cluster, s.engine, options...)
 }
@@ -1059,6 +4678,7 @@ type optionContext struct {
 	mode         keyvaluestore.OperationMode
 	ordering     []*keyvaluestore.Mock_Backend
 	rollbackArgs *keyvaluestore.RollbackArgs
+	err          error
 }
 
 type Option func(o *optionContext)
@@ -1091,6 +4711,12 @@ func WithRollbackArgs(args keyvaluestore.RollbackArgs) Option {
 	}
 }
 
+func WithWriteError(err error) Option {
+	return func(o *optionContext) {
+		o.err = err
+	}
+}
+
 func (s *CoreServiceTestSuite) SetupTest() {
 	s.node1 = &keyvaluestore.Mock_Backend{}
 	s.node2 = &keyvaluestore.Mock_Backend{}
@@ -1110,3 +4736,35 @@ func (s *CoreServiceTestSuite) SetupTest() {
 		return VALUE == string(raw)
 	}
 }
+
+// countCalls counts calls of method across all nodes.
+func (s *CoreServiceTestSuite) countCalls(method string) int {
+	count := 0
+	for _, node := range []*keyvaluestore.Mock_Backend{s.node1, s.node2, s.node3} {
+		for _, call := range node.Calls {
+			if call.Method == method {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+func (s *CoreServiceTestSuite) makeKeyring() *encryption.Keyring {
+	keyring, err := encryption.NewKeyring(map[byte][]byte{1: bytes.Repeat([]byte{1}, 32)}, 1)
+	s.Require().Nil(err)
+	return keyring
+}
+
+// idempotencyRecordKey is the key of the idempotency record of a write of
+// operation on keys.
+func idempotencyRecordKey(operation string, idempotencyKey string, keys ...string) string {
+	digest := sha256.New()
+	for _, key := range keys {
+		digest.Write([]byte(strconv.Itoa(len(key)) + ":" + key))
+	}
+
+	return "__kvs_idempotency:" + operation + ":" + hex.EncodeToString(digest.Sum(nil)[:16]) + ":" +
+		idempotencyKey
+}
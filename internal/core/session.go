@@ -0,0 +1,133 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+type sessionKey struct {
+	session string
+	key     string
+}
+
+type sessionWrite struct {
+	consistency keyvaluestore.ConsistencyLevel
+	expiresAt   time.Time
+}
+
+// sessionTracker remembers keys recently written by each session along with
+// the consistency they have been written with, so that later reads of the
+// same session are guaranteed to reach a node which got the write.
+type sessionTracker struct {
+	ttl       time.Duration
+	lock      sync.Mutex
+	writes    map[sessionKey]sessionWrite
+	lastSweep time.Time
+	// majoritiesOverlap tells whether any two majorities share a node, which
+	// does not hold if half of an even number of nodes is a majority.
+	majoritiesOverlap bool
+}
+
+func newSessionTracker(ttl time.Duration) *sessionTracker {
+	return &sessionTracker{
+		ttl:               ttl,
+		writes:            make(map[sessionKey]sessionWrite),
+		majoritiesOverlap: true,
+	}
+}
+
+func (t *sessionTracker) recordWrite(session string, key string,
+	consistency keyvaluestore.ConsistencyLevel, now time.Time) {
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if now.Sub(t.lastSweep) > t.ttl {
+		for written, write := range t.writes {
+			if now.After(write.expiresAt) {
+				delete(t.writes, written)
+			}
+		}
+
+		t.lastSweep = now
+	}
+
+	t.writes[sessionKey{session: session, key: key}] = sessionWrite{
+		consistency: consistency,
+		expiresAt:   now.Add(t.ttl),
+	}
+}
+
+func (t *sessionTracker) readConsistency(session string, key string,
+	consistency keyvaluestore.ConsistencyLevel, nodes int, now time.Time) keyvaluestore.ConsistencyLevel {
+
+	t.lock.Lock()
+	write, ok := t.writes[sessionKey{session: session, key: key}]
+	t.lock.Unlock()
+
+	if !ok || now.After(write.expiresAt) {
+		return consistency
+	}
+
+	return readYourWriteConsistency(write.consistency, consistency, t.majoritiesOverlap, nodes)
+}
+
+// sessionRequiredNodes raises requiredNodes of a read of key by session to
+// the number of nodes the session needs to observe its own write of key, if
+// any, out of nodes.
+func (s *coreService) sessionRequiredNodes(session string, key string, requiredNodes int, nodes int) (int, error) {
+	consistency := s.sessions.readConsistency(session, key, keyvaluestore.ConsistencyLevel_ONE, nodes, time.Now())
+	if consistency == keyvaluestore.ConsistencyLevel_ONE {
+		return requiredNodes, nil
+	}
+
+	view, err := s.cluster.Read(key, consistency)
+	if err != nil {
+		return 0, err
+	}
+
+	if view.VoteRequired > requiredNodes {
+		return view.VoteRequired, nil
+	}
+
+	return requiredNodes, nil
+}
+
+// readYourWriteConsistency returns the weakest consistency, no weaker than
+// read, whose nodes are guaranteed to overlap with the nodes which
+// acknowledged a write of the given consistency, out of nodes.
+func readYourWriteConsistency(write keyvaluestore.ConsistencyLevel,
+	read keyvaluestore.ConsistencyLevel, majoritiesOverlap bool, nodes int) keyvaluestore.ConsistencyLevel {
+
+	if read == keyvaluestore.ConsistencyLevel_ALL || write == keyvaluestore.ConsistencyLevel_ALL {
+		return read
+	}
+
+	if write == keyvaluestore.ConsistencyLevel_MAJORITY && majoritiesOverlap {
+		// Fixed levels reaching a majority anyway are stronger than MAJORITY
+		if replicas := fixedReplicas(read); replicas > nodes/2 {
+			return read
+		}
+
+		return keyvaluestore.ConsistencyLevel_MAJORITY
+	}
+
+	return keyvaluestore.ConsistencyLevel_ALL
+}
+
+// fixedReplicas is the number of nodes of the fixed consistency levels, or
+// zero for the other ones.
+func fixedReplicas(consistency keyvaluestore.ConsistencyLevel) int {
+	switch consistency {
+	case keyvaluestore.ConsistencyLevel_TWO:
+		return 2
+
+	case keyvaluestore.ConsistencyLevel_THREE:
+		return 3
+
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+func (s *coreService) StreamAdd(ctx context.Context,
+	request *keyvaluestore.StreamAddRequest) (*keyvaluestore.StreamAddResponse, error) {
+
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	if err := s.validateKeyValue(request.Key, request.Data); err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	// The ID is recorded along with the idempotency key, so that retries
+	// return the ID of the original entry instead of appending another one.
+	result, err := s.performIdempotentWrite(ctx, idempotentStreamAdd, []string{request.Key}, request.Options,
+		func(ctx context.Context) ([]byte, error) {
+			id, err := s.streamAdd(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+
+			return []byte(id.String()), nil
+		})
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	id, err := keyvaluestore.ParseStreamID(string(result))
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	return &keyvaluestore.StreamAddResponse{ID: id}, nil
+}
+
+func (s *coreService) streamAdd(ctx context.Context,
+	request *keyvaluestore.StreamAddRequest) (keyvaluestore.StreamID, error) {
+
+	data, err := s.encodeValue(request.Key, request.Data, "", 0)
+	if err != nil {
+		return keyvaluestore.StreamID{}, err
+	}
+
+	// The ID is generated once for all nodes, so that replicas agree on it
+	entry := keyvaluestore.StreamEntry{ID: s.nextStreamID(), Data: data}
+
+	writeOperator := func(node keyvaluestore.Backend) error {
+		return node.StreamAdd(request.Key, entry)
+	}
+
+	removeOperator := func(node keyvaluestore.Backend) error {
+		return node.StreamRemove(request.Key, entry.ID)
+	}
+
+	removeRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+	}
+
+	rollbackOperator := func(args keyvaluestore.RollbackArgs) {
+		err := s.engine.Write(args.Nodes, 0, removeOperator, removeRollbackOperator,
+			keyvaluestore.OperationModeConcurrent)
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during STREAMADD rollback")
+		}
+	}
+
+	err = s.performWrite(ctx, request.Key, request.Options, writeOperator,
+		s.valueRollback(rollbackOperator), keyvaluestore.OperationModeConcurrent)
+	if err != nil {
+		return keyvaluestore.StreamID{}, err
+	}
+
+	return entry.ID, nil
+}
+
+func (s *coreService) StreamRange(ctx context.Context,
+	request *keyvaluestore.StreamRangeRequest) (*keyvaluestore.StreamRangeResponse, error) {
+
+	ctx, cancel := withTimeout(ctx, request.Options.Timeout)
+	defer cancel()
+
+	end := request.End
+	if end == (keyvaluestore.StreamID{}) {
+		end = keyvaluestore.MaxStreamID
+	}
+
+	readOperator := func(node keyvaluestore.Backend) (interface{}, error) {
+		entries, err := node.StreamRange(request.Key, request.Start, end, request.Count)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			return entries, keyvaluestore.ErrNotFound
+		}
+
+		return entries, nil
+	}
+
+	// Entries missing from losers are copied to them, but entries only the
+	// losers have are kept, since they might belong to an append which has
+	// not reached the other nodes yet.
+	repairOperator := func(args keyvaluestore.RepairArgs) {
+		if args.Err != nil {
+			return
+		}
+
+		entries := args.Value.([]keyvaluestore.StreamEntry)
+		addOperator := func(node keyvaluestore.Backend) error {
+			for _, entry := range entries {
+				if err := node.StreamAdd(request.Key, entry); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		addRollbackOperator := func(args keyvaluestore.RollbackArgs) {
+		}
+
+		s.recordRepair(ctx, request.Key, metrics.RepairValue, args.Losers)
+
+		err := s.engine.Write(args.Losers, 0, addOperator, addRollbackOperator,
+			keyvaluestore.OperationModeConcurrent)
+		if err != nil {
+			logEntry(ctx).WithError(err).Error("unexpected error during read repair")
+		}
+	}
+
+	rawResult, err := s.performRead(ctx, request.Key, request.Options, readOperator,
+		repairOperator, s.streamEntriesComparer)
+	if err == keyvaluestore.ErrNotFound {
+		return &keyvaluestore.StreamRangeResponse{}, nil
+	} else if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	stored := rawResult.([]keyvaluestore.StreamEntry)
+	entries := make([]keyvaluestore.StreamEntry, 0, len(stored))
+	for _, entry := range stored {
+		value, err := s.decodeStoredValue(request.Key, entry.Data)
+		if err != nil {
+			return nil, s.convertErrorToGRPC(err)
+		}
+
+		entries = append(entries, keyvaluestore.StreamEntry{ID: entry.ID, Data: value.data})
+	}
+
+	return &keyvaluestore.StreamRangeResponse{Entries: entries}, nil
+}
+
+// nextStreamID returns IDs which are strictly increasing within this
+// instance, and made of the current time unless the clock has gone back.
+func (s *coreService) nextStreamID() keyvaluestore.StreamID {
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+
+	id := keyvaluestore.StreamID{Time: uint64(time.Now().UnixNano() / int64(time.Millisecond))}
+	if !s.lastStreamID.Less(id) {
+		id = keyvaluestore.StreamID{Time: s.lastStreamID.Time, Sequence: s.lastStreamID.Sequence + 1}
+	}
+
+	s.lastStreamID = id
+	return id
+}
+
+// streamEntriesComparer compares entries as stored, which is enough since
+// every node is given the same encoded entry.
+func (s *coreService) streamEntriesComparer(x, y interface{}) bool {
+	left := x.([]keyvaluestore.StreamEntry)
+	right := y.([]keyvaluestore.StreamEntry)
+	if len(left) != len(right) {
+		return false
+	}
+
+	for i := range left {
+		if left[i].ID != right[i].ID || !bytes.Equal(left[i].Data, right[i].Data) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+	"github.com/sirupsen/logrus"
+)
+
+// eventDeduplicationWindow is how long notifications of a change are expected
+// to take to arrive from every node.
+const eventDeduplicationWindow = 1 * time.Second
+
+type seenEvent struct {
+	count int
+	at    time.Time
+}
+
+// eventDeduplicator drops notifications of the same change arriving from
+// different nodes. A change arriving from every node within the window is
+// delivered once, while repeated changes are delivered again as soon as
+// more notifications arrive than there are nodes.
+type eventDeduplicator struct {
+	nodes int
+	seen  map[keyvaluestore.Event]*seenEvent
+}
+
+func newEventDeduplicator(nodes int) *eventDeduplicator {
+	return &eventDeduplicator{
+		nodes: nodes,
+		seen:  make(map[keyvaluestore.Event]*seenEvent),
+	}
+}
+
+func (d *eventDeduplicator) isDuplicate(event keyvaluestore.Event, now time.Time) bool {
+	for key, seen := range d.seen {
+		if now.Sub(seen.at) > eventDeduplicationWindow {
+			delete(d.seen, key)
+		}
+	}
+
+	seen, ok := d.seen[event]
+	if ok && seen.count < d.nodes {
+		seen.count++
+		return true
+	}
+
+	d.seen[event] = &seenEvent{count: 1, at: now}
+
+	return false
+}
+
+func (s *coreService) Watch(ctx context.Context,
+	request *keyvaluestore.WatchRequest) (<-chan keyvaluestore.Event, error) {
+
+	consistency := s.readConsistency(request.Options)
+	view, err := s.readView(request.Key, consistency, request.Options.RequiredNodes)
+	if err != nil {
+		return nil, s.convertErrorToGRPC(err)
+	}
+
+	pattern := escapePattern(request.Key)
+	if request.Prefix {
+		pattern += "*"
+	}
+
+	var channels []<-chan keyvaluestore.Event
+	for _, node := range view.Backends {
+		events, err := node.Watch(ctx, pattern)
+		if err != nil {
+			logrus.WithError(err).WithField("node", node.Address()).Error("failed to watch node")
+			continue
+		}
+
+		channels = append(channels, events)
+	}
+
+	if len(channels) == 0 {
+		return nil, s.convertErrorToGRPC(keyvaluestore.ErrConsistency)
+	}
+
+	merged := make(chan keyvaluestore.Event)
+	var wg sync.WaitGroup
+
+	for _, events := range channels {
+		wg.Add(1)
+
+		go func(events <-chan keyvaluestore.Event) {
+			defer wg.Done()
+
+			for event := range events {
+				select {
+				case merged <- event:
+				case <-ctx.Done():
+				}
+			}
+		}(events)
+	}
+
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	result := make(chan keyvaluestore.Event)
+
+	go func() {
+		defer close(result)
+
+		deduplicator := newEventDeduplicator(len(channels))
+		for event := range merged {
+			if deduplicator.isDuplicate(event, time.Now()) {
+				continue
+			}
+
+			select {
+			case result <- event:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return result, nil
+}
+
+var patternEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func escapePattern(key string) string {
+	return patternEscaper.Replace(key)
+}
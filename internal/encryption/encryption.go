@@ -0,0 +1,123 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+)
+
+var magic = []byte{0x00, 'K', 'V', 'E'}
+
+// Formats of encrypted values, following magic. Values of formatUnbound were
+// written before the key name was authenticated along with them.
+const (
+	formatUnbound  byte = 0x01
+	formatKeyBound byte = 0x02
+)
+
+var (
+	ErrUnknownKey = errors.New("value is encrypted with an unknown key")
+	ErrCorrupt    = errors.New("encrypted value is corrupt")
+)
+
+// Keyring encrypts values using AES-GCM with its current key, and decrypts
+// values encrypted with any of its keys. The id of the key is stored along
+// with every value, so keys can be rotated by adding a new current key while
+// keeping the old ones for decryption. The name of the key a value is stored
+// under is authenticated along with it, so values copied to other keys fail
+// to decrypt.
+type Keyring struct {
+	current byte
+	ciphers map[byte]cipher.AEAD
+}
+
+// NewKeyring creates a keyring from AES keys (16, 24 or 32 bytes) indexed by
+// their ids. Errors never contain key material.
+func NewKeyring(keys map[byte][]byte, current byte) (*Keyring, error) {
+	result := &Keyring{
+		current: current,
+		ciphers: make(map[byte]cipher.AEAD),
+	}
+
+	for id, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Errorf("invalid encryption key %d: expected 16, 24 or 32 bytes", id)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Errorf("invalid encryption key %d", id)
+		}
+
+		result.ciphers[id] = aead
+	}
+
+	if _, ok := result.ciphers[current]; !ok {
+		return nil, errors.Errorf("current encryption key %d is not provided", current)
+	}
+
+	return result, nil
+}
+
+// Encrypt encrypts data stored under key.
+func (k *Keyring) Encrypt(key string, data []byte) ([]byte, error) {
+	aead := k.ciphers[k.current]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, magic...), formatKeyBound, k.current)
+	result := append(header, nonce...)
+
+	return aead.Seal(result, nonce, data, additionalData(header, key)), nil
+}
+
+// Decrypt reverses Encrypt, given the same key. Data which has not been
+// encrypted is returned as is.
+func (k *Keyring) Decrypt(key string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, magic) || len(data) == len(magic) {
+		return data, nil
+	}
+
+	format := data[len(magic)]
+	if format != formatUnbound && format != formatKeyBound {
+		return data, nil
+	}
+
+	if len(data) < len(magic)+2 {
+		return nil, ErrCorrupt
+	}
+
+	header := data[:len(magic)+2]
+	aead, ok := k.ciphers[header[len(magic)+1]]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	rest := data[len(header):]
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+
+	authenticated := header
+	if format == formatKeyBound {
+		authenticated = additionalData(header, key)
+	}
+
+	result, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], authenticated)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	return result, nil
+}
+
+func additionalData(header []byte, key string) []byte {
+	return append(append([]byte{}, header...), key...)
+}
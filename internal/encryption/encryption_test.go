@@ -0,0 +1,105 @@
+package encryption_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/cafebazaar/keyvalue-store/internal/encryption"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	key1 = bytes.Repeat([]byte{1}, 32)
+	key2 = bytes.Repeat([]byte{2}, 32)
+)
+
+type EncryptionTestSuite struct {
+	suite.Suite
+}
+
+func TestEncryptionTestSuite(t *testing.T) {
+	suite.Run(t, new(EncryptionTestSuite))
+}
+
+func (s *EncryptionTestSuite) TestDecryptShouldReverseEncrypt() {
+	keyring := s.makeKeyring(map[byte][]byte{1: key1}, 1)
+	encrypted, err := keyring.Encrypt("key", []byte("hello"))
+	s.Nil(err)
+	s.False(bytes.Contains(encrypted, []byte("hello")))
+
+	decrypted, err := keyring.Decrypt("key", encrypted)
+	s.Nil(err)
+	s.Equal("hello", string(decrypted))
+}
+
+func (s *EncryptionTestSuite) TestDecryptShouldReturnPlainDataAsIs() {
+	keyring := s.makeKeyring(map[byte][]byte{1: key1}, 1)
+	decrypted, err := keyring.Decrypt("key", []byte("hello"))
+	s.Nil(err)
+	s.Equal("hello", string(decrypted))
+}
+
+func (s *EncryptionTestSuite) TestDecryptShouldSupportRotatedKeys() {
+	encrypted, err := s.makeKeyring(map[byte][]byte{1: key1}, 1).Encrypt("key", []byte("hello"))
+	s.Nil(err)
+
+	decrypted, err := s.makeKeyring(map[byte][]byte{1: key1, 2: key2}, 2).Decrypt("key", encrypted)
+	s.Nil(err)
+	s.Equal("hello", string(decrypted))
+}
+
+func (s *EncryptionTestSuite) TestDecryptShouldFailOnUnknownKey() {
+	encrypted, err := s.makeKeyring(map[byte][]byte{1: key1}, 1).Encrypt("key", []byte("hello"))
+	s.Nil(err)
+
+	_, err = s.makeKeyring(map[byte][]byte{2: key2}, 2).Decrypt("key", encrypted)
+	s.Equal(encryption.ErrUnknownKey, err)
+}
+
+func (s *EncryptionTestSuite) TestDecryptShouldFailOnTamperedData() {
+	keyring := s.makeKeyring(map[byte][]byte{1: key1}, 1)
+	encrypted, err := keyring.Encrypt("key", []byte("hello"))
+	s.Nil(err)
+
+	encrypted[len(encrypted)-1] ^= 0xff
+	_, err = keyring.Decrypt("key", encrypted)
+	s.Equal(encryption.ErrCorrupt, err)
+}
+
+func (s *EncryptionTestSuite) TestDecryptShouldFailOnOtherKeyName() {
+	keyring := s.makeKeyring(map[byte][]byte{1: key1}, 1)
+	encrypted, err := keyring.Encrypt("key", []byte("hello"))
+	s.Nil(err)
+
+	_, err = keyring.Decrypt("other", encrypted)
+	s.Equal(encryption.ErrCorrupt, err)
+}
+
+func (s *EncryptionTestSuite) TestDecryptShouldSupportValuesNotBoundToKeyName() {
+	block, err := aes.NewCipher(key1)
+	s.Require().Nil(err)
+	aead, err := cipher.NewGCM(block)
+	s.Require().Nil(err)
+
+	header := []byte{0x00, 'K', 'V', 'E', 0x01, 1}
+	nonce := make([]byte, aead.NonceSize())
+	encrypted := aead.Seal(append(append([]byte{}, header...), nonce...), nonce, []byte("hello"), header)
+
+	decrypted, err := s.makeKeyring(map[byte][]byte{1: key1}, 1).Decrypt("key", encrypted)
+	s.Nil(err)
+	s.Equal("hello", string(decrypted))
+}
+
+func (s *EncryptionTestSuite) TestNewKeyringShouldRejectInvalidKeys() {
+	_, err := encryption.NewKeyring(map[byte][]byte{1: []byte("short")}, 1)
+	s.NotNil(err)
+	s.NotContains(err.Error(), "short")
+}
+
+func (s *EncryptionTestSuite) makeKeyring(keys map[byte][]byte, current byte) *encryption.Keyring {
+	keyring, err := encryption.NewKeyring(keys, current)
+	s.Require().Nil(err)
+	return keyring
+}
@@ -14,9 +14,33 @@ type keyValueEngine struct {
 	mutex                    sync.Mutex
 	wg                       sync.WaitGroup
 	ignoreWriteResultChannel chan asyncWriteResult
+	semaphore                chan struct{}
+	fallback                 keyvaluestore.FallbackOperator
 }
 
-func New(votingFactory keyvaluestore.VotingFactory) keyvaluestore.Engine {
+type Option func(e *keyValueEngine)
+
+// WithMaxConcurrency limits the number of per-node operations which are
+// in-flight at the same time across all concurrent reads and writes.
+func WithMaxConcurrency(maxConcurrency int) Option {
+	return func(e *keyValueEngine) {
+		if maxConcurrency > 0 {
+			e.semaphore = make(chan struct{}, maxConcurrency)
+		}
+	}
+}
+
+// WithNoMajorityFallback makes reads return the value picked by fallback,
+// among the values nodes have answered, if at least as many nodes as the
+// required votes have answered but no value has reached them. Such reads fail
+// with ErrConsistency otherwise, and are never repaired.
+func WithNoMajorityFallback(fallback keyvaluestore.FallbackOperator) Option {
+	return func(e *keyValueEngine) {
+		e.fallback = fallback
+	}
+}
+
+func New(votingFactory keyvaluestore.VotingFactory, options ...Option) keyvaluestore.Engine {
 
 	result := &keyValueEngine{
 		votingFactory:            votingFactory,
@@ -24,6 +48,10 @@ func New(votingFactory keyvaluestore.VotingFactory) keyvaluestore.Engine {
 		closed:                   make(chan struct{}),
 	}
 
+	for _, option := range options {
+		option(result)
+	}
+
 	started := make(chan struct{})
 	result.wg.Add(1)
 	go result.beginLogDelivery(started)
@@ -53,7 +81,8 @@ func (e *keyValueEngine) Read(nodes []keyvaluestore.Backend,
 	operator keyvaluestore.ReadOperator,
 	repair keyvaluestore.RepairOperator,
 	cmp keyvaluestore.ValueComparer,
-	mode keyvaluestore.VotingMode) (interface{}, error) {
+	mode keyvaluestore.VotingMode,
+	observer keyvaluestore.VoteObserver) (interface{}, error) {
 
 	e.operating.Add(1)
 	defer e.operating.Done()
@@ -66,7 +95,7 @@ func (e *keyValueEngine) Read(nodes []keyvaluestore.Backend,
 	resultChannel := make(chan asyncReadResult, len(nodes))
 
 	e.startReadOperatorOnMultipleNodes(nodes, operator, &wg, resultChannel)
-	voteChannel := e.startReadVote(&wg, resultChannel, cmp, votesRequired, repair, mode)
+	voteChannel := e.startReadVote(&wg, resultChannel, cmp, len(nodes), votesRequired, repair, mode, observer)
 
 	vote := <-voteChannel
 	return vote.value, vote.err
@@ -82,7 +111,8 @@ func (e *keyValueEngine) Write(nodes []keyvaluestore.Backend,
 	resultChannel := make(chan asyncWriteResult, len(nodes))
 
 	e.startWriteOperatorOnMultipleNodes(nodes, operator, &wg, resultChannel, mode)
-	completedChannel := e.startWaitingForWriteCompletion(&wg, resultChannel, rollback, acknowledgeRequired)
+	completedChannel := e.startWaitingForWriteCompletion(&wg, resultChannel, rollback,
+		len(nodes), acknowledgeRequired)
 
 	result := <-completedChannel
 	return result.err
@@ -91,30 +121,34 @@ func (e *keyValueEngine) Write(nodes []keyvaluestore.Backend,
 func (e *keyValueEngine) startWaitingForWriteCompletion(wg *sync.WaitGroup,
 	resultChannel chan asyncWriteResult,
 	rollback keyvaluestore.RollbackOperator,
+	nodeCount int,
 	requiredNodes int) chan asyncWriteResult {
 
 	ch := make(chan asyncWriteResult, 1)
 	e.operating.Add(1)
-	go e.waitForWriteCompletion(wg, resultChannel, requiredNodes, rollback, ch)
+	go e.waitForWriteCompletion(wg, resultChannel, nodeCount, requiredNodes, rollback, ch)
 	return ch
 }
 
 func (e *keyValueEngine) startReadVote(wg *sync.WaitGroup,
 	resultChannel chan asyncReadResult,
 	comparer keyvaluestore.ValueComparer,
+	nodeCount int,
 	requiredVotes int,
 	repair keyvaluestore.RepairOperator,
-	mode keyvaluestore.VotingMode) chan asyncReadResult {
+	mode keyvaluestore.VotingMode,
+	observer keyvaluestore.VoteObserver) chan asyncReadResult {
 
 	ch := make(chan asyncReadResult, 1)
 	e.operating.Add(1)
-	go e.waitForReadVote(wg, resultChannel, comparer, requiredVotes, repair, mode, ch)
+	go e.waitForReadVote(wg, resultChannel, comparer, nodeCount, requiredVotes, repair, mode, observer, ch)
 
 	return ch
 }
 
 func (e *keyValueEngine) waitForWriteCompletion(wg *sync.WaitGroup,
 	resultChannel chan asyncWriteResult,
+	nodeCount int,
 	requiredNodes int,
 	rollback keyvaluestore.RollbackOperator,
 	finalResultChannel chan asyncWriteResult) {
@@ -123,6 +157,7 @@ func (e *keyValueEngine) waitForWriteCompletion(wg *sync.WaitGroup,
 
 	done := e.beginWaitGroupMonitor(wg)
 	completed := 0
+	failed := 0
 	var lastErr error
 	var completedNodes []keyvaluestore.Backend
 
@@ -132,6 +167,19 @@ func (e *keyValueEngine) waitForWriteCompletion(wg *sync.WaitGroup,
 		finalResultChannel = nil
 	}
 
+	// Nodes might fail or go away while others are still pending, e.g. upon
+	// removal from the cluster. Once the remaining nodes cannot make up the
+	// quorum anymore, the write fails without waiting for them. Rollback still
+	// waits for every node, so that it covers late acknowledgements as well.
+	failIfQuorumIsUnreachable := func() {
+		if finalResultChannel != nil && nodeCount-failed < requiredNodes {
+			finalResultChannel <- asyncWriteResult{err: keyvaluestore.ErrConsistency}
+			close(finalResultChannel)
+			finalResultChannel = nil
+		}
+	}
+	failIfQuorumIsUnreachable()
+
 	for {
 		select {
 		case <-done:
@@ -141,19 +189,14 @@ func (e *keyValueEngine) waitForWriteCompletion(wg *sync.WaitGroup,
 		case result, ok := <-resultChannel:
 			if !ok {
 				if finalResultChannel != nil {
-					if completed < requiredNodes || lastErr == nil {
-						finalResultChannel <- asyncWriteResult{err: keyvaluestore.ErrConsistency}
-					} else {
-						finalResultChannel <- asyncWriteResult{err: lastErr}
-					}
-
+					finalResultChannel <- asyncWriteResult{err: keyvaluestore.ErrConsistency}
 					close(finalResultChannel)
+				}
 
-					if rollback != nil {
-						rollback(keyvaluestore.RollbackArgs{
-							Nodes: completedNodes,
-						})
-					}
+				if completed < requiredNodes && rollback != nil {
+					rollback(keyvaluestore.RollbackArgs{
+						Nodes: completedNodes,
+					})
 				}
 
 				return
@@ -172,6 +215,8 @@ func (e *keyValueEngine) waitForWriteCompletion(wg *sync.WaitGroup,
 				}
 
 				lastErr = result.err
+				failed++
+				failIfQuorumIsUnreachable()
 			}
 		}
 	}
@@ -180,17 +225,36 @@ func (e *keyValueEngine) waitForWriteCompletion(wg *sync.WaitGroup,
 func (e *keyValueEngine) waitForReadVote(wg *sync.WaitGroup,
 	everyNodeResultChannel chan asyncReadResult,
 	cmp keyvaluestore.ValueComparer,
+	nodeCount int,
 	requiredVotes int,
 	repair keyvaluestore.RepairOperator,
 	mode keyvaluestore.VotingMode,
+	observer keyvaluestore.VoteObserver,
 	finalResultChannel chan asyncReadResult) {
 
 	defer e.operating.Done()
 
 	var lastErr error
+	var responses int
+	var notFoundResponses int
+	var failedResponses int
+	var failedEarly bool
+	var values []interface{}
 	done := e.beginWaitGroupMonitor(wg)
 	votes := e.votingFactory(e.makeVoteComparer(cmp))
 
+	observe := func(outcome keyvaluestore.VoteOutcome) {
+		if observer == nil {
+			return
+		}
+
+		if outcome == keyvaluestore.VoteOutcomeFirstResponses && responses > requiredVotes {
+			outcome = keyvaluestore.VoteOutcomeWaited
+		}
+
+		observer(outcome)
+	}
+
 	for {
 		select {
 		case <-done:
@@ -199,6 +263,11 @@ func (e *keyValueEngine) waitForReadVote(wg *sync.WaitGroup,
 
 		case result, ok := <-everyNodeResultChannel:
 			if !ok {
+				if failedEarly {
+					e.logError(lastErr)
+					return
+				}
+
 				if finalResultChannel == nil {
 					losers := votes.Losers()
 
@@ -225,22 +294,36 @@ func (e *keyValueEngine) waitForReadVote(wg *sync.WaitGroup,
 
 						repair(args)
 					}
-				} else if !votes.Empty() || lastErr == nil {
+				} else {
+					// Not-found is only reported if enough nodes have affirmatively
+					// reported it, rather than because too few nodes have responded
 					_, winnerVote := votes.MaxVote()
-					if winnerVote == 0 {
+					if winnerVote == 0 && notFoundResponses >= requiredVotes {
+						observe(keyvaluestore.VoteOutcomeFirstResponses)
 						finalResultChannel <- asyncReadResult{err: keyvaluestore.ErrNotFound}
+					} else if value, ok := e.fallBack(values, responses-failedResponses, requiredVotes); ok {
+						observe(keyvaluestore.VoteOutcomeFallback)
+						finalResultChannel <- asyncReadResult{value: value}
 					} else {
+						if lastErr != nil {
+							e.logError(lastErr)
+						}
+
+						observe(keyvaluestore.VoteOutcomeFailed)
 						finalResultChannel <- asyncReadResult{err: keyvaluestore.ErrConsistency}
 					}
 					close(finalResultChannel)
-				} else {
-					finalResultChannel <- asyncReadResult{err: lastErr}
-					close(finalResultChannel)
 				}
 
 				return
-			} else if result.err != nil {
+			}
+
+			responses++
+
+			if result.err != nil {
 				if result.err == keyvaluestore.ErrNotFound {
+					notFoundResponses++
+
 					var weight int
 
 					switch mode {
@@ -251,6 +334,7 @@ func (e *keyValueEngine) waitForReadVote(wg *sync.WaitGroup,
 					}
 
 					if votes.Add(voteItem{notFound: true}, result.node, weight) >= requiredVotes && finalResultChannel != nil {
+						observe(keyvaluestore.VoteOutcomeFirstResponses)
 						finalResultChannel <- asyncReadResult{err: keyvaluestore.ErrNotFound}
 						close(finalResultChannel)
 						finalResultChannel = nil
@@ -261,9 +345,25 @@ func (e *keyValueEngine) waitForReadVote(wg *sync.WaitGroup,
 					}
 
 					lastErr = result.err
+					failedResponses++
+
+					// Same as writes, give up once the remaining nodes cannot
+					// make up the quorum anymore
+					if finalResultChannel != nil && nodeCount-failedResponses < requiredVotes {
+						observe(keyvaluestore.VoteOutcomeFailed)
+						finalResultChannel <- asyncReadResult{err: keyvaluestore.ErrConsistency}
+						close(finalResultChannel)
+						finalResultChannel = nil
+						failedEarly = true
+					}
 				}
 			} else {
+				if e.fallback != nil {
+					values = append(values, result.value)
+				}
+
 				if votes.Add(voteItem{value: result.value}, result.node, 1) >= requiredVotes && finalResultChannel != nil {
+					observe(keyvaluestore.VoteOutcomeFirstResponses)
 					finalResultChannel <- asyncReadResult{value: result.value}
 					close(finalResultChannel)
 					finalResultChannel = nil
@@ -273,6 +373,16 @@ func (e *keyValueEngine) waitForReadVote(wg *sync.WaitGroup,
 	}
 }
 
+// fallBack picks one of values using the fallback of the engine, provided
+// that enough nodes have answered to make up the required votes.
+func (e *keyValueEngine) fallBack(values []interface{}, answered int, requiredVotes int) (interface{}, bool) {
+	if e.fallback == nil || len(values) == 0 || answered < requiredVotes {
+		return nil, false
+	}
+
+	return e.fallback(values)
+}
+
 func (e *keyValueEngine) startWriteOperatorOnMultipleNodes(nodes []keyvaluestore.Backend,
 	operator keyvaluestore.WriteOperator,
 	wg *sync.WaitGroup,
@@ -284,7 +394,12 @@ func (e *keyValueEngine) startWriteOperatorOnMultipleNodes(nodes []keyvaluestore
 		for _, node := range nodes {
 			e.performAdd(wg, 1)
 			e.operating.Add(1)
-			go e.performWriteOperatorOnSingleNode(node, operator, wg, resultChannel)
+			go func(node keyvaluestore.Backend) {
+				e.acquire()
+				defer e.release()
+
+				e.performWriteOperatorOnSingleNode(node, operator, wg, resultChannel)
+			}(node)
 		}
 
 	case keyvaluestore.OperationModeSequential:
@@ -307,7 +422,24 @@ func (e *keyValueEngine) startReadOperatorOnMultipleNodes(nodes []keyvaluestore.
 	for _, node := range nodes {
 		e.performAdd(wg, 1)
 		e.operating.Add(1)
-		go e.performReadOperatorOnSingleNode(node, operator, wg, resultChannel)
+		go func(node keyvaluestore.Backend) {
+			e.acquire()
+			defer e.release()
+
+			e.performReadOperatorOnSingleNode(node, operator, wg, resultChannel)
+		}(node)
+	}
+}
+
+func (e *keyValueEngine) acquire() {
+	if e.semaphore != nil {
+		e.semaphore <- struct{}{}
+	}
+}
+
+func (e *keyValueEngine) release() {
+	if e.semaphore != nil {
+		<-e.semaphore
 	}
 }
 
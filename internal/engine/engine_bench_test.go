@@ -0,0 +1,131 @@
+package engine_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/internal/engine"
+	"github.com/cafebazaar/keyvalue-store/internal/voting"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// fakeBackend stands for a node which answers after latency. Operators of the
+// benchmarks never call the backend itself, so the embedded one stays nil.
+type fakeBackend struct {
+	keyvaluestore.Backend
+
+	latency time.Duration
+}
+
+func (b *fakeBackend) wait() {
+	if b.latency > 0 {
+		time.Sleep(b.latency)
+	}
+}
+
+var benchmarkNodeCounts = []int{1, 3, 5, 7}
+
+var benchmarkLatencies = []time.Duration{0, 2 * time.Millisecond}
+
+// benchmarkQuorums maps consistency levels to the number of nodes they require.
+var benchmarkQuorums = []struct {
+	name   string
+	quorum func(nodes int) int
+}{
+	{name: "one", quorum: func(nodes int) int { return 1 }},
+	{name: "majority", quorum: func(nodes int) int { return nodes/2 + 1 }},
+	{name: "all", quorum: func(nodes int) int { return nodes }},
+}
+
+// makeFakeBackends spreads latencies over nodes, from latency for the first one
+// to twice as much for the last, so that the quorum decides how long to wait.
+func makeFakeBackends(count int, latency time.Duration) []keyvaluestore.Backend {
+	result := make([]keyvaluestore.Backend, count)
+	for i := range result {
+		result[i] = &fakeBackend{latency: latency + latency*time.Duration(i)/time.Duration(count)}
+	}
+
+	return result
+}
+
+func runEngineBenchmarks(b *testing.B, run func(b *testing.B, e keyvaluestore.Engine,
+	nodes []keyvaluestore.Backend, quorum int)) {
+
+	for _, latency := range benchmarkLatencies {
+		for _, count := range benchmarkNodeCounts {
+			for _, consistency := range benchmarkQuorums {
+				name := fmt.Sprintf("latency=%v/nodes=%v/%v", latency, count, consistency.name)
+
+				b.Run(name, func(b *testing.B) {
+					e := engine.New(voting.New)
+					defer e.Close()
+
+					nodes := makeFakeBackends(count, latency)
+					b.ReportAllocs()
+					b.ResetTimer()
+
+					run(b, e, nodes, consistency.quorum(count))
+				})
+			}
+		}
+	}
+}
+
+func BenchmarkEngineRead(b *testing.B) {
+	operator := func(node keyvaluestore.Backend) (interface{}, error) {
+		node.(*fakeBackend).wait()
+		return RESULT, nil
+	}
+
+	comparer := func(x, y interface{}) bool {
+		return x == y
+	}
+
+	runEngineBenchmarks(b, func(b *testing.B, e keyvaluestore.Engine,
+		nodes []keyvaluestore.Backend, quorum int) {
+
+		for i := 0; i < b.N; i++ {
+			if _, err := e.Read(nodes, quorum, operator, nil, comparer,
+				keyvaluestore.VotingModeVoteOnNotFound, nil); err != nil {
+
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkEngineWrite(b *testing.B) {
+	operator := func(node keyvaluestore.Backend) error {
+		node.(*fakeBackend).wait()
+		return nil
+	}
+
+	runEngineBenchmarks(b, func(b *testing.B, e keyvaluestore.Engine,
+		nodes []keyvaluestore.Backend, quorum int) {
+
+		for i := 0; i < b.N; i++ {
+			if err := e.Write(nodes, quorum, operator, nil, keyvaluestore.OperationModeConcurrent); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkEngineSequentialWrite(b *testing.B) {
+	operator := func(node keyvaluestore.Backend) error {
+		node.(*fakeBackend).wait()
+		return nil
+	}
+
+	runEngineBenchmarks(b, func(b *testing.B, e keyvaluestore.Engine,
+		nodes []keyvaluestore.Backend, quorum int) {
+
+		for i := 0; i < b.N; i++ {
+			if err := e.Write(nodes, quorum, operator, nil, keyvaluestore.OperationModeSequential); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
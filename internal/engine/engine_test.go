@@ -92,6 +92,48 @@ func (s *EngineTestSuite) TestWriteShouldReportErrorIfAcknowledgeAreNotSatisfied
 	s.assertAllCalled()
 }
 
+func (s *EngineTestSuite) TestWriteShouldFailFastIfNodesGoAwayWhileOthersArePending() {
+	rolledBack := make(chan []keyvaluestore.Backend, 1)
+	s.setNodeSlow(0)
+	s.setNodeOnError(1, keyvaluestore.ErrClosed)
+	err := s.engine.Write(s.nodes, 3, s.writeOperator, func(args keyvaluestore.RollbackArgs) {
+		rolledBack <- args.Nodes
+	}, keyvaluestore.OperationModeConcurrent)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+	s.False(s.mark[0])
+
+	s.continueSlow()
+	s.wg.Wait()
+	s.ElementsMatch([]keyvaluestore.Backend{s.node1, s.node3}, <-rolledBack)
+}
+
+func (s *EngineTestSuite) TestWriteShouldFailFastIfQuorumExceedsNodes() {
+	s.setNodeSlow(0)
+	s.Equal(keyvaluestore.ErrConsistency, s.engine.Write(s.nodes, 4, s.writeOperator, nil,
+		keyvaluestore.OperationModeConcurrent))
+	s.False(s.mark[0])
+	s.continueSlow()
+	s.wg.Wait()
+}
+
+func (s *EngineTestSuite) TestReadShouldFailFastIfNodesGoAwayWhileOthersArePending() {
+	var outcomes []keyvaluestore.VoteOutcome
+	s.setNodeSlow(0)
+	s.setNodeOnError(1, keyvaluestore.ErrClosed)
+	s.setNodeOnError(2, keyvaluestore.ErrClosed)
+	_, err := s.engine.Read(s.nodes, 2, s.readOperator, func(args keyvaluestore.RepairArgs) {
+		s.Fail("failed reads should not be repaired")
+	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound, func(outcome keyvaluestore.VoteOutcome) {
+		outcomes = append(outcomes, outcome)
+	})
+	s.Equal(keyvaluestore.ErrConsistency, err)
+	s.False(s.mark[0])
+	s.Equal([]keyvaluestore.VoteOutcome{keyvaluestore.VoteOutcomeFailed}, outcomes)
+
+	s.continueSlow()
+	s.wg.Wait()
+}
+
 func (s *EngineTestSuite) TestConcurrentWriteShouldWriteConcurrentlyOnNodes() {
 	var current int32
 	var max int32
@@ -116,6 +158,33 @@ func (s *EngineTestSuite) TestConcurrentWriteShouldWriteConcurrentlyOnNodes() {
 	s.True(max > 1)
 }
 
+func (s *EngineTestSuite) TestConcurrentWriteShouldRespectMaxConcurrency() {
+	s.Nil(s.engine.Close())
+	s.engine = engine.New(voting.New, engine.WithMaxConcurrency(1))
+
+	var current int32
+	var max int32
+	var lock sync.Mutex
+	record := func(count int32) {
+		lock.Lock()
+		defer lock.Unlock()
+		if count > max {
+			max = count
+		}
+	}
+	op := func(backend keyvaluestore.Backend) error {
+		record(atomic.AddInt32(&current, 1))
+		defer atomic.AddInt32(&current, -1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	s.Nil(s.engine.Write(s.nodes, 3, op, nil, keyvaluestore.OperationModeConcurrent))
+	lock.Lock()
+	defer lock.Unlock()
+	s.Equal(int32(1), max)
+}
+
 func (s *EngineTestSuite) TestSequentialWriteShouldKeepNodePartialOrder() {
 	var current int32
 	var max int32
@@ -144,7 +213,7 @@ func (s *EngineTestSuite) TestSequentialWriteShouldKeepNodePartialOrder() {
 
 func (s *EngineTestSuite) TestReadShouldCallAllNodes() {
 	value, err := s.engine.Read(s.nodes, 3, s.readOperator, nil, s.comparer,
-		keyvaluestore.VotingModeVoteOnNotFound)
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Nil(err)
 	s.Equal(RESULT, value)
 	s.wg.Wait()
@@ -154,7 +223,7 @@ func (s *EngineTestSuite) TestReadShouldCallAllNodes() {
 func (s *EngineTestSuite) TestReadShouldNotCallRepairIfAllNodesAggree() {
 	value, err := s.engine.Read(s.nodes, 3, s.readOperator, func(args keyvaluestore.RepairArgs) {
 		s.FailNow("repair should not have been called since all nodes agree")
-	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound)
+	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Nil(err)
 	s.Equal(RESULT, value)
 	s.assertAllCalled()
@@ -164,7 +233,7 @@ func (s *EngineTestSuite) TestReadShouldNotCallRepairIfAllNodesAggree() {
 func (s *EngineTestSuite) TestReadShouldNotWaitOnSlowNodesIfVotesAreSatisfied() {
 	s.setNodeSlow(0)
 	value, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
-		keyvaluestore.VotingModeVoteOnNotFound)
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Nil(err)
 	s.Equal(RESULT, value)
 	s.False(s.mark[0])
@@ -173,10 +242,117 @@ func (s *EngineTestSuite) TestReadShouldNotWaitOnSlowNodesIfVotesAreSatisfied()
 	s.assertAllCalled()
 }
 
+func (s *EngineTestSuite) TestReadShouldObserveQuorumOnFirstResponses() {
+	var outcomes []keyvaluestore.VoteOutcome
+	s.setNodeSlow(0)
+	_, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeVoteOnNotFound, func(outcome keyvaluestore.VoteOutcome) {
+			outcomes = append(outcomes, outcome)
+		})
+	s.Nil(err)
+	s.continueSlow()
+	s.wg.Wait()
+	s.Equal([]keyvaluestore.VoteOutcome{keyvaluestore.VoteOutcomeFirstResponses}, outcomes)
+}
+
+func (s *EngineTestSuite) TestReadShouldObserveWaitingForMoreResponses() {
+	var outcomes []keyvaluestore.VoteOutcome
+	s.setNodeSlow(0)
+	s.setNodeResult(1, RESULT+1)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		s.continueSlow()
+	}()
+	_, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeVoteOnNotFound, func(outcome keyvaluestore.VoteOutcome) {
+			outcomes = append(outcomes, outcome)
+		})
+	s.Nil(err)
+	s.wg.Wait()
+	s.Equal([]keyvaluestore.VoteOutcome{keyvaluestore.VoteOutcomeWaited}, outcomes)
+}
+
+func (s *EngineTestSuite) TestReadShouldObserveFailureToReachQuorum() {
+	var outcomes []keyvaluestore.VoteOutcome
+	s.setNodeResult(1, RESULT+1)
+	s.setNodeResult(2, RESULT+2)
+	_, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeVoteOnNotFound, func(outcome keyvaluestore.VoteOutcome) {
+			outcomes = append(outcomes, outcome)
+		})
+	s.Equal(keyvaluestore.ErrConsistency, err)
+	s.Equal([]keyvaluestore.VoteOutcome{keyvaluestore.VoteOutcomeFailed}, outcomes)
+}
+
+func (s *EngineTestSuite) TestReadShouldReturnFallbackValueIfNoValueHasMajority() {
+	s.Nil(s.engine.Close())
+	s.engine = engine.New(voting.New, engine.WithNoMajorityFallback(func(values []interface{}) (interface{}, bool) {
+		s.ElementsMatch([]interface{}{RESULT, RESULT + 1, RESULT + 2}, values)
+		return RESULT + 2, true
+	}))
+
+	var outcomes []keyvaluestore.VoteOutcome
+	s.setNodeResult(1, RESULT+1)
+	s.setNodeResult(2, RESULT+2)
+	value, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeVoteOnNotFound, func(outcome keyvaluestore.VoteOutcome) {
+			outcomes = append(outcomes, outcome)
+		})
+	s.Nil(err)
+	s.Equal(RESULT+2, value)
+	s.Equal([]keyvaluestore.VoteOutcome{keyvaluestore.VoteOutcomeFallback}, outcomes)
+}
+
+func (s *EngineTestSuite) TestReadShouldFailIfFallbackPicksNoValue() {
+	s.Nil(s.engine.Close())
+	s.engine = engine.New(voting.New, engine.WithNoMajorityFallback(func(values []interface{}) (interface{}, bool) {
+		return nil, false
+	}))
+
+	s.setNodeResult(1, RESULT+1)
+	s.setNodeResult(2, RESULT+2)
+	_, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+}
+
+func (s *EngineTestSuite) TestReadShouldNotFallBackIfTooFewNodesAnswer() {
+	s.Nil(s.engine.Close())
+	s.engine = engine.New(voting.New, engine.WithNoMajorityFallback(func(values []interface{}) (interface{}, bool) {
+		s.Fail("fallback should not be called")
+		return nil, false
+	}))
+
+	s.setNodeResult(1, RESULT+1)
+	s.setNodeOnError(2, errors.New("some error"))
+	_, err := s.engine.Read(s.nodes, 3, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+}
+
+func (s *EngineTestSuite) TestReadShouldNotBeDelayedBySlowNodeIfQuorumIsMet() {
+	op := func(backend keyvaluestore.Backend) (interface{}, error) {
+		if backend == s.node3 {
+			time.Sleep(500 * time.Millisecond)
+		}
+
+		return RESULT, nil
+	}
+
+	start := time.Now()
+	value, err := s.engine.Read(s.nodes, 2, op, nil, s.comparer,
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
+	elapsed := time.Since(start)
+
+	s.Nil(err)
+	s.Equal(RESULT, value)
+	s.True(elapsed < 100*time.Millisecond, "read took %v", elapsed)
+}
+
 func (s *EngineTestSuite) TestReadShouldNotReportErrorIfVotesAreSatisfied() {
 	s.setNodeOnError(0, errors.New("some error"))
 	value, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
-		keyvaluestore.VotingModeVoteOnNotFound)
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Nil(err)
 	s.Equal(RESULT, value)
 	s.assertAllCalled()
@@ -185,7 +361,7 @@ func (s *EngineTestSuite) TestReadShouldNotReportErrorIfVotesAreSatisfied() {
 func (s *EngineTestSuite) TestReadShouldReportErrorIfVotesAreNotSatisfied() {
 	s.setNodeOnError(0, errors.New("some error"))
 	_, err := s.engine.Read(s.nodes, 3, s.readOperator, nil, s.comparer,
-		keyvaluestore.VotingModeVoteOnNotFound)
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.NotNil(err)
 	s.assertAllCalled()
 }
@@ -194,17 +370,57 @@ func (s *EngineTestSuite) TestReadShouldReportNotFoundErrorIfVotesAggree() {
 	s.setNodeOnError(0, keyvaluestore.ErrNotFound)
 	s.setNodeOnError(1, keyvaluestore.ErrNotFound)
 	_, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
-		keyvaluestore.VotingModeVoteOnNotFound)
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Equal(keyvaluestore.ErrNotFound, err)
 	s.wg.Wait()
 	s.assertAllCalled()
 }
 
+func (s *EngineTestSuite) TestReadShouldReportConsistencyErrorIfTooFewNodesReportNotFound() {
+	s.setNodeOnError(0, keyvaluestore.ErrNotFound)
+	s.setNodeOnError(1, errors.New("connection refused"))
+	s.setNodeOnError(2, errors.New("connection refused"))
+	_, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+	s.assertAllCalled()
+}
+
+func (s *EngineTestSuite) TestReadShouldReportConsistencyErrorIfTooFewNodesReportNotFoundOnVoteModeSkipNotFound() {
+	s.setNodeOnError(0, keyvaluestore.ErrNotFound)
+	s.setNodeOnError(1, errors.New("connection refused"))
+	s.setNodeOnError(2, errors.New("connection refused"))
+	_, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeSkipVoteOnNotFound, nil)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+	s.assertAllCalled()
+}
+
+func (s *EngineTestSuite) TestReadShouldReportNotFoundOnVoteModeSkipNotFoundIfEnoughNodesReportIt() {
+	s.setNodeOnError(0, keyvaluestore.ErrNotFound)
+	s.setNodeOnError(1, keyvaluestore.ErrNotFound)
+	s.setNodeOnError(2, errors.New("connection refused"))
+	_, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeSkipVoteOnNotFound, nil)
+	s.Equal(keyvaluestore.ErrNotFound, err)
+	s.assertAllCalled()
+}
+
+func (s *EngineTestSuite) TestReadShouldReportConsistencyErrorIfAllNodesFail() {
+	s.setNodeOnError(0, errors.New("connection refused"))
+	s.setNodeOnError(1, errors.New("connection refused"))
+	s.setNodeOnError(2, errors.New("connection refused"))
+	_, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
+	s.Equal(keyvaluestore.ErrConsistency, err)
+	s.assertAllCalled()
+}
+
 func (s *EngineTestSuite) TestReadShouldNotConsiderErrorfulBackendsInRepair() {
 	s.setNodeOnError(0, errors.New("some error"))
 	value, err := s.engine.Read(s.nodes, 2, s.readOperator, func(args keyvaluestore.RepairArgs) {
 		s.FailNow("unexpected method call, node 0 is faulty and should not trigger a repair action")
-	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound)
+	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Nil(err)
 	s.Equal(RESULT, value)
 	s.wg.Wait()
@@ -224,7 +440,7 @@ func (s *EngineTestSuite) TestReadShouldConsiderNotFoundErrorInRepair() {
 		s.Equal(2, len(args.Winners))
 		s.Equal(s.node1, args.Losers[0])
 		s.Subset(args.Winners, []keyvaluestore.Backend{s.node2, s.node3})
-	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound)
+	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Nil(err)
 	s.Equal(RESULT, value)
 	s.wg.Wait()
@@ -243,7 +459,7 @@ func (s *EngineTestSuite) TestReadShouldConsiderDifferenetValueInRepair() {
 		s.Equal(2, len(args.Winners))
 		s.Equal(s.node1, args.Losers[0])
 		s.Subset(args.Winners, []keyvaluestore.Backend{s.node2, s.node3})
-	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound)
+	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Nil(err)
 	s.Equal(RESULT, value)
 	s.wg.Wait()
@@ -262,7 +478,7 @@ func (s *EngineTestSuite) TestReadShouldRepairNodesWithValueIfMajorityReportNotF
 		s.Equal(2, len(args.Winners))
 		s.Equal(s.node2, args.Losers[0])
 		s.Subset(args.Winners, []keyvaluestore.Backend{s.node1, s.node2})
-	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound)
+	}, s.comparer, keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Equal(keyvaluestore.ErrNotFound, err)
 	s.wg.Wait()
 	s.assertAllCalled()
@@ -281,7 +497,7 @@ func (s *EngineTestSuite) TestWriteShouldImmediatelyReturnIfAcknowledgeCountIsZe
 func (s *EngineTestSuite) TestReadShouldSkipRepairIfNilIsProvided() {
 	s.setNodeResult(0, RESULT+1)
 	value, err := s.engine.Read(s.nodes, 2, s.readOperator, nil, s.comparer,
-		keyvaluestore.VotingModeVoteOnNotFound)
+		keyvaluestore.VotingModeVoteOnNotFound, nil)
 	s.Nil(err)
 	s.Equal(RESULT, value)
 	s.assertAllCalled()
@@ -292,7 +508,7 @@ func (s *EngineTestSuite) TestReadShouldReturnFirstDataOnVoteModeSkipNotFound()
 	s.setNodeResult(1, RESULT)
 	s.setNodeOnError(2, keyvaluestore.ErrNotFound)
 	value, err := s.engine.Read(s.nodes, 1, s.readOperator, nil, s.comparer,
-		keyvaluestore.VotingModeSkipVoteOnNotFound)
+		keyvaluestore.VotingModeSkipVoteOnNotFound, nil)
 	s.Nil(err)
 	s.Equal(RESULT, value)
 	s.wg.Wait()
@@ -313,7 +529,7 @@ func (s *EngineTestSuite) TestReadShouldRepairOthersWithFirstDataOnVoteModeSkipN
 		s.Equal(2, len(args.Losers))
 		s.Subset(args.Winners, []keyvaluestore.Backend{s.node2})
 		s.Subset(args.Losers, []keyvaluestore.Backend{s.node1, s.node3})
-	}, s.comparer, keyvaluestore.VotingModeSkipVoteOnNotFound)
+	}, s.comparer, keyvaluestore.VotingModeSkipVoteOnNotFound, nil)
 	s.Nil(err)
 	s.wg.Wait()
 	s.assertAllCalled()
@@ -325,7 +541,7 @@ func (s *EngineTestSuite) TestReadShouldReturnNotFoundIfAllVotesAreZeroOnSkipVot
 	s.setNodeOnError(2, keyvaluestore.ErrNotFound)
 	_, err := s.engine.Read(s.nodes, 1, s.readOperator, func(args keyvaluestore.RepairArgs) {
 		s.FailNow("repair should not have been called")
-	}, s.comparer, keyvaluestore.VotingModeSkipVoteOnNotFound)
+	}, s.comparer, keyvaluestore.VotingModeSkipVoteOnNotFound, nil)
 	s.Equal(keyvaluestore.ErrNotFound, err)
 	s.wg.Wait()
 	s.assertAllCalled()
@@ -0,0 +1,69 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+var magic = []byte{0x00, 'K', 'V', 'V', 0x01}
+
+const headerSize = 5 + 8
+
+// Encode prepends a version header to data. Version is usually a timestamp
+// in nanoseconds, which makes newer writes have greater versions.
+func Encode(version int64, data []byte) []byte {
+	result := make([]byte, headerSize+len(data))
+	copy(result, magic)
+	binary.BigEndian.PutUint64(result[len(magic):], uint64(version))
+	copy(result[headerSize:], data)
+
+	return result
+}
+
+// Decode strips the version header from data. Values which were stored
+// without an envelope are returned as is, with a zero version.
+func Decode(data []byte) (int64, []byte) {
+	if len(data) < headerSize || !bytes.HasPrefix(data, magic) {
+		return 0, data
+	}
+
+	return int64(binary.BigEndian.Uint64(data[len(magic):headerSize])), data[headerSize:]
+}
+
+var metadataMagic = []byte{0x00, 'K', 'V', 'M', 0x01}
+
+// EncodeMetadata prepends a header carrying the content type and creation
+// time of data.
+func EncodeMetadata(contentType string, createdAt time.Time, data []byte) []byte {
+	header := make([]byte, len(metadataMagic)+8+binary.MaxVarintLen64)
+	copy(header, metadataMagic)
+	binary.BigEndian.PutUint64(header[len(metadataMagic):], uint64(createdAt.UnixNano()))
+	size := binary.PutUvarint(header[len(metadataMagic)+8:], uint64(len(contentType)))
+
+	result := make([]byte, 0, len(metadataMagic)+8+size+len(contentType)+len(data))
+	result = append(result, header[:len(metadataMagic)+8+size]...)
+	result = append(result, contentType...)
+
+	return append(result, data...)
+}
+
+// DecodeMetadata strips the metadata header from data. Values which were
+// stored without metadata are returned as is, with ok set to false.
+func DecodeMetadata(data []byte) (contentType string, createdAt time.Time, payload []byte, ok bool) {
+	if len(data) < len(metadataMagic)+8 || !bytes.HasPrefix(data, metadataMagic) {
+		return "", time.Time{}, data, false
+	}
+
+	createdAt = time.Unix(0, int64(binary.BigEndian.Uint64(data[len(metadataMagic):])))
+	rest := data[len(metadataMagic)+8:]
+
+	length, size := binary.Uvarint(rest)
+	if size <= 0 || uint64(len(rest)-size) < length {
+		return "", time.Time{}, data, false
+	}
+
+	rest = rest[size:]
+
+	return string(rest[:length]), createdAt, rest[length:], true
+}
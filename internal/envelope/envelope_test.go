@@ -0,0 +1,58 @@
+package envelope_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/internal/envelope"
+	"github.com/stretchr/testify/suite"
+)
+
+type EnvelopeTestSuite struct {
+	suite.Suite
+}
+
+func TestEnvelopeTestSuite(t *testing.T) {
+	suite.Run(t, new(EnvelopeTestSuite))
+}
+
+func (s *EnvelopeTestSuite) TestDecodeShouldReturnEncodedVersionAndData() {
+	version, data := envelope.Decode(envelope.Encode(42, []byte("hello")))
+	s.Equal(int64(42), version)
+	s.Equal("hello", string(data))
+}
+
+func (s *EnvelopeTestSuite) TestDecodeShouldSupportEmptyData() {
+	version, data := envelope.Decode(envelope.Encode(42, nil))
+	s.Equal(int64(42), version)
+	s.Empty(data)
+}
+
+func (s *EnvelopeTestSuite) TestDecodeShouldReturnPlainValuesAsIs() {
+	version, data := envelope.Decode([]byte("hello"))
+	s.Zero(version)
+	s.Equal("hello", string(data))
+}
+
+func (s *EnvelopeTestSuite) TestDecodeMetadataShouldReturnEncodedMetadataAndData() {
+	createdAt := time.Unix(1600000000, 42)
+	contentType, decodedCreatedAt, data, ok := envelope.DecodeMetadata(
+		envelope.EncodeMetadata("application/json", createdAt, []byte("{}")))
+	s.True(ok)
+	s.Equal("application/json", contentType)
+	s.True(createdAt.Equal(decodedCreatedAt))
+	s.Equal("{}", string(data))
+}
+
+func (s *EnvelopeTestSuite) TestDecodeMetadataShouldReturnPlainValuesAsIs() {
+	_, _, data, ok := envelope.DecodeMetadata([]byte("hello"))
+	s.False(ok)
+	s.Equal("hello", string(data))
+}
+
+func (s *EnvelopeTestSuite) TestDecodeMetadataShouldRejectTruncatedHeader() {
+	encoded := envelope.EncodeMetadata("application/json", time.Now(), nil)
+	_, _, data, ok := envelope.DecodeMetadata(encoded[:len(encoded)-4])
+	s.False(ok)
+	s.Equal(encoded[:len(encoded)-4], data)
+}
@@ -0,0 +1,182 @@
+package handoff
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+const defaultMaxHints = 10000
+
+// Store keeps hints, i.e. writes which nodes have missed although the write
+// has been acknowledged by the quorum, and replays them once the nodes respond
+// to pings again. Only the latest hint of each key is kept per node, so hints
+// should only be taken for writes whose latest one wins, such as SET and DEL.
+type Store struct {
+	interval time.Duration
+	maxHints int
+
+	lock  sync.Mutex
+	hints map[keyvaluestore.Backend]map[string]*hint
+
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+type hint struct {
+	operator keyvaluestore.WriteOperator
+}
+
+type Option func(s *Store)
+
+// WithMaxHints sets how many hints are kept per node. Hints of a node which
+// has that many are dropped, leaving the key to read-repair.
+func WithMaxHints(maxHints int) Option {
+	return func(s *Store) {
+		s.maxHints = maxHints
+	}
+}
+
+// New replays hints every interval.
+func New(interval time.Duration, options ...Option) *Store {
+	result := &Store{
+		interval: interval,
+		maxHints: defaultMaxHints,
+		hints:    make(map[keyvaluestore.Backend]map[string]*hint),
+		closed:   make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	return result
+}
+
+func (s *Store) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.closed:
+				return
+
+			case <-ticker.C:
+				s.Replay()
+			}
+		}
+	}()
+}
+
+func (s *Store) Close() error {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+
+	s.wg.Wait()
+
+	return nil
+}
+
+// Hint records that node has missed a write of key, which operator replays.
+// It replaces any previous hint of key for node.
+func (s *Store) Hint(node keyvaluestore.Backend, key string, operator keyvaluestore.WriteOperator) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	hints := s.hints[node]
+	if hints == nil {
+		hints = make(map[string]*hint)
+		s.hints[node] = hints
+	}
+
+	if _, ok := hints[key]; !ok && len(hints) >= s.maxHints {
+		metrics.HintsTotal.WithLabelValues(metrics.HintDropped).Inc()
+		return
+	}
+
+	hints[key] = &hint{operator: operator}
+	metrics.HintsTotal.WithLabelValues(metrics.HintStored).Inc()
+}
+
+// Forget drops the hint of key for node, since a later write of key has
+// reached it.
+func (s *Store) Forget(node keyvaluestore.Backend, key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if hints := s.hints[node]; hints != nil {
+		delete(hints, key)
+	}
+}
+
+// Pending returns the number of hints waiting to be replayed on node.
+func (s *Store) Pending(node keyvaluestore.Backend) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.hints[node])
+}
+
+// Replay writes hints to nodes which respond to a ping. Hints failing again
+// are kept for the next round.
+func (s *Store) Replay() {
+	for _, node := range s.hintedNodes() {
+		if node.Ping() != nil {
+			continue
+		}
+
+		for key, current := range s.nodeHints(node) {
+			if err := current.operator(node); err != nil {
+				break
+			}
+
+			s.lock.Lock()
+			// A newer hint of the key might have arrived in the meantime
+			if s.hints[node][key] == current {
+				delete(s.hints[node], key)
+			}
+			s.lock.Unlock()
+
+			metrics.HintsTotal.WithLabelValues(metrics.HintReplayed).Inc()
+		}
+	}
+}
+
+func (s *Store) hintedNodes() []keyvaluestore.Backend {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []keyvaluestore.Backend
+	for node, hints := range s.hints {
+		if len(hints) == 0 {
+			delete(s.hints, node)
+			continue
+		}
+
+		result = append(result, node)
+	}
+
+	return result
+}
+
+func (s *Store) nodeHints(node keyvaluestore.Backend) map[string]*hint {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := make(map[string]*hint, len(s.hints[node]))
+	for key, current := range s.hints[node] {
+		result[key] = current
+	}
+
+	return result
+}
@@ -0,0 +1,85 @@
+package handoff_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/cafebazaar/keyvalue-store/internal/handoff"
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+const KEY = "key"
+
+type HandoffTestSuite struct {
+	suite.Suite
+
+	node  *keyvaluestore.Mock_Backend
+	store *handoff.Store
+}
+
+func TestHandoffTestSuite(t *testing.T) {
+	suite.Run(t, new(HandoffTestSuite))
+}
+
+func (s *HandoffTestSuite) SetupTest() {
+	s.node = &keyvaluestore.Mock_Backend{}
+	s.store = handoff.New(time.Hour)
+}
+
+func (s *HandoffTestSuite) TestReplayShouldWriteHintsToNodesWhichAreBack() {
+	s.node.On("Ping").Once().Return(nil)
+	s.node.On("Set", KEY, []byte("value"), time.Duration(0)).Once().Return(nil)
+
+	s.store.Hint(s.node, KEY, s.setOperator("value"))
+	s.store.Replay()
+	s.Zero(s.store.Pending(s.node))
+	s.node.AssertExpectations(s.T())
+}
+
+func (s *HandoffTestSuite) TestReplayShouldKeepHintsOfNodesWhichAreDown() {
+	s.node.On("Ping").Once().Return(errors.New("connection refused"))
+
+	s.store.Hint(s.node, KEY, s.setOperator("value"))
+	s.store.Replay()
+	s.Equal(1, s.store.Pending(s.node))
+	s.node.AssertNotCalled(s.T(), "Set", KEY, []byte("value"), time.Duration(0))
+}
+
+func (s *HandoffTestSuite) TestHintShouldReplaceHintOfSameKey() {
+	s.node.On("Ping").Once().Return(nil)
+	s.node.On("Set", KEY, []byte("new"), time.Duration(0)).Once().Return(nil)
+
+	s.store.Hint(s.node, KEY, s.setOperator("old"))
+	s.store.Hint(s.node, KEY, s.setOperator("new"))
+	s.Equal(1, s.store.Pending(s.node))
+	s.store.Replay()
+	s.node.AssertExpectations(s.T())
+}
+
+func (s *HandoffTestSuite) TestForgetShouldDropHintOfKey() {
+	s.store.Hint(s.node, KEY, s.setOperator("value"))
+	s.store.Forget(s.node, KEY)
+	s.Zero(s.store.Pending(s.node))
+}
+
+func (s *HandoffTestSuite) TestHintShouldDropHintsBeyondLimit() {
+	counter := metrics.HintsTotal.WithLabelValues(metrics.HintDropped)
+	before := testutil.ToFloat64(counter)
+
+	s.store = handoff.New(time.Hour, handoff.WithMaxHints(1))
+	s.store.Hint(s.node, KEY, s.setOperator("value"))
+	s.store.Hint(s.node, "other", s.setOperator("value"))
+	s.Equal(1, s.store.Pending(s.node))
+	s.Equal(before+1, testutil.ToFloat64(counter))
+}
+
+func (s *HandoffTestSuite) setOperator(value string) keyvaluestore.WriteOperator {
+	return func(node keyvaluestore.Backend) error {
+		return node.Set(KEY, []byte(value), 0)
+	}
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "keyvaluestore"
+
+const correlationIDLabel = "correlation_id"
+
+const (
+	RepairStaleDelete = "stale-delete"
+	RepairValue       = "value-repair"
+	RepairTTL         = "ttl-repair"
+)
+
+const (
+	RetryRead  = "read"
+	RetryWrite = "write"
+)
+
+const (
+	HintStored   = "stored"
+	HintReplayed = "replayed"
+	HintDropped  = "dropped"
+)
+
+const (
+	ErrorClassTimeout           = "timeout"
+	ErrorClassConnectionRefused = "connection-refused"
+	ErrorClassConnection        = "connection"
+	ErrorClassAuth              = "auth"
+	ErrorClassLogical           = "logical"
+)
+
+var (
+	RepairsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "read_repairs_total",
+		Help:      "Number of read-repairs which wrote to loser nodes, by repair type.",
+	}, []string{"type"})
+
+	DeferredRepairsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "deferred_repairs_total",
+		Help:      "Number of loser nodes left unrepaired by a read because of repair limits.",
+	})
+
+	HintsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hinted_handoff_hints_total",
+		Help:      "Number of writes missed by nodes which have been stored, replayed or dropped as hints.",
+	}, []string{"result"})
+
+	ConsistencyRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "consistency_retries_total",
+		Help:      "Number of operations retried after failing to reach consistency, by operation type.",
+	}, []string{"type"})
+
+	ReadVotesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "read_votes_total",
+		Help:      "Number of read votes, by consistency level and how the vote has been resolved.",
+	}, []string{"consistency", "outcome"})
+
+	SlowOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slow_operations_total",
+		Help:      "Number of service calls which took longer than the slow log threshold, by operation.",
+	}, []string{"operation"})
+
+	NodeErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "node_errors_total",
+		Help:      "Number of failed commands sent to nodes, by node address and error class.",
+	}, []string{"node", "class"})
+
+	NodeCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "node_command_duration_seconds",
+		Help:      "Duration of commands sent to nodes, by node address and command.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16),
+	}, []string{"node", "command"})
+
+	DivergentReplicasTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "divergent_replicas_total",
+		Help:      "Number of out-of-date replicas found by reads of the read-all policy, by node address.",
+	}, []string{"node"})
+
+	TTLDivergenceSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ttl_divergence_seconds",
+		Help:      "Largest difference between TTLs of the same key on different nodes, among the last sampled keys.",
+	})
+
+	RejectedConnectionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rejected_connections_total",
+		Help:      "Number of client connections rejected because of the connection limit.",
+	})
+
+	BufferedWritesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "buffered_writes_total",
+		Help:      "Number of Set requests which went through the write coalescing buffer.",
+	})
+
+	CoalescedWritesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coalesced_writes_total",
+		Help:      "Number of buffered Set requests which joined a pending write instead of writing on their own.",
+	})
+)
+
+// IncWithCorrelationID increments counter, attaching correlationID as an
+// exemplar unless it is empty or too long for one.
+func IncWithCorrelationID(counter prometheus.Counter, correlationID string) {
+	adder, ok := counter.(prometheus.ExemplarAdder)
+	if !ok || correlationID == "" || !utf8.ValidString(correlationID) ||
+		utf8.RuneCountInString(correlationIDLabel+correlationID) > prometheus.ExemplarMaxRunes {
+
+		counter.Inc()
+		return
+	}
+
+	adder.AddWithExemplar(1, prometheus.Labels{correlationIDLabel: correlationID})
+}
@@ -0,0 +1,401 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+const (
+	OperationSet            = "set"
+	OperationMSet           = "mset"
+	OperationGet            = "get"
+	OperationGetMany        = "getmany"
+	OperationDelete         = "delete"
+	OperationDeleteMany     = "deletemany"
+	OperationDeletePattern  = "deletepattern"
+	OperationTransaction    = "transaction"
+	OperationOptimistic     = "optimistic"
+	OperationImport         = "import"
+	OperationExport         = "export"
+	OperationLock           = "lock"
+	OperationUnlock         = "unlock"
+	OperationRenewLock      = "renewlock"
+	OperationAcquire        = "acquire"
+	OperationRelease        = "release"
+	OperationExists         = "exists"
+	OperationGetTTL         = "getttl"
+	OperationExpire         = "expire"
+	OperationTouch          = "touch"
+	OperationRateLimitCheck = "ratelimitcheck"
+	OperationStreamAdd      = "streamadd"
+	OperationStreamRange    = "streamrange"
+	OperationFlushDB        = "flushdb"
+	OperationStats          = "stats"
+	OperationCapabilities   = "capabilities"
+	OperationInspect        = "inspect"
+	OperationWatch          = "watch"
+)
+
+var operations = map[string]bool{
+	OperationSet: true, OperationMSet: true, OperationGet: true, OperationGetMany: true,
+	OperationDelete: true, OperationDeleteMany: true, OperationDeletePattern: true,
+	OperationTransaction: true, OperationOptimistic: true, OperationImport: true, OperationExport: true, OperationLock: true, OperationUnlock: true, OperationRenewLock: true,
+	OperationAcquire: true, OperationRelease: true, OperationExists: true,
+	OperationGetTTL: true, OperationExpire: true, OperationTouch: true, OperationRateLimitCheck: true,
+	OperationStreamAdd: true, OperationStreamRange: true,
+	OperationFlushDB: true, OperationStats: true, OperationCapabilities: true, OperationInspect: true, OperationWatch: true,
+}
+
+// Bucket is a token bucket which holds up to a second worth of tokens, so
+// bursts of up to rate operations are allowed.
+type Bucket struct {
+	lock   sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func NewBucket(opsPerSecond int) *Bucket {
+	return &Bucket{
+		rate:   float64(opsPerSecond),
+		tokens: float64(opsPerSecond),
+		last:   time.Now(),
+	}
+}
+
+func (b *Bucket) Allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+type limitedService struct {
+	keyvaluestore.Service
+
+	global     *Bucket
+	operations map[string]*Bucket
+}
+
+type Option func(s *limitedService)
+
+// WithRate limits the total number of operations per second.
+func WithRate(opsPerSecond int) Option {
+	return func(s *limitedService) {
+		s.global = NewBucket(opsPerSecond)
+	}
+}
+
+// WithOperationRate limits the number of operations per second of a single
+// operation, on top of the total rate.
+func WithOperationRate(operation string, opsPerSecond int) Option {
+	return func(s *limitedService) {
+		if !operations[operation] {
+			logrus.WithField("operation", operation).Panic("unknown operation")
+		}
+
+		s.operations[operation] = NewBucket(opsPerSecond)
+	}
+}
+
+// New wraps service so that operations exceeding the configured rates are
+// rejected with ResourceExhausted instead of reaching the backends.
+func New(service keyvaluestore.Service, options ...Option) keyvaluestore.Service {
+	result := &limitedService{
+		Service:    service,
+		operations: make(map[string]*Bucket),
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	return result
+}
+
+func (s *limitedService) allow(operation string) error {
+	if bucket, ok := s.operations[operation]; ok && !bucket.Allow() {
+		return status.Error(codes.ResourceExhausted, keyvaluestore.ErrRateLimited.Error())
+	}
+
+	if s.global != nil && !s.global.Allow() {
+		return status.Error(codes.ResourceExhausted, keyvaluestore.ErrRateLimited.Error())
+	}
+
+	return nil
+}
+
+func (s *limitedService) Set(ctx context.Context, request *keyvaluestore.SetRequest) error {
+	if err := s.allow(OperationSet); err != nil {
+		return err
+	}
+
+	return s.Service.Set(ctx, request)
+}
+
+func (s *limitedService) MSet(ctx context.Context, request *keyvaluestore.MSetRequest) error {
+	if err := s.allow(OperationMSet); err != nil {
+		return err
+	}
+
+	return s.Service.MSet(ctx, request)
+}
+
+func (s *limitedService) Get(ctx context.Context,
+	request *keyvaluestore.GetRequest) (*keyvaluestore.GetResponse, error) {
+
+	if err := s.allow(OperationGet); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Get(ctx, request)
+}
+
+func (s *limitedService) GetMany(ctx context.Context,
+	request *keyvaluestore.GetManyRequest) (*keyvaluestore.GetManyResponse, error) {
+
+	if err := s.allow(OperationGetMany); err != nil {
+		return nil, err
+	}
+
+	return s.Service.GetMany(ctx, request)
+}
+
+func (s *limitedService) Delete(ctx context.Context, request *keyvaluestore.DeleteRequest) error {
+	if err := s.allow(OperationDelete); err != nil {
+		return err
+	}
+
+	return s.Service.Delete(ctx, request)
+}
+
+func (s *limitedService) DeleteMany(ctx context.Context,
+	request *keyvaluestore.DeleteManyRequest) (*keyvaluestore.DeleteManyResponse, error) {
+
+	if err := s.allow(OperationDeleteMany); err != nil {
+		return nil, err
+	}
+
+	return s.Service.DeleteMany(ctx, request)
+}
+
+func (s *limitedService) DeletePattern(ctx context.Context,
+	request *keyvaluestore.DeletePatternRequest) (*keyvaluestore.DeletePatternResponse, error) {
+
+	if err := s.allow(OperationDeletePattern); err != nil {
+		return nil, err
+	}
+
+	return s.Service.DeletePattern(ctx, request)
+}
+
+func (s *limitedService) Transaction(ctx context.Context, request *keyvaluestore.TransactionRequest) error {
+	if err := s.allow(OperationTransaction); err != nil {
+		return err
+	}
+
+	return s.Service.Transaction(ctx, request)
+}
+
+func (s *limitedService) Optimistic(ctx context.Context, request *keyvaluestore.OptimisticRequest) error {
+	if err := s.allow(OperationOptimistic); err != nil {
+		return err
+	}
+
+	return s.Service.Optimistic(ctx, request)
+}
+
+func (s *limitedService) Import(ctx context.Context,
+	request *keyvaluestore.ImportRequest) (*keyvaluestore.ImportResponse, error) {
+
+	if err := s.allow(OperationImport); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Import(ctx, request)
+}
+
+func (s *limitedService) Export(ctx context.Context,
+	request *keyvaluestore.ExportRequest) (*keyvaluestore.ExportResponse, error) {
+
+	if err := s.allow(OperationExport); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Export(ctx, request)
+}
+
+func (s *limitedService) Lock(ctx context.Context, request *keyvaluestore.LockRequest) error {
+	if err := s.allow(OperationLock); err != nil {
+		return err
+	}
+
+	return s.Service.Lock(ctx, request)
+}
+
+func (s *limitedService) Unlock(ctx context.Context, request *keyvaluestore.UnlockRequest) error {
+	if err := s.allow(OperationUnlock); err != nil {
+		return err
+	}
+
+	return s.Service.Unlock(ctx, request)
+}
+
+func (s *limitedService) RenewLock(ctx context.Context, request *keyvaluestore.RenewLockRequest) error {
+	if err := s.allow(OperationRenewLock); err != nil {
+		return err
+	}
+
+	return s.Service.RenewLock(ctx, request)
+}
+
+func (s *limitedService) Acquire(ctx context.Context, request *keyvaluestore.AcquireRequest) error {
+	if err := s.allow(OperationAcquire); err != nil {
+		return err
+	}
+
+	return s.Service.Acquire(ctx, request)
+}
+
+func (s *limitedService) Release(ctx context.Context, request *keyvaluestore.ReleaseRequest) error {
+	if err := s.allow(OperationRelease); err != nil {
+		return err
+	}
+
+	return s.Service.Release(ctx, request)
+}
+
+func (s *limitedService) Exists(ctx context.Context,
+	request *keyvaluestore.ExistsRequest) (*keyvaluestore.ExistsResponse, error) {
+
+	if err := s.allow(OperationExists); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Exists(ctx, request)
+}
+
+func (s *limitedService) GetTTL(ctx context.Context,
+	request *keyvaluestore.GetTTLRequest) (*keyvaluestore.GetTTLResponse, error) {
+
+	if err := s.allow(OperationGetTTL); err != nil {
+		return nil, err
+	}
+
+	return s.Service.GetTTL(ctx, request)
+}
+
+func (s *limitedService) Expire(ctx context.Context,
+	request *keyvaluestore.ExpireRequest) (*keyvaluestore.ExpireResponse, error) {
+
+	if err := s.allow(OperationExpire); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Expire(ctx, request)
+}
+
+func (s *limitedService) Touch(ctx context.Context,
+	request *keyvaluestore.TouchRequest) (*keyvaluestore.TouchResponse, error) {
+
+	if err := s.allow(OperationTouch); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Touch(ctx, request)
+}
+
+func (s *limitedService) RateLimitCheck(ctx context.Context,
+	request *keyvaluestore.RateLimitCheckRequest) (*keyvaluestore.RateLimitCheckResponse, error) {
+
+	if err := s.allow(OperationRateLimitCheck); err != nil {
+		return nil, err
+	}
+
+	return s.Service.RateLimitCheck(ctx, request)
+}
+
+func (s *limitedService) StreamAdd(ctx context.Context,
+	request *keyvaluestore.StreamAddRequest) (*keyvaluestore.StreamAddResponse, error) {
+
+	if err := s.allow(OperationStreamAdd); err != nil {
+		return nil, err
+	}
+
+	return s.Service.StreamAdd(ctx, request)
+}
+
+func (s *limitedService) StreamRange(ctx context.Context,
+	request *keyvaluestore.StreamRangeRequest) (*keyvaluestore.StreamRangeResponse, error) {
+
+	if err := s.allow(OperationStreamRange); err != nil {
+		return nil, err
+	}
+
+	return s.Service.StreamRange(ctx, request)
+}
+
+func (s *limitedService) FlushDB(ctx context.Context,
+	request *keyvaluestore.FlushDBRequest) (*keyvaluestore.FlushDBResponse, error) {
+
+	if err := s.allow(OperationFlushDB); err != nil {
+		return nil, err
+	}
+
+	return s.Service.FlushDB(ctx, request)
+}
+
+func (s *limitedService) Stats(ctx context.Context) (*keyvaluestore.StatsResponse, error) {
+	if err := s.allow(OperationStats); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Stats(ctx)
+}
+
+func (s *limitedService) Capabilities(ctx context.Context) (*keyvaluestore.CapabilitiesResponse, error) {
+	if err := s.allow(OperationCapabilities); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Capabilities(ctx)
+}
+
+func (s *limitedService) Inspect(ctx context.Context,
+	request *keyvaluestore.InspectRequest) (*keyvaluestore.InspectResponse, error) {
+
+	if err := s.allow(OperationInspect); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Inspect(ctx, request)
+}
+
+func (s *limitedService) Watch(ctx context.Context,
+	request *keyvaluestore.WatchRequest) (<-chan keyvaluestore.Event, error) {
+
+	if err := s.allow(OperationWatch); err != nil {
+		return nil, err
+	}
+
+	return s.Service.Watch(ctx, request)
+}
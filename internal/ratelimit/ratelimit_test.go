@@ -0,0 +1,65 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cafebazaar/keyvalue-store/internal/ratelimit"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type RateLimitTestSuite struct {
+	suite.Suite
+
+	service *keyvaluestore.Mock_Service
+}
+
+func TestRateLimitTestSuite(t *testing.T) {
+	suite.Run(t, new(RateLimitTestSuite))
+}
+
+func (s *RateLimitTestSuite) SetupTest() {
+	s.service = &keyvaluestore.Mock_Service{}
+	s.service.On("Set", mock.Anything, mock.Anything).Return(nil)
+	s.service.On("Get", mock.Anything, mock.Anything).Return(&keyvaluestore.GetResponse{}, nil)
+}
+
+func (s *RateLimitTestSuite) TestShouldRejectOperationsExceedingRate() {
+	svc := ratelimit.New(s.service, ratelimit.WithRate(2))
+
+	s.Nil(svc.Set(context.Background(), &keyvaluestore.SetRequest{}))
+	_, err := svc.Get(context.Background(), &keyvaluestore.GetRequest{})
+	s.Nil(err)
+
+	err = svc.Set(context.Background(), &keyvaluestore.SetRequest{})
+	s.assertStatusCode(err, codes.ResourceExhausted)
+	s.service.AssertNumberOfCalls(s.T(), "Set", 1)
+}
+
+func (s *RateLimitTestSuite) TestOperationRateShouldNotAffectOtherOperations() {
+	svc := ratelimit.New(s.service, ratelimit.WithOperationRate(ratelimit.OperationSet, 1))
+
+	s.Nil(svc.Set(context.Background(), &keyvaluestore.SetRequest{}))
+	s.assertStatusCode(svc.Set(context.Background(), &keyvaluestore.SetRequest{}), codes.ResourceExhausted)
+
+	for i := 0; i < 10; i++ {
+		_, err := svc.Get(context.Background(), &keyvaluestore.GetRequest{})
+		s.Nil(err)
+	}
+}
+
+func (s *RateLimitTestSuite) TestUnknownOperationShouldPanic() {
+	s.Panics(func() {
+		ratelimit.New(s.service, ratelimit.WithOperationRate("unknown", 1))
+	})
+}
+
+func (s *RateLimitTestSuite) assertStatusCode(err error, code codes.Code) {
+	grpcStatus, ok := status.FromError(err)
+	s.True(ok)
+	s.Equal(code, grpcStatus.Code())
+}
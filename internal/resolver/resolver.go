@@ -0,0 +1,89 @@
+package resolver
+
+import (
+	"github.com/cafebazaar/keyvalue-store/internal/envelope"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// LastWriteWins prefers the value with the greatest version stored in its
+// envelope. Values stored without an envelope are considered the oldest.
+func LastWriteWins(values []*keyvaluestore.ValueWithTTL) *keyvaluestore.ValueWithTTL {
+	var winner *keyvaluestore.ValueWithTTL
+	var winnerVersion int64
+
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+
+		version, _ := envelope.Decode(value.Data)
+		if winner == nil || version > winnerVersion {
+			winner = value
+			winnerVersion = version
+		}
+	}
+
+	return winner
+}
+
+// LongestTTL prefers the value which lives longer. Values without a TTL never
+// expire and therefore win over any expiring value.
+func LongestTTL(values []*keyvaluestore.ValueWithTTL) *keyvaluestore.ValueWithTTL {
+	var winner *keyvaluestore.ValueWithTTL
+
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+
+		if winner == nil || outlives(value, winner) {
+			winner = value
+		}
+	}
+
+	return winner
+}
+
+func outlives(x, y *keyvaluestore.ValueWithTTL) bool {
+	if x.TTL == nil {
+		return y.TTL != nil
+	}
+
+	if y.TTL == nil {
+		return false
+	}
+
+	return *x.TTL > *y.TTL
+}
+
+// AnyValue falls back to the value which has been answered first.
+func AnyValue(values []interface{}) (interface{}, bool) {
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	return values[0], true
+}
+
+// NewestValue falls back to the value with the greatest version stored in its
+// envelope. There is no winner if no value carries a version, e.g. values
+// stored without an envelope or results of reads other than values.
+func NewestValue(values []interface{}) (interface{}, bool) {
+	var winner interface{}
+	var winnerVersion int64
+
+	for _, value := range values {
+		data, ok := value.([]byte)
+		if !ok {
+			continue
+		}
+
+		version, _ := envelope.Decode(data)
+		if version > winnerVersion {
+			winner = value
+			winnerVersion = version
+		}
+	}
+
+	return winner, winner != nil
+}
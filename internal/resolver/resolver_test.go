@@ -0,0 +1,79 @@
+package resolver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/internal/envelope"
+	"github.com/cafebazaar/keyvalue-store/internal/resolver"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+	"github.com/stretchr/testify/suite"
+)
+
+type ResolverTestSuite struct {
+	suite.Suite
+}
+
+func TestResolverTestSuite(t *testing.T) {
+	suite.Run(t, new(ResolverTestSuite))
+}
+
+func (s *ResolverTestSuite) TestLongestTTLShouldReturnNilForNoValues() {
+	s.Nil(resolver.LongestTTL(nil))
+}
+
+func (s *ResolverTestSuite) TestLongestTTLShouldPreferLongerTTL() {
+	short := s.makeValue("short", 1*time.Minute)
+	long := s.makeValue("long", 1*time.Hour)
+	s.Equal(long, resolver.LongestTTL([]*keyvaluestore.ValueWithTTL{short, long}))
+	s.Equal(long, resolver.LongestTTL([]*keyvaluestore.ValueWithTTL{long, short}))
+}
+
+func (s *ResolverTestSuite) TestLongestTTLShouldPreferPersistentValues() {
+	long := s.makeValue("long", 1*time.Hour)
+	persistent := &keyvaluestore.ValueWithTTL{Data: []byte("persistent")}
+	s.Equal(persistent, resolver.LongestTTL([]*keyvaluestore.ValueWithTTL{long, persistent}))
+}
+
+func (s *ResolverTestSuite) TestLastWriteWinsShouldPreferNewerVersion() {
+	older := &keyvaluestore.ValueWithTTL{Data: envelope.Encode(1, []byte("older"))}
+	newer := &keyvaluestore.ValueWithTTL{Data: envelope.Encode(2, []byte("newer"))}
+	s.Equal(newer, resolver.LastWriteWins([]*keyvaluestore.ValueWithTTL{older, newer}))
+	s.Equal(newer, resolver.LastWriteWins([]*keyvaluestore.ValueWithTTL{newer, older}))
+}
+
+func (s *ResolverTestSuite) TestLastWriteWinsShouldConsiderPlainValuesOldest() {
+	plain := &keyvaluestore.ValueWithTTL{Data: []byte("plain")}
+	versioned := &keyvaluestore.ValueWithTTL{Data: envelope.Encode(1, []byte("versioned"))}
+	s.Equal(versioned, resolver.LastWriteWins([]*keyvaluestore.ValueWithTTL{plain, versioned}))
+}
+
+func (s *ResolverTestSuite) TestAnyValueShouldPickFirstValue() {
+	value, ok := resolver.AnyValue([]interface{}{[]byte("first"), []byte("second")})
+	s.True(ok)
+	s.Equal([]byte("first"), value)
+
+	_, ok = resolver.AnyValue(nil)
+	s.False(ok)
+}
+
+func (s *ResolverTestSuite) TestNewestValueShouldPreferNewerVersion() {
+	older := envelope.Encode(1, []byte("older"))
+	newer := envelope.Encode(2, []byte("newer"))
+
+	value, ok := resolver.NewestValue([]interface{}{older, []byte("plain"), newer})
+	s.True(ok)
+	s.Equal(newer, value)
+}
+
+func (s *ResolverTestSuite) TestNewestValueShouldFailWithoutVersions() {
+	_, ok := resolver.NewestValue([]interface{}{[]byte("x"), []byte("y")})
+	s.False(ok)
+
+	_, ok = resolver.NewestValue([]interface{}{1, 2})
+	s.False(ok)
+}
+
+func (s *ResolverTestSuite) makeValue(data string, ttl time.Duration) *keyvaluestore.ValueWithTTL {
+	return &keyvaluestore.ValueWithTTL{Data: []byte(data), TTL: &ttl}
+}
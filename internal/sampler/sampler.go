@@ -0,0 +1,156 @@
+package sampler
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+const defaultKeysPerRound = 10
+
+// Sampler periodically reads the TTLs of random keys from every node, and
+// reports how far replicas have drifted apart on expirations, which points
+// to clock skew or replication lag.
+type Sampler struct {
+	nodes        func() []keyvaluestore.Backend
+	interval     time.Duration
+	keysPerRound int
+
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+type Option func(s *Sampler)
+
+// WithKeysPerRound sets how many random keys are compared in each round.
+func WithKeysPerRound(keys int) Option {
+	return func(s *Sampler) {
+		s.keysPerRound = keys
+	}
+}
+
+// New samples members of the cluster returned by nodes, which is called
+// upon every round so that membership changes are followed.
+func New(nodes func() []keyvaluestore.Backend, interval time.Duration, options ...Option) *Sampler {
+	result := &Sampler{
+		nodes:        nodes,
+		interval:     interval,
+		keysPerRound: defaultKeysPerRound,
+		closed:       make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	return result
+}
+
+func (s *Sampler) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.closed:
+				return
+
+			case <-ticker.C:
+				s.Sample()
+			}
+		}
+	}()
+}
+
+func (s *Sampler) Close() error {
+	select {
+	case <-s.closed:
+	default:
+		close(s.closed)
+	}
+
+	s.wg.Wait()
+
+	return nil
+}
+
+// Sample compares the TTLs of random keys across nodes and records the
+// largest difference found. Nodes where a key is missing or never expires
+// are left out of its comparison, since that is a divergence of values
+// rather than of expirations.
+func (s *Sampler) Sample() time.Duration {
+	nodes := s.nodes()
+	if len(nodes) < 2 {
+		metrics.TTLDivergenceSeconds.Set(0)
+		return 0
+	}
+
+	var divergence time.Duration
+	for i := 0; i < s.keysPerRound; i++ {
+		key, err := nodes[rand.Intn(len(nodes))].RandomKey()
+		if err == keyvaluestore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			logrus.WithError(err).Warn("failed to pick a key for TTL sampling")
+			continue
+		}
+
+		if difference := ttlDifference(nodes, key); difference > divergence {
+			divergence = difference
+		}
+	}
+
+	metrics.TTLDivergenceSeconds.Set(divergence.Seconds())
+
+	return divergence
+}
+
+// ttlDifference reads the TTL of key from every node at once, so that the
+// time spent reading does not count as divergence.
+func ttlDifference(nodes []keyvaluestore.Backend, key string) time.Duration {
+	ttls := make([]*time.Duration, len(nodes))
+
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node keyvaluestore.Backend) {
+			defer wg.Done()
+
+			ttl, err := node.TTL(key)
+			if err == nil {
+				ttls[i] = ttl
+			}
+		}(i, node)
+	}
+	wg.Wait()
+
+	var min, max *time.Duration
+	for _, ttl := range ttls {
+		if ttl == nil {
+			continue
+		}
+
+		if min == nil || *ttl < *min {
+			min = ttl
+		}
+		if max == nil || *ttl > *max {
+			max = ttl
+		}
+	}
+
+	if min == nil {
+		return 0
+	}
+
+	return *max - *min
+}
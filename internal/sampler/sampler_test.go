@@ -0,0 +1,64 @@
+package sampler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/internal/sampler"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+const KEY = "key"
+
+type SamplerTestSuite struct {
+	suite.Suite
+
+	node1 *keyvaluestore.Mock_Backend
+	node2 *keyvaluestore.Mock_Backend
+	node3 *keyvaluestore.Mock_Backend
+}
+
+func TestSamplerTestSuite(t *testing.T) {
+	suite.Run(t, new(SamplerTestSuite))
+}
+
+func (s *SamplerTestSuite) SetupTest() {
+	s.node1 = &keyvaluestore.Mock_Backend{}
+	s.node2 = &keyvaluestore.Mock_Backend{}
+	s.node3 = &keyvaluestore.Mock_Backend{}
+
+	for _, node := range s.nodes() {
+		node.(*keyvaluestore.Mock_Backend).On("RandomKey").Return(KEY, nil)
+	}
+}
+
+func (s *SamplerTestSuite) TestShouldRecordLargestTTLDifferenceAcrossNodes() {
+	s.node1.On("TTL", KEY).Return(duration(10*time.Second), nil)
+	s.node2.On("TTL", KEY).Return(duration(7*time.Second), nil)
+	s.node3.On("TTL", KEY).Return(duration(9*time.Second), nil)
+
+	divergence := sampler.New(s.nodes, time.Hour, sampler.WithKeysPerRound(1)).Sample()
+	s.Equal(3*time.Second, divergence)
+	s.Equal(3.0, testutil.ToFloat64(metrics.TTLDivergenceSeconds))
+}
+
+func (s *SamplerTestSuite) TestShouldIgnoreNodesWhereKeyIsMissingOrPersistent() {
+	s.node1.On("TTL", KEY).Return(duration(10*time.Second), nil)
+	s.node2.On("TTL", KEY).Return(nil, keyvaluestore.ErrNotFound)
+	s.node3.On("TTL", KEY).Return(nil, nil)
+
+	divergence := sampler.New(s.nodes, time.Hour, sampler.WithKeysPerRound(1)).Sample()
+	s.Zero(divergence)
+}
+
+func (s *SamplerTestSuite) nodes() []keyvaluestore.Backend {
+	return []keyvaluestore.Backend{s.node1, s.node2, s.node3}
+}
+
+func duration(d time.Duration) *time.Duration {
+	return &d
+}
@@ -0,0 +1,342 @@
+package slowlog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// maxLoggedKeys limits the number of keys logged for multi-key operations.
+const maxLoggedKeys = 10
+
+type slowService struct {
+	keyvaluestore.Service
+
+	threshold time.Duration
+	hashKeys  bool
+}
+
+type Option func(s *slowService)
+
+// WithHashedKeys logs hashes of keys instead of the keys themselves, which
+// might be sensitive.
+func WithHashedKeys(hashKeys bool) Option {
+	return func(s *slowService) {
+		s.hashKeys = hashKeys
+	}
+}
+
+// New wraps service so that calls taking at least threshold are logged along
+// with their keys, consistency and the time spent on each node and phase.
+func New(service keyvaluestore.Service, threshold time.Duration, options ...Option) keyvaluestore.Service {
+	result := &slowService{
+		Service:   service,
+		threshold: threshold,
+	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	return result
+}
+
+func (s *slowService) start(ctx context.Context, operation string) (context.Context, func()) {
+	trace := &Trace{}
+	start := time.Now()
+
+	return NewContext(ctx, trace), func() {
+		duration := time.Since(start)
+		if duration < s.threshold {
+			return
+		}
+
+		correlationID := keyvaluestore.CorrelationIDFromContext(ctx)
+		metrics.IncWithCorrelationID(metrics.SlowOperationsTotal.WithLabelValues(operation), correlationID)
+
+		keys, consistency, timings := trace.snapshot()
+		fields := logrus.Fields{
+			"operation":   operation,
+			"keys":        s.formatKeys(keys),
+			"consistency": consistency.String(),
+			"durationMs":  duration.Milliseconds(),
+			"nodes":       formatTimings(timings),
+		}
+		if correlationID != "" {
+			fields["correlation_id"] = correlationID
+		}
+
+		logrus.WithFields(fields).Warn("slow operation")
+	}
+}
+
+func (s *slowService) formatKeys(keys []string) string {
+	var result []string
+	for i, key := range keys {
+		if i == maxLoggedKeys {
+			result = append(result, fmt.Sprintf("(%v more)", len(keys)-maxLoggedKeys))
+			break
+		}
+
+		if s.hashKeys {
+			hash := sha256.Sum256([]byte(key))
+			key = hex.EncodeToString(hash[:8])
+		}
+
+		result = append(result, key)
+	}
+
+	return strings.Join(result, ",")
+}
+
+func formatTimings(timings []NodeTiming) string {
+	var result []string
+	for _, timing := range timings {
+		item := fmt.Sprintf("%v=%v", timing.Phase, timing.Duration)
+		if timing.Address != "" {
+			item = timing.Address + "/" + item
+		}
+		if timing.Err != nil {
+			item += " (" + timing.Err.Error() + ")"
+		}
+
+		result = append(result, item)
+	}
+
+	return strings.Join(result, ", ")
+}
+
+func (s *slowService) Set(ctx context.Context, request *keyvaluestore.SetRequest) error {
+	ctx, done := s.start(ctx, "set")
+	defer done()
+
+	return s.Service.Set(ctx, request)
+}
+
+func (s *slowService) MSet(ctx context.Context, request *keyvaluestore.MSetRequest) error {
+	ctx, done := s.start(ctx, "mset")
+	defer done()
+
+	return s.Service.MSet(ctx, request)
+}
+
+func (s *slowService) Get(ctx context.Context,
+	request *keyvaluestore.GetRequest) (*keyvaluestore.GetResponse, error) {
+
+	ctx, done := s.start(ctx, "get")
+	defer done()
+
+	return s.Service.Get(ctx, request)
+}
+
+func (s *slowService) GetMany(ctx context.Context,
+	request *keyvaluestore.GetManyRequest) (*keyvaluestore.GetManyResponse, error) {
+
+	ctx, done := s.start(ctx, "getmany")
+	defer done()
+
+	return s.Service.GetMany(ctx, request)
+}
+
+func (s *slowService) Delete(ctx context.Context, request *keyvaluestore.DeleteRequest) error {
+	ctx, done := s.start(ctx, "delete")
+	defer done()
+
+	return s.Service.Delete(ctx, request)
+}
+
+func (s *slowService) DeleteMany(ctx context.Context,
+	request *keyvaluestore.DeleteManyRequest) (*keyvaluestore.DeleteManyResponse, error) {
+
+	ctx, done := s.start(ctx, "deletemany")
+	defer done()
+
+	return s.Service.DeleteMany(ctx, request)
+}
+
+func (s *slowService) DeletePattern(ctx context.Context,
+	request *keyvaluestore.DeletePatternRequest) (*keyvaluestore.DeletePatternResponse, error) {
+
+	ctx, done := s.start(ctx, "deletepattern")
+	defer done()
+
+	return s.Service.DeletePattern(ctx, request)
+}
+
+func (s *slowService) Transaction(ctx context.Context, request *keyvaluestore.TransactionRequest) error {
+	ctx, done := s.start(ctx, "transaction")
+	defer done()
+
+	return s.Service.Transaction(ctx, request)
+}
+
+func (s *slowService) Optimistic(ctx context.Context, request *keyvaluestore.OptimisticRequest) error {
+	ctx, done := s.start(ctx, "optimistic")
+	defer done()
+
+	return s.Service.Optimistic(ctx, request)
+}
+
+func (s *slowService) Import(ctx context.Context,
+	request *keyvaluestore.ImportRequest) (*keyvaluestore.ImportResponse, error) {
+
+	ctx, done := s.start(ctx, "import")
+	defer done()
+
+	return s.Service.Import(ctx, request)
+}
+
+func (s *slowService) Export(ctx context.Context,
+	request *keyvaluestore.ExportRequest) (*keyvaluestore.ExportResponse, error) {
+
+	ctx, done := s.start(ctx, "export")
+	defer done()
+
+	return s.Service.Export(ctx, request)
+}
+
+func (s *slowService) Lock(ctx context.Context, request *keyvaluestore.LockRequest) error {
+	ctx, done := s.start(ctx, "lock")
+	defer done()
+
+	return s.Service.Lock(ctx, request)
+}
+
+func (s *slowService) Unlock(ctx context.Context, request *keyvaluestore.UnlockRequest) error {
+	ctx, done := s.start(ctx, "unlock")
+	defer done()
+
+	return s.Service.Unlock(ctx, request)
+}
+
+func (s *slowService) RenewLock(ctx context.Context, request *keyvaluestore.RenewLockRequest) error {
+	ctx, done := s.start(ctx, "renewlock")
+	defer done()
+
+	return s.Service.RenewLock(ctx, request)
+}
+
+func (s *slowService) Acquire(ctx context.Context, request *keyvaluestore.AcquireRequest) error {
+	ctx, done := s.start(ctx, "acquire")
+	defer done()
+
+	return s.Service.Acquire(ctx, request)
+}
+
+func (s *slowService) Release(ctx context.Context, request *keyvaluestore.ReleaseRequest) error {
+	ctx, done := s.start(ctx, "release")
+	defer done()
+
+	return s.Service.Release(ctx, request)
+}
+
+func (s *slowService) Exists(ctx context.Context,
+	request *keyvaluestore.ExistsRequest) (*keyvaluestore.ExistsResponse, error) {
+
+	ctx, done := s.start(ctx, "exists")
+	defer done()
+
+	return s.Service.Exists(ctx, request)
+}
+
+func (s *slowService) GetTTL(ctx context.Context,
+	request *keyvaluestore.GetTTLRequest) (*keyvaluestore.GetTTLResponse, error) {
+
+	ctx, done := s.start(ctx, "getttl")
+	defer done()
+
+	return s.Service.GetTTL(ctx, request)
+}
+
+func (s *slowService) Expire(ctx context.Context,
+	request *keyvaluestore.ExpireRequest) (*keyvaluestore.ExpireResponse, error) {
+
+	ctx, done := s.start(ctx, "expire")
+	defer done()
+
+	return s.Service.Expire(ctx, request)
+}
+
+func (s *slowService) Touch(ctx context.Context,
+	request *keyvaluestore.TouchRequest) (*keyvaluestore.TouchResponse, error) {
+
+	ctx, done := s.start(ctx, "touch")
+	defer done()
+
+	return s.Service.Touch(ctx, request)
+}
+
+func (s *slowService) RateLimitCheck(ctx context.Context,
+	request *keyvaluestore.RateLimitCheckRequest) (*keyvaluestore.RateLimitCheckResponse, error) {
+
+	ctx, done := s.start(ctx, "ratelimitcheck")
+	defer done()
+
+	return s.Service.RateLimitCheck(ctx, request)
+}
+
+func (s *slowService) StreamAdd(ctx context.Context,
+	request *keyvaluestore.StreamAddRequest) (*keyvaluestore.StreamAddResponse, error) {
+
+	ctx, done := s.start(ctx, "streamadd")
+	defer done()
+
+	return s.Service.StreamAdd(ctx, request)
+}
+
+func (s *slowService) StreamRange(ctx context.Context,
+	request *keyvaluestore.StreamRangeRequest) (*keyvaluestore.StreamRangeResponse, error) {
+
+	ctx, done := s.start(ctx, "streamrange")
+	defer done()
+
+	return s.Service.StreamRange(ctx, request)
+}
+
+func (s *slowService) FlushDB(ctx context.Context,
+	request *keyvaluestore.FlushDBRequest) (*keyvaluestore.FlushDBResponse, error) {
+
+	ctx, done := s.start(ctx, "flushdb")
+	defer done()
+
+	return s.Service.FlushDB(ctx, request)
+}
+
+func (s *slowService) Stats(ctx context.Context) (*keyvaluestore.StatsResponse, error) {
+	ctx, done := s.start(ctx, "stats")
+	defer done()
+
+	return s.Service.Stats(ctx)
+}
+
+func (s *slowService) Capabilities(ctx context.Context) (*keyvaluestore.CapabilitiesResponse, error) {
+	ctx, done := s.start(ctx, "capabilities")
+	defer done()
+
+	return s.Service.Capabilities(ctx)
+}
+
+func (s *slowService) Inspect(ctx context.Context,
+	request *keyvaluestore.InspectRequest) (*keyvaluestore.InspectResponse, error) {
+
+	ctx, done := s.start(ctx, "inspect")
+	defer done()
+
+	return s.Service.Inspect(ctx, request)
+}
+
+// Watch only times starting the watch, since it lasts until ctx is done.
+func (s *slowService) Watch(ctx context.Context, request *keyvaluestore.WatchRequest) (<-chan keyvaluestore.Event, error) {
+	ctx, done := s.start(ctx, "watch")
+	defer done()
+
+	return s.Service.Watch(ctx, request)
+}
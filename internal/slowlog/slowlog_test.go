@@ -0,0 +1,120 @@
+package slowlog_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/internal/slowlog"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	KEY     = "sensitive-key"
+	ADDRESS = "10.0.0.1:6379"
+)
+
+type SlowLogTestSuite struct {
+	suite.Suite
+
+	service *keyvaluestore.Mock_Service
+	hook    *test.Hook
+}
+
+func TestSlowLogTestSuite(t *testing.T) {
+	suite.Run(t, new(SlowLogTestSuite))
+}
+
+func (s *SlowLogTestSuite) SetupTest() {
+	s.service = &keyvaluestore.Mock_Service{}
+	s.hook = test.NewGlobal()
+}
+
+func (s *SlowLogTestSuite) TearDownTest() {
+	logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+}
+
+func (s *SlowLogTestSuite) TestShouldLogSlowOperationWithNodeTimings() {
+	s.service.On("Get", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		trace := slowlog.FromContext(args.Get(0).(context.Context))
+		trace.RecordKey(KEY, keyvaluestore.ConsistencyLevel_MAJORITY)
+		trace.RecordTiming(ADDRESS, slowlog.PhaseRead, 5*time.Millisecond, errors.New("timeout"))
+		time.Sleep(5 * time.Millisecond)
+	}).Return(&keyvaluestore.GetResponse{}, nil)
+
+	svc := slowlog.New(s.service, time.Millisecond)
+	_, err := svc.Get(context.Background(), &keyvaluestore.GetRequest{Key: KEY})
+	s.Nil(err)
+
+	s.Equal(1, len(s.hook.Entries))
+	entry := s.hook.LastEntry()
+	s.Equal(logrus.WarnLevel, entry.Level)
+	s.Equal("get", entry.Data["operation"])
+	s.Equal(KEY, entry.Data["keys"])
+	s.Equal("majority", entry.Data["consistency"])
+	s.Equal(ADDRESS+"/read=5ms (timeout)", entry.Data["nodes"])
+}
+
+func (s *SlowLogTestSuite) TestShouldLogSlowExport() {
+	s.service.On("Export", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		slowlog.FromContext(args.Get(0).(context.Context)).RecordKey(KEY, keyvaluestore.ConsistencyLevel_ONE)
+	}).Return(&keyvaluestore.ExportResponse{}, nil)
+
+	svc := slowlog.New(s.service, 0)
+	_, err := svc.Export(context.Background(), &keyvaluestore.ExportRequest{})
+	s.Nil(err)
+
+	s.Equal(1, len(s.hook.Entries))
+	s.Equal("export", s.hook.LastEntry().Data["operation"])
+	s.Equal(KEY, s.hook.LastEntry().Data["keys"])
+}
+
+func (s *SlowLogTestSuite) TestShouldNotLogFastOperations() {
+	s.service.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	svc := slowlog.New(s.service, time.Hour)
+	s.Nil(svc.Set(context.Background(), &keyvaluestore.SetRequest{Key: KEY}))
+
+	s.Empty(s.hook.Entries)
+}
+
+func (s *SlowLogTestSuite) TestShouldHashKeys() {
+	s.service.On("Set", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		slowlog.FromContext(args.Get(0).(context.Context)).RecordKey(KEY, keyvaluestore.ConsistencyLevel_ALL)
+	}).Return(nil)
+
+	svc := slowlog.New(s.service, 0, slowlog.WithHashedKeys(true))
+	s.Nil(svc.Set(context.Background(), &keyvaluestore.SetRequest{Key: KEY}))
+
+	s.Equal(1, len(s.hook.Entries))
+	keys := s.hook.LastEntry().Data["keys"].(string)
+	s.Len(keys, 16)
+	s.False(strings.Contains(keys, KEY))
+}
+
+func (s *SlowLogTestSuite) TestShouldLogCorrelationID() {
+	s.service.On("Set", mock.Anything, mock.Anything).Return(nil)
+
+	svc := slowlog.New(s.service, 0)
+	ctx := keyvaluestore.NewCorrelationContext(context.Background(), "request-42")
+	s.Nil(svc.Set(ctx, &keyvaluestore.SetRequest{Key: KEY}))
+
+	s.Equal(1, len(s.hook.Entries))
+	s.Equal("request-42", s.hook.LastEntry().Data["correlation_id"])
+}
+
+func (s *SlowLogTestSuite) TestNilTraceShouldIgnoreRecords() {
+	trace := slowlog.FromContext(context.Background())
+	s.Nil(trace)
+
+	s.NotPanics(func() {
+		trace.RecordKey(KEY, keyvaluestore.ConsistencyLevel_ONE)
+		trace.RecordTiming(ADDRESS, slowlog.PhaseWrite, time.Millisecond, nil)
+	})
+}
@@ -0,0 +1,81 @@
+package slowlog
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+const (
+	PhaseRead     = "read"
+	PhaseRepair   = "repair"
+	PhaseWrite    = "write"
+	PhaseRollback = "rollback"
+)
+
+// NodeTiming is the duration of a single phase of an operation, on a single
+// node. Address is empty for phases spanning multiple nodes.
+type NodeTiming struct {
+	Address  string
+	Phase    string
+	Duration time.Duration
+	Err      error
+}
+
+// Trace collects keys, consistency and node timings of a service call. A nil
+// trace ignores everything recorded into it.
+type Trace struct {
+	lock        sync.Mutex
+	keys        []string
+	consistency keyvaluestore.ConsistencyLevel
+	timings     []NodeTiming
+}
+
+type traceKey struct{}
+
+// NewContext returns a context carrying trace, which core records into.
+func NewContext(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+func FromContext(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey{}).(*Trace)
+	return trace
+}
+
+func (t *Trace) RecordKey(key string, consistency keyvaluestore.ConsistencyLevel) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.keys = append(t.keys, key)
+	t.consistency = consistency
+}
+
+func (t *Trace) RecordTiming(address string, phase string, duration time.Duration, err error) {
+	if t == nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.timings = append(t.timings, NodeTiming{
+		Address:  address,
+		Phase:    phase,
+		Duration: duration,
+		Err:      err,
+	})
+}
+
+func (t *Trace) snapshot() ([]string, keyvaluestore.ConsistencyLevel, []NodeTiming) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return append([]string{}, t.keys...), t.consistency, append([]NodeTiming{}, t.timings...)
+}
@@ -0,0 +1,3 @@
+package redis
+
+var ReadCommand = readCommand
@@ -0,0 +1,51 @@
+//go:build go1.18
+// +build go1.18
+
+package redis_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cafebazaar/go-redisproto"
+
+	"github.com/cafebazaar/keyvalue-store/internal/transport/redis"
+)
+
+func FuzzReadCommand(f *testing.F) {
+	seeds := []string{
+		"PING\r\n",
+		"*1\r\n$4\r\nPING\r\n",
+		"*3\r\n$3\r\nSET\r\n$5\r\nmykey\r\n$5\r\nhello\r\n",
+		"*2\r\n$3\r\nGET\r\n$-1\r\n",
+		"*-1\r\n\r\n",
+		"*-5\r\n",
+		"*0\r\n",
+		"\n",
+		"\r\n",
+		"*2\r\n$3\r\nGET",
+		"*2\r\n$3\r\nGET\r\n$5\r\nmy",
+		"*99999999999999999999\r\n",
+		"*1\r\n$-5\r\n",
+		"*1\r\n$99999999999999999999\r\n",
+		fmt.Sprintf("*1\r\n$%d\r\n", redisproto.MaxBulkSize+1),
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parser := redisproto.NewParser(bytes.NewReader(data))
+		for {
+			command, err := redis.ReadCommand(parser)
+			if err != nil {
+				return
+			}
+
+			if command == nil || command.ArgCount() == 0 {
+				t.Fatalf("got an empty command without an error for %q", data)
+			}
+		}
+	})
+}
@@ -2,16 +2,21 @@ package redis
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/cafebazaar/keyvalue-store/internal/metrics"
 	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
@@ -23,6 +28,8 @@ import (
 const (
 	defaultTimeout           = 1 * time.Second
 	defaultConnectionTimeout = 30 * time.Second
+
+	unixSocketScheme = "unix://"
 )
 
 type redisServer struct {
@@ -33,6 +40,92 @@ type redisServer struct {
 	wg                sync.WaitGroup
 	listener          net.Listener
 	connectionTimeout time.Duration
+	connections       sync.WaitGroup
+	connectionsLock   sync.Mutex
+	activeConnections map[net.Conn]struct{}
+	draining          chan struct{}
+	drainOnce         sync.Once
+	maxConnections    int
+	listenSocket      string
+}
+
+type Option func(s *redisServer)
+
+// WithMaxConnections limits the number of concurrent client connections,
+// further connections are rejected the same way redis rejects them once
+// maxclients is reached. Zero means no limit.
+func WithMaxConnections(maxConnections int) Option {
+	return func(s *redisServer) {
+		s.maxConnections = maxConnections
+	}
+}
+
+// WithListenSocket listens on a UNIX domain socket given as unix://<path>
+// instead of the TCP port. A stale socket file left by a previous process is
+// replaced, and the socket file is removed once the server is closed.
+func WithListenSocket(address string) Option {
+	return func(s *redisServer) {
+		s.listenSocket = address
+	}
+}
+
+// connectionSession holds state of a single client connection, which might be
+// altered by the client using CONSISTENCY command.
+type connectionSession struct {
+	readConsistency  keyvaluestore.ConsistencyLevel
+	writeConsistency keyvaluestore.ConsistencyLevel
+
+	// readRequiredNodes and writeRequiredNodes are set by CONSISTENCY with a
+	// number of nodes, which takes precedence over the consistency levels.
+	readRequiredNodes  int
+	writeRequiredNodes int
+
+	// events is set once the connection has subscribed to key changes using
+	// SUBSCRIBE or PSUBSCRIBE, after which only events are sent to it.
+	events      chan subscriptionEvent
+	cancelWatch context.CancelFunc
+
+	// token is the session token set by the SESSION command.
+	token string
+
+	// staleReads is set by CONSISTENCY STALE ON, which makes GET serve
+	// stale values while revalidating them in the background.
+	staleReads bool
+
+	// correlationID is set by the CORRELATION command, and attached to logs
+	// and metrics of later commands.
+	correlationID string
+}
+
+// context returns the base context of commands of the connection.
+func (c *connectionSession) context() context.Context {
+	if c.correlationID == "" {
+		return context.Background()
+	}
+
+	return keyvaluestore.NewCorrelationContext(context.Background(), c.correlationID)
+}
+
+// subscriptionEvent is an event along with the pattern it has been
+// subscribed by, which is empty for SUBSCRIBE.
+type subscriptionEvent struct {
+	pattern string
+	event   keyvaluestore.Event
+}
+
+var (
+	errMalformedCommand = errors.New("malformed command")
+	errEmptyCommand     = errors.New("empty command")
+	errMaxConnections   = errors.New("ERR max number of clients reached")
+)
+
+// protocolError reports malformed input from the client.
+type protocolError struct {
+	err error
+}
+
+func (e *protocolError) Error() string {
+	return "ERR Protocol error: " + e.err.Error()
 }
 
 type commandExecutionError struct {
@@ -56,25 +149,33 @@ func wrapError(err error) error {
 func New(core keyvaluestore.Service, listenPort int,
 	connectionTimeout time.Duration,
 	readConsistency keyvaluestore.ConsistencyLevel,
-	writeConsistency keyvaluestore.ConsistencyLevel) keyvaluestore.Server {
+	writeConsistency keyvaluestore.ConsistencyLevel, options ...Option) keyvaluestore.Server {
 
 	if connectionTimeout == 0 {
 		connectionTimeout = defaultConnectionTimeout
 	}
 
-	return &redisServer{
+	result := &redisServer{
 		core:              core,
 		listenPort:        listenPort,
 		readConsistency:   readConsistency,
 		writeConsistency:  writeConsistency,
 		connectionTimeout: connectionTimeout,
+		activeConnections: make(map[net.Conn]struct{}),
+		draining:          make(chan struct{}),
 	}
+
+	for _, option := range options {
+		option(result)
+	}
+
+	return result
 }
 
 func (s *redisServer) Start() error {
 	var err error
 
-	s.listener, err = net.Listen("tcp", fmt.Sprintf(":%d", s.listenPort))
+	s.listener, err = s.listen()
 	if err != nil {
 		return err
 	}
@@ -91,6 +192,11 @@ func (s *redisServer) Start() error {
 				return
 			}
 
+			if !s.trackConnection(conn) {
+				s.rejectConnection(conn)
+				continue
+			}
+
 			go s.handleConnection(conn)
 		}
 	}()
@@ -99,25 +205,157 @@ func (s *redisServer) Start() error {
 	return nil
 }
 
+func (s *redisServer) listen() (net.Listener, error) {
+	if s.listenSocket == "" {
+		return net.Listen("tcp", fmt.Sprintf(":%d", s.listenPort))
+	}
+
+	if !strings.HasPrefix(s.listenSocket, unixSocketScheme) {
+		return nil, fmt.Errorf("unsupported listen socket %v, expected %v<path>",
+			s.listenSocket, unixSocketScheme)
+	}
+
+	path := strings.TrimPrefix(s.listenSocket, unixSocketScheme)
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		// Only sockets left behind by crashed processes are stale, others
+		// are still served by a running one.
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("listen socket %v is in use", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	// Closing a listener created by net.Listen removes its socket file.
+	return net.Listen("unix", path)
+}
+
 func (s *redisServer) Close() error {
 	err := s.listener.Close()
 	s.wg.Wait()
 	return err
 }
 
+func (s *redisServer) Shutdown(ctx context.Context) error {
+	s.drainOnce.Do(func() {
+		close(s.draining)
+	})
+
+	err := s.listener.Close()
+	s.wg.Wait()
+
+	// Wake up idle connections which are blocked on reading the next command,
+	// connections in the middle of executing a command finish it first.
+	s.connectionsLock.Lock()
+	for conn := range s.activeConnections {
+		if deadlineErr := conn.SetReadDeadline(time.Now()); deadlineErr != nil {
+			logrus.WithError(deadlineErr).Info("unexpected error while draining connection")
+		}
+	}
+	s.connectionsLock.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.connections.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return err
+
+	case <-ctx.Done():
+		s.connectionsLock.Lock()
+		for conn := range s.activeConnections {
+			if closeErr := conn.Close(); closeErr != nil {
+				logrus.WithError(closeErr).Info("unexpected error while closing connection")
+			}
+		}
+		s.connectionsLock.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+func (s *redisServer) trackConnection(conn net.Conn) bool {
+	s.connectionsLock.Lock()
+	defer s.connectionsLock.Unlock()
+
+	if s.maxConnections > 0 && len(s.activeConnections) >= s.maxConnections {
+		return false
+	}
+
+	s.activeConnections[conn] = struct{}{}
+	s.connections.Add(1)
+	return true
+}
+
+func (s *redisServer) rejectConnection(conn net.Conn) {
+	metrics.RejectedConnectionsTotal.Inc()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(defaultTimeout)); err == nil {
+		_, _ = conn.Write([]byte("-" + errMaxConnections.Error() + "\r\n"))
+	}
+
+	if err := conn.Close(); err != nil {
+		logrus.WithError(err).Info("unexpected error while rejecting connection")
+	}
+}
+
+func (s *redisServer) untrackConnection(conn net.Conn) {
+	s.connectionsLock.Lock()
+	defer s.connectionsLock.Unlock()
+
+	delete(s.activeConnections, conn)
+	s.connections.Done()
+}
+
+func (s *redisServer) isDraining() bool {
+	select {
+	case <-s.draining:
+		return true
+
+	default:
+		return false
+	}
+}
+
 func (s *redisServer) handleConnection(conn net.Conn) {
+	defer s.untrackConnection(conn)
 	defer func() {
-		if err := conn.Close(); err != nil {
+		if err := conn.Close(); err != nil && !s.isDraining() {
 			logrus.WithError(err).Info("unexpected error while closing connection")
 		}
 	}()
 
 	parser := redisproto.NewParser(conn)
 	writer := redisproto.NewWriter(bufio.NewWriter(conn))
+	session := &connectionSession{
+		readConsistency:  s.readConsistency,
+		writeConsistency: s.writeConsistency,
+	}
 
 	for {
-		if err := s.connectionLoopWithTimeout(parser, writer); err != nil {
-			if err != keyvaluestore.ErrClosed {
+		if s.isDraining() {
+			if err := writer.Flush(); err != nil {
+				logrus.WithError(err).Info("unexpected error while draining connection")
+			}
+
+			return
+		}
+
+		var err error
+		if session.events != nil {
+			err = s.subscriptionLoop(session, parser, writer)
+		} else {
+			err = s.connectionLoopWithTimeout(session, parser, writer)
+		}
+
+		if err != nil {
+			if err != keyvaluestore.ErrClosed && !s.isDraining() {
 				logrus.WithError(err).Error("unexpected error while handling connection")
 			}
 
@@ -126,14 +364,105 @@ func (s *redisServer) handleConnection(conn net.Conn) {
 	}
 }
 
-func (s *redisServer) connectionLoopWithTimeout(parser *redisproto.Parser, writer *redisproto.Writer) error {
+// subscriptionLoop streams events to a subscribed connection until either
+// side closes it. Subscribed connections are exempt from the connection
+// timeout, and only accept PING.
+func (s *redisServer) subscriptionLoop(session *connectionSession,
+	parser *redisproto.Parser, writer *redisproto.Writer) error {
+
+	defer session.cancelWatch()
+
+	var lock sync.Mutex
+	errChannel := make(chan error, 1)
+
+	go func() {
+		for {
+			command, err := readCommand(parser)
+			if err == nil {
+				lock.Lock()
+				err = s.dispatchSubscribedCommand(command, writer)
+				lock.Unlock()
+			}
+
+			if err != nil {
+				errChannel <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-session.events:
+			if !ok {
+				return keyvaluestore.ErrClosed
+			}
+
+			lock.Lock()
+			err := s.writeSubscriptionEvent(event, writer)
+			lock.Unlock()
+
+			if err != nil {
+				return err
+			}
+
+		case err := <-errChannel:
+			if err == io.EOF {
+				return keyvaluestore.ErrClosed
+			}
+
+			return err
+
+		case <-s.draining:
+			return keyvaluestore.ErrClosed
+		}
+	}
+}
+
+func (s *redisServer) dispatchSubscribedCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+	cmd := strings.ToUpper(string(command.Get(0)))
+
+	var err error
+	if cmd == "PING" {
+		err = writer.WriteObjects("pong", string(command.Get(1)))
+	} else {
+		err = writer.WriteError(fmt.Sprintf("only PING is allowed while subscribed, got %v", cmd))
+	}
+	if err != nil {
+		return err
+	}
+
+	if command.IsLast() {
+		return writer.Flush()
+	}
+
+	return nil
+}
+
+func (s *redisServer) writeSubscriptionEvent(event subscriptionEvent, writer *redisproto.Writer) error {
+	var err error
+	if event.pattern == "" {
+		err = writer.WriteObjects("message", event.event.Key, event.event.Type.String())
+	} else {
+		err = writer.WriteObjects("pmessage", event.pattern, event.event.Key, event.event.Type.String())
+	}
+	if err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+func (s *redisServer) connectionLoopWithTimeout(session *connectionSession,
+	parser *redisproto.Parser, writer *redisproto.Writer) error {
+
 	connectionTimeoutTicker := time.NewTicker(s.connectionTimeout)
 	defer connectionTimeoutTicker.Stop()
 
 	errChannel := make(chan error, 1)
 
 	go func() {
-		errChannel <- s.connectionLoop(parser, writer)
+		errChannel <- s.connectionLoop(session, parser, writer)
 	}()
 
 	select {
@@ -145,94 +474,151 @@ func (s *redisServer) connectionLoopWithTimeout(parser *redisproto.Parser, write
 	}
 }
 
-func (s *redisServer) connectionLoop(parser *redisproto.Parser, writer *redisproto.Writer) error {
-	command, err := parser.ReadCommand()
-	if err != nil {
-		_, ok := err.(*redisproto.ProtocolError)
-		if ok {
-			logrus.WithError(err).Error("unexpected protocol error")
-
-			return writer.WriteError(err.Error())
+// readCommand reads the next command of the connection. The parser panics or
+// returns no command upon some malformed input, which is reported as a
+// protocol error along with the errors the parser reports by itself.
+func readCommand(parser *redisproto.Parser) (command *redisproto.Command, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			command, err = nil, &protocolError{err: errMalformedCommand}
 		}
+	}()
 
-		if err == io.EOF {
-			return keyvaluestore.ErrClosed
+	command, err = parser.ReadCommand()
+	if err != nil {
+		if isParserError(err) {
+			return nil, &protocolError{err: err}
 		}
 
-		return err
+		return nil, err
 	}
 
-	return s.dispatchCommand(command, writer)
-}
-
-func (s *redisServer) dispatchCommand(command *redisproto.Command, writer *redisproto.Writer) error {
-	cmd := strings.ToUpper(string(command.Get(0)))
-	var err error
-
-	switch cmd {
-	case "SET":
-		err = s.handleSetCommand(command, writer)
+	if command == nil || command.ArgCount() == 0 {
+		return nil, &protocolError{err: errEmptyCommand}
+	}
 
-	case "DEL":
-		err = s.handleDeleteCommand(command, writer)
+	return command, nil
+}
 
-	case "GET":
-		err = s.handleGetCommand(command, writer)
+func isParserError(err error) bool {
+	if _, ok := err.(*redisproto.ProtocolError); ok {
+		return true
+	}
 
-	case "MGET":
-		err = s.handlerMGetCommand(command, writer)
+	return errors.Is(err, redisproto.InvalidNumArg) || errors.Is(err, redisproto.InvalidBulkSize) ||
+		errors.Is(err, redisproto.LineTooLong)
+}
 
-	case "MSET":
-		err = s.handleMSetCommand(command, writer)
+func (s *redisServer) connectionLoop(session *connectionSession,
+	parser *redisproto.Parser, writer *redisproto.Writer) error {
 
-	case "PING":
-		err = s.handlePingCommand(command, writer)
+	command, err := readCommand(parser)
+	if err != nil {
+		if _, ok := err.(*protocolError); ok {
+			logrus.WithError(err).Error("unexpected protocol error")
 
-	case "ECHO":
-		err = s.handleEchoCommand(command, writer)
+			// The parser cannot recover from malformed input, so the
+			// connection is closed same as redis does
+			if err := writer.WriteError(err.Error()); err != nil {
+				return err
+			}
+			if err := writer.Flush(); err != nil {
+				return err
+			}
 
-	case "SETNX":
-		err = s.handleSetNXCommand(command, writer)
+			return keyvaluestore.ErrClosed
+		}
 
-	case "SETEX":
-		err = s.handleSetEXCommand(command, writer)
+		if err == io.EOF {
+			return keyvaluestore.ErrClosed
+		}
 
-	case "EXISTS":
-		err = s.handleExistsCommand(command, writer)
+		return err
+	}
 
-	case "TTL":
-		err = s.handleTTLCommand(command, writer)
+	return s.dispatchCommand(session, command, writer)
+}
 
-	case "PTTL":
-		err = s.handlePTTLCommand(command, writer)
+// commandHandler serves a single redis command of a connection.
+type commandHandler func(s *redisServer, session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error
+
+// commandTable maps every supported redis command to its handler. Commands
+// missing from it are answered with an unknown command error.
+var commandTable map[string]commandHandler
+
+func init() {
+	commandTable = map[string]commandHandler{
+		"SET":         (*redisServer).handleSetCommand,
+		"DEL":         (*redisServer).handleDeleteCommand,
+		"GETDEL":      (*redisServer).handleGetDelCommand,
+		"GET":         (*redisServer).handleGetCommand,
+		"MGET":        (*redisServer).handlerMGetCommand,
+		"MSET":        (*redisServer).handleMSetCommand,
+		"PING":        (*redisServer).handlePingCommand,
+		"ECHO":        (*redisServer).handleEchoCommand,
+		"SETNX":       (*redisServer).handleSetNXCommand,
+		"SETEX":       (*redisServer).handleSetEXCommand,
+		"EXISTS":      (*redisServer).handleExistsCommand,
+		"TTL":         (*redisServer).handleTTLCommand,
+		"PTTL":        (*redisServer).handlePTTLCommand,
+		"EXPIRE":      expireCommand("EXPIRE", true, false),
+		"PEXPIRE":     expireCommand("PEXPIRE", false, false),
+		"EXPIREAT":    expireCommand("EXPIREAT", true, true),
+		"PEXPIREAT":   expireCommand("PEXPIREAT", false, true),
+		"SELECT":      (*redisServer).handleSelectCommand,
+		"FLUSHDB":     (*redisServer).handleFlushDbCommand,
+		"DELPATTERN":  (*redisServer).handleDeletePatternCommand,
+		"CONSISTENCY": (*redisServer).handleConsistencyCommand,
+		"SESSION":     (*redisServer).handleSessionCommand,
+		"CORRELATION": (*redisServer).handleCorrelationCommand,
+		"SUBSCRIBE":   subscribeCommand(false),
+		"PSUBSCRIBE":  subscribeCommand(true),
+		"COMMAND":     (*redisServer).handleCommandCommand,
+		"XADD":        (*redisServer).handleXAddCommand,
+		"XRANGE":      (*redisServer).handleXRangeCommand,
+	}
+}
 
-	case "EXPIRE":
-		err = s.handleExpireCommand(command, writer, "EXPIRE", true, false)
+func expireCommand(name string, seconds bool, absolute bool) commandHandler {
+	return func(s *redisServer, session *connectionSession,
+		command *redisproto.Command, writer *redisproto.Writer) error {
 
-	case "PEXPIRE":
-		err = s.handleExpireCommand(command, writer, "PEXPIRE", false, false)
+		return s.handleExpireCommand(session, command, writer, name, seconds, absolute)
+	}
+}
 
-	case "EXPIREAT":
-		err = s.handleExpireCommand(command, writer, "EXPIREAT", true, true)
+func subscribeCommand(pattern bool) commandHandler {
+	return func(s *redisServer, session *connectionSession,
+		command *redisproto.Command, writer *redisproto.Writer) error {
 
-	case "PEXPIREAT":
-		err = s.handleExpireCommand(command, writer, "PEXPIREAT", false, true)
+		return s.handleSubscribeCommand(session, command, writer, pattern)
+	}
+}
 
-	case "SELECT":
-		err = s.handleSelectCommand(command, writer)
+func (s *redisServer) dispatchCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
 
-	case "FLUSHDB":
-		err = s.handleFlushDbCommand(command, writer)
+	cmd := strings.ToUpper(string(command.Get(0)))
+	var err error
 
-	default:
+	handler, ok := commandTable[cmd]
+	if ok {
+		err = handler(s, session, command, writer)
+	} else {
 		logrus.WithField("cmd", cmd).Error("command not supported")
 
-		err = wrapStringAsError("command not supported: %v", cmd)
+		err = unknownCommandError(command)
 	}
 
 	if err != nil {
 		if execErr, ok := err.(*commandExecutionError); ok {
-			logrus.WithError(execErr.err).WithField("cmd", cmd).Error(execErr.Error())
+			entry := logrus.WithError(execErr.err).WithField("cmd", cmd)
+			if session.correlationID != "" {
+				entry = entry.WithField("correlation_id", session.correlationID)
+			}
+
+			entry.Error(execErr.Error())
 
 			err = writer.WriteError(execErr.Error())
 			if err != nil {
@@ -250,11 +636,14 @@ func (s *redisServer) dispatchCommand(command *redisproto.Command, writer *redis
 	return nil
 }
 
-func (s *redisServer) handleSetCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleSetCommand(session *connectionSession, command *redisproto.Command, writer *redisproto.Writer) error {
 	key := string(command.Get(1))
 	value := command.Get(2)
 	var expiration time.Duration
 	nx := false
+	persistent := false
+	keepTTL := false
+	var acknowledgement *keyvaluestore.WriteAcknowledgement
 
 	if command.ArgCount() < 3 {
 		return wrapStringAsError("expected at least 3 arguments for SET command")
@@ -289,6 +678,18 @@ func (s *redisServer) handleSetCommand(command *redisproto.Command, writer *redi
 		case "NX":
 			nx = true
 
+		// PERSIST is not a redis argument, it opts out of the default write TTL
+		case "PERSIST":
+			persistent = true
+
+		case "KEEPTTL":
+			keepTTL = true
+
+		// ACKS is not a redis argument either, it replies with the number of
+		// nodes which acknowledged the write, followed by the number of nodes
+		case "ACKS":
+			acknowledgement = &keyvaluestore.WriteAcknowledgement{}
+
 		default:
 			logrus.WithField("arg", arg).Error("unsupported SET argument")
 
@@ -298,34 +699,51 @@ func (s *redisServer) handleSetCommand(command *redisproto.Command, writer *redi
 
 	var err error
 
+	if nx && acknowledgement != nil {
+		return wrapStringAsError("ACKS is not supported along with NX in SET")
+	}
+
+	if nx && keepTTL {
+		return wrapStringAsError("KEEPTTL is not supported along with NX in SET")
+	}
+
 	if !nx {
 		request := &keyvaluestore.SetRequest{
-			Key:        key,
-			Data:       value,
-			Expiration: expiration,
+			Key:             key,
+			Data:            value,
+			Expiration:      expiration,
+			Persistent:      persistent,
+			KeepTTL:         keepTTL,
+			Acknowledgement: acknowledgement,
 			Options: keyvaluestore.WriteOptions{
-				Consistency: s.writeConsistency,
+				Consistency:   session.writeConsistency,
+				RequiredNodes: session.writeRequiredNodes,
+				Session:       session.token,
 			},
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 		defer cancel()
 
 		err = s.core.Set(ctx, request)
 		if err != nil {
 			return wrapError(err)
 		}
+
+		if acknowledgement != nil {
+			return writer.WriteObjects(acknowledgement.Acknowledged, acknowledgement.Nodes)
+		}
 	} else {
 		request := &keyvaluestore.LockRequest{
 			Key:        key,
 			Expiration: expiration,
 			Data:       value,
 			Options: keyvaluestore.WriteOptions{
-				Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+				Consistency: keyvaluestore.ConsistencyLevel_DEFAULT,
 			},
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+		ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 		defer cancel()
 
 		err = s.core.Lock(ctx, request)
@@ -342,7 +760,7 @@ func (s *redisServer) handleSetCommand(command *redisproto.Command, writer *redi
 	return writer.WriteBulkString("OK")
 }
 
-func (s *redisServer) handlePTTLCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handlePTTLCommand(session *connectionSession, command *redisproto.Command, writer *redisproto.Writer) error {
 	if command.ArgCount() != 2 {
 		return wrapStringAsError("expected exactly 2 arguments for PTTL command")
 	}
@@ -351,11 +769,13 @@ func (s *redisServer) handlePTTLCommand(command *redisproto.Command, writer *red
 	request := &keyvaluestore.GetTTLRequest{
 		Key: key,
 		Options: keyvaluestore.ReadOptions{
-			Consistency: s.readConsistency,
+			Consistency:   session.readConsistency,
+			RequiredNodes: session.readRequiredNodes,
+			Session:       session.token,
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 	defer cancel()
 
 	response, err := s.core.GetTTL(ctx, request)
@@ -375,7 +795,7 @@ func (s *redisServer) handlePTTLCommand(command *redisproto.Command, writer *red
 	return writer.WriteInt(int64(*response.TTL) / int64(time.Millisecond))
 }
 
-func (s *redisServer) handleTTLCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleTTLCommand(session *connectionSession, command *redisproto.Command, writer *redisproto.Writer) error {
 	if command.ArgCount() != 2 {
 		return wrapStringAsError("expected exactly 2 arguments for TTL command")
 	}
@@ -384,11 +804,13 @@ func (s *redisServer) handleTTLCommand(command *redisproto.Command, writer *redi
 	request := &keyvaluestore.GetTTLRequest{
 		Key: key,
 		Options: keyvaluestore.ReadOptions{
-			Consistency: s.readConsistency,
+			Consistency:   session.readConsistency,
+			RequiredNodes: session.readRequiredNodes,
+			Session:       session.token,
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 	defer cancel()
 
 	response, err := s.core.GetTTL(ctx, request)
@@ -409,6 +831,7 @@ func (s *redisServer) handleTTLCommand(command *redisproto.Command, writer *redi
 }
 
 func (s *redisServer) handleExpireCommand(
+	session *connectionSession,
 	command *redisproto.Command,
 	writer *redisproto.Writer,
 	cmd string,
@@ -444,11 +867,13 @@ func (s *redisServer) handleExpireCommand(
 		Key:        key,
 		Expiration: duration,
 		Options: keyvaluestore.WriteOptions{
-			Consistency: s.readConsistency,
+			Consistency:   session.writeConsistency,
+			RequiredNodes: session.writeRequiredNodes,
+			Session:       session.token,
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 	defer cancel()
 
 	result, err := s.core.Expire(ctx, request)
@@ -462,7 +887,7 @@ func (s *redisServer) handleExpireCommand(
 	return writer.WriteInt(0)
 }
 
-func (s *redisServer) handleExistsCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleExistsCommand(session *connectionSession, command *redisproto.Command, writer *redisproto.Writer) error {
 	if command.ArgCount() < 2 {
 		return wrapStringAsError("expected at least 2 arguments for EXISTS command")
 	}
@@ -471,7 +896,7 @@ func (s *redisServer) handleExistsCommand(command *redisproto.Command, writer *r
 	var wg sync.WaitGroup
 	errorChannel := make(chan error, 1)
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 	defer cancel()
 
 	for i := 1; i < command.ArgCount(); i++ {
@@ -483,7 +908,9 @@ func (s *redisServer) handleExistsCommand(command *redisproto.Command, writer *r
 			request := &keyvaluestore.ExistsRequest{
 				Key: key,
 				Options: keyvaluestore.ReadOptions{
-					Consistency: s.readConsistency,
+					Consistency:   session.readConsistency,
+					RequiredNodes: session.readRequiredNodes,
+					Session:       session.token,
 				},
 			}
 
@@ -519,7 +946,7 @@ func (s *redisServer) handleExistsCommand(command *redisproto.Command, writer *r
 	}
 }
 
-func (s *redisServer) handleMSetCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleMSetCommand(session *connectionSession, command *redisproto.Command, writer *redisproto.Writer) error {
 	if command.ArgCount() < 3 {
 		return wrapStringAsError("expected at least 3 arguments for MSET command")
 	}
@@ -528,49 +955,32 @@ func (s *redisServer) handleMSetCommand(command *redisproto.Command, writer *red
 		return wrapStringAsError("key-value pairs for MSET command")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 	defer cancel()
 
-	var wg sync.WaitGroup
-	errorChannel := make(chan error, 1)
+	request := &keyvaluestore.MSetRequest{
+		Options: keyvaluestore.WriteOptions{
+			Consistency:   session.writeConsistency,
+			RequiredNodes: session.writeRequiredNodes,
+			Session:       session.token,
+		},
+	}
 
 	for i := 2; i < command.ArgCount(); i += 2 {
-		key := string(command.Get(i - 1))
-		value := command.Get(i)
-		wg.Add(1)
-
-		go func(targetKey string, targetValue []byte) {
-			defer wg.Done()
-
-			request := &keyvaluestore.SetRequest{
-				Key:  targetKey,
-				Data: targetValue,
-				Options: keyvaluestore.WriteOptions{
-					Consistency: s.writeConsistency,
-				},
-			}
-			err := s.core.Set(ctx, request)
-			if err != nil {
-				select {
-				case errorChannel <- wrapError(err):
-				default:
-				}
-			}
-		}(key, value)
+		request.Items = append(request.Items, keyvaluestore.KeyValue{
+			Key:  string(command.Get(i - 1)),
+			Data: command.Get(i),
+		})
 	}
 
-	wg.Wait()
-
-	select {
-	case err := <-errorChannel:
-		return err
-
-	default:
-		return writer.WriteBulkString("OK")
+	if err := s.core.MSet(ctx, request); err != nil {
+		return wrapError(err)
 	}
+
+	return writer.WriteBulkString("OK")
 }
 
-func (s *redisServer) handleSetEXCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleSetEXCommand(session *connectionSession, command *redisproto.Command, writer *redisproto.Writer) error {
 	if command.ArgCount() < 4 {
 		return wrapStringAsError("expected at least 4 arguments for SETEX command")
 	}
@@ -588,11 +998,13 @@ func (s *redisServer) handleSetEXCommand(command *redisproto.Command, writer *re
 		Data:       value,
 		Expiration: expiration,
 		Options: keyvaluestore.WriteOptions{
-			Consistency: s.writeConsistency,
+			Consistency:   session.writeConsistency,
+			RequiredNodes: session.writeRequiredNodes,
+			Session:       session.token,
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 	defer cancel()
 
 	err = s.core.Set(ctx, request)
@@ -603,34 +1015,255 @@ func (s *redisServer) handleSetEXCommand(command *redisproto.Command, writer *re
 	return writer.WriteBulkString("OK")
 }
 
-func (s *redisServer) handleDeleteCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleDeleteCommand(session *connectionSession, command *redisproto.Command, writer *redisproto.Writer) error {
+	if command.ArgCount() < 2 {
+		return wrapStringAsError("expected at least 2 arguments for DEL command")
+	}
+
+	keys := make([]string, 0, command.ArgCount()-1)
 	for i := 1; i < command.ArgCount(); i++ {
-		key := string(command.Get(i))
+		keys = append(keys, string(command.Get(i)))
+	}
 
-		request := &keyvaluestore.DeleteRequest{
-			Key: key,
-			Options: keyvaluestore.WriteOptions{
-				Consistency: s.writeConsistency,
-			},
+	request := &keyvaluestore.DeleteManyRequest{
+		Keys: keys,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:   session.writeConsistency,
+			RequiredNodes: session.writeRequiredNodes,
+			Session:       session.token,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
+	defer cancel()
+
+	response, err := s.core.DeleteMany(ctx, request)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return writer.WriteInt(response.Deleted)
+}
+
+func (s *redisServer) handleGetDelCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
+	if command.ArgCount() != 2 {
+		return wrapStringAsError("expected 2 arguments for GETDEL command")
+	}
+
+	previous := &keyvaluestore.GetResponse{}
+	request := &keyvaluestore.DeleteRequest{
+		Key:      string(command.Get(1)),
+		Previous: previous,
+		Options: keyvaluestore.WriteOptions{
+			Consistency:   session.writeConsistency,
+			RequiredNodes: session.writeRequiredNodes,
+			Session:       session.token,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
+	defer cancel()
+
+	if err := s.core.Delete(ctx, request); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return writer.WriteBulk(nil)
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-		defer cancel()
+		return wrapError(err)
+	}
 
-		err := s.core.Delete(ctx, request)
-		if err != nil {
-			return wrapError(err)
+	return writer.WriteBulk(previous.Data)
+}
+
+// handleXAddCommand only supports IDs generated by the service, i.e. "*".
+// Field-value pairs of the entry are stored as a RESP array.
+func (s *redisServer) handleXAddCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
+	if command.ArgCount() < 5 || (command.ArgCount()-3)%2 != 0 {
+		return wrapStringAsError("ERR wrong number of arguments for 'xadd' command")
+	}
+	if string(command.Get(2)) != "*" {
+		return wrapStringAsError("ERR only auto-generated IDs are supported by XADD")
+	}
+
+	fields := make([][]byte, 0, command.ArgCount()-3)
+	for i := 3; i < command.ArgCount(); i++ {
+		fields = append(fields, command.Get(i))
+	}
+
+	var data bytes.Buffer
+	encoder := redisproto.NewWriter(bufio.NewWriter(&data))
+	if err := encoder.WriteBulks(fields...); err != nil {
+		return wrapError(err)
+	}
+	if err := encoder.Flush(); err != nil {
+		return wrapError(err)
+	}
+
+	request := &keyvaluestore.StreamAddRequest{
+		Key:  string(command.Get(1)),
+		Data: data.Bytes(),
+		Options: keyvaluestore.WriteOptions{
+			Consistency:   session.writeConsistency,
+			RequiredNodes: session.writeRequiredNodes,
+			Session:       session.token,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
+	defer cancel()
+
+	response, err := s.core.StreamAdd(ctx, request)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	return writer.WriteBulkString(response.ID.String())
+}
+
+// handleXRangeCommand replies entries which have not been added by XADD
+// with their data as the value of a single "data" field.
+func (s *redisServer) handleXRangeCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
+	if command.ArgCount() != 4 && command.ArgCount() != 6 {
+		return wrapStringAsError("ERR wrong number of arguments for 'xrange' command")
+	}
+
+	start, err := parseRangeStreamID(string(command.Get(2)), false)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	end, err := parseRangeStreamID(string(command.Get(3)), true)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	var count int64
+	if command.ArgCount() == 6 {
+		if strings.ToUpper(string(command.Get(4))) != "COUNT" {
+			return wrapStringAsError("ERR syntax error")
+		}
+
+		count, err = strconv.ParseInt(string(command.Get(5)), 10, 64)
+		if err != nil || count < 0 {
+			return wrapStringAsError("ERR value is not an integer or out of range")
+		}
+	}
+
+	// Zero End means the last entry to the service, while no entry precedes
+	// the zero ID in redis.
+	if end == (keyvaluestore.StreamID{}) {
+		return writer.WriteBulks([][]byte{}...)
+	}
+
+	request := &keyvaluestore.StreamRangeRequest{
+		Key:   string(command.Get(1)),
+		Start: start,
+		End:   end,
+		Count: count,
+		Options: keyvaluestore.ReadOptions{
+			Consistency:   session.readConsistency,
+			RequiredNodes: session.readRequiredNodes,
+			Session:       session.token,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
+	defer cancel()
+
+	response, err := s.core.StreamRange(ctx, request)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if _, err := fmt.Fprintf(writer, "*%d\r\n", len(response.Entries)); err != nil {
+		return err
+	}
+
+	for _, entry := range response.Entries {
+		if _, err := writer.Write([]byte("*2\r\n")); err != nil {
+			return err
+		}
+		if err := writer.WriteBulkString(entry.ID.String()); err != nil {
+			return err
+		}
+		if err := writer.WriteBulks(streamEntryFields(entry.Data)...); err != nil {
+			return err
 		}
 	}
 
-	return writer.WriteInt(int64(command.ArgCount() - 1))
+	return nil
+}
+
+// parseRangeStreamID parses the bounds of XRANGE, where "-" and "+" are the
+// smallest and greatest IDs, and IDs missing the sequence number stand for
+// the first or, as the end, the last ID of their millisecond.
+func parseRangeStreamID(value string, end bool) (keyvaluestore.StreamID, error) {
+	switch value {
+	case "-":
+		return keyvaluestore.StreamID{}, nil
+
+	case "+":
+		return keyvaluestore.MaxStreamID, nil
+	}
+
+	id, err := keyvaluestore.ParseStreamID(value)
+	if err != nil {
+		return id, errors.New("ERR Invalid stream ID specified as stream command argument")
+	}
+
+	if end && !strings.Contains(value, "-") {
+		id.Sequence = keyvaluestore.MaxStreamID.Sequence
+	}
+
+	return id, nil
+}
+
+func streamEntryFields(data []byte) [][]byte {
+	if len(data) == 0 || data[0] != '*' {
+		return [][]byte{[]byte("data"), data}
+	}
+
+	command, err := redisproto.NewParser(bytes.NewReader(data)).ReadCommand()
+	if err != nil || command.ArgCount()%2 != 0 {
+		return [][]byte{[]byte("data"), data}
+	}
+
+	fields := make([][]byte, command.ArgCount())
+	for i := range fields {
+		fields[i] = command.Get(i)
+	}
+
+	return fields
 }
 
-func (s *redisServer) handleFlushDbCommand(command *redisproto.Command, writer *redisproto.Writer) error {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+// handleFlushDbCommand flushes every node, or only the given nodes using the
+// non-standard FLUSHDB NODES <address> [<address> ...] form, which retries
+// nodes reported as failed by a previous flush.
+func (s *redisServer) handleFlushDbCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
+	request := &keyvaluestore.FlushDBRequest{}
+
+	if command.ArgCount() > 1 {
+		if strings.ToUpper(string(command.Get(1))) != "NODES" || command.ArgCount() < 3 {
+			return wrapStringAsError("expected FLUSHDB or FLUSHDB NODES <address> [<address> ...]")
+		}
+
+		for i := 2; i < command.ArgCount(); i++ {
+			request.Nodes = append(request.Nodes, string(command.Get(i)))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 	defer cancel()
 
-	err := s.core.FlushDB(ctx)
+	_, err := s.core.FlushDB(ctx, request)
 	if err != nil {
 		return wrapError(err)
 	}
@@ -638,14 +1271,55 @@ func (s *redisServer) handleFlushDbCommand(command *redisproto.Command, writer *
 	return writer.WriteBulkString("OK")
 }
 
-func (s *redisServer) handlerMGetCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+// handleDeletePatternCommand deletes keys matching a pattern using the
+// non-standard DELPATTERN <pattern> command, replying with the number of
+// deleted keys, or an error listing the nodes which failed.
+func (s *redisServer) handleDeletePatternCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
+	if command.ArgCount() != 2 {
+		return wrapStringAsError("expected 2 arguments for DELPATTERN command")
+	}
+
+	request := &keyvaluestore.DeletePatternRequest{
+		Pattern: string(command.Get(1)),
+		Options: keyvaluestore.WriteOptions{
+			Consistency:   session.writeConsistency,
+			RequiredNodes: session.writeRequiredNodes,
+			Session:       session.token,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
+	defer cancel()
+
+	response, err := s.core.DeletePattern(ctx, request)
+	if err != nil {
+		return wrapError(err)
+	}
+
+	if len(response.Errors) > 0 {
+		failed := make([]string, 0, len(response.Errors))
+		for address := range response.Errors {
+			failed = append(failed, address)
+		}
+		sort.Strings(failed)
+
+		return wrapStringAsError("deleted %v keys, failed on nodes: %v",
+			response.Deleted, strings.Join(failed, ", "))
+	}
+
+	return writer.WriteInt(response.Deleted)
+}
+
+func (s *redisServer) handlerMGetCommand(session *connectionSession, command *redisproto.Command, writer *redisproto.Writer) error {
 	if command.ArgCount() < 2 {
 		return wrapStringAsError("expected at least 2 arguments for MGET command")
 	}
 
 	bulks := make([][]byte, command.ArgCount()-1)
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 	defer cancel()
 
 	var wg sync.WaitGroup
@@ -661,7 +1335,9 @@ func (s *redisServer) handlerMGetCommand(command *redisproto.Command, writer *re
 			request := &keyvaluestore.GetRequest{
 				Key: targetKey,
 				Options: keyvaluestore.ReadOptions{
-					Consistency: s.readConsistency,
+					Consistency:   session.readConsistency,
+					RequiredNodes: session.readRequiredNodes,
+					Session:       session.token,
 				},
 			}
 
@@ -707,7 +1383,7 @@ func (s *redisServer) handlerMGetCommand(command *redisproto.Command, writer *re
 	}
 }
 
-func (s *redisServer) handleGetCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleGetCommand(session *connectionSession, command *redisproto.Command, writer *redisproto.Writer) error {
 	if command.ArgCount() < 2 {
 		return wrapStringAsError("expected at least 2 arguments for GET command")
 	}
@@ -717,11 +1393,14 @@ func (s *redisServer) handleGetCommand(command *redisproto.Command, writer *redi
 	request := &keyvaluestore.GetRequest{
 		Key: key,
 		Options: keyvaluestore.ReadOptions{
-			Consistency: s.readConsistency,
+			Consistency:          session.readConsistency,
+			RequiredNodes:        session.readRequiredNodes,
+			Session:              session.token,
+			StaleWhileRevalidate: session.staleReads,
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(session.context(), defaultTimeout)
 	defer cancel()
 
 	result, err := s.core.Get(ctx, request)
@@ -741,7 +1420,9 @@ func (s *redisServer) handleGetCommand(command *redisproto.Command, writer *redi
 	return writer.WriteBulk(result.Data)
 }
 
-func (s *redisServer) handleSelectCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleSelectCommand(_ *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
 	if command.ArgCount() != 2 {
 		return wrapStringAsError("expected 1 argument for SELECT command")
 	}
@@ -758,7 +1439,271 @@ func (s *redisServer) handleSelectCommand(command *redisproto.Command, writer *r
 	return writer.WriteBulkString("OK")
 }
 
-func (s *redisServer) handlePingCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleSessionCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
+	if command.ArgCount() != 2 {
+		return wrapStringAsError("expected 2 arguments for SESSION command")
+	}
+
+	session.token = string(command.Get(1))
+
+	return writer.WriteBulkString("OK")
+}
+
+// handleCorrelationCommand sets the correlation ID of later commands of the
+// connection, where an empty ID clears it.
+func (s *redisServer) handleCorrelationCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
+	if command.ArgCount() != 2 {
+		return wrapStringAsError("expected 2 arguments for CORRELATION command")
+	}
+
+	session.correlationID = string(command.Get(1))
+
+	return writer.WriteBulkString("OK")
+}
+
+func (s *redisServer) handleConsistencyCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
+	switch command.ArgCount() {
+	case 2:
+		readConsistency, readRequiredNodes, err := s.parseConsistency(string(command.Get(1)), s.readConsistency)
+		if err != nil {
+			return err
+		}
+
+		writeConsistency, writeRequiredNodes, err := s.parseConsistency(string(command.Get(1)), s.writeConsistency)
+		if err != nil {
+			return err
+		}
+
+		session.readConsistency, session.readRequiredNodes = readConsistency, readRequiredNodes
+		session.writeConsistency, session.writeRequiredNodes = writeConsistency, writeRequiredNodes
+
+	case 3:
+		target := strings.ToUpper(string(command.Get(1)))
+
+		switch target {
+		case "READ":
+			readConsistency, readRequiredNodes, err := s.parseConsistency(string(command.Get(2)), s.readConsistency)
+			if err != nil {
+				return err
+			}
+
+			session.readConsistency, session.readRequiredNodes = readConsistency, readRequiredNodes
+
+		case "WRITE":
+			writeConsistency, writeRequiredNodes, err := s.parseConsistency(string(command.Get(2)),
+				s.writeConsistency)
+			if err != nil {
+				return err
+			}
+
+			session.writeConsistency, session.writeRequiredNodes = writeConsistency, writeRequiredNodes
+
+		case "STALE":
+			switch strings.ToUpper(string(command.Get(2))) {
+			case "ON":
+				session.staleReads = true
+
+			case "OFF":
+				session.staleReads = false
+
+			default:
+				return wrapError(fmt.Errorf("expected ON or OFF for CONSISTENCY STALE command: %v",
+					string(command.Get(2))))
+			}
+
+		default:
+			return wrapStringAsError("expected READ, WRITE or STALE for CONSISTENCY command: %v", target)
+		}
+
+	default:
+		return wrapStringAsError("expected 2-3 arguments for CONSISTENCY command")
+	}
+
+	return writer.WriteBulkString("OK")
+}
+
+// parseConsistency parses either a named consistency level, or a positive
+// number of nodes which is returned as required nodes along with the
+// default level.
+func (s *redisServer) parseConsistency(value string,
+	defaultConsistency keyvaluestore.ConsistencyLevel) (keyvaluestore.ConsistencyLevel, int, error) {
+
+	if requiredNodes, err := strconv.Atoi(value); err == nil {
+		if requiredNodes < 1 {
+			return 0, 0, wrapStringAsError("expected a positive number of nodes: %v", value)
+		}
+
+		return defaultConsistency, requiredNodes, nil
+	}
+
+	switch strings.ToUpper(value) {
+	case "DEFAULT":
+		return defaultConsistency, 0, nil
+
+	case "ONE":
+		return keyvaluestore.ConsistencyLevel_ONE, 0, nil
+
+	case "TWO":
+		return keyvaluestore.ConsistencyLevel_TWO, 0, nil
+
+	case "THREE":
+		return keyvaluestore.ConsistencyLevel_THREE, 0, nil
+
+	case "MAJORITY":
+		return keyvaluestore.ConsistencyLevel_MAJORITY, 0, nil
+
+	case "ALL":
+		return keyvaluestore.ConsistencyLevel_ALL, 0, nil
+
+	default:
+		return 0, 0, wrapStringAsError("unknown consistency level: %v", value)
+	}
+}
+
+// handleSubscribeCommand subscribes to changes of keys, which are published
+// with the type of change (set, del or expired) as message. Patterns are
+// restricted to prefixes, i.e. a single trailing '*'.
+func (s *redisServer) handleSubscribeCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer, pattern bool) error {
+
+	name := "SUBSCRIBE"
+	if pattern {
+		name = "PSUBSCRIBE"
+	}
+
+	if command.ArgCount() < 2 {
+		return wrapStringAsError("expected at least 2 arguments for %v command", name)
+	}
+	if session.events != nil {
+		return wrapStringAsError("connection is already subscribed")
+	}
+
+	var requests []*keyvaluestore.WatchRequest
+	for i := 1; i < command.ArgCount(); i++ {
+		request := &keyvaluestore.WatchRequest{
+			Key: string(command.Get(i)),
+			Options: keyvaluestore.ReadOptions{
+				Consistency:   session.readConsistency,
+				RequiredNodes: session.readRequiredNodes,
+				Session:       session.token,
+			},
+		}
+
+		if pattern {
+			if !strings.HasSuffix(request.Key, "*") ||
+				strings.ContainsAny(strings.TrimSuffix(request.Key, "*"), `*?[]\`) {
+
+				return wrapStringAsError("only prefix patterns such as 'prefix*' are supported: %v", request.Key)
+			}
+
+			request.Key = strings.TrimSuffix(request.Key, "*")
+			request.Prefix = true
+		}
+
+		requests = append(requests, request)
+	}
+
+	ctx, cancel := context.WithCancel(session.context())
+	events := make(chan subscriptionEvent)
+
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		watched, err := s.core.Watch(ctx, request)
+		if err != nil {
+			cancel()
+			return wrapError(err)
+		}
+
+		subscription := ""
+		if pattern {
+			subscription = string(command.Get(i + 1))
+		}
+
+		wg.Add(1)
+		go func(watched <-chan keyvaluestore.Event, subscription string) {
+			defer wg.Done()
+
+			for event := range watched {
+				select {
+				case events <- subscriptionEvent{pattern: subscription, event: event}:
+				case <-ctx.Done():
+				}
+			}
+		}(watched, subscription)
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	session.events = events
+	session.cancelWatch = cancel
+
+	for i := 1; i < command.ArgCount(); i++ {
+		if err := writer.WriteObjects(strings.ToLower(name), string(command.Get(i)), i); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// handleCommandCommand supports the introspection subcommands clients such as
+// redis-cli issue upon connecting. Documentation of commands is not provided.
+func (s *redisServer) handleCommandCommand(_ *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
+	if command.ArgCount() < 2 {
+		return wrapStringAsError("expected a subcommand for COMMAND command")
+	}
+
+	switch subcommand := strings.ToUpper(string(command.Get(1))); subcommand {
+	case "COUNT":
+		return writer.WriteInt(int64(len(commandTable)))
+
+	case "LIST":
+		return writer.WriteBulkStrings(supportedCommands())
+
+	case "DOCS":
+		return writer.WriteBulkStrings([]string{})
+
+	default:
+		return wrapError(fmt.Errorf("ERR unknown subcommand '%v' for COMMAND command", subcommand))
+	}
+}
+
+func supportedCommands() []string {
+	result := make([]string, 0, len(commandTable))
+	for name := range commandTable {
+		result = append(result, strings.ToLower(name))
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// unknownCommandError is worded exactly as redis does, which some clients
+// rely on to detect unsupported commands.
+func unknownCommandError(command *redisproto.Command) error {
+	var args strings.Builder
+	for i := 1; i < command.ArgCount(); i++ {
+		fmt.Fprintf(&args, "'%s' ", command.Get(i))
+	}
+
+	return wrapError(fmt.Errorf("ERR unknown command '%s', with args beginning with: %s",
+		command.Get(0), args.String()))
+}
+
+func (s *redisServer) handlePingCommand(_ *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
 	if command.ArgCount() > 2 {
 		return wrapStringAsError("expected 1-2 arguments for Ping command")
 	}
@@ -770,7 +1715,9 @@ func (s *redisServer) handlePingCommand(command *redisproto.Command, writer *red
 	return writer.WriteBulk(command.Get(1))
 }
 
-func (s *redisServer) handleEchoCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleEchoCommand(_ *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
 	if command.ArgCount() != 2 {
 		return wrapStringAsError("expected 2 arguments for Echo command")
 	}
@@ -778,7 +1725,9 @@ func (s *redisServer) handleEchoCommand(command *redisproto.Command, writer *red
 	return writer.WriteBulk(command.Get(1))
 }
 
-func (s *redisServer) handleSetNXCommand(command *redisproto.Command, writer *redisproto.Writer) error {
+func (s *redisServer) handleSetNXCommand(session *connectionSession,
+	command *redisproto.Command, writer *redisproto.Writer) error {
+
 	if command.ArgCount() != 3 {
 		return wrapStringAsError("expected 3 arguments for SetNX command")
 	}
@@ -789,11 +1738,11 @@ func (s *redisServer) handleSetNXCommand(command *redisproto.Command, writer *re
 		Key:  key,
 		Data: value,
 		Options: keyvaluestore.WriteOptions{
-			Consistency: keyvaluestore.ConsistencyLevel_MAJORITY,
+			Consistency: keyvaluestore.ConsistencyLevel_DEFAULT,
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithTimeout(session.context(), 1*time.Second)
 	defer cancel()
 
 	err := s.core.Lock(ctx, request)
@@ -1,8 +1,16 @@
 package redis_test
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -101,6 +109,146 @@ func (s *RedisTransportTestSuite) TestSetShouldProvideExpiration() {
 	wg.Wait()
 }
 
+func (s *RedisTransportTestSuite) TestSetShouldProvidePersistentIfRequested() {
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Set", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.SetRequest) bool {
+		defer wg.Done()
+
+		s.True(request.Persistent)
+		s.Zero(request.Expiration)
+
+		return true
+	})).Once().Return(nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+	s.Nil(client.Do("SET", Key, VALUE, "PERSIST").Err())
+	wg.Wait()
+}
+
+func (s *RedisTransportTestSuite) TestSetShouldProvideKeepTTLIfRequested() {
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Set", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.SetRequest) bool {
+		defer wg.Done()
+
+		s.True(request.KeepTTL)
+		s.Zero(request.Expiration)
+
+		return true
+	})).Once().Return(nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+	s.Nil(client.Do("SET", Key, VALUE, "KEEPTTL").Err())
+	wg.Wait()
+}
+
+func (s *RedisTransportTestSuite) TestSetShouldReplyAcknowledgementsIfRequested() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Set", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.SetRequest) bool {
+		return request.Acknowledgement != nil
+	})).Once().Run(func(args mock.Arguments) {
+		acknowledgement := args.Get(1).(*keyvaluestore.SetRequest).Acknowledgement
+		acknowledgement.Acknowledged = 2
+		acknowledgement.Nodes = 3
+	}).Return(nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+	result, err := client.Do("SET", Key, VALUE, "ACKS").Result()
+	s.Nil(err)
+	s.Equal([]interface{}{int64(2), int64(3)}, result)
+}
+
+func (s *RedisTransportTestSuite) TestSetShouldFailIfAcknowledgementsAreRequestedWithNX() {
+	core := &keyvaluestore.Mock_Service{}
+
+	s.runServer(core)
+	client := s.makeClient()
+	s.NotNil(client.Do("SET", Key, VALUE, "NX", "ACKS").Err())
+	core.AssertNotCalled(s.T(), "Lock", mock.Anything, mock.Anything)
+}
+
+func (s *RedisTransportTestSuite) TestGetDelShouldReplyPreviousValue() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Delete", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.DeleteRequest) bool {
+		return request.Key == Key && request.Previous != nil
+	})).Once().Run(func(args mock.Arguments) {
+		args.Get(1).(*keyvaluestore.DeleteRequest).Previous.Data = []byte(VALUE)
+	}).Return(nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+	result, err := client.Do("GETDEL", Key).String()
+	s.Nil(err)
+	s.Equal(VALUE, result)
+}
+
+func (s *RedisTransportTestSuite) TestGetDelShouldReplyNilIfKeyDoesNotExist() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Delete", mock.Anything, mock.Anything).Once().Return(
+		status.Error(codes.NotFound, keyvaluestore.ErrNotFound.Error()))
+
+	s.runServer(core)
+	client := s.makeClient()
+	s.Equal(redisClient.Nil, client.Do("GETDEL", Key).Err())
+}
+
+func (s *RedisTransportTestSuite) TestXAddAndXRangeShouldRoundTripFields() {
+	var stored []byte
+	id := keyvaluestore.StreamID{Time: 1500, Sequence: 2}
+
+	core := &keyvaluestore.Mock_Service{}
+	core.On("StreamAdd", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.StreamAddRequest) bool {
+		return request.Key == Key
+	})).Once().Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*keyvaluestore.StreamAddRequest).Data
+	}).Return(&keyvaluestore.StreamAddResponse{ID: id}, nil)
+	core.On("StreamRange", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.StreamRangeRequest) bool {
+		return request.Key == Key && request.Start == keyvaluestore.StreamID{Time: 1500} &&
+			request.End == keyvaluestore.MaxStreamID && request.Count == 10
+	})).Once().Return(func(context.Context, *keyvaluestore.StreamRangeRequest) *keyvaluestore.StreamRangeResponse {
+		return &keyvaluestore.StreamRangeResponse{Entries: []keyvaluestore.StreamEntry{
+			{ID: id, Data: stored},
+			{ID: keyvaluestore.StreamID{Time: 1600}, Data: []byte(VALUE)},
+		}}
+	}, nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	added, err := client.XAdd(&redisClient.XAddArgs{
+		Stream: Key,
+		Values: map[string]interface{}{"event": VALUE},
+	}).Result()
+	s.Nil(err)
+	s.Equal("1500-2", added)
+
+	messages, err := client.XRangeN(Key, "1500", "+", 10).Result()
+	s.Nil(err)
+	s.Equal([]redisClient.XMessage{
+		{ID: "1500-2", Values: map[string]interface{}{"event": VALUE}},
+		{ID: "1600-0", Values: map[string]interface{}{"data": VALUE}},
+	}, messages)
+}
+
+func (s *RedisTransportTestSuite) TestXAddShouldRejectExplicitIDs() {
+	s.runServer(&keyvaluestore.Mock_Service{})
+	client := s.makeClient()
+
+	s.NotNil(client.XAdd(&redisClient.XAddArgs{
+		Stream: Key,
+		ID:     "1-1",
+		Values: map[string]interface{}{"event": VALUE},
+	}).Err())
+}
+
 func (s *RedisTransportTestSuite) TestSetShouldProvideNilExpirationIfZero() {
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -142,14 +290,14 @@ func (s *RedisTransportTestSuite) TestDeleteShouldProvideConsistency() {
 	wg.Add(1)
 
 	core := &keyvaluestore.Mock_Service{}
-	core.On("Delete", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.DeleteRequest) bool {
+	core.On("DeleteMany", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.DeleteManyRequest) bool {
 		defer wg.Done()
 
-		s.Equal(Key, request.Key)
+		s.Equal([]string{Key}, request.Keys)
 		s.Equal(CONSISTENCY, request.Options.Consistency)
 
-		return Key == request.Key && CONSISTENCY == request.Options.Consistency
-	})).Return(nil)
+		return CONSISTENCY == request.Options.Consistency
+	})).Return(&keyvaluestore.DeleteManyResponse{Deleted: 1}, nil)
 
 	s.runServer(core)
 	client := s.makeClient()
@@ -161,18 +309,16 @@ func (s *RedisTransportTestSuite) TestDeleteShouldProvideConsistency() {
 
 func (s *RedisTransportTestSuite) TestDeleteShouldSupportMultipleKeys() {
 	var wg sync.WaitGroup
-	wg.Add(2)
-
-	seenKeys := map[string]bool{}
+	wg.Add(1)
 
 	core := &keyvaluestore.Mock_Service{}
-	core.On("Delete", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.DeleteRequest) bool {
+	core.On("DeleteMany", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.DeleteManyRequest) bool {
 		defer wg.Done()
 
-		seenKeys[request.Key] = true
+		s.Equal([]string{Key, AnotherKey}, request.Keys)
 
 		return true
-	})).Return(nil)
+	})).Return(&keyvaluestore.DeleteManyResponse{Deleted: 2}, nil)
 
 	s.runServer(core)
 	client := s.makeClient()
@@ -180,9 +326,18 @@ func (s *RedisTransportTestSuite) TestDeleteShouldSupportMultipleKeys() {
 	s.Nil(err)
 	s.Equal(2, int(result))
 	wg.Wait()
-	s.Equal(2, len(seenKeys))
-	s.True(seenKeys[Key])
-	s.True(seenKeys[AnotherKey])
+}
+
+func (s *RedisTransportTestSuite) TestDeleteShouldReportNumberOfDeletedKeys() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("DeleteMany", mock.Anything, mock.Anything).
+		Return(&keyvaluestore.DeleteManyResponse{Deleted: 1}, nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+	result, err := client.Del(Key, AnotherKey).Result()
+	s.Nil(err)
+	s.Equal(1, int(result))
 }
 
 func (s *RedisTransportTestSuite) TestDeleteShouldProcessEndpointError() {
@@ -190,12 +345,12 @@ func (s *RedisTransportTestSuite) TestDeleteShouldProcessEndpointError() {
 	wg.Add(1)
 
 	core := &keyvaluestore.Mock_Service{}
-	core.On("Delete", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.DeleteRequest) bool {
+	core.On("DeleteMany", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.DeleteManyRequest) bool {
 		defer wg.Done()
 
-		s.Equal(Key, request.Key)
-		return Key == request.Key
-	})).Return(errors.New("some error"))
+		s.Equal([]string{Key}, request.Keys)
+		return true
+	})).Return(nil, errors.New("some error"))
 
 	s.runServer(core)
 	client := s.makeClient()
@@ -299,6 +454,140 @@ func (s *RedisTransportTestSuite) TestShouldSupportPingCommnand() {
 	s.Equal("PONG", value)
 }
 
+func (s *RedisTransportTestSuite) TestPingShouldNotTouchCore() {
+	core := &keyvaluestore.Mock_Service{}
+
+	s.runServer(core)
+	s.Equal("+PONG\r\n", s.sendRaw("*1\r\n$4\r\nPING\r\n", 7))
+	s.Equal("$5\r\nhello\r\n", s.sendRaw("*2\r\n$4\r\nPING\r\n$5\r\nhello\r\n", 11))
+	s.Equal("$5\r\nhello\r\n", s.sendRaw("*2\r\n$4\r\nECHO\r\n$5\r\nhello\r\n", 11))
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestShouldSupportInlinePingCommand() {
+	s.runServer(&keyvaluestore.Mock_Service{})
+	s.Equal("+PONG\r\n", s.sendRaw("PING\r\n", 7))
+}
+
+func (s *RedisTransportTestSuite) TestShouldCloseConnectionUponMalformedInput() {
+	core := &keyvaluestore.Mock_Service{}
+
+	s.runServer(core)
+	for _, request := range []string{
+		"*-1\r\n\r\n",
+		"*0\r\n",
+		"\n",
+		"*abc\r\n",
+		"*1\r\n$-5\r\n",
+		"*1\r\n$999999999999\r\n",
+		"*1\r\n$99999999999999999999\r\n",
+		"*1\r\nPING\r\n",
+	} {
+		response := s.sendRawUntilClosed(request)
+		s.True(strings.HasPrefix(response, "-ERR Protocol error"), "request %q, response %q", request, response)
+	}
+
+	s.Equal("+PONG\r\n", s.sendRaw("PING\r\n", 7))
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestShouldServeOnUnixSocket() {
+	dir, err := ioutil.TempDir("", "keyvaluestore")
+	s.Require().Nil(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "redis.sock")
+	stale, err := net.Listen("unix", path)
+	s.Require().Nil(err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	s.Nil(stale.Close())
+
+	s.runServer(&keyvaluestore.Mock_Service{}, redis.WithListenSocket("unix://"+path))
+
+	client := redisClient.NewClient(&redisClient.Options{Network: "unix", Addr: path})
+	defer client.Close()
+	s.Nil(client.Ping().Err())
+
+	s.Nil(s.server.Close())
+	s.server = nil
+	_, err = os.Stat(path)
+	s.True(os.IsNotExist(err))
+}
+
+func (s *RedisTransportTestSuite) TestShouldNotReplaceUnixSocketInUse() {
+	dir, err := ioutil.TempDir("", "keyvaluestore")
+	s.Require().Nil(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "redis.sock")
+	live, err := net.Listen("unix", path)
+	s.Require().Nil(err)
+	defer live.Close()
+
+	server := redis.New(&keyvaluestore.Mock_Service{}, s.port, time.Minute, CONSISTENCY, CONSISTENCY,
+		redis.WithListenSocket("unix://"+path))
+	s.NotNil(server.Start())
+
+	conn, err := net.Dial("unix", path)
+	s.Require().Nil(err)
+	s.Nil(conn.Close())
+}
+
+func (s *RedisTransportTestSuite) TestShouldFailToStartOnUnsupportedListenSocket() {
+	server := redis.New(&keyvaluestore.Mock_Service{}, s.port, time.Minute, CONSISTENCY, CONSISTENCY,
+		redis.WithListenSocket("tcp://127.0.0.1:6379"))
+	s.NotNil(server.Start())
+}
+
+func (s *RedisTransportTestSuite) TestShouldRejectConnectionsBeyondMaxConnections() {
+	s.runServer(&keyvaluestore.Mock_Service{}, redis.WithMaxConnections(1))
+
+	client := s.makeSingleConnectionClient()
+	s.Nil(client.Ping().Err())
+
+	s.Equal("-ERR max number of clients reached\r\n", s.sendRawUntilClosed(""))
+	s.Nil(client.Ping().Err())
+
+	s.Nil(client.Close())
+	anotherClient := s.makeSingleConnectionClient()
+	defer anotherClient.Close()
+	s.Eventually(func() bool {
+		return anotherClient.Ping().Err() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (s *RedisTransportTestSuite) TestShouldRejectUnknownCommandAndKeepServing() {
+	core := &keyvaluestore.Mock_Service{}
+
+	s.runServer(core)
+	client := s.makeSingleConnectionClient()
+	err := client.Do("HSET", "hash", "field").Err()
+	s.NotNil(err)
+	s.Equal("ERR unknown command 'HSET', with args beginning with: 'hash' 'field' ", err.Error())
+
+	s.Nil(client.Ping().Err())
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestCommandShouldListSupportedCommands() {
+	s.runServer(&keyvaluestore.Mock_Service{})
+	client := s.makeClient()
+
+	names, err := client.Do("COMMAND", "LIST").Result()
+	s.Nil(err)
+	s.Contains(names, "get")
+	s.Contains(names, "consistency")
+	s.NotContains(names, "hset")
+
+	count, err := client.Do("COMMAND", "COUNT").Int64()
+	s.Nil(err)
+	s.Equal(int64(len(names.([]interface{}))), count)
+
+	docs, err := client.Do("COMMAND", "DOCS").Result()
+	s.Nil(err)
+	s.Empty(docs)
+}
+
 func (s *RedisTransportTestSuite) TestShouldSupportEchoCommand() {
 	core := &keyvaluestore.Mock_Service{}
 
@@ -583,34 +872,33 @@ func (s *RedisTransportTestSuite) TestTTLShouldReturnNumberOfSeconds() {
 }
 
 func (s *RedisTransportTestSuite) TestMSetShouldSetMultipleKeys() {
-	var wg sync.WaitGroup
-	wg.Add(3)
-
 	core := &keyvaluestore.Mock_Service{}
-	core.On("Set", mock.Anything, mock.MatchedBy(func(setRequest *keyvaluestore.SetRequest) bool {
-		return setRequest.Key == "A"
-	})).Times(1).Run(func(args mock.Arguments) {
-		wg.Done()
-	}).Return(nil)
-
-	core.On("Set", mock.Anything, mock.MatchedBy(func(setRequest *keyvaluestore.SetRequest) bool {
-		return setRequest.Key == "B"
-	})).Times(1).Run(func(args mock.Arguments) {
-		wg.Done()
-	}).Return(nil)
-
-	core.On("Set", mock.Anything, mock.MatchedBy(func(setRequest *keyvaluestore.SetRequest) bool {
-		return setRequest.Key == "C"
-	})).Times(1).Run(func(args mock.Arguments) {
-		wg.Done()
-	}).Return(nil)
+	core.On("MSet", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.MSetRequest) bool {
+		return s.Equal([]keyvaluestore.KeyValue{
+			{Key: "A", Data: []byte("1")},
+			{Key: "B", Data: []byte("2")},
+			{Key: "C", Data: []byte("3")},
+		}, request.Items)
+	})).Once().Return(nil)
 
 	s.runServer(core)
 	client := s.makeClient()
 
 	err := client.MSet("A", 1, "B", 2, "C", 3).Err()
 	s.Nil(err)
-	wg.Wait()
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestMSetShouldReportRejectedItems() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("MSet", mock.Anything, mock.Anything).Once().Return(
+		status.Error(codes.InvalidArgument, keyvaluestore.ErrValueTooLarge.Error()))
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	err := client.MSet("A", 1).Err()
+	s.NotNil(err)
 }
 
 func (s *RedisTransportTestSuite) TestMGetShouldReturnNilInMiddleOfKeys() {
@@ -644,15 +932,357 @@ func (s *RedisTransportTestSuite) TestMGetShouldReturnNilInMiddleOfKeys() {
 	wg.Wait()
 }
 
-func (s *RedisTransportTestSuite) runServer(core keyvaluestore.Service) {
-	s.server = redis.New(core, s.port, 5*time.Minute, CONSISTENCY, CONSISTENCY)
+func (s *RedisTransportTestSuite) TestFlushDbShouldFlushRequestedNodes() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("FlushDB", mock.Anything, &keyvaluestore.FlushDBRequest{
+		Nodes: []string{"10.0.0.1:6379", "10.0.0.2:6379"},
+	}).Once().Return(&keyvaluestore.FlushDBResponse{}, nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	s.Nil(client.Do("FLUSHDB", "NODES", "10.0.0.1:6379", "10.0.0.2:6379").Err())
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestDeletePatternShouldReplyDeletedKeys() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("DeletePattern", mock.Anything, &keyvaluestore.DeletePatternRequest{
+		Pattern: "user:*",
+		Options: keyvaluestore.WriteOptions{Consistency: CONSISTENCY},
+	}).Once().Return(&keyvaluestore.DeletePatternResponse{Deleted: 3}, nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	deleted, err := client.Do("DELPATTERN", "user:*").Int64()
+	s.Nil(err)
+	s.Equal(int64(3), deleted)
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestDeletePatternShouldReportFailedNodes() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("DeletePattern", mock.Anything, mock.Anything).Once().Return(&keyvaluestore.DeletePatternResponse{
+		Deleted: 1,
+		Errors: map[string]error{
+			"10.0.0.2:6379": errors.New("connection refused"),
+			"10.0.0.1:6379": errors.New("connection refused"),
+		},
+	}, nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	err := client.Do("DELPATTERN", "user:*").Err()
+	s.Require().NotNil(err)
+	s.Contains(err.Error(), "deleted 1 keys, failed on nodes: 10.0.0.1:6379, 10.0.0.2:6379")
+}
+
+func (s *RedisTransportTestSuite) TestFlushDbShouldReportFailedNodes() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("FlushDB", mock.Anything, &keyvaluestore.FlushDBRequest{}).Once().Return(nil,
+		status.Error(codes.Unavailable, "flush failed on nodes: 10.0.0.1:6379"))
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	err := client.FlushDB().Err()
+	s.NotNil(err)
+	s.Contains(err.Error(), "10.0.0.1:6379")
+}
+
+func (s *RedisTransportTestSuite) TestSubscribeShouldPublishKeyEvents() {
+	events := make(chan keyvaluestore.Event, 1)
+
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Watch", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.WatchRequest) bool {
+		return request.Key == Key && !request.Prefix
+	})).Once().Return((<-chan keyvaluestore.Event)(events), nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	pubsub := client.Subscribe(Key)
+	defer pubsub.Close()
+
+	_, err := pubsub.ReceiveTimeout(time.Second)
+	s.Nil(err)
+
+	events <- keyvaluestore.Event{Key: Key, Type: keyvaluestore.EventDelete}
+	message, err := pubsub.ReceiveMessage()
+	s.Nil(err)
+	s.Equal(Key, message.Channel)
+	s.Equal("del", message.Payload)
+}
+
+func (s *RedisTransportTestSuite) TestPSubscribeShouldWatchPrefix() {
+	events := make(chan keyvaluestore.Event, 1)
+
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Watch", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.WatchRequest) bool {
+		return request.Key == "prefix:" && request.Prefix
+	})).Once().Return((<-chan keyvaluestore.Event)(events), nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	pubsub := client.PSubscribe("prefix:*")
+	defer pubsub.Close()
+
+	_, err := pubsub.ReceiveTimeout(time.Second)
+	s.Nil(err)
+
+	events <- keyvaluestore.Event{Key: "prefix:a", Type: keyvaluestore.EventSet}
+	message, err := pubsub.ReceiveMessage()
+	s.Nil(err)
+	s.Equal("prefix:*", message.Pattern)
+	s.Equal("prefix:a", message.Channel)
+	s.Equal("set", message.Payload)
+}
+
+func (s *RedisTransportTestSuite) TestPSubscribeShouldRejectNonPrefixPatterns() {
+	core := &keyvaluestore.Mock_Service{}
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	pubsub := client.PSubscribe("a*b*")
+	defer pubsub.Close()
+
+	_, err := pubsub.ReceiveTimeout(time.Second)
+	s.NotNil(err)
+	core.AssertNotCalled(s.T(), "Watch", mock.Anything, mock.Anything)
+}
+
+func (s *RedisTransportTestSuite) TestShutdownShouldWaitForInflightCommands() {
+	started := make(chan struct{})
+
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Set", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+	}).Return(nil)
+
+	s.runServer(core)
+	client := s.makeClient()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- client.Set(Key, VALUE, 0).Err()
+	}()
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.Nil(s.server.Shutdown(ctx))
+	s.server = nil
+
+	s.Nil(<-result)
+}
+
+func (s *RedisTransportTestSuite) TestShutdownShouldNotWaitForIdleConnections() {
+	core := &keyvaluestore.Mock_Service{}
+
+	s.runServer(core)
+	client := s.makeClient()
+	s.Nil(client.Ping().Err())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.Nil(s.server.Shutdown(ctx))
+	s.server = nil
+}
+
+func (s *RedisTransportTestSuite) TestConsistencyCommandShouldOverrideReadConsistencyOfConnection() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Get", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.GetRequest) bool {
+		return request.Options.Consistency == keyvaluestore.ConsistencyLevel_ONE
+	})).Once().Return(&keyvaluestore.GetResponse{Data: []byte(VALUE)}, nil)
+	core.On("Set", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.SetRequest) bool {
+		return request.Options.Consistency == CONSISTENCY
+	})).Once().Return(nil)
+
+	s.runServer(core)
+	client := s.makeSingleConnectionClient()
+
+	s.Nil(client.Do("CONSISTENCY", "READ", "ONE").Err())
+	s.Nil(client.Get(Key).Err())
+	s.Nil(client.Set(Key, VALUE, 0).Err())
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestConsistencyCommandShouldOverrideBothConsistenciesOfConnection() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Get", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.GetRequest) bool {
+		return request.Options.Consistency == keyvaluestore.ConsistencyLevel_ALL
+	})).Once().Return(&keyvaluestore.GetResponse{Data: []byte(VALUE)}, nil)
+	core.On("Set", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.SetRequest) bool {
+		return request.Options.Consistency == keyvaluestore.ConsistencyLevel_ALL
+	})).Once().Return(nil)
+
+	s.runServer(core)
+	client := s.makeSingleConnectionClient()
+
+	s.Nil(client.Do("CONSISTENCY", "ALL").Err())
+	s.Nil(client.Get(Key).Err())
+	s.Nil(client.Set(Key, VALUE, 0).Err())
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestConsistencyCommandShouldAcceptNumberOfNodes() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Get", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.GetRequest) bool {
+		return request.Options.RequiredNodes == 2
+	})).Once().Return(&keyvaluestore.GetResponse{Data: []byte(VALUE)}, nil)
+	core.On("Get", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.GetRequest) bool {
+		return request.Options.RequiredNodes == 0 && request.Options.Consistency == keyvaluestore.ConsistencyLevel_ONE
+	})).Once().Return(&keyvaluestore.GetResponse{Data: []byte(VALUE)}, nil)
+	core.On("Set", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.SetRequest) bool {
+		return request.Options.RequiredNodes == 0 && request.Options.Consistency == CONSISTENCY
+	})).Once().Return(nil)
+
+	s.runServer(core)
+	client := s.makeSingleConnectionClient()
+
+	s.Nil(client.Do("CONSISTENCY", "READ", "2").Err())
+	s.Nil(client.Get(Key).Err())
+	s.Nil(client.Set(Key, VALUE, 0).Err())
+	s.Nil(client.Do("CONSISTENCY", "READ", "ONE").Err())
+	s.Nil(client.Get(Key).Err())
+	s.NotNil(client.Do("CONSISTENCY", "READ", "0").Err())
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestExpireShouldUseWriteConsistencyOfConnection() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Expire", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.ExpireRequest) bool {
+		return request.Options.RequiredNodes == 2 && request.Options.Consistency == CONSISTENCY
+	})).Once().Return(&keyvaluestore.ExpireResponse{Exists: true}, nil)
+
+	s.runServer(core)
+	client := s.makeSingleConnectionClient()
+
+	s.Nil(client.Do("CONSISTENCY", "READ", "ONE").Err())
+	s.Nil(client.Do("CONSISTENCY", "WRITE", "2").Err())
+	s.Nil(client.Expire(Key, 1*time.Minute).Err())
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestConsistencyStaleCommandShouldToggleStaleReadsOfConnection() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Get", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.GetRequest) bool {
+		return request.Options.StaleWhileRevalidate
+	})).Once().Return(&keyvaluestore.GetResponse{Data: []byte(VALUE)}, nil)
+	core.On("Get", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.GetRequest) bool {
+		return !request.Options.StaleWhileRevalidate
+	})).Once().Return(&keyvaluestore.GetResponse{Data: []byte(VALUE)}, nil)
+
+	s.runServer(core)
+	client := s.makeSingleConnectionClient()
+
+	s.Nil(client.Do("CONSISTENCY", "STALE", "ON").Err())
+	s.Nil(client.Get(Key).Err())
+	s.Nil(client.Do("CONSISTENCY", "STALE", "OFF").Err())
+	s.Nil(client.Get(Key).Err())
+	s.NotNil(client.Do("CONSISTENCY", "STALE", "SOMETIMES").Err())
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestSessionCommandShouldSetSessionOfConnection() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Set", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.SetRequest) bool {
+		return request.Options.Session == "token"
+	})).Once().Return(nil)
+	core.On("Get", mock.Anything, mock.MatchedBy(func(request *keyvaluestore.GetRequest) bool {
+		return request.Options.Session == "token"
+	})).Once().Return(&keyvaluestore.GetResponse{Data: []byte(VALUE)}, nil)
+
+	s.runServer(core)
+	client := s.makeSingleConnectionClient()
+
+	s.Nil(client.Do("SESSION", "token").Err())
+	s.Nil(client.Set(Key, VALUE, 0).Err())
+	s.Nil(client.Get(Key).Err())
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestCorrelationCommandShouldSetCorrelationIDOfLaterCommands() {
+	core := &keyvaluestore.Mock_Service{}
+	core.On("Set", mock.MatchedBy(func(ctx context.Context) bool {
+		return keyvaluestore.CorrelationIDFromContext(ctx) == "request-42"
+	}), mock.Anything).Once().Return(nil)
+	core.On("Get", mock.MatchedBy(func(ctx context.Context) bool {
+		return keyvaluestore.CorrelationIDFromContext(ctx) == ""
+	}), mock.Anything).Once().Return(&keyvaluestore.GetResponse{Data: []byte(VALUE)}, nil)
+
+	s.runServer(core)
+	client := s.makeSingleConnectionClient()
+
+	s.Nil(client.Do("CORRELATION", "request-42").Err())
+	s.Nil(client.Set(Key, VALUE, 0).Err())
+	s.Nil(client.Do("CORRELATION", "").Err())
+	s.Nil(client.Get(Key).Err())
+	core.AssertExpectations(s.T())
+}
+
+func (s *RedisTransportTestSuite) TestConsistencyCommandShouldRejectUnknownLevel() {
+	core := &keyvaluestore.Mock_Service{}
+
+	s.runServer(core)
+	client := s.makeSingleConnectionClient()
+
+	s.NotNil(client.Do("CONSISTENCY", "READ", "SOME").Err())
+}
+
+func (s *RedisTransportTestSuite) runServer(core keyvaluestore.Service, options ...redis.Option) {
+	s.server = redis.New(core, s.port, 5*time.Minute, CONSISTENCY, CONSISTENCY, options...)
 	s.Nil(s.server.Start())
 }
 
+// sendRaw sends request over a new connection, as load balancer probes do, and
+// returns the first n bytes of the response.
+func (s *RedisTransportTestSuite) sendRaw(request string, n int) string {
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", s.port))
+	s.Require().Nil(err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(request))
+	s.Require().Nil(err)
+
+	response := make([]byte, n)
+	_, err = io.ReadFull(bufio.NewReader(conn), response)
+	s.Require().Nil(err)
+
+	return string(response)
+}
+
+func (s *RedisTransportTestSuite) sendRawUntilClosed(request string) string {
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", s.port))
+	s.Require().Nil(err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(request))
+	s.Require().Nil(err)
+
+	s.Require().Nil(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	response, err := ioutil.ReadAll(conn)
+	s.Require().Nil(err)
+
+	return string(response)
+}
+
 func (s *RedisTransportTestSuite) makeClient() *redisClient.Client {
 	return redisClient.NewClient(&redisClient.Options{Addr: fmt.Sprintf("127.0.0.1:%d", s.port)})
 }
 
+func (s *RedisTransportTestSuite) makeSingleConnectionClient() *redisClient.Client {
+	return redisClient.NewClient(&redisClient.Options{
+		Addr:     fmt.Sprintf("127.0.0.1:%d", s.port),
+		PoolSize: 1,
+	})
+}
+
 func (s *RedisTransportTestSuite) SetupTest() {
 	var err error
 
@@ -0,0 +1,41 @@
+package backend_test
+
+import (
+	"fmt"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-redis/redis"
+
+	redisBackend "github.com/cafebazaar/keyvalue-store/internal/backend/redis"
+	"github.com/cafebazaar/keyvalue-store/pkg/backend"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// There is no in-memory backend in the tree, so the example registers the
+// redis backend under its own name and connects it to an in-memory redis
+// server instead.
+func ExampleRegister() {
+	server, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer server.Close()
+
+	backend.Register("miniredis", func(address string) (keyvaluestore.Backend, error) {
+		return redisBackend.New(redis.NewClient(&redis.Options{Addr: address}), address), nil
+	})
+
+	node, err := backend.Open("miniredis", server.Addr())
+	if err != nil {
+		panic(err)
+	}
+	defer node.Close()
+
+	if err := node.Set("greeting", []byte("hello"), 0); err != nil {
+		panic(err)
+	}
+
+	value, err := node.Get("greeting")
+	fmt.Println(string(value), err)
+	// Output: hello <nil>
+}
@@ -0,0 +1,74 @@
+// Package backend keeps a registry of backend types, so that backends other
+// than the built-in ones may be plugged in without modifying the daemon.
+// Implementations register themselves, typically from an init function, and
+// nodes are then connected by the name of their backend type.
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+// Factory connects to the node at address.
+type Factory func(address string) (keyvaluestore.Backend, error)
+
+var (
+	factoriesLock sync.RWMutex
+	factories     = make(map[string]Factory)
+)
+
+// Register makes a backend type available by name. It panics if factory is
+// nil or name is already registered, as either is a programming error.
+func Register(name string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	if factory == nil {
+		panic("backend: nil factory of " + name)
+	}
+	if _, ok := factories[name]; ok {
+		panic("backend: duplicate registration of " + name)
+	}
+
+	factories[name] = factory
+}
+
+// Registered tells whether a backend type is registered by name.
+func Registered(name string) bool {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	_, ok := factories[name]
+	return ok
+}
+
+// Names returns the sorted names of registered backend types.
+func Names() []string {
+	factoriesLock.RLock()
+	defer factoriesLock.RUnlock()
+
+	result := make([]string, 0, len(factories))
+	for name := range factories {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// Open connects to the node at address using the backend type registered by
+// name.
+func Open(name, address string) (keyvaluestore.Backend, error) {
+	factoriesLock.RLock()
+	factory, ok := factories[name]
+	factoriesLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown backend: %v", name)
+	}
+
+	return factory(address)
+}
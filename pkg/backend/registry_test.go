@@ -0,0 +1,60 @@
+package backend_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/cafebazaar/keyvalue-store/pkg/backend"
+	"github.com/cafebazaar/keyvalue-store/pkg/keyvaluestore"
+)
+
+type RegistryTestSuite struct {
+	suite.Suite
+}
+
+func TestRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(RegistryTestSuite))
+}
+
+func (s *RegistryTestSuite) TestOpenShouldConnectUsingRegisteredFactory() {
+	node := &keyvaluestore.Mock_Backend{}
+	backend.Register("registry-test-open", func(address string) (keyvaluestore.Backend, error) {
+		s.Equal("10.0.0.1:6379", address)
+		return node, nil
+	})
+
+	result, err := backend.Open("registry-test-open", "10.0.0.1:6379")
+	s.Nil(err)
+	s.Equal(node, result)
+	s.True(backend.Registered("registry-test-open"))
+	s.Contains(backend.Names(), "registry-test-open")
+}
+
+func (s *RegistryTestSuite) TestOpenShouldPassFactoryErrors() {
+	factoryErr := errors.New("connection refused")
+	backend.Register("registry-test-error", func(address string) (keyvaluestore.Backend, error) {
+		return nil, factoryErr
+	})
+
+	_, err := backend.Open("registry-test-error", "10.0.0.1:6379")
+	s.Equal(factoryErr, err)
+}
+
+func (s *RegistryTestSuite) TestOpenShouldFailForUnknownBackend() {
+	_, err := backend.Open("registry-test-unknown", "10.0.0.1:6379")
+	s.NotNil(err)
+	s.False(backend.Registered("registry-test-unknown"))
+}
+
+func (s *RegistryTestSuite) TestRegisterShouldPanicUponDuplicateName() {
+	factory := func(address string) (keyvaluestore.Backend, error) {
+		return nil, nil
+	}
+	backend.Register("registry-test-duplicate", factory)
+
+	s.Panics(func() {
+		backend.Register("registry-test-duplicate", factory)
+	})
+}
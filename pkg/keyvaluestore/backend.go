@@ -1,21 +1,164 @@
 package keyvaluestore
 
 import (
+	"context"
 	"io"
 	"time"
 )
 
+type ValueWithTTL struct {
+	Data []byte
+	TTL  *time.Duration
+}
+
+type OpType int
+
+const (
+	OpSet OpType = iota
+	OpDelete
+)
+
+// Op is a single operation of a transaction. Data and Expiration are only
+// used by OpSet, where zero Expiration means the default write TTL, if any.
+type Op struct {
+	Type       OpType
+	Key        string
+	Data       []byte
+	Expiration time.Duration
+}
+
+// OpKeys returns the keys of ops, in order.
+func OpKeys(ops []Op) []string {
+	keys := make([]string, len(ops))
+	for i, op := range ops {
+		keys[i] = op.Key
+	}
+
+	return keys
+}
+
+// OptimisticFunc computes the ops of an optimistic transaction from the
+// current values of its keys. Keys which do not exist are missing from values.
+type OptimisticFunc func(values map[string][]byte) ([]Op, error)
+
 type Backend interface {
 	io.Closer
 
 	Set(key string, value []byte, expiration time.Duration) error
+
+	// SetKeepTTL sets value while keeping the current expiration of key. A
+	// key which does not exist yet expires after expiration, zero meaning never.
+	SetKeepTTL(key string, value []byte, expiration time.Duration) error
 	Expire(key string, expiration time.Duration) error
 	Lock(key string, value []byte, expiration time.Duration) error
 	Unlock(key string) error
+
+	// RenewLock sets expiration of the lock only if it still holds value,
+	// otherwise it returns ErrLockLost.
+	RenewLock(key string, value []byte, expiration time.Duration) error
+
+	// Acquire takes one of maxHolders slots of the semaphore key for holder,
+	// or renews it if holder already has one, in which case renewed is true.
+	// It returns ErrNotAcquired if all slots are taken.
+	Acquire(key string, holder string, maxHolders int, expiration time.Duration) (renewed bool, err error)
+	Release(key string, holder string) error
+
+	// IncrWindow increments the counter of key and returns its new value. The
+	// increment which creates the counter also sets it to expire after window.
+	IncrWindow(key string, window time.Duration) (int64, error)
 	TTL(key string) (*time.Duration, error)
 	Get(key string) ([]byte, error)
+	GetWithTTL(key string) (*ValueWithTTL, error)
+
+	// GetMany returns the values of keys which exist, in a single round trip.
+	GetMany(keys []string) (map[string][]byte, error)
 	Delete(key string) error
+
+	// GetDel deletes key and returns the value it had, or ErrNotFound.
+	GetDel(key string) ([]byte, error)
+	DeleteMany(keys []string) (int64, error)
+
+	// Transaction applies ops atomically, either all or none of them. It
+	// returns ErrCrossShard if the keys do not belong to the same shard.
+	Transaction(ops []Op) error
+
+	// OptimisticTransaction watches keys, passes their current values to
+	// apply and atomically applies the ops it returns, which are returned as
+	// well. It returns ErrOptimisticConflict, without applying any op, if
+	// any of the keys has been changed meanwhile.
+	OptimisticTransaction(keys []string, apply OptimisticFunc) ([]Op, error)
+
+	// StreamAdd adds entry to the stream key, unless the stream already has
+	// an entry of the same ID.
+	StreamAdd(key string, entry StreamEntry) error
+
+	// StreamRemove removes the entry of id from the stream key, if any.
+	StreamRemove(key string, id StreamID) error
+
+	// StreamRange returns up to count entries of the stream key, ordered by
+	// ID, whose IDs are between start and end inclusive. Zero count means no
+	// limit. Streams which do not exist have no entries.
+	StreamRange(key string, start StreamID, end StreamID, count int64) ([]StreamEntry, error)
+	Scan(cursor uint64, pattern string, count int64) ([]string, uint64, error)
+
+	// RandomKey returns a random existing key, or ErrNotFound if there is
+	// none.
+	RandomKey() (string, error)
 	FlushDB() error
 	Exists(key string) (bool, error)
+
+	// Type returns the redis type of key, such as string or zset, or
+	// ErrNotFound if key does not exist.
+	Type(key string) (string, error)
+	Ping() error
+	DBSize() (int64, error)
+	UsedMemory() (int64, error)
+
+	// Watch notifies about changes of keys matching the glob-style pattern
+	// until ctx is done, after which the channel is closed.
+	Watch(ctx context.Context, pattern string) (<-chan Event, error)
 	Address() string
 }
+
+// ContextBackend is a Backend whose commands can be bound to a context, so
+// that commands of a request are not sent anymore once the request is done.
+type ContextBackend interface {
+	Backend
+
+	WithContext(ctx context.Context) Backend
+}
+
+type boundBackend struct {
+	Backend
+
+	origin Backend
+}
+
+// Close leaves the node open, since bound backends share it.
+func (b *boundBackend) Close() error {
+	return nil
+}
+
+// BindContext binds the commands of node to ctx, if node is a ContextBackend,
+// or returns node as-is otherwise. Origin returns node back.
+func BindContext(ctx context.Context, node Backend) Backend {
+	backend, ok := node.(ContextBackend)
+	if !ok {
+		return node
+	}
+
+	return &boundBackend{
+		Backend: backend.WithContext(ctx),
+		origin:  node,
+	}
+}
+
+// Origin returns the node which has been bound by BindContext, or node itself
+// if it has not been bound, so that nodes can be told apart by identity.
+func Origin(node Backend) Backend {
+	if bound, ok := node.(*boundBackend); ok {
+		return bound.origin
+	}
+
+	return node
+}
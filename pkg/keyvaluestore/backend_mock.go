@@ -1,6 +1,7 @@
 package keyvaluestore
 
 import (
+	"context"
 	"time"
 
 	"github.com/stretchr/testify/mock"
@@ -36,6 +37,41 @@ func (m *Mock_Backend) Set(key string, value []byte, expiration time.Duration) e
 	return r0
 }
 
+func (m *Mock_Backend) SetKeepTTL(key string, value []byte, expiration time.Duration) error {
+	ret := m.Called(key, value, expiration)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(key string, value []byte, expiration time.Duration) error); ok {
+		r0 = rf(key, value, expiration)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Backend) GetDel(key string) ([]byte, error) {
+	ret := m.Called(key)
+
+	var r0 []byte
+	if rf, ok := ret.Get(0).(func(key string) []byte); ok {
+		r0 = rf(key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(key string) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 func (m *Mock_Backend) TTL(key string) (*time.Duration, error) {
 	ret := m.Called(key)
 
@@ -93,6 +129,41 @@ func (m *Mock_Backend) Delete(key string) error {
 	return r0
 }
 
+func (m *Mock_Backend) Transaction(ops []Op) error {
+	ret := m.Called(ops)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(ops []Op) error); ok {
+		r0 = rf(ops)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Backend) OptimisticTransaction(keys []string, apply OptimisticFunc) ([]Op, error) {
+	ret := m.Called(keys, apply)
+
+	var r0 []Op
+	if rf, ok := ret.Get(0).(func(keys []string, apply OptimisticFunc) []Op); ok {
+		r0 = rf(keys, apply)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]Op)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(keys []string, apply OptimisticFunc) error); ok {
+		r1 = rf(keys, apply)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 func (m *Mock_Backend) Address() string {
 	ret := m.Called()
 
@@ -154,6 +225,68 @@ func (m *Mock_Backend) Exists(key string) (bool, error) {
 	return r0, r1
 }
 
+func (m *Mock_Backend) Type(key string) (string, error) {
+	ret := m.Called(key)
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func(key string) string); ok {
+		r0 = rf(key)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(key string) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Backend) GetMany(keys []string) (map[string][]byte, error) {
+	ret := m.Called(keys)
+
+	var r0 map[string][]byte
+	if rf, ok := ret.Get(0).(func(keys []string) map[string][]byte); ok {
+		r0 = rf(keys)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string][]byte)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(keys []string) error); ok {
+		r1 = rf(keys)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Backend) RandomKey() (string, error) {
+	ret := m.Called()
+
+	var r0 string
+	if rf, ok := ret.Get(0).(func() string); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 func (m *Mock_Backend) Expire(key string, expiration time.Duration) error {
 	ret := m.Called(key, expiration)
 
@@ -179,3 +312,265 @@ func (m *Mock_Backend) FlushDB() error {
 
 	return r0
 }
+
+func (m *Mock_Backend) DeleteMany(keys []string) (int64, error) {
+	ret := m.Called(keys)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(keys []string) int64); ok {
+		r0 = rf(keys)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(keys []string) error); ok {
+		r1 = rf(keys)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Backend) StreamAdd(key string, entry StreamEntry) error {
+	ret := m.Called(key, entry)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(key string, entry StreamEntry) error); ok {
+		r0 = rf(key, entry)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Backend) StreamRemove(key string, id StreamID) error {
+	ret := m.Called(key, id)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(key string, id StreamID) error); ok {
+		r0 = rf(key, id)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Backend) StreamRange(key string, start StreamID, end StreamID,
+	count int64) ([]StreamEntry, error) {
+
+	ret := m.Called(key, start, end, count)
+
+	var r0 []StreamEntry
+	if rf, ok := ret.Get(0).(func(key string, start StreamID, end StreamID, count int64) []StreamEntry); ok {
+		r0 = rf(key, start, end, count)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]StreamEntry)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(key string, start StreamID, end StreamID, count int64) error); ok {
+		r1 = rf(key, start, end, count)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Backend) Scan(cursor uint64, pattern string, count int64) ([]string, uint64, error) {
+	ret := m.Called(cursor, pattern, count)
+
+	var r0 []string
+	if rf, ok := ret.Get(0).(func(cursor uint64, pattern string, count int64) []string); ok {
+		r0 = rf(cursor, pattern, count)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	var r1 uint64
+	if rf, ok := ret.Get(1).(func(cursor uint64, pattern string, count int64) uint64); ok {
+		r1 = rf(cursor, pattern, count)
+	} else {
+		r1 = ret.Get(1).(uint64)
+	}
+
+	var r2 error
+	if rf, ok := ret.Get(2).(func(cursor uint64, pattern string, count int64) error); ok {
+		r2 = rf(cursor, pattern, count)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+func (m *Mock_Backend) GetWithTTL(key string) (*ValueWithTTL, error) {
+	ret := m.Called(key)
+
+	var r0 *ValueWithTTL
+	if rf, ok := ret.Get(0).(func(key string) *ValueWithTTL); ok {
+		r0 = rf(key)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ValueWithTTL)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(key string) error); ok {
+		r1 = rf(key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Backend) Ping() error {
+	ret := m.Called()
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func() error); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Backend) Watch(ctx context.Context, pattern string) (<-chan Event, error) {
+	ret := m.Called(ctx, pattern)
+
+	var r0 <-chan Event
+	if rf, ok := ret.Get(0).(func(ctx context.Context, pattern string) <-chan Event); ok {
+		r0 = rf(ctx, pattern)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, pattern string) error); ok {
+		r1 = rf(ctx, pattern)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Backend) Acquire(key string, holder string, maxHolders int, expiration time.Duration) (bool, error) {
+	ret := m.Called(key, holder, maxHolders, expiration)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(key string, holder string, maxHolders int, expiration time.Duration) bool); ok {
+		r0 = rf(key, holder, maxHolders, expiration)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(key string, holder string, maxHolders int, expiration time.Duration) error); ok {
+		r1 = rf(key, holder, maxHolders, expiration)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Backend) Release(key string, holder string) error {
+	ret := m.Called(key, holder)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(key string, holder string) error); ok {
+		r0 = rf(key, holder)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Backend) IncrWindow(key string, window time.Duration) (int64, error) {
+	ret := m.Called(key, window)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(key string, window time.Duration) int64); ok {
+		r0 = rf(key, window)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(key string, window time.Duration) error); ok {
+		r1 = rf(key, window)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Backend) RenewLock(key string, value []byte, expiration time.Duration) error {
+	ret := m.Called(key, value, expiration)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(key string, value []byte, expiration time.Duration) error); ok {
+		r0 = rf(key, value, expiration)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Backend) DBSize() (int64, error) {
+	ret := m.Called()
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func() int64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Backend) UsedMemory() (int64, error) {
+	ret := m.Called()
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func() int64); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
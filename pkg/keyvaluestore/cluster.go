@@ -9,20 +9,58 @@ type Policy int
 var (
 	PolicyReadOneLocalOrRandomNode Policy
 	PolicyReadOneFirstAvailable    Policy = 1
+	PolicyReadOneRoundRobin        Policy = 2
+	PolicyReadAll                  Policy = 3
 )
 
+// QuorumMode decides how many nodes make up a majority of an even number of
+// nodes.
+type QuorumMode int
+
+var (
+	// QuorumModeStrictMajority requires more than half of the nodes.
+	QuorumModeStrictMajority QuorumMode
+	// QuorumModeHalf requires half of an even number of nodes, e.g. 2 of 4.
+	// Two such halves might not overlap, so a majority read might miss a
+	// majority write.
+	QuorumModeHalf QuorumMode = 1
+)
+
+// Majority returns the number of nodes out of count which make up a
+// majority.
+func (m QuorumMode) Majority(count int) int {
+	if m == QuorumModeHalf && count > 0 && count%2 == 0 {
+		return count / 2
+	}
+
+	return count/2 + 1
+}
+
 type Cluster interface {
 	io.Closer
 
 	Read(key string, consistency ConsistencyLevel) (ReadClusterView, error)
 	Write(key string, consistency ConsistencyLevel) (WriteClusterView, error)
 	FlushDB() (WriteClusterView, error)
+
+	// Nodes returns the current members of the cluster.
+	Nodes() []Backend
+	// AddNode makes backend a member of the cluster, which serves reads and
+	// writes from then on.
+	AddNode(backend Backend) error
+	// RemoveNode removes the member with address from the cluster and closes
+	// it.
+	RemoveNode(address string) error
 }
 
 type ReadClusterView struct {
 	Backends     []Backend
 	VoteRequired int
 	VotingMode   VotingMode
+	SkipRepair   bool
+	// ReadOnly lists nodes which serve reads but must never be written to,
+	// not even by read-repair.
+	ReadOnly []Backend
 }
 
 type WriteClusterView struct {
@@ -84,3 +84,44 @@ func (m *Mock_Cluster) FlushDB() (WriteClusterView, error) {
 
 	return r0, r1
 }
+
+func (m *Mock_Cluster) Nodes() []Backend {
+	ret := m.Called()
+
+	var r0 []Backend
+	if rf, ok := ret.Get(0).(func() []Backend); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]Backend)
+		}
+	}
+
+	return r0
+}
+
+func (m *Mock_Cluster) AddNode(backend Backend) error {
+	ret := m.Called(backend)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(backend Backend) error); ok {
+		r0 = rf(backend)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Cluster) RemoveNode(address string) error {
+	ret := m.Called(address)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(address string) error); ok {
+		r0 = rf(address)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
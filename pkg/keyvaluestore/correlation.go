@@ -0,0 +1,17 @@
+package keyvaluestore
+
+import "context"
+
+type correlationIDKey struct{}
+
+// NewCorrelationContext returns a context carrying id, a client-supplied ID
+// which is attached to logs and metrics of operations run with the context.
+func NewCorrelationContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID carried by ctx, if any.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
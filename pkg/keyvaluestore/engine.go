@@ -8,6 +8,12 @@ type ReadOperator func(backend Backend) (interface{}, error)
 type WriteOperator func(backend Backend) error
 type RepairOperator func(args RepairArgs)
 type RollbackOperator func(args RollbackArgs)
+type VoteObserver func(outcome VoteOutcome)
+
+// FallbackOperator picks the value of a read whose replicas have answered
+// without any value reaching the required votes. It returns false if none of
+// values should be returned.
+type FallbackOperator func(values []interface{}) (interface{}, bool)
 
 type RepairArgs struct {
 	Value   interface{}
@@ -23,6 +29,42 @@ type RollbackArgs struct {
 type OperationMode int
 type VotingMode int
 
+// VoteOutcome tells how a read vote has been resolved.
+type VoteOutcome int
+
+const (
+	// VoteOutcomeFirstResponses means that the first responses, as many as
+	// the required votes, have reached the quorum.
+	VoteOutcomeFirstResponses VoteOutcome = 0
+	// VoteOutcomeWaited means that the quorum has been reached, but more
+	// responses than the required votes had to be waited for.
+	VoteOutcomeWaited VoteOutcome = 1
+	// VoteOutcomeFailed means that the quorum has not been reached.
+	VoteOutcomeFailed VoteOutcome = 2
+	// VoteOutcomeFallback means that the quorum has not been reached, and a
+	// value has been picked by the fallback of the engine instead.
+	VoteOutcomeFallback VoteOutcome = 3
+)
+
+func (o VoteOutcome) String() string {
+	switch o {
+	case VoteOutcomeFirstResponses:
+		return "first-responses"
+
+	case VoteOutcomeWaited:
+		return "waited"
+
+	case VoteOutcomeFailed:
+		return "failed"
+
+	case VoteOutcomeFallback:
+		return "fallback"
+
+	default:
+		return "unknown"
+	}
+}
+
 var (
 	OperationModeConcurrent OperationMode
 	OperationModeSequential OperationMode = 1
@@ -40,7 +82,8 @@ type Engine interface {
 		operator ReadOperator,
 		repair RepairOperator,
 		cmp ValueComparer,
-		mode VotingMode) (interface{}, error)
+		mode VotingMode,
+		observer VoteObserver) (interface{}, error)
 
 	Write(nodes []Backend, acknowledgeRequired int,
 		operator WriteOperator,
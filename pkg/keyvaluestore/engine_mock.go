@@ -21,20 +21,20 @@ func (m *Mock_Engine) Close() error {
 
 func (m *Mock_Engine) Read(nodes []Backend, votesRequired int,
 	operator ReadOperator, repair RepairOperator,
-	cmp ValueComparer, mode VotingMode) (interface{}, error) {
+	cmp ValueComparer, mode VotingMode, observer VoteObserver) (interface{}, error) {
 
-	ret := m.Called(nodes, votesRequired, operator, repair, cmp, mode)
+	ret := m.Called(nodes, votesRequired, operator, repair, cmp, mode, observer)
 
 	var r0 interface{}
-	if rf, ok := ret.Get(0).(func(nodes []Backend, votesRequired int, operator ReadOperator, repair RepairOperator, cmp ValueComparer, mode VotingMode) interface{}); ok {
-		r0 = rf(nodes, votesRequired, operator, repair, cmp, mode)
+	if rf, ok := ret.Get(0).(func(nodes []Backend, votesRequired int, operator ReadOperator, repair RepairOperator, cmp ValueComparer, mode VotingMode, observer VoteObserver) interface{}); ok {
+		r0 = rf(nodes, votesRequired, operator, repair, cmp, mode, observer)
 	} else {
 		r0 = ret.Get(0)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(nodes []Backend, votesRequired int, operator ReadOperator, repair RepairOperator, cmp ValueComparer, mode VotingMode) error); ok {
-		r1 = rf(nodes, votesRequired, operator, repair, cmp, mode)
+	if rf, ok := ret.Get(1).(func(nodes []Backend, votesRequired int, operator ReadOperator, repair RepairOperator, cmp ValueComparer, mode VotingMode, observer VoteObserver) error); ok {
+		r1 = rf(nodes, votesRequired, operator, repair, cmp, mode, observer)
 	} else {
 		r1 = ret.Error(1)
 	}
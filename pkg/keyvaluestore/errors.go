@@ -2,11 +2,62 @@ package keyvaluestore
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 )
 
 var (
-	ErrClosed      = errors.New("closed")
-	ErrConsistency = errors.New("consistency not satisfied")
-	ErrNotFound    = errors.New("not found")
-	ErrNotAcquired = errors.New("lock not acquired")
+	ErrClosed             = errors.New("closed")
+	ErrConsistency        = errors.New("consistency not satisfied")
+	ErrNotFound           = errors.New("not found")
+	ErrNotAcquired        = errors.New("lock not acquired")
+	ErrWriteInProgress    = errors.New("write with the same idempotency key is in progress")
+	ErrKeyTooLarge        = errors.New("key is too large")
+	ErrValueTooLarge      = errors.New("value is too large")
+	ErrInvalidHolders     = errors.New("semaphore requires a positive number of holders and expiration")
+	ErrLockLost           = errors.New("lock is not held anymore")
+	ErrUnknownNode        = errors.New("unknown node")
+	ErrNodeExists         = errors.New("node already exists")
+	ErrQuorumLoss         = errors.New("removing the node would leave too few nodes for a quorum")
+	ErrTooFewReplicas     = errors.New("removing the node would leave fewer nodes than the required replicas")
+	ErrRateLimited        = errors.New("rate limit exceeded")
+	ErrInvalidLimit       = errors.New("rate limit check requires a positive limit and window")
+	ErrTTLTooLong         = errors.New("expiration exceeds the maximum TTL")
+	ErrEmptyTransaction   = errors.New("transaction has no operations")
+	ErrInvalidOp          = errors.New("unknown transaction operation")
+	ErrCrossShard         = errors.New("transaction spans multiple shards")
+	ErrKeepTTLConflict    = errors.New("keeping the TTL conflicts with an expiration")
+	ErrNoKeys             = errors.New("no keys given")
+	ErrOptimisticConflict = errors.New("keys changed during optimistic transaction")
+	ErrInvalidCursor      = errors.New("invalid export cursor")
+	ErrInvalidDump        = errors.New("invalid export dump")
+	ErrInvalidStreamID    = errors.New("invalid stream ID")
+	ErrTooManyNodes       = errors.New("required nodes exceed the number of nodes")
 )
+
+// FlushDBError lists nodes which failed to flush, keyed by node address.
+type FlushDBError struct {
+	Errors map[string]error
+}
+
+func (e *FlushDBError) Error() string {
+	var addresses []string
+	for address := range e.Errors {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	return fmt.Sprintf("flush failed on nodes: %v", strings.Join(addresses, ", "))
+}
+
+// NoAvailableNodesError reports that every node owning a key is known to be
+// down, listing their addresses.
+type NoAvailableNodesError struct {
+	Key   string
+	Nodes []string
+}
+
+func (e *NoAvailableNodesError) Error() string {
+	return fmt.Sprintf("%v: every node of key %q is down: %v", ErrConsistency, e.Key, strings.Join(e.Nodes, ", "))
+}
@@ -0,0 +1,32 @@
+package keyvaluestore
+
+type EventType int
+
+const (
+	EventSet    EventType = 0
+	EventDelete EventType = 1
+	EventExpire EventType = 2
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+
+	case EventDelete:
+		return "del"
+
+	case EventExpire:
+		return "expired"
+
+	default:
+		return "unknown"
+	}
+}
+
+// Event notifies about a change of a key. Events are best-effort: they are
+// not persisted and might be lost, e.g. while reconnecting to a node.
+type Event struct {
+	Key  string
+	Type EventType
+}
@@ -0,0 +1,5 @@
+package keyvaluestore
+
+// ConflictResolver picks the value which should be replicated to every node
+// when replicas disagree on a key. It returns nil if there is no winner.
+type ConflictResolver func(values []*ValueWithTTL) *ValueWithTTL
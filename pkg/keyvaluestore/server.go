@@ -1,6 +1,7 @@
 package keyvaluestore
 
 import (
+	"context"
 	"io"
 )
 
@@ -8,4 +9,9 @@ type Server interface {
 	io.Closer
 
 	Start() error
+
+	// Shutdown stops accepting new connections and waits for in-flight
+	// operations to finish until ctx is done, after which remaining
+	// connections are closed forcibly.
+	Shutdown(ctx context.Context) error
 }
@@ -3,6 +3,7 @@ package keyvaluestore
 import (
 	"context"
 	"io"
+	"sync"
 	"time"
 )
 
@@ -13,13 +14,77 @@ const (
 	ConsistencyLevel_ONE      ConsistencyLevel = 1
 	ConsistencyLevel_MAJORITY ConsistencyLevel = 2
 	ConsistencyLevel_ALL      ConsistencyLevel = 3
+	ConsistencyLevel_TWO      ConsistencyLevel = 4
+	ConsistencyLevel_THREE    ConsistencyLevel = 5
 )
 
+func (c ConsistencyLevel) String() string {
+	switch c {
+	case ConsistencyLevel_DEFAULT:
+		return "default"
+
+	case ConsistencyLevel_ONE:
+		return "one"
+
+	case ConsistencyLevel_TWO:
+		return "two"
+
+	case ConsistencyLevel_THREE:
+		return "three"
+
+	case ConsistencyLevel_MAJORITY:
+		return "majority"
+
+	case ConsistencyLevel_ALL:
+		return "all"
+
+	default:
+		return "unknown"
+	}
+}
+
 type SetRequest struct {
 	Key        string
 	Data       []byte
 	Expiration time.Duration
 	Options    WriteOptions
+	// Version is only used when value versioning is enabled. Zero means
+	// that the server time should be used.
+	Version int64
+	// Persistent prevents the default write TTL from being applied when
+	// Expiration is zero, so that the value never expires.
+	Persistent bool
+	// ContentType is only stored when value metadata is enabled.
+	ContentType string
+	// KeepTTL keeps the current expiration of the key, like KEEPTTL of
+	// redis, so Expiration must be zero. Keys which do not exist yet get the
+	// default write TTL, unless Persistent.
+	KeepTTL bool
+	// Acknowledgement, when given, is filled in with the number of nodes
+	// which acknowledged the write by the time it succeeded.
+	Acknowledgement *WriteAcknowledgement
+}
+
+// WriteAcknowledgement reports how many of the nodes a write has been sent
+// to acknowledged it. Nodes which were still pending when the write met its
+// consistency are not counted, even if they acknowledge later, so fewer
+// acknowledgements than nodes do not necessarily mean a failure.
+type WriteAcknowledgement struct {
+	Acknowledged int
+	Nodes        int
+}
+
+// KeyValue is a single item of MSet. Each item may have its own
+// Expiration, where zero means the default write TTL, if any.
+type KeyValue struct {
+	Key        string
+	Data       []byte
+	Expiration time.Duration
+}
+
+type MSetRequest struct {
+	Items   []KeyValue
+	Options WriteOptions
 }
 
 type LockRequest struct {
@@ -27,6 +92,18 @@ type LockRequest struct {
 	Data       []byte
 	Expiration time.Duration
 	Options    WriteOptions
+	// WaitTimeout is how long to wait for a held lock to become free. Zero
+	// means failing immediately.
+	WaitTimeout time.Duration
+}
+
+// RenewLockRequest extends the expiration of a lock, provided that it is
+// still held with Data, which acts as the token of the lock holder.
+type RenewLockRequest struct {
+	Key        string
+	Data       []byte
+	Expiration time.Duration
+	Options    WriteOptions
 }
 
 type UnlockRequest struct {
@@ -34,26 +111,232 @@ type UnlockRequest struct {
 	Options WriteOptions
 }
 
-type GetRequest struct {
+// AcquireRequest takes a slot of a counting semaphore which allows up to
+// MaxHolders concurrent holders. Holders identify themselves to release
+// their slot, and slots are freed after Expiration anyway.
+type AcquireRequest struct {
+	Key        string
+	Holder     string
+	MaxHolders int
+	Expiration time.Duration
+	Options    WriteOptions
+}
+
+type ReleaseRequest struct {
 	Key     string
-	Options ReadOptions
+	Holder  string
+	Options WriteOptions
+}
+
+// GetRequest returns a response with NotModified set, and without data, if
+// IfVersionNot is the version of the value, which requires value versioning.
+type GetRequest struct {
+	Key          string
+	IfVersionNot string
+	Options      ReadOptions
 }
 
 type GetResponse struct {
 	Data []byte
+	// Metadata is only set when value metadata is enabled, and the value has
+	// been written since.
+	Metadata *ValueMetadata
+	// Version is an opaque tag of the value, like an HTTP ETag, which is only
+	// set when value versioning is enabled.
+	Version     string
+	NotModified bool
+}
+
+// ValueMetadata describes a stored value. Version is only set when value
+// versioning is enabled.
+type ValueMetadata struct {
+	ContentType string
+	CreatedAt   time.Time
+	Version     int64
+}
+
+type GetManyRequest struct {
+	Keys    []string
+	WithTTL bool
+	Options ReadOptions
+}
+
+// GetManyItem tells whether a key was found, which is not the same as having
+// an empty value. TTL is only filled if requested, and is nil for keys
+// without expiration.
+type GetManyItem struct {
+	Found    bool
+	Data     []byte
+	TTL      *time.Duration
+	Metadata *ValueMetadata
+}
+
+type GetManyResponse struct {
+	Items map[string]*GetManyItem
 }
 
 type DeleteRequest struct {
 	Key     string
 	Options WriteOptions
+	// Previous, when given, is filled in with the value the key had before
+	// deletion, like GETDEL of redis. The value is voted on by the nodes the
+	// key is deleted from, and the delete fails with NotFound if the key does
+	// not exist.
+	Previous *GetResponse
+}
+
+type TransactionRequest struct {
+	Ops     []Op
+	Options WriteOptions
+}
+
+// OptimisticRequest reads Keys and applies the ops computed by Apply from
+// their values, provided that none of them has changed in the meantime.
+// Conflicting transactions call Apply again, up to MaxRetries times.
+type OptimisticRequest struct {
+	Keys       []string
+	Apply      OptimisticFunc
+	MaxRetries int
+	Options    WriteOptions
+}
+
+// ImportRequest sets every entry received from Entries until it is closed.
+// At most Concurrency entries are written at once, and Entries is not read
+// while that many are in flight. Progress, if set, is called with the running
+// totals after each entry, one call at a time. Source, if set, is read for
+// entries written by Export instead of Entries. SkipExisting writes entries
+// with SETNX, so that nodes which already hold a key keep their value.
+type ImportRequest struct {
+	Entries      <-chan KeyValue
+	Source       io.Reader
+	Concurrency  int
+	Progress     func(ImportProgress)
+	SkipExisting bool
+	Options      WriteOptions
+}
+
+// ImportProgress counts entries whose key already existed on some node as
+// skipped rather than imported, when skipping existing keys.
+type ImportProgress struct {
+	Imported int64
+	Skipped  int64
+	Failed   int64
+}
+
+// ImportError is the failure of setting a single entry of an import.
+type ImportError struct {
+	Key string
+	Err error
+}
+
+type ImportResponse struct {
+	ImportProgress
+	Errors []ImportError
+}
+
+// ExportRequest writes every key matching Pattern, or every key if it is
+// empty, to Writer along with its value and TTL. Cursor resumes an export
+// from the Cursor of a previous response. RateLimit, if positive, caps the
+// number of keys exported per second.
+type ExportRequest struct {
+	Writer    io.Writer
+	Pattern   string
+	Cursor    string
+	RateLimit int
+	Options   ReadOptions
+}
+
+// ExportResponse reports the number of exported keys. Cursor is empty once
+// the export is complete, and otherwise resumes it where it stopped.
+type ExportResponse struct {
+	Exported int64
+	Cursor   string
+}
+
+type DeleteManyRequest struct {
+	Keys    []string
+	Options WriteOptions
+}
+
+type DeleteManyResponse struct {
+	Deleted int64
+}
+
+type DeletePatternRequest struct {
+	Pattern string
+	Options WriteOptions
+}
+
+// DeletePatternResponse reports the number of deleted keys along with errors
+// of nodes which failed to complete the scan, keyed by node address.
+type DeletePatternResponse struct {
+	Deleted int64
+	Errors  map[string]error
 }
 
 type WriteOptions struct {
 	Consistency ConsistencyLevel
+	// RequiredNodes, if positive, is the number of nodes out of every
+	// writable node which must acknowledge the write, in place of Consistency.
+	RequiredNodes int
+	// IdempotencyKey makes retries of the same write safe. Writes sharing an
+	// idempotency key are applied only once while the key is remembered.
+	IdempotencyKey string
+	// DryRun validates the write without applying it. The nodes it would be
+	// written to are pinged instead, and the outcome is reported in DryRun.
+	// The write fails with Unavailable if consistency is not achievable.
+	DryRun *DryRunResult
+	// Session identifies a client session, e.g. using a token shared by its
+	// connections. Reads of a key recently written by the same session are
+	// guaranteed to observe the write when session consistency is enabled.
+	Session string
+	// Timeout, if positive, bounds the whole request, which then fails with
+	// DeadlineExceeded. Nodes not written to by then are skipped, while
+	// writes already sent to nodes are rolled back in the background as usual.
+	Timeout time.Duration
+}
+
+// DryRunResult lists addresses of nodes a write would be sent to, along with
+// the ones which responded to a ping. A single result may be shared by
+// writes of many keys, e.g. by MSet, in which case nodes are merged.
+type DryRunResult struct {
+	Nodes     []string
+	Reachable []string
+
+	lock sync.Mutex
+}
+
+// Add records a node, and whether it is reachable, unless already recorded.
+func (r *DryRunResult) Add(address string, reachable bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, node := range r.Nodes {
+		if node == address {
+			return
+		}
+	}
+
+	r.Nodes = append(r.Nodes, address)
+	if reachable {
+		r.Reachable = append(r.Reachable, address)
+	}
 }
 
 type ReadOptions struct {
 	Consistency ConsistencyLevel
+	// RequiredNodes, if positive, is the number of nodes out of every node
+	// which must agree on the result, in place of Consistency.
+	RequiredNodes int
+	Session       string
+	// Timeout, if positive, bounds the whole request, which then fails with
+	// DeadlineExceeded. Watch ignores it.
+	Timeout time.Duration
+
+	// StaleWhileRevalidate makes Get return the value of a single node right
+	// away, while a read of the requested consistency revalidates the key in
+	// the background and repairs diverged nodes. Only Get supports it.
+	StaleWhileRevalidate bool
 }
 
 type ExistsRequest struct {
@@ -70,13 +353,27 @@ type GetTTLRequest struct {
 	Options ReadOptions
 }
 
+// GetTTLResponse carries the remaining TTL as a Duration, or nil if the key
+// does not expire. A TTL which is about to run out is still non-nil, even if
+// it is zero. The redis proxy replies to PTTL with it in milliseconds.
 type GetTTLResponse struct {
 	TTL *time.Duration
 }
 
+// MissingKeyMode tells what Expire does if the key does not exist.
+type MissingKeyMode int
+
+const (
+	// MissingKeyNoop reports the key as not existing, like redis EXPIRE.
+	MissingKeyNoop MissingKeyMode = iota
+	// MissingKeyNotFound fails with NotFound instead.
+	MissingKeyNotFound
+)
+
 type ExpireRequest struct {
 	Key        string
 	Expiration time.Duration
+	MissingKey MissingKeyMode
 	Options    WriteOptions
 }
 
@@ -84,16 +381,229 @@ type ExpireResponse struct {
 	Exists bool
 }
 
+// TouchRequest sets a new expiration of an existing key without reading or
+// writing its value.
+type TouchRequest struct {
+	Key        string
+	Expiration time.Duration
+	Options    WriteOptions
+}
+
+type TouchResponse struct {
+	Exists bool
+}
+
+// RateLimitCheckRequest counts a hit of Key, allowing up to Limit hits per
+// Window. The window starts with the first hit, after which the counter
+// expires.
+type RateLimitCheckRequest struct {
+	Key     string
+	Limit   int64
+	Window  time.Duration
+	Options WriteOptions
+}
+
+type RateLimitCheckResponse struct {
+	Allowed   bool
+	Remaining int64
+}
+
+// StreamAddRequest appends Data to the stream Key as a new entry.
+type StreamAddRequest struct {
+	Key     string
+	Data    []byte
+	Options WriteOptions
+}
+
+type StreamAddResponse struct {
+	ID StreamID
+}
+
+// StreamRangeRequest reads up to Count entries of the stream Key whose IDs
+// are between Start and End inclusive. Zero End means up to the last entry,
+// and zero Count means no limit.
+type StreamRangeRequest struct {
+	Key     string
+	Start   StreamID
+	End     StreamID
+	Count   int64
+	Options ReadOptions
+}
+
+type StreamRangeResponse struct {
+	Entries []StreamEntry
+}
+
+// FlushDBRequest limits the flush to nodes with the given addresses, e.g. to
+// retry nodes which failed a previous flush. Empty means every node.
+type FlushDBRequest struct {
+	Nodes []string
+}
+
+// FlushDBResponse lists addresses of the flushed nodes along with errors of
+// nodes which failed to flush, keyed by node address.
+type FlushDBResponse struct {
+	Flushed []string
+	Errors  map[string]error
+}
+
+// NodeStats reports the size of a single node. Error is set instead if the
+// node could not be queried.
+type NodeStats struct {
+	Address         string `json:"address"`
+	Keys            int64  `json:"keys"`
+	UsedMemoryBytes int64  `json:"usedMemoryBytes"`
+	Error           string `json:"error,omitempty"`
+}
+
+type StatsResponse struct {
+	Nodes []NodeStats `json:"nodes"`
+}
+
+// CapabilitiesResponse describes what the service supports as configured.
+// Operations are named after the methods of Service in lower case. Sharding
+// tells whether keys are partitioned across nodes rather than replicated to
+// all of them.
+type CapabilitiesResponse struct {
+	Operations              []string `json:"operations"`
+	ConsistencyLevels       []string `json:"consistencyLevels"`
+	DefaultReadConsistency  string   `json:"defaultReadConsistency"`
+	DefaultWriteConsistency string   `json:"defaultWriteConsistency"`
+	Nodes                   int      `json:"nodes"`
+	Sharding                bool     `json:"sharding"`
+	ValueVersioning         bool     `json:"valueVersioning"`
+	ValueMetadata           bool     `json:"valueMetadata"`
+	Compression             bool     `json:"compression"`
+	Encryption              bool     `json:"encryption"`
+	SessionConsistency      bool     `json:"sessionConsistency"`
+	MaxKeyBytes             int      `json:"maxKeyBytes,omitempty"`
+	MaxValueBytes           int      `json:"maxValueBytes,omitempty"`
+}
+
+type InspectRequest struct {
+	Key string
+}
+
+// NodeValue is the value of a key on a single node exactly as stored, e.g.
+// including envelopes of value versioning. TTLMs is nil if the value does not
+// expire, and Error is set instead if the node could not be queried.
+type NodeValue struct {
+	Address string `json:"address"`
+	Exists  bool   `json:"exists"`
+	Data    []byte `json:"data,omitempty"`
+	TTLMs   *int64 `json:"ttlMs,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type InspectResponse struct {
+	Nodes []NodeValue `json:"nodes"`
+}
+
+// WatchRequest watches a single key, or every key starting with Key if
+// Prefix is set.
+type WatchRequest struct {
+	Key     string
+	Prefix  bool
+	Options ReadOptions
+}
+
 type Service interface {
 	io.Closer
 
 	Set(ctx context.Context, request *SetRequest) error
+
+	// MSet validates every item before setting any of them. Items are set
+	// independently, so a failure might leave some of them set.
+	MSet(ctx context.Context, request *MSetRequest) error
 	Get(ctx context.Context, request *GetRequest) (*GetResponse, error)
+
+	// GetMany reads every key independently, each meeting the requested
+	// consistency on its own. It fails if any key fails for a reason other
+	// than not being found.
+	GetMany(ctx context.Context, request *GetManyRequest) (*GetManyResponse, error)
 	Delete(ctx context.Context, request *DeleteRequest) error
+	DeleteMany(ctx context.Context, request *DeleteManyRequest) (*DeleteManyResponse, error)
+
+	// Transaction applies all ops atomically on every node, so its keys
+	// must be stored on the same nodes. Otherwise it fails with
+	// InvalidArgument instead of applying any of them.
+	Transaction(ctx context.Context, request *TransactionRequest) error
+
+	// Optimistic runs a read-modify-write of keys stored on the same nodes
+	// with WATCH on the first of them, and replicates the applied ops to the
+	// rest. It fails with Aborted if it keeps conflicting with other writes.
+	Optimistic(ctx context.Context, request *OptimisticRequest) error
+
+	// Import sets a stream of entries with bounded concurrency, as MSet does
+	// for a fixed list. Entries are set independently and failures of single
+	// entries are reported in the response rather than stopping the import.
+	Import(ctx context.Context, request *ImportRequest) (*ImportResponse, error)
+
+	// Export scans every node of the read view for keys, and writes each key
+	// once as a SET command of the redis protocol, which Import reads back.
+	// Keys might be exported again when resuming, and keys written while the
+	// export is in progress might be missed.
+	Export(ctx context.Context, request *ExportRequest) (*ExportResponse, error)
+
+	// DeletePattern removes every key matching the glob-style pattern on all
+	// writable nodes, regardless of the consistency of the request. It is
+	// best-effort and not atomic: keys written while the scan is in progress
+	// might survive, and keys deleted from some nodes are not rolled back if
+	// others fail, which are reported by the response instead.
+	DeletePattern(ctx context.Context, request *DeletePatternRequest) (*DeletePatternResponse, error)
 	Lock(ctx context.Context, request *LockRequest) error
 	Unlock(ctx context.Context, request *UnlockRequest) error
+
+	// RenewLock fails with FailedPrecondition if the lock has been lost on
+	// enough nodes to prevent the requested consistency.
+	RenewLock(ctx context.Context, request *RenewLockRequest) error
+
+	// Acquire fails with ResourceExhausted if the semaphore is full on
+	// enough nodes to prevent the requested consistency.
+	Acquire(ctx context.Context, request *AcquireRequest) error
+	Release(ctx context.Context, request *ReleaseRequest) error
 	Exists(ctx context.Context, request *ExistsRequest) (*ExistsResponse, error)
 	GetTTL(ctx context.Context, request *GetTTLRequest) (*GetTTLResponse, error)
 	Expire(ctx context.Context, request *ExpireRequest) (*ExpireResponse, error)
-	FlushDB(ctx context.Context) error
+
+	// Touch is like Expire, but requires the write consistency instead of
+	// the read consistency. The key is reported to exist if it existed on
+	// any of the acknowledging nodes.
+	Touch(ctx context.Context, request *TouchRequest) (*TouchResponse, error)
+
+	// RateLimitCheck increments the counter on every node of the requested
+	// consistency and decides using the highest count among them, so that
+	// lagging nodes never let more than Limit hits through.
+	RateLimitCheck(ctx context.Context, request *RateLimitCheckRequest) (*RateLimitCheckResponse, error)
+
+	// StreamAdd appends an entry to a stream, with an ID generated by the
+	// service which is greater than IDs it has generated before. Entries are
+	// rolled back from the nodes which have taken them if the write fails.
+	StreamAdd(ctx context.Context, request *StreamAddRequest) (*StreamAddResponse, error)
+
+	// StreamRange reads entries of a stream ordered by their ID. Streams
+	// which do not exist have no entries.
+	StreamRange(ctx context.Context, request *StreamRangeRequest) (*StreamRangeResponse, error)
+
+	// FlushDB requires every requested node to flush, since a partially
+	// flushed cluster is rarely desirable. If any node fails, an error is
+	// returned along with the response describing which nodes failed.
+	FlushDB(ctx context.Context, request *FlushDBRequest) (*FlushDBResponse, error)
+
+	// Stats reports the number of keys and memory usage of every node.
+	Stats(ctx context.Context) (*StatsResponse, error)
+
+	// Capabilities describes the configuration of the service, without
+	// querying any node.
+	Capabilities(ctx context.Context) (*CapabilitiesResponse, error)
+
+	// Inspect reads key from every node of the cluster without voting or
+	// repairing, for debugging divergence of replicas. It requires no quorum,
+	// failures of nodes are reported in the response instead.
+	Inspect(ctx context.Context, request *InspectRequest) (*InspectResponse, error)
+
+	// Watch notifies about changes of the watched keys on nodes of the read
+	// view, until ctx is done. Duplicate notifications of the same change
+	// arriving from different nodes are delivered once.
+	Watch(ctx context.Context, request *WatchRequest) (<-chan Event, error)
 }
@@ -163,15 +163,394 @@ func (m *Mock_Service) Expire(ctx context.Context, request *ExpireRequest) (*Exp
 	return r0, r1
 }
 
-func (m *Mock_Service) FlushDB(ctx context.Context) error {
-	ret := m.Called(ctx)
+func (m *Mock_Service) DeleteMany(ctx context.Context, request *DeleteManyRequest) (*DeleteManyResponse, error) {
+	ret := m.Called(ctx, request)
+
+	var r0 *DeleteManyResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *DeleteManyRequest) *DeleteManyResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*DeleteManyResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *DeleteManyRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) Import(ctx context.Context, request *ImportRequest) (*ImportResponse, error) {
+	ret := m.Called(ctx, request)
+
+	var r0 *ImportResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *ImportRequest) *ImportResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ImportResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *ImportRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) Export(ctx context.Context, request *ExportRequest) (*ExportResponse, error) {
+	ret := m.Called(ctx, request)
+
+	var r0 *ExportResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *ExportRequest) *ExportResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*ExportResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *ExportRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) DeletePattern(ctx context.Context, request *DeletePatternRequest) (*DeletePatternResponse, error) {
+	ret := m.Called(ctx, request)
+
+	var r0 *DeletePatternResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *DeletePatternRequest) *DeletePatternResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*DeletePatternResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *DeletePatternRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) MSet(ctx context.Context, request *MSetRequest) error {
+	ret := m.Called(ctx, request)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(ctx context.Context) error); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *MSetRequest) error); ok {
+		r0 = rf(ctx, request)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Service) Transaction(ctx context.Context, request *TransactionRequest) error {
+	ret := m.Called(ctx, request)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *TransactionRequest) error); ok {
+		r0 = rf(ctx, request)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Service) Optimistic(ctx context.Context, request *OptimisticRequest) error {
+	ret := m.Called(ctx, request)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *OptimisticRequest) error); ok {
+		r0 = rf(ctx, request)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Service) GetMany(ctx context.Context, request *GetManyRequest) (*GetManyResponse, error) {
+	ret := m.Called(ctx, request)
+
+	var r0 *GetManyResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *GetManyRequest) *GetManyResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*GetManyResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *GetManyRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) Watch(ctx context.Context, request *WatchRequest) (<-chan Event, error) {
+	ret := m.Called(ctx, request)
+
+	var r0 <-chan Event
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *WatchRequest) <-chan Event); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(<-chan Event)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *WatchRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) Acquire(ctx context.Context, request *AcquireRequest) error {
+	ret := m.Called(ctx, request)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *AcquireRequest) error); ok {
+		r0 = rf(ctx, request)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Service) Release(ctx context.Context, request *ReleaseRequest) error {
+	ret := m.Called(ctx, request)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *ReleaseRequest) error); ok {
+		r0 = rf(ctx, request)
 	} else {
 		r0 = ret.Error(0)
 	}
 
 	return r0
 }
+
+func (m *Mock_Service) RenewLock(ctx context.Context, request *RenewLockRequest) error {
+	ret := m.Called(ctx, request)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *RenewLockRequest) error); ok {
+		r0 = rf(ctx, request)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+func (m *Mock_Service) Inspect(ctx context.Context, request *InspectRequest) (*InspectResponse, error) {
+	ret := m.Called(ctx, request)
+
+	var r0 *InspectResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *InspectRequest) *InspectResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*InspectResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *InspectRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) Stats(ctx context.Context) (*StatsResponse, error) {
+	ret := m.Called(ctx)
+
+	var r0 *StatsResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context) *StatsResponse); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*StatsResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) Capabilities(ctx context.Context) (*CapabilitiesResponse, error) {
+	ret := m.Called(ctx)
+
+	var r0 *CapabilitiesResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context) *CapabilitiesResponse); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*CapabilitiesResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) FlushDB(ctx context.Context, request *FlushDBRequest) (*FlushDBResponse, error) {
+	ret := m.Called(ctx, request)
+
+	var r0 *FlushDBResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *FlushDBRequest) *FlushDBResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*FlushDBResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *FlushDBRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) RateLimitCheck(ctx context.Context,
+	request *RateLimitCheckRequest) (*RateLimitCheckResponse, error) {
+
+	ret := m.Called(ctx, request)
+
+	var r0 *RateLimitCheckResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *RateLimitCheckRequest) *RateLimitCheckResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*RateLimitCheckResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *RateLimitCheckRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) StreamAdd(ctx context.Context,
+	request *StreamAddRequest) (*StreamAddResponse, error) {
+
+	ret := m.Called(ctx, request)
+
+	var r0 *StreamAddResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *StreamAddRequest) *StreamAddResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*StreamAddResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *StreamAddRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) StreamRange(ctx context.Context,
+	request *StreamRangeRequest) (*StreamRangeResponse, error) {
+
+	ret := m.Called(ctx, request)
+
+	var r0 *StreamRangeResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *StreamRangeRequest) *StreamRangeResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*StreamRangeResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *StreamRangeRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+func (m *Mock_Service) Touch(ctx context.Context, request *TouchRequest) (*TouchResponse, error) {
+	ret := m.Called(ctx, request)
+
+	var r0 *TouchResponse
+	if rf, ok := ret.Get(0).(func(ctx context.Context, request *TouchRequest) *TouchResponse); ok {
+		r0 = rf(ctx, request)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*TouchResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ctx context.Context, request *TouchRequest) error); ok {
+		r1 = rf(ctx, request)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
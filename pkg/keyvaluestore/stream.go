@@ -0,0 +1,56 @@
+package keyvaluestore
+
+import (
+	"fmt"
+	"math"
+)
+
+// StreamID identifies an entry of a stream. Like IDs of redis streams, it is
+// made of the time of the append in milliseconds and a sequence number
+// telling apart entries appended within the same millisecond.
+type StreamID struct {
+	Time     uint64
+	Sequence uint64
+}
+
+// MaxStreamID is greater than or equal to every other ID.
+var MaxStreamID = StreamID{Time: math.MaxUint64, Sequence: math.MaxUint64}
+
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d-%d", id.Time, id.Sequence)
+}
+
+func (id StreamID) Less(other StreamID) bool {
+	if id.Time != other.Time {
+		return id.Time < other.Time
+	}
+
+	return id.Sequence < other.Sequence
+}
+
+// ParseStreamID parses IDs formatted as "<time>-<sequence>", or as "<time>"
+// which stands for the first ID of that millisecond.
+func ParseStreamID(value string) (StreamID, error) {
+	var result StreamID
+
+	if _, err := fmt.Sscanf(value, "%d-%d", &result.Time, &result.Sequence); err == nil {
+		if value == result.String() {
+			return result, nil
+		}
+	}
+
+	result.Sequence = 0
+	if _, err := fmt.Sscanf(value, "%d", &result.Time); err == nil {
+		if value == fmt.Sprint(result.Time) {
+			return result, nil
+		}
+	}
+
+	return StreamID{}, ErrInvalidStreamID
+}
+
+// StreamEntry is an entry of a stream. Entries are ordered by their ID.
+type StreamEntry struct {
+	ID   StreamID
+	Data []byte
+}